@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jf-tech/go-corelib/jsons"
+	"github.com/spf13/cobra"
+
+	"github.com/jf-tech/omniparser/customfuncs"
+	v21 "github.com/jf-tech/omniparser/extensions/omniv21/customfuncs"
+)
+
+var (
+	funcsCmd = &cobra.Command{
+		Use:   "funcs [name...]",
+		Short: "Lists the built-in and extension custom funcs, or only the ones with the given names.",
+		RunE: func(cmd *cobra.Command, names []string) error {
+			if err := doFuncs(names); err != nil {
+				fmt.Println() // to sure cobra cli always write out "Error: ..." on a new line.
+				return err
+			}
+			return nil
+		},
+	}
+	funcsJSON bool
+)
+
+func init() {
+	funcsCmd.Flags().BoolVarP(&funcsJSON, "json", "", false, "if specified, the custom funcs are printed out in JSON")
+}
+
+// funcInfos returns the descriptions of all the custom funcs available to schemas.
+func funcInfos() []customfuncs.FuncInfo {
+	return customfuncs.Describe(
+		customfuncs.Merge(customfuncs.CommonCustomFuncs, v21.OmniV21CustomFuncs),
+		customfuncs.MergeDocs(customfuncs.CommonCustomFuncDocs, v21.OmniV21CustomFuncDocs))
+}
+
+func funcSignature(info customfuncs.FuncInfo) string {
+	args := make([]string, len(info.Args))
+	for i, arg := range info.Args {
+		if arg.Variadic {
+			args[i] = arg.Name + " ..." + arg.Type
+			continue
+		}
+		args[i] = arg.Name + " " + arg.Type
+	}
+	return fmt.Sprintf("%s(%s) %s", info.Name, strings.Join(args, ", "), info.ReturnType)
+}
+
+func doFuncs(names []string) error {
+	infos := funcInfos()
+	if len(names) > 0 {
+		byName := map[string]customfuncs.FuncInfo{}
+		for _, info := range infos {
+			byName[info.Name] = info
+		}
+		infos = nil
+		for _, name := range names {
+			info, found := byName[name]
+			if !found {
+				return fmt.Errorf("unknown custom func '%s'", name)
+			}
+			infos = append(infos, info)
+		}
+	}
+	if funcsJSON {
+		fmt.Println(jsons.BPM(infos))
+		return nil
+	}
+	for _, info := range infos {
+		fmt.Println(funcSignature(info))
+		if info.Description != "" {
+			fmt.Println("    " + info.Description)
+		}
+		if info.Example != "" {
+			fmt.Println("    e.g. " + info.Example)
+		}
+	}
+	return nil
+}
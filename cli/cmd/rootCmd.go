@@ -15,6 +15,7 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(transformCmd)
 	rootCmd.AddCommand(serverCmd)
+	rootCmd.AddCommand(funcsCmd)
 }
 
 type buildInfo struct {
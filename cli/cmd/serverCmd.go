@@ -11,8 +11,10 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -20,6 +22,10 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/jf-tech/omniparser"
+	"github.com/jf-tech/omniparser/customfuncs"
+	"github.com/jf-tech/omniparser/extensions/omniv21"
+	v21 "github.com/jf-tech/omniparser/extensions/omniv21/customfuncs"
+	"github.com/jf-tech/omniparser/idr"
 	"github.com/jf-tech/omniparser/transformctx"
 )
 
@@ -32,11 +38,53 @@ var (
 			doServer()
 		},
 	}
-	port int
+	port             int
+	jsTimeout        time.Duration
+	jsMaxCallStack   int
+	jsDisabled       bool
+	jsRemovedGlobals []string
+	readerLimits     idr.Limits
 )
 
 func init() {
 	serverCmd.Flags().IntVarP(&port, "port", "p", 8080, "the listening HTTP port")
+	serverCmd.Flags().DurationVar(
+		&jsTimeout, "js-timeout", time.Second, "max execution time of a single javascript custom_func call")
+	serverCmd.Flags().IntVar(
+		&jsMaxCallStack, "js-max-call-stack", 1000, "max call stack depth of javascript custom_funcs")
+	serverCmd.Flags().BoolVar(&jsDisabled, "disable-js", false, "disable javascript custom_funcs entirely")
+	serverCmd.Flags().StringSliceVar(
+		&jsRemovedGlobals, "js-removed-globals", nil,
+		"javascript global objects and functions to remove from the runtimes, e.g. eval,Function")
+	serverCmd.Flags().IntVar(
+		&readerLimits.MaxNodesPerRecord, "max-record-nodes", 0, "max number of IDR nodes of a record, 0 for unlimited")
+	serverCmd.Flags().Int64Var(
+		&readerLimits.MaxRecordBytes, "max-record-bytes", 0, "max input bytes of a record, 0 for unlimited")
+	serverCmd.Flags().IntVar(
+		&readerLimits.MaxDepth, "max-depth", 0, "max nesting depth of XML/JSON/YAML inputs, 0 for unlimited")
+	serverCmd.Flags().IntVar(
+		&readerLimits.MaxLineLength, "max-line-length", 0,
+		"max bytes of a line of flat file or JSON Lines inputs, or a segment of EDI inputs, 0 for unlimited")
+	serverCmd.Flags().IntVar(
+		&readerLimits.MaxAttributes, "max-attributes", 0, "max number of attributes of an XML element, 0 for unlimited")
+}
+
+// serverExt returns the 'omni.2.1' extension with the javascript sandbox and the reader limits applied,
+// given the server accepts schemas and inputs from anyone on the network.
+func serverExt() omniparser.Extension {
+	return omniparser.Extension{
+		CreateSchemaHandler: omniv21.CreateSchemaHandler,
+		CreateSchemaHandlerParams: &omniv21.CreateParams{
+			JSSandbox: &v21.JSSandbox{
+				Disabled:         jsDisabled,
+				Timeout:          jsTimeout,
+				MaxCallStackSize: jsMaxCallStack,
+				RemovedGlobals:   jsRemovedGlobals,
+			},
+			ReaderLimits: &readerLimits,
+		},
+		CustomFuncs: customfuncs.Merge(customfuncs.CommonCustomFuncs, v21.OmniV21CustomFuncs),
+	}
 }
 
 const (
@@ -56,6 +104,9 @@ func doServer() {
 	versionRouter := chi.NewRouter()
 	versionRouter.Get("/", httpGetVersion)
 
+	funcsRouter := chi.NewRouter()
+	funcsRouter.Get("/", httpGetFuncs)
+
 	rootRouter := chi.NewRouter()
 	rootRouter.Get("/", func(w http.ResponseWriter, req *http.Request) {
 		http.FileServer(http.Dir(filepath.Join(serverCmdDir(), "web"))).ServeHTTP(w, req)
@@ -63,6 +114,7 @@ func doServer() {
 	rootRouter.Mount("/transform", transformRouter)
 	rootRouter.Mount("/samples", samplesRouter)
 	rootRouter.Mount("/version", versionRouter)
+	rootRouter.Mount("/funcs", funcsRouter)
 
 	envPort, found := os.LookupEnv("PORT")
 	if found {
@@ -107,9 +159,10 @@ func writeSuccess(w http.ResponseWriter, v interface{}) {
 }
 
 type reqTransform struct {
-	Schema     string            `json:"schema"`
-	Input      string            `json:"input"`
-	Properties map[string]string `json:"properties"`
+	Schema     string                 `json:"schema"`
+	Input      string                 `json:"input"`
+	Properties map[string]string      `json:"properties"`
+	Externals  map[string]interface{} `json:"externals"`
 }
 
 func httpPostTransform(w http.ResponseWriter, r *http.Request) {
@@ -125,13 +178,13 @@ func httpPostTransform(w http.ResponseWriter, r *http.Request) {
 		writeBadRequest(w, fmt.Sprintf("bad request: invalid request body. err: %s", err))
 		return
 	}
-	s, err := omniparser.NewSchema("test-schema", strings.NewReader(req.Schema))
+	s, err := omniparser.NewSchema("test-schema", strings.NewReader(req.Schema), serverExt())
 	if err != nil {
 		writeBadRequest(w, fmt.Sprintf("bad request: invalid schema. err: %s", err))
 		return
 	}
 	t, err := s.NewTransform(
-		"test-input", strings.NewReader(req.Input), &transformctx.Ctx{ExternalProperties: req.Properties})
+		"test-input", strings.NewReader(req.Input), &transformctx.Ctx{ExternalProperties: req.Properties, ExternalValues: req.Externals})
 	if err != nil {
 		writeBadRequest(w, fmt.Sprintf("bad request: unable to new transform. err: %s", err))
 		return
@@ -149,12 +202,37 @@ func httpPostTransform(w http.ResponseWriter, r *http.Request) {
 		records = append(records, string(b))
 	}
 	writeSuccessJSON(w, "["+strings.Join(records, ",")+"]")
-	log.Print(jsons.BPM(req))
+	logReqTransform(req)
+}
+
+// logReqTransform logs a '/transform' request, without its input and the values of its properties and
+// externals, which may carry sensitive data, or secrets such as the keys of 'hmac' and 'tokenize'.
+func logReqTransform(req reqTransform) {
+	var properties, externals []string
+	for name := range req.Properties {
+		properties = append(properties, name)
+	}
+	for name := range req.Externals {
+		externals = append(externals, name)
+	}
+	sort.Strings(properties)
+	sort.Strings(externals)
+	log.Print(jsons.BPM(struct {
+		Schema     string   `json:"schema"`
+		InputBytes int      `json:"input_bytes"`
+		Properties []string `json:"properties,omitempty"`
+		Externals  []string `json:"externals,omitempty"`
+	}{
+		Schema:     req.Schema,
+		InputBytes: len(req.Input),
+		Properties: properties,
+		Externals:  externals,
+	}))
 }
 
 var (
 	sampleDir                  = "../../extensions/omniv21/samples/"
-	sampleFormats              = []string{"csv2", "json", "xml", "fixedlength2", "edi"}
+	sampleFormats              = []string{"csv2", "json", "jsonl", "yaml", "xml", "fixedlength2", "edi"}
 	sampleInputFilenamePattern = regexp.MustCompile("^([0-9]+[_a-zA-Z0-9]+)\\.input\\.[a-z]+$")
 )
 
@@ -206,3 +284,19 @@ func httpGetVersion(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Serving GET '/version' request from %s ... ", r.RemoteAddr)
 	writeSuccess(w, build)
 }
+
+func httpGetFuncs(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Serving GET '/funcs' request from %s ... ", r.RemoteAddr)
+	infos := funcInfos()
+	if jsDisabled {
+		// javascript custom funcs are removed from schemas when disabled, see serverExt().
+		filtered := infos[:0]
+		for _, info := range infos {
+			if !strings.HasPrefix(info.Name, "javascript") {
+				filtered = append(filtered, info)
+			}
+		}
+		infos = filtered
+	}
+	writeSuccess(w, infos)
+}
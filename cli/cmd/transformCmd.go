@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -29,9 +30,10 @@ var (
 			return nil
 		},
 	}
-	schema string
-	input  string
-	stream bool
+	schema    string
+	input     string
+	stream    bool
+	externals string
 )
 
 func init() {
@@ -42,6 +44,8 @@ func init() {
 		&input, "input", "i", "", "input file (optional; if not specified, stdin/pipe is used)")
 	transformCmd.Flags().BoolVarP(
 		&stream, "stream", "", false, "if specified, each record will be a standalone/full JSON blob and printed out immediately once transform is done")
+	transformCmd.Flags().StringVarP(
+		&externals, "externals", "e", "", "external values JSON file (optional; a JSON object whose top level keys are the external value names)")
 }
 
 func openFile(label string, filepath string) (io.ReadCloser, error) {
@@ -51,6 +55,22 @@ func openFile(label string, filepath string) (io.ReadCloser, error) {
 	return os.Open(filepath)
 }
 
+func loadExternals() (map[string]interface{}, error) {
+	if !strs.IsStrNonBlank(externals) {
+		return nil, nil
+	}
+	externalsReadCloser, err := openFile("externals", externals)
+	if err != nil {
+		return nil, err
+	}
+	defer externalsReadCloser.Close()
+	var values map[string]interface{}
+	if err := json.NewDecoder(externalsReadCloser).Decode(&values); err != nil {
+		return nil, fmt.Errorf("externals file '%s' is not a valid JSON object: %s", externals, err.Error())
+	}
+	return values, nil
+}
+
 func doTransform() error {
 	schemaName := filepath.Base(schema)
 	schemaReadCloser, err := openFile("schema", schema)
@@ -79,7 +99,13 @@ func doTransform() error {
 		return err
 	}
 
-	transform, err := schema.NewTransform(inputName, inputReadCloser, &transformctx.Ctx{})
+	externalValues, err := loadExternals()
+	if err != nil {
+		return err
+	}
+
+	transform, err := schema.NewTransform(
+		inputName, inputReadCloser, &transformctx.Ctx{ExternalValues: externalValues})
 	if err != nil {
 		return err
 	}
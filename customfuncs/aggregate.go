@@ -0,0 +1,90 @@
+package customfuncs
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+// The aggregate custom funcs below are typically fed with a "multi" arg, which passes all the values
+// of the nodes matching its xpath, instead of a single one, to the custom func.
+
+// Count returns the number of the values.
+func Count(_ *transformctx.Ctx, values ...string) (int, error) {
+	return len(values), nil
+}
+
+// Distinct returns the values with the duplicates removed, in the order of their first occurrences.
+func Distinct(_ *transformctx.Ctx, values ...string) ([]string, error) {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+// minMax returns the value that comes first according to better, which is given the comparison result
+// of a value against the best value so far. If all the values are numbers, they're compared numerically,
+// otherwise as strings. Empty values are ignored, and if there is no value, "" is returned.
+func minMax(values []string, better func(cmp int) bool) string {
+	var nonEmpty []string
+	var nums []decimal
+	for _, v := range values {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		nonEmpty = append(nonEmpty, v)
+		if d, err := parseDecimal(v); err == nil {
+			nums = append(nums, d)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return ""
+	}
+	numeric := len(nums) == len(nonEmpty)
+	best := 0
+	for i := 1; i < len(nonEmpty); i++ {
+		cmp := strings.Compare(nonEmpty[i], nonEmpty[best])
+		if numeric {
+			cmp = nums[i].cmp(nums[best])
+		}
+		if better(cmp) {
+			best = i
+		}
+	}
+	return nonEmpty[best]
+}
+
+// Max returns the largest of the values. If all the values are numbers, they're compared numerically,
+// otherwise as strings. Empty values are ignored, and if there is no value, "" is returned.
+func Max(_ *transformctx.Ctx, values ...string) (string, error) {
+	return minMax(values, func(cmp int) bool { return cmp > 0 }), nil
+}
+
+// Min returns the smallest of the values. If all the values are numbers, they're compared numerically,
+// otherwise as strings. Empty values are ignored, and if there is no value, "" is returned.
+func Min(_ *transformctx.Ctx, values ...string) (string, error) {
+	return minMax(values, func(cmp int) bool { return cmp < 0 }), nil
+}
+
+// Sum adds up the decimal values with arbitrary precision. Empty values are ignored, and if there is
+// no value, "0" is returned.
+func Sum(_ *transformctx.Ctx, values ...string) (string, error) {
+	sum := decimal{unscaled: new(big.Int)}
+	for _, v := range values {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		d, err := parseDecimal(v)
+		if err != nil {
+			return "", err
+		}
+		sum = sum.add(d)
+	}
+	return sum.String(), nil
+}
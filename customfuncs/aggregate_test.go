@@ -0,0 +1,43 @@
+package customfuncs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateFuncs(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		fn       func() (interface{}, error)
+		err      string
+		expected interface{}
+	}{
+		{name: "count", fn: func() (interface{}, error) { return Count(nil, "a", "", "c") }, expected: 3},
+		{name: "count - nothing", fn: func() (interface{}, error) { return Count(nil) }, expected: 0},
+		{name: "distinct", fn: func() (interface{}, error) { return Distinct(nil, "b", "a", "b", "c", "a") }, expected: []string{"b", "a", "c"}},
+		{name: "distinct - nothing", fn: func() (interface{}, error) { return Distinct(nil) }, expected: []string{}},
+		{name: "max - numbers", fn: func() (interface{}, error) { return Max(nil, "9", "10.50", "", "-2") }, expected: "10.50"},
+		{name: "max - strings", fn: func() (interface{}, error) { return Max(nil, "9", "10", "x") }, expected: "x"},
+		{name: "max - dates", fn: func() (interface{}, error) { return Max(nil, "2021-01-31", "2021-12-01", "2020-12-31") }, expected: "2021-12-01"},
+		{name: "max - nothing", fn: func() (interface{}, error) { return Max(nil, "", " ") }, expected: ""},
+		{name: "min - numbers", fn: func() (interface{}, error) { return Min(nil, "9", "10", "-2.5", "-2.50") }, expected: "-2.5"},
+		{name: "min - strings", fn: func() (interface{}, error) { return Min(nil, "b", "a", "10") }, expected: "10"},
+		{name: "min - nothing", fn: func() (interface{}, error) { return Min(nil) }, expected: ""},
+		{name: "sum", fn: func() (interface{}, error) { return Sum(nil, "1.5", "", "2.25", "-3") }, expected: "0.75"},
+		{name: "sum - precision", fn: func() (interface{}, error) { return Sum(nil, "0.1", "0.2") }, expected: "0.3"},
+		{name: "sum - nothing", fn: func() (interface{}, error) { return Sum(nil) }, expected: "0"},
+		{name: "sum - invalid", fn: func() (interface{}, error) { return Sum(nil, "1", "abc") }, err: "'abc' is not a valid decimal number"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := test.fn()
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, r)
+		})
+	}
+}
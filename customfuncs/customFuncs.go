@@ -30,16 +30,69 @@ func Merge(funcs ...CustomFuncs) CustomFuncs {
 // for all versions of schemas.
 var CommonCustomFuncs = map[string]CustomFuncType{
 	// keep these custom funcs lexically sorted
+	"base32Decode":            Base32Decode,
+	"base32Encode":            Base32Encode,
+	"base64Decode":            Base64Decode,
+	"base64Encode":            Base64Encode,
 	"coalesce":                Coalesce,
 	"concat":                  Concat,
+	"contains":                Contains,
+	"count":                   Count,
+	"dateTimeAdd":             DateTimeAdd,
+	"dateTimeDiff":            DateTimeDiff,
+	"dateTimeFormat":          DateTimeFormat,
 	"dateTimeLayoutToRFC3339": DateTimeLayoutToRFC3339,
+	"dateTimePart":            DateTimePart,
+	"dateTimeSub":             DateTimeSub,
 	"dateTimeToEpoch":         DateTimeToEpoch,
 	"dateTimeToRFC3339":       DateTimeToRFC3339,
+	"dateTimeTruncate":        DateTimeTruncate,
+	"decimalAdd":              DecimalAdd,
+	"decimalDiv":              DecimalDiv,
+	"decimalMul":              DecimalMul,
+	"decimalRound":            DecimalRound,
+	"decimalSub":              DecimalSub,
+	"distinct":                Distinct,
+	"ediDateTimeToRFC3339":    EDIDateTimeToRFC3339,
+	"endsWith":                EndsWith,
 	"epochToDateTimeRFC3339":  EpochToDateTimeRFC3339,
+	"formatNumber":            FormatNumber,
+	"hexDecode":               HexDecode,
+	"hexEncode":               HexEncode,
+	"hmac":                    HMAC,
+	"impliedDecimal":          ImpliedDecimal,
+	"join":                    Join,
 	"lower":                   Lower,
+	"mask":                    Mask,
+	"max":                     Max,
+	"min":                     Min,
 	"now":                     Now,
+	"padLeft":                 PadLeft,
+	"padRight":                PadRight,
+	"parseNumber":             ParseNumber,
+	"regexExtract":            RegexExtract,
+	"regexMatch":              RegexMatch,
+	"regexReplace":            RegexReplace,
+	"repeat":                  Repeat,
+	"replace":                 Replace,
+	"sha1":                    SHA1,
+	"sha256":                  SHA256,
+	"split":                   Split,
+	"sprintf":                 Sprintf,
+	"startsWith":              StartsWith,
+	"substring":               Substring,
+	"sum":                     Sum,
+	"tokenize":                Tokenize,
+	"trim":                    Trim,
+	"trimLeft":                TrimLeft,
+	"trimPrefix":              TrimPrefix,
+	"trimRight":               TrimRight,
+	"trimSuffix":              TrimSuffix,
 	"upper":                   Upper,
+	"urlEscape":               URLEscape,
+	"urlUnescape":             URLUnescape,
 	"uuidv3":                  UUIDv3,
+	"uuidv5":                  UUIDv5,
 }
 
 // Coalesce returns the first non-empty string of the input strings. If no input strings are given or
@@ -3,6 +3,7 @@ package customfuncs
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jf-tech/go-corelib/caches"
@@ -12,9 +13,10 @@ import (
 )
 
 const (
-	rfc3339NoTZ = "2006-01-02T15:04:05"
-	second      = "SECOND"
-	millisecond = "MILLISECOND"
+	rfc3339NoTZ     = "2006-01-02T15:04:05"
+	rfc3339NanoNoTZ = "2006-01-02T15:04:05.999999999"
+	second          = "SECOND"
+	millisecond     = "MILLISECOND"
 )
 
 // parseDateTime parse in an input datetime string and returns a time.Time and a flag indicate there
@@ -74,6 +76,14 @@ func rfc3339(t time.Time, hasTZ bool) string {
 	return t.Format(rfc3339NoTZ)
 }
 
+// rfc3339Nano is similar to rfc3339, except it keeps the fractional seconds, if any.
+func rfc3339Nano(t time.Time, hasTZ bool) string {
+	if hasTZ {
+		return t.Format(time.RFC3339Nano)
+	}
+	return t.Format(rfc3339NanoNoTZ)
+}
+
 // DateTimeToRFC3339 parses a 'datetime' string intelligently, normalizes and returns it in RFC3339 format.
 // 'fromTZ' is only used if 'datetime' doesn't contain TZ info; if not specified, the parser will keep the
 // original TZ (or lack of it) of 'datetime'. 'toTZ' decides what TZ the output RFC3339 date time will be in.
@@ -177,3 +187,342 @@ func EpochToDateTimeRFC3339(_ *transformctx.Ctx, epoch, unit string, tz ...strin
 func Now(_ *transformctx.Ctx) (string, error) {
 	return rfc3339(time.Now().UTC(), true), nil
 }
+
+const (
+	unitYear    = "YEAR"
+	unitQuarter = "QUARTER"
+	unitMonth   = "MONTH"
+	unitWeek    = "WEEK"
+	unitDay     = "DAY"
+	unitHour    = "HOUR"
+	unitMinute  = "MINUTE"
+)
+
+var fixedUnitDurations = map[string]time.Duration{
+	unitWeek:    7 * 24 * time.Hour,
+	unitDay:     24 * time.Hour,
+	unitHour:    time.Hour,
+	unitMinute:  time.Minute,
+	second:      time.Second,
+	millisecond: time.Millisecond,
+}
+
+// addMonths adds n months to t, clamping the day to the last day of the resulting month, so that, say,
+// 2021-01-31 plus 1 month is 2021-02-28, instead of time.AddDate's normalized 2021-03-03.
+func addMonths(t time.Time, n int) time.Time {
+	y, m, d := t.Date()
+	firstOfMonth := time.Date(y, m+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if lastDay := firstOfMonth.AddDate(0, 1, -1).Day(); d > lastDay {
+		d = lastDay
+	}
+	return firstOfMonth.AddDate(0, 0, d-1)
+}
+
+func addToDateTime(datetime, amount, unit string, sign int) (string, error) {
+	n, err := parseIntArg("amount", amount)
+	if err != nil {
+		return "", err
+	}
+	if datetime == "" {
+		return "", nil
+	}
+	t, hasTZ, err := parseDateTime(datetime, "", false, "", "")
+	if err != nil {
+		return "", err
+	}
+	n *= sign
+	switch unit {
+	case unitYear:
+		t = addMonths(t, n*12)
+	case unitQuarter:
+		t = addMonths(t, n*3)
+	case unitMonth:
+		t = addMonths(t, n)
+	default:
+		d, found := fixedUnitDurations[unit]
+		if !found {
+			return "", fmt.Errorf("unknown time unit '%s'", unit)
+		}
+		if unit == unitDay || unit == unitWeek {
+			// calendar days are used so that adding days across a DST change keeps the wall clock.
+			t = t.AddDate(0, 0, n*int(d/fixedUnitDurations[unitDay]))
+		} else {
+			t = t.Add(time.Duration(n) * d)
+		}
+	}
+	return rfc3339Nano(t, hasTZ), nil
+}
+
+// DateTimeAdd parses a 'datetime' string intelligently, adds 'amount' (an integer, can be negative) of
+// 'unit' to it and returns the result in RFC3339 format with the original TZ (or lack of it) and the
+// fractional seconds, if any, kept. 'unit' can be "YEAR", "QUARTER", "MONTH", "WEEK", "DAY", "HOUR",
+// "MINUTE", "SECOND" or "MILLISECOND". When adding years, quarters or months, the day is clamped to the
+// last day of the resulting month.
+func DateTimeAdd(_ *transformctx.Ctx, datetime, amount, unit string) (string, error) {
+	return addToDateTime(datetime, amount, unit, 1)
+}
+
+// DateTimeSub is similar to DateTimeAdd, except it subtracts 'amount' of 'unit' from 'datetime'.
+func DateTimeSub(_ *transformctx.Ctx, datetime, amount, unit string) (string, error) {
+	return addToDateTime(datetime, amount, unit, -1)
+}
+
+// monthsBetween returns the number of whole months from t2 to t1, negative if t1 is before t2.
+func monthsBetween(t1, t2 time.Time) int {
+	months := (t1.Year()-t2.Year())*12 + int(t1.Month()-t2.Month())
+	switch {
+	case months > 0 && addMonths(t2, months).After(t1):
+		months--
+	case months < 0 && addMonths(t2, months).Before(t1):
+		months++
+	}
+	return months
+}
+
+// DateTimeDiff parses two datetime strings intelligently, and returns the number of whole 'unit's
+// 'datetime1' is after 'datetime2' (negative if it is before). 'unit' can be "YEAR", "QUARTER", "MONTH",
+// "WEEK", "DAY", "HOUR", "MINUTE", "SECOND" or "MILLISECOND". If either datetime is empty, "" is returned.
+func DateTimeDiff(_ *transformctx.Ctx, datetime1, datetime2, unit string) (string, error) {
+	if datetime1 == "" || datetime2 == "" {
+		return "", nil
+	}
+	t1, _, err := parseDateTime(datetime1, "", false, "", "")
+	if err != nil {
+		return "", err
+	}
+	t2, _, err := parseDateTime(datetime2, "", false, "", "")
+	if err != nil {
+		return "", err
+	}
+	var diff int64
+	switch unit {
+	case unitYear:
+		diff = int64(monthsBetween(t1, t2) / 12)
+	case unitQuarter:
+		diff = int64(monthsBetween(t1, t2) / 3)
+	case unitMonth:
+		diff = int64(monthsBetween(t1, t2))
+	default:
+		d, found := fixedUnitDurations[unit]
+		if !found {
+			return "", fmt.Errorf("unknown time unit '%s'", unit)
+		}
+		diff = int64(t1.Sub(t2) / d)
+	}
+	return strconv.FormatInt(diff, 10), nil
+}
+
+// DateTimeTruncate parses a 'datetime' string intelligently, truncates it to the beginning of the 'unit'
+// it is in and returns the result in RFC3339 format with the original TZ (or lack of it) kept. 'unit' can
+// be "YEAR", "QUARTER", "MONTH", "WEEK" (which starts on Monday), "DAY", "HOUR", "MINUTE" or "SECOND".
+func DateTimeTruncate(_ *transformctx.Ctx, datetime, unit string) (string, error) {
+	if datetime == "" {
+		return "", nil
+	}
+	t, hasTZ, err := parseDateTime(datetime, "", false, "", "")
+	if err != nil {
+		return "", err
+	}
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch unit {
+	case unitYear:
+		t = time.Date(y, time.January, 1, 0, 0, 0, 0, loc)
+	case unitQuarter:
+		t = time.Date(y, (m-1)/3*3+1, 1, 0, 0, 0, 0, loc)
+	case unitMonth:
+		t = time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case unitWeek:
+		t = time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	case unitDay:
+		t = time.Date(y, m, d, 0, 0, 0, 0, loc)
+	case unitHour:
+		t = time.Date(y, m, d, t.Hour(), 0, 0, 0, loc)
+	case unitMinute:
+		t = time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc)
+	case second:
+		t = time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, loc)
+	default:
+		return "", fmt.Errorf("unknown time unit '%s'", unit)
+	}
+	return rfc3339(t, hasTZ), nil
+}
+
+// DateTimePart parses a 'datetime' string intelligently and returns one part of it. 'part' can be "YEAR",
+// "QUARTER" (1-4), "MONTH" (1-12), "DAY" (1-31), "HOUR", "MINUTE", "SECOND", "WEEKDAY" (such as "Monday"),
+// "WEEKDAY_NUMBER" (ISO 8601, 1 for Monday to 7 for Sunday), "DAY_OF_YEAR" (1-366), "ISO_WEEK" (1-53) or
+// "ISO_YEAR" (the year the ISO week belongs to).
+func DateTimePart(_ *transformctx.Ctx, datetime, part string) (string, error) {
+	if datetime == "" {
+		return "", nil
+	}
+	t, _, err := parseDateTime(datetime, "", false, "", "")
+	if err != nil {
+		return "", err
+	}
+	isoYear, isoWeek := t.ISOWeek()
+	var n int
+	switch part {
+	case unitYear:
+		n = t.Year()
+	case unitQuarter:
+		n = (int(t.Month())-1)/3 + 1
+	case unitMonth:
+		n = int(t.Month())
+	case unitDay:
+		n = t.Day()
+	case unitHour:
+		n = t.Hour()
+	case unitMinute:
+		n = t.Minute()
+	case second:
+		n = t.Second()
+	case "WEEKDAY":
+		return t.Weekday().String(), nil
+	case "WEEKDAY_NUMBER":
+		n = (int(t.Weekday())+6)%7 + 1
+	case "DAY_OF_YEAR":
+		n = t.YearDay()
+	case "ISO_WEEK":
+		n = isoWeek
+	case "ISO_YEAR":
+		n = isoYear
+	default:
+		return "", fmt.Errorf("unknown date/time part '%s'", part)
+	}
+	return strconv.Itoa(n), nil
+}
+
+// strftime formats t according to a C strftime style format.
+func strftime(t time.Time, format string) (string, error) {
+	var w strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			w.WriteByte(format[i])
+			continue
+		}
+		if i++; i >= len(format) {
+			return "", fmt.Errorf("incomplete strftime directive at the end of '%s'", format)
+		}
+		switch format[i] {
+		case 'Y':
+			w.WriteString(t.Format("2006"))
+		case 'y':
+			w.WriteString(t.Format("06"))
+		case 'm':
+			w.WriteString(t.Format("01"))
+		case 'd':
+			w.WriteString(t.Format("02"))
+		case 'e':
+			w.WriteString(t.Format("_2"))
+		case 'H':
+			w.WriteString(t.Format("15"))
+		case 'I':
+			w.WriteString(t.Format("03"))
+		case 'M':
+			w.WriteString(t.Format("04"))
+		case 'S':
+			w.WriteString(t.Format("05"))
+		case 'L':
+			w.WriteString(fmt.Sprintf("%03d", t.Nanosecond()/int(time.Millisecond)))
+		case 'p':
+			w.WriteString(t.Format("PM"))
+		case 'b':
+			w.WriteString(t.Format("Jan"))
+		case 'B':
+			w.WriteString(t.Format("January"))
+		case 'a':
+			w.WriteString(t.Format("Mon"))
+		case 'A':
+			w.WriteString(t.Format("Monday"))
+		case 'j':
+			w.WriteString(fmt.Sprintf("%03d", t.YearDay()))
+		case 'u':
+			w.WriteString(strconv.Itoa((int(t.Weekday())+6)%7 + 1))
+		case 'V':
+			_, week := t.ISOWeek()
+			w.WriteString(fmt.Sprintf("%02d", week))
+		case 'G':
+			year, _ := t.ISOWeek()
+			w.WriteString(strconv.Itoa(year))
+		case 'z':
+			w.WriteString(t.Format("-0700"))
+		case 'Z':
+			w.WriteString(t.Format("MST"))
+		case '%':
+			w.WriteByte('%')
+		default:
+			return "", fmt.Errorf("unsupported strftime directive '%%%c' in '%s'", format[i], format)
+		}
+	}
+	return w.String(), nil
+}
+
+// DateTimeFormat parses a 'datetime' string intelligently and formats it with 'layout', which is either
+// a C strftime style format (if it contains any '%', such as "%Y%m%d") or a golang time layout (such as
+// "Jan 2, 2006"). If 'toTZ' is specified, the datetime is converted into it before formatting.
+func DateTimeFormat(_ *transformctx.Ctx, datetime, layout string, toTZ ...string) (string, error) {
+	if len(toTZ) > 1 {
+		return "", fmt.Errorf("cannot specify tz argument more than once")
+	}
+	if datetime == "" {
+		return "", nil
+	}
+	tz := ""
+	if len(toTZ) == 1 {
+		tz = toTZ[0]
+	}
+	t, _, err := parseDateTime(datetime, "", false, "", tz)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(layout, "%") {
+		return strftime(t, layout)
+	}
+	return t.Format(layout), nil
+}
+
+// ediTimeLayouts are the X12/EDIFACT time layouts keyed by their lengths: HHMM, HHMMSS, HHMMSSD and HHMMSSDD.
+var ediTimeLayouts = map[int]string{
+	4: "1504",
+	6: "150405",
+	7: "150405.0",
+	8: "150405.00",
+}
+
+// EDIDateTimeToRFC3339 combines an EDI 'date' (CCYYMMDD or YYMMDD) and an EDI 'time' (HHMM, HHMMSS, HHMMSSD
+// or HHMMSSDD, can be empty for midnight), as commonly seen in X12/EDIFACT segments such as DTM or ISA/GS,
+// and returns the date time in RFC3339 format. 'fromTZ' and 'toTZ' work the same way as in
+// DateTimeToRFC3339. If 'date' is empty, "" is returned.
+func EDIDateTimeToRFC3339(_ *transformctx.Ctx, date, tm, fromTZ, toTZ string) (string, error) {
+	if date == "" {
+		return "", nil
+	}
+	var layout string
+	switch len(date) {
+	case 8:
+		layout = "20060102"
+	case 6:
+		layout = "060102"
+	default:
+		return "", fmt.Errorf("'%s' is not a valid EDI date, expected CCYYMMDD or YYMMDD", date)
+	}
+	datetime := date
+	if tm != "" {
+		timeLayout, found := ediTimeLayouts[len(tm)]
+		if !found {
+			return "", fmt.Errorf("'%s' is not a valid EDI time, expected HHMM, HHMMSS, HHMMSSD or HHMMSSDD", tm)
+		}
+		layout += timeLayout
+		datetime += tm
+		if len(tm) > 6 {
+			// golang time layout requires a '.' before fractional seconds.
+			datetime = date + tm[:6] + "." + tm[6:]
+		}
+	}
+	t, hasTZ, err := parseDateTime(datetime, layout, false, fromTZ, toTZ)
+	if err != nil {
+		return "", err
+	}
+	return rfc3339(t, hasTZ), nil
+}
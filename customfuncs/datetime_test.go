@@ -368,3 +368,109 @@ func TestNow(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, len(now) > 0)
 }
+
+func TestDateTimeToolkit(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		fn       func() (string, error)
+		err      string
+		expected string
+	}{
+		{name: "add - empty datetime", fn: func() (string, error) { return DateTimeAdd(nil, "", "1", "DAY") }, expected: ""},
+		{name: "add - days keep tz", fn: func() (string, error) { return DateTimeAdd(nil, "2021-03-13T12:00:00-05:00", "2", "DAY") }, expected: "2021-03-15T12:00:00-05:00"},
+		{name: "add - days keep wall clock across dst", fn: func() (string, error) { return DateTimeAdd(nil, "2021-03-13T12:00:00-America/New_York", "1", "DAY") }, expected: "2021-03-14T12:00:00-04:00"},
+		{name: "add - weeks no tz", fn: func() (string, error) { return DateTimeAdd(nil, "2021/01/01", "2", "WEEK") }, expected: "2021-01-15T00:00:00"},
+		{name: "add - months clamped", fn: func() (string, error) { return DateTimeAdd(nil, "2021-01-31T10:00:00", "1", "MONTH") }, expected: "2021-02-28T10:00:00"},
+		{name: "add - quarters", fn: func() (string, error) { return DateTimeAdd(nil, "2021-11-30T10:00:00", "1", "QUARTER") }, expected: "2022-02-28T10:00:00"},
+		{name: "add - negative years clamped", fn: func() (string, error) { return DateTimeAdd(nil, "2020-02-29T00:00:00Z", "-1", "YEAR") }, expected: "2019-02-28T00:00:00Z"},
+		{name: "add - hours", fn: func() (string, error) { return DateTimeAdd(nil, "2021-01-01T23:30:00Z", "1", "HOUR") }, expected: "2021-01-02T00:30:00Z"},
+		{name: "add - milliseconds", fn: func() (string, error) { return DateTimeAdd(nil, "2021-01-01T00:00:00Z", "500", "MILLISECOND") }, expected: "2021-01-01T00:00:00.5Z"},
+		{name: "add - fractional seconds kept", fn: func() (string, error) { return DateTimeAdd(nil, "2021-01-01T00:00:00.250", "1", "SECOND") }, expected: "2021-01-01T00:00:01.25"},
+		{name: "add - invalid amount", fn: func() (string, error) { return DateTimeAdd(nil, "2021-01-01", "1.5", "DAY") }, err: "'amount' must be an integer, instead got '1.5'"},
+		{name: "add - invalid unit", fn: func() (string, error) { return DateTimeAdd(nil, "2021-01-01", "1", "DECADE") }, err: "unknown time unit 'DECADE'"},
+		{name: "add - invalid datetime", fn: func() (string, error) { return DateTimeAdd(nil, "invalid", "1", "DAY") }, err: "unable to parse 'invalid' in any supported date/time format"},
+		{name: "sub - minutes", fn: func() (string, error) { return DateTimeSub(nil, "2021-01-01T00:10:00Z", "15", "MINUTE") }, expected: "2020-12-31T23:55:00Z"},
+		{name: "sub - months", fn: func() (string, error) { return DateTimeSub(nil, "2021-03-31", "1", "MONTH") }, expected: "2021-02-28T00:00:00"},
+		{name: "diff - empty", fn: func() (string, error) { return DateTimeDiff(nil, "2021-01-01", "", "DAY") }, expected: ""},
+		{name: "diff - days", fn: func() (string, error) { return DateTimeDiff(nil, "2021-03-01", "2021-02-01", "DAY") }, expected: "28"},
+		{name: "diff - partial days truncated", fn: func() (string, error) {
+			return DateTimeDiff(nil, "2021-01-01T00:00:00Z", "2021-01-02T12:00:00Z", "DAY")
+		}, expected: "-1"},
+		{name: "diff - hours across tz", fn: func() (string, error) {
+			return DateTimeDiff(nil, "2021-01-01T12:00:00Z", "2021-01-01T12:00:00+02:00", "HOUR")
+		}, expected: "2"},
+		{name: "diff - milliseconds", fn: func() (string, error) {
+			return DateTimeDiff(nil, "2021-01-01T00:00:01.5Z", "2021-01-01T00:00:00Z", "MILLISECOND")
+		}, expected: "1500"},
+		{name: "diff - months incomplete", fn: func() (string, error) { return DateTimeDiff(nil, "2021-03-30", "2021-01-31", "MONTH") }, expected: "1"},
+		{name: "diff - months from month end", fn: func() (string, error) { return DateTimeDiff(nil, "2021-02-28", "2021-01-31", "MONTH") }, expected: "1"},
+		{name: "diff - negative months", fn: func() (string, error) { return DateTimeDiff(nil, "2021-01-15", "2021-03-14", "MONTH") }, expected: "-1"},
+		{name: "diff - quarters", fn: func() (string, error) { return DateTimeDiff(nil, "2021-12-31", "2021-01-01", "QUARTER") }, expected: "3"},
+		{name: "diff - years from leap day", fn: func() (string, error) { return DateTimeDiff(nil, "2021-02-28", "2020-02-29", "YEAR") }, expected: "1"},
+		{name: "diff - invalid unit", fn: func() (string, error) { return DateTimeDiff(nil, "2021-01-01", "2021-01-01", "x") }, err: "unknown time unit 'x'"},
+		{name: "diff - invalid datetime1", fn: func() (string, error) { return DateTimeDiff(nil, "x", "2021-01-01", "DAY") }, err: "unable to parse 'x' in any supported date/time format"},
+		{name: "diff - invalid datetime2", fn: func() (string, error) { return DateTimeDiff(nil, "2021-01-01", "y", "DAY") }, err: "unable to parse 'y' in any supported date/time format"},
+		{name: "truncate - empty", fn: func() (string, error) { return DateTimeTruncate(nil, "", "DAY") }, expected: ""},
+		{name: "truncate - year", fn: func() (string, error) { return DateTimeTruncate(nil, "2021-08-16T12:34:56Z", "YEAR") }, expected: "2021-01-01T00:00:00Z"},
+		{name: "truncate - quarter", fn: func() (string, error) { return DateTimeTruncate(nil, "2021-08-16T12:34:56Z", "QUARTER") }, expected: "2021-07-01T00:00:00Z"},
+		{name: "truncate - month", fn: func() (string, error) { return DateTimeTruncate(nil, "2021-08-16T12:34:56-07:00", "MONTH") }, expected: "2021-08-01T00:00:00-07:00"},
+		{name: "truncate - week", fn: func() (string, error) { return DateTimeTruncate(nil, "2021-08-15T12:34:56", "WEEK") }, expected: "2021-08-09T00:00:00"},
+		{name: "truncate - day", fn: func() (string, error) { return DateTimeTruncate(nil, "2021-08-16T12:34:56", "DAY") }, expected: "2021-08-16T00:00:00"},
+		{name: "truncate - hour", fn: func() (string, error) { return DateTimeTruncate(nil, "2021-08-16T12:34:56", "HOUR") }, expected: "2021-08-16T12:00:00"},
+		{name: "truncate - minute", fn: func() (string, error) { return DateTimeTruncate(nil, "2021-08-16T12:34:56", "MINUTE") }, expected: "2021-08-16T12:34:00"},
+		{name: "truncate - second", fn: func() (string, error) { return DateTimeTruncate(nil, "2021-08-16T12:34:56.789Z", "SECOND") }, expected: "2021-08-16T12:34:56Z"},
+		{name: "truncate - invalid unit", fn: func() (string, error) { return DateTimeTruncate(nil, "2021-08-16", "MILLISECOND") }, err: "unknown time unit 'MILLISECOND'"},
+		{name: "truncate - invalid datetime", fn: func() (string, error) { return DateTimeTruncate(nil, "x", "DAY") }, err: "unable to parse 'x' in any supported date/time format"},
+		{name: "part - empty", fn: func() (string, error) { return DateTimePart(nil, "", "YEAR") }, expected: ""},
+		{name: "part - year", fn: func() (string, error) { return DateTimePart(nil, "2021-01-03T04:05:06Z", "YEAR") }, expected: "2021"},
+		{name: "part - quarter", fn: func() (string, error) { return DateTimePart(nil, "2021-01-03T04:05:06Z", "QUARTER") }, expected: "1"},
+		{name: "part - month", fn: func() (string, error) { return DateTimePart(nil, "2021-01-03T04:05:06Z", "MONTH") }, expected: "1"},
+		{name: "part - day", fn: func() (string, error) { return DateTimePart(nil, "2021-01-03T04:05:06Z", "DAY") }, expected: "3"},
+		{name: "part - hour", fn: func() (string, error) { return DateTimePart(nil, "2021-01-03T04:05:06Z", "HOUR") }, expected: "4"},
+		{name: "part - minute", fn: func() (string, error) { return DateTimePart(nil, "2021-01-03T04:05:06Z", "MINUTE") }, expected: "5"},
+		{name: "part - second", fn: func() (string, error) { return DateTimePart(nil, "2021-01-03T04:05:06Z", "SECOND") }, expected: "6"},
+		{name: "part - weekday", fn: func() (string, error) { return DateTimePart(nil, "2021-01-03T04:05:06Z", "WEEKDAY") }, expected: "Sunday"},
+		{name: "part - weekday number", fn: func() (string, error) { return DateTimePart(nil, "2021-01-03T04:05:06Z", "WEEKDAY_NUMBER") }, expected: "7"},
+		{name: "part - day of year", fn: func() (string, error) { return DateTimePart(nil, "2021-12-31", "DAY_OF_YEAR") }, expected: "365"},
+		{name: "part - iso week", fn: func() (string, error) { return DateTimePart(nil, "2021-01-03T04:05:06Z", "ISO_WEEK") }, expected: "53"},
+		{name: "part - iso year", fn: func() (string, error) { return DateTimePart(nil, "2021-01-03T04:05:06Z", "ISO_YEAR") }, expected: "2020"},
+		{name: "part - invalid part", fn: func() (string, error) { return DateTimePart(nil, "2021-01-03", "ERA") }, err: "unknown date/time part 'ERA'"},
+		{name: "part - invalid datetime", fn: func() (string, error) { return DateTimePart(nil, "x", "YEAR") }, err: "unable to parse 'x' in any supported date/time format"},
+		{name: "format - empty", fn: func() (string, error) { return DateTimeFormat(nil, "", "%Y") }, expected: ""},
+		{name: "format - strftime", fn: func() (string, error) { return DateTimeFormat(nil, "2026-01-16T12:34:56Z", "%Y%m%d") }, expected: "20260116"},
+		{name: "format - strftime all", fn: func() (string, error) {
+			return DateTimeFormat(nil, "2026-01-06T15:04:05.123-07:00", "%y|%e|%H|%I|%M|%S|%L|%p|%b|%B|%a|%A|%j|%u|%V|%G|%z|%%")
+		}, expected: "26| 6|15|03|04|05|123|PM|Jan|January|Tue|Tuesday|006|2|02|2026|-0700|%"},
+		{name: "format - strftime literal text not mistaken as layout", fn: func() (string, error) { return DateTimeFormat(nil, "2026-01-16", "Mon %d, 2006") }, expected: "Mon 16, 2006"},
+		{name: "format - strftime with tz name", fn: func() (string, error) {
+			return DateTimeFormat(nil, "2026-01-16T12:00:00Z", "%H:%M %Z", "America/New_York")
+		}, expected: "07:00 EST"},
+		{name: "format - golang layout", fn: func() (string, error) { return DateTimeFormat(nil, "2026-01-16T12:34:56Z", "Jan 2, 2006") }, expected: "Jan 16, 2026"},
+		{name: "format - unsupported directive", fn: func() (string, error) { return DateTimeFormat(nil, "2026-01-16", "%Q") }, err: "unsupported strftime directive '%Q' in '%Q'"},
+		{name: "format - incomplete directive", fn: func() (string, error) { return DateTimeFormat(nil, "2026-01-16", "%Y%") }, err: "incomplete strftime directive at the end of '%Y%'"},
+		{name: "format - too many tz", fn: func() (string, error) { return DateTimeFormat(nil, "2026-01-16", "%Y", "UTC", "UTC") }, err: "cannot specify tz argument more than once"},
+		{name: "format - invalid tz", fn: func() (string, error) { return DateTimeFormat(nil, "2026-01-16", "%Y", "invalid") }, err: "unknown time zone invalid"},
+		{name: "edi - empty date", fn: func() (string, error) { return EDIDateTimeToRFC3339(nil, "", "1230", "", "") }, expected: ""},
+		{name: "edi - CCYYMMDD only", fn: func() (string, error) { return EDIDateTimeToRFC3339(nil, "20260116", "", "", "") }, expected: "2026-01-16T00:00:00"},
+		{name: "edi - YYMMDD HHMM", fn: func() (string, error) { return EDIDateTimeToRFC3339(nil, "260116", "1230", "", "") }, expected: "2026-01-16T12:30:00"},
+		{name: "edi - HHMMSS fromTZ toTZ", fn: func() (string, error) {
+			return EDIDateTimeToRFC3339(nil, "20260116", "123045", "America/New_York", "UTC")
+		}, expected: "2026-01-16T17:30:45Z"},
+		{name: "edi - HHMMSSDD", fn: func() (string, error) { return EDIDateTimeToRFC3339(nil, "20260116", "12304599", "UTC", "") }, expected: "2026-01-16T12:30:45Z"},
+		{name: "edi - invalid date length", fn: func() (string, error) { return EDIDateTimeToRFC3339(nil, "2026011", "", "", "") }, err: "'2026011' is not a valid EDI date, expected CCYYMMDD or YYMMDD"},
+		{name: "edi - invalid time length", fn: func() (string, error) { return EDIDateTimeToRFC3339(nil, "20260116", "12", "", "") }, err: "'12' is not a valid EDI time, expected HHMM, HHMMSS, HHMMSSD or HHMMSSDD"},
+		{name: "edi - invalid date", fn: func() (string, error) { return EDIDateTimeToRFC3339(nil, "20261316", "", "", "") }, err: `parsing time "20261316": month out of range`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := test.fn()
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Equal(t, "", r)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, r)
+		})
+	}
+}
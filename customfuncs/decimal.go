@@ -0,0 +1,366 @@
+package customfuncs
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+// Rounding modes supported by decimal custom funcs.
+const (
+	roundHalfUp   = "HALF_UP"
+	roundHalfEven = "HALF_EVEN"
+	roundUp       = "UP"
+	roundDown     = "DOWN"
+	roundCeiling  = "CEILING"
+	roundFloor    = "FLOOR"
+)
+
+// decimal is an arbitrary-precision decimal number: unscaled * 10^(-scale).
+type decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+var bigTen = big.NewInt(10)
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// parseDecimal parses a decimal string in the form of '[+-]digits[.digits]'.
+func parseDecimal(s string) (decimal, error) {
+	str := strings.TrimSpace(s)
+	digits := strings.TrimLeft(str, "+-")
+	if len(str)-len(digits) > 1 {
+		return decimal{}, fmt.Errorf("'%s' is not a valid decimal number", s)
+	}
+	scale := 0
+	if dot := strings.IndexByte(digits, '.'); dot >= 0 {
+		scale = len(digits) - dot - 1
+		digits = digits[:dot] + digits[dot+1:]
+	}
+	if digits == "" || strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return decimal{}, fmt.Errorf("'%s' is not a valid decimal number", s)
+	}
+	unscaled, _ := new(big.Int).SetString(digits, 10)
+	if strings.HasPrefix(str, "-") {
+		unscaled.Neg(unscaled)
+	}
+	return decimal{unscaled: unscaled, scale: scale}, nil
+}
+
+func (d decimal) String() string {
+	digits := new(big.Int).Abs(d.unscaled).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if d.unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// rescale returns a decimal with the same value but a larger scale.
+func (d decimal) rescale(scale int) decimal {
+	if scale <= d.scale {
+		return d
+	}
+	return decimal{unscaled: new(big.Int).Mul(d.unscaled, pow10(scale-d.scale)), scale: scale}
+}
+
+// align rescales the two decimals to the larger of their scales.
+func align(d1, d2 decimal) (decimal, decimal) {
+	if d2.scale > d1.scale {
+		return d1.rescale(d2.scale), d2
+	}
+	return d1, d2.rescale(d1.scale)
+}
+
+func (d decimal) add(d2 decimal) decimal {
+	d1, d2 := align(d, d2)
+	return decimal{unscaled: new(big.Int).Add(d1.unscaled, d2.unscaled), scale: d1.scale}
+}
+
+func (d decimal) cmp(d2 decimal) int {
+	d1, d2 := align(d, d2)
+	return d1.unscaled.Cmp(d2.unscaled)
+}
+
+// roundQuo returns n/d rounded to an integer according to the rounding mode.
+func roundQuo(n, d *big.Int, mode string) (*big.Int, error) {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Sign() == 0 {
+		return q, nil
+	}
+	sign := n.Sign() * d.Sign()
+	var awayFromZero bool
+	switch strings.ToUpper(mode) {
+	case roundHalfUp, roundHalfEven:
+		cmp := new(big.Int).Abs(new(big.Int).Lsh(r, 1)).Cmp(new(big.Int).Abs(d))
+		awayFromZero = cmp > 0 || (cmp == 0 && (strings.ToUpper(mode) == roundHalfUp || q.Bit(0) == 1))
+	case roundUp:
+		awayFromZero = true
+	case roundDown:
+		awayFromZero = false
+	case roundCeiling:
+		awayFromZero = sign > 0
+	case roundFloor:
+		awayFromZero = sign < 0
+	default:
+		return nil, fmt.Errorf("unknown rounding mode '%s'", mode)
+	}
+	if awayFromZero {
+		q.Add(q, big.NewInt(int64(sign)))
+	}
+	return q, nil
+}
+
+func (d decimal) round(scale int, mode string) (decimal, error) {
+	if scale >= d.scale {
+		return d.rescale(scale), nil
+	}
+	unscaled, err := roundQuo(d.unscaled, pow10(d.scale-scale), mode)
+	if err != nil {
+		return decimal{}, err
+	}
+	return decimal{unscaled: unscaled, scale: scale}, nil
+}
+
+// maxScale caps the scale args, which would otherwise let a single call allocate arbitrarily large
+// numbers, e.g. 10^1000000000.
+const maxScale = 1000
+
+func parseScaleArg(scale string) (int, error) {
+	n, err := parseIntArg("scale", scale)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("'scale' must not be negative, instead got %d", n)
+	}
+	if n > maxScale {
+		return 0, fmt.Errorf("'scale' must not be greater than %d, instead got %d", maxScale, n)
+	}
+	return n, nil
+}
+
+func parseRoundingModeArg(mode []string) (string, error) {
+	switch len(mode) {
+	case 0:
+		return roundHalfUp, nil
+	case 1:
+		return mode[0], nil
+	}
+	return "", fmt.Errorf("at most one rounding mode can be specified, instead got %d", len(mode))
+}
+
+// decimalOp parses all the decimal operands and applies op on them, left to right. If any of the
+// operands is empty, "" is returned.
+func decimalOp(operands []string, op func(d1, d2 decimal) (decimal, error)) (string, error) {
+	var result decimal
+	for i, operand := range operands {
+		if strings.TrimSpace(operand) == "" {
+			return "", nil
+		}
+		d, err := parseDecimal(operand)
+		if err != nil {
+			return "", err
+		}
+		if i == 0 {
+			result = d
+			continue
+		}
+		if result, err = op(result, d); err != nil {
+			return "", err
+		}
+	}
+	return result.String(), nil
+}
+
+// DecimalAdd adds up a number of decimal numbers with arbitrary precision. The result scale is the
+// largest scale of the numbers. If any of the numbers is empty, "" is returned.
+func DecimalAdd(_ *transformctx.Ctx, d1 string, ds ...string) (string, error) {
+	return decimalOp(append([]string{d1}, ds...), func(d1, d2 decimal) (decimal, error) {
+		return d1.add(d2), nil
+	})
+}
+
+// DecimalSub subtracts d2 from d1 with arbitrary precision. The result scale is the larger scale of the
+// two numbers. If any of the numbers is empty, "" is returned.
+func DecimalSub(_ *transformctx.Ctx, d1, d2 string) (string, error) {
+	return decimalOp([]string{d1, d2}, func(d1, d2 decimal) (decimal, error) {
+		d1, d2 = align(d1, d2)
+		return decimal{unscaled: new(big.Int).Sub(d1.unscaled, d2.unscaled), scale: d1.scale}, nil
+	})
+}
+
+// DecimalMul multiplies a number of decimal numbers with arbitrary precision. The result scale is the
+// sum of the scales of the numbers. If any of the numbers is empty, "" is returned.
+func DecimalMul(_ *transformctx.Ctx, d1 string, ds ...string) (string, error) {
+	return decimalOp(append([]string{d1}, ds...), func(d1, d2 decimal) (decimal, error) {
+		return decimal{unscaled: new(big.Int).Mul(d1.unscaled, d2.unscaled), scale: d1.scale + d2.scale}, nil
+	})
+}
+
+// DecimalDiv divides d1 by d2, and rounds the result to the given scale using the optional rounding
+// mode (default HALF_UP). If any of the numbers is empty, "" is returned.
+func DecimalDiv(_ *transformctx.Ctx, d1, d2, scale string, mode ...string) (string, error) {
+	resultScale, err := parseScaleArg(scale)
+	if err != nil {
+		return "", err
+	}
+	roundingMode, err := parseRoundingModeArg(mode)
+	if err != nil {
+		return "", err
+	}
+	return decimalOp([]string{d1, d2}, func(d1, d2 decimal) (decimal, error) {
+		if d2.unscaled.Sign() == 0 {
+			return decimal{}, errors.New("division by zero")
+		}
+		// d1/d2 * 10^resultScale = (d1.unscaled * 10^(d2.scale+resultScale)) / (d2.unscaled * 10^d1.scale)
+		n := new(big.Int).Mul(d1.unscaled, pow10(d2.scale+resultScale))
+		d := new(big.Int).Mul(d2.unscaled, pow10(d1.scale))
+		unscaled, err := roundQuo(n, d, roundingMode)
+		if err != nil {
+			return decimal{}, err
+		}
+		return decimal{unscaled: unscaled, scale: resultScale}, nil
+	})
+}
+
+// DecimalRound rounds a decimal number to the given scale using the optional rounding mode (default
+// HALF_UP). Supported rounding modes are HALF_UP, HALF_EVEN, UP, DOWN, CEILING and FLOOR. If the number
+// is empty, "" is returned.
+func DecimalRound(_ *transformctx.Ctx, d, scale string, mode ...string) (string, error) {
+	resultScale, err := parseScaleArg(scale)
+	if err != nil {
+		return "", err
+	}
+	roundingMode, err := parseRoundingModeArg(mode)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(d) == "" {
+		return "", nil
+	}
+	dec, err := parseDecimal(d)
+	if err != nil {
+		return "", err
+	}
+	dec, err = dec.round(resultScale, roundingMode)
+	if err != nil {
+		return "", err
+	}
+	return dec.String(), nil
+}
+
+// ImpliedDecimal converts an integer string with an implied decimal point, commonly seen in fixed-length
+// formats, into a decimal number with the given scale, e.g. "0001234" with scale "2" becomes "12.34".
+// If the input is empty, "" is returned.
+func ImpliedDecimal(_ *transformctx.Ctx, s, scale string) (string, error) {
+	resultScale, err := parseScaleArg(scale)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(s) == "" {
+		return "", nil
+	}
+	if strings.Contains(s, ".") {
+		return "", fmt.Errorf("'%s' is not a valid implied decimal number", s)
+	}
+	d, err := parseDecimal(s)
+	if err != nil {
+		return "", err
+	}
+	d.scale = resultScale
+	return d.String(), nil
+}
+
+func validateSeparators(decimalSep, groupSep string) error {
+	if decimalSep == "" {
+		return errors.New("decimal separator must not be empty")
+	}
+	if decimalSep == groupSep {
+		return fmt.Errorf("decimal separator and grouping separator must be different, instead both are '%s'", decimalSep)
+	}
+	return nil
+}
+
+// ParseNumber parses a locale-specific number string, such as "1.234,56", with the given decimal
+// separator and (optional) grouping separator, into a canonical decimal number, such as "1234.56",
+// which can be further used by other decimal custom funcs or converted by 'type'. If the input is
+// empty, "" is returned.
+func ParseNumber(_ *transformctx.Ctx, s, decimalSep, groupSep string) (string, error) {
+	if err := validateSeparators(decimalSep, groupSep); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(s) == "" {
+		return "", nil
+	}
+	canonical := s
+	if groupSep != "" {
+		canonical = strings.ReplaceAll(canonical, groupSep, "")
+	}
+	canonical = strings.ReplaceAll(canonical, decimalSep, ".")
+	d, err := parseDecimal(canonical)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a valid number", s)
+	}
+	return d.String(), nil
+}
+
+// FormatNumber formats a canonical decimal number, such as "1234.5", with the given decimal separator
+// and (optional) grouping separator every 3 digits, such as "1.234,5". If scale is specified, the number
+// is rounded (HALF_UP) or zero-padded to the scale first. If the input is empty, "" is returned.
+func FormatNumber(_ *transformctx.Ctx, s, decimalSep, groupSep string, scale ...string) (string, error) {
+	if err := validateSeparators(decimalSep, groupSep); err != nil {
+		return "", err
+	}
+	if len(scale) > 1 {
+		return "", fmt.Errorf("at most one 'scale' can be specified, instead got %d", len(scale))
+	}
+	if strings.TrimSpace(s) == "" {
+		return "", nil
+	}
+	d, err := parseDecimal(s)
+	if err != nil {
+		return "", err
+	}
+	if len(scale) > 0 {
+		resultScale, err := parseScaleArg(scale[0])
+		if err != nil {
+			return "", err
+		}
+		// HALF_UP is always valid.
+		d, _ = d.round(resultScale, roundHalfUp)
+	}
+	str := d.String()
+	sign := ""
+	if strings.HasPrefix(str, "-") {
+		sign, str = "-", str[1:]
+	}
+	intPart, fracPart := str, ""
+	if dot := strings.IndexByte(str, '.'); dot >= 0 {
+		intPart, fracPart = str[:dot], str[dot+1:]
+	}
+	var sb strings.Builder
+	sb.WriteString(sign)
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			sb.WriteString(groupSep)
+		}
+		sb.WriteRune(c)
+	}
+	if fracPart != "" {
+		sb.WriteString(decimalSep)
+		sb.WriteString(fracPart)
+	}
+	return sb.String(), nil
+}
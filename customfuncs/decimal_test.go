@@ -0,0 +1,85 @@
+package customfuncs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecimalFuncs(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		fn       func() (string, error)
+		err      string
+		expected string
+	}{
+		{name: "add", fn: func() (string, error) { return DecimalAdd(nil, "0.1", "0.2") }, expected: "0.3"},
+		{name: "add - many with different scales", fn: func() (string, error) { return DecimalAdd(nil, "1.10", "-2", "+0.005") }, expected: "-0.895"},
+		{name: "add - big numbers", fn: func() (string, error) { return DecimalAdd(nil, "99999999999999999999.99", "0.01") }, expected: "100000000000000000000.00"},
+		{name: "add - single", fn: func() (string, error) { return DecimalAdd(nil, " 12.50 ") }, expected: "12.50"},
+		{name: "add - empty operand", fn: func() (string, error) { return DecimalAdd(nil, "1", "") }, expected: ""},
+		{name: "add - invalid operand", fn: func() (string, error) { return DecimalAdd(nil, "1", "1.2.3") }, err: "'1.2.3' is not a valid decimal number"},
+		{name: "add - invalid sign", fn: func() (string, error) { return DecimalAdd(nil, "+-1") }, err: "'+-1' is not a valid decimal number"},
+		{name: "sub", fn: func() (string, error) { return DecimalSub(nil, "1.00", "0.999") }, expected: "0.001"},
+		{name: "sub - negative result", fn: func() (string, error) { return DecimalSub(nil, ".5", "1") }, expected: "-0.5"},
+		{name: "mul", fn: func() (string, error) { return DecimalMul(nil, "1.5", "-0.25", "2") }, expected: "-0.750"},
+		{name: "div", fn: func() (string, error) { return DecimalDiv(nil, "10", "3", "4") }, expected: "3.3333"},
+		{name: "div - rounding", fn: func() (string, error) { return DecimalDiv(nil, "2", "3", "2") }, expected: "0.67"},
+		{name: "div - rounding mode", fn: func() (string, error) { return DecimalDiv(nil, "-2", "3", "2", "down") }, expected: "-0.66"},
+		{name: "div - scales", fn: func() (string, error) { return DecimalDiv(nil, "0.5", "0.25", "0") }, expected: "2"},
+		{name: "div - by zero", fn: func() (string, error) { return DecimalDiv(nil, "1", "0.00", "2") }, err: "division by zero"},
+		{name: "div - invalid scale", fn: func() (string, error) { return DecimalDiv(nil, "1", "2", "-1") }, err: "'scale' must not be negative, instead got -1"},
+		{name: "div - too many modes", fn: func() (string, error) { return DecimalDiv(nil, "1", "2", "1", "UP", "DOWN") }, err: "at most one rounding mode can be specified, instead got 2"},
+		{name: "div - invalid mode", fn: func() (string, error) { return DecimalDiv(nil, "1", "3", "1", "SIDEWAYS") }, err: "unknown rounding mode 'SIDEWAYS'"},
+		{name: "round - half up", fn: func() (string, error) { return DecimalRound(nil, "2.345", "2") }, expected: "2.35"},
+		{name: "round - half up negative", fn: func() (string, error) { return DecimalRound(nil, "-2.345", "2") }, expected: "-2.35"},
+		{name: "round - half even down", fn: func() (string, error) { return DecimalRound(nil, "2.345", "2", "HALF_EVEN") }, expected: "2.34"},
+		{name: "round - half even up", fn: func() (string, error) { return DecimalRound(nil, "2.355", "2", "HALF_EVEN") }, expected: "2.36"},
+		{name: "round - half even above half", fn: func() (string, error) { return DecimalRound(nil, "2.3451", "2", "HALF_EVEN") }, expected: "2.35"},
+		{name: "round - up", fn: func() (string, error) { return DecimalRound(nil, "2.341", "2", "UP") }, expected: "2.35"},
+		{name: "round - down", fn: func() (string, error) { return DecimalRound(nil, "2.349", "2", "DOWN") }, expected: "2.34"},
+		{name: "round - ceiling negative", fn: func() (string, error) { return DecimalRound(nil, "-2.349", "2", "CEILING") }, expected: "-2.34"},
+		{name: "round - floor negative", fn: func() (string, error) { return DecimalRound(nil, "-2.341", "2", "FLOOR") }, expected: "-2.35"},
+		{name: "round - exact", fn: func() (string, error) { return DecimalRound(nil, "2.300", "1") }, expected: "2.3"},
+		{name: "round - pad zeros", fn: func() (string, error) { return DecimalRound(nil, "2", "2") }, expected: "2.00"},
+		{name: "round - empty", fn: func() (string, error) { return DecimalRound(nil, "", "2") }, expected: ""},
+		{name: "round - invalid number", fn: func() (string, error) { return DecimalRound(nil, "abc", "2") }, err: "'abc' is not a valid decimal number"},
+		{name: "round - invalid scale", fn: func() (string, error) { return DecimalRound(nil, "1", "x") }, err: "'scale' must be an integer, instead got 'x'"},
+		{name: "round - scale too large", fn: func() (string, error) { return DecimalRound(nil, "1", "1000000000") }, err: "'scale' must not be greater than 1000, instead got 1000000000"},
+		{name: "round - invalid mode", fn: func() (string, error) { return DecimalRound(nil, "1.55", "1", "x") }, err: "unknown rounding mode 'x'"},
+		{name: "implied", fn: func() (string, error) { return ImpliedDecimal(nil, "0001234", "2") }, expected: "12.34"},
+		{name: "implied - small", fn: func() (string, error) { return ImpliedDecimal(nil, "-5", "3") }, expected: "-0.005"},
+		{name: "implied - scale 0", fn: func() (string, error) { return ImpliedDecimal(nil, "00120", "0") }, expected: "120"},
+		{name: "implied - empty", fn: func() (string, error) { return ImpliedDecimal(nil, "  ", "2") }, expected: ""},
+		{name: "implied - has decimal point", fn: func() (string, error) { return ImpliedDecimal(nil, "12.34", "2") }, err: "'12.34' is not a valid implied decimal number"},
+		{name: "implied - invalid scale", fn: func() (string, error) { return ImpliedDecimal(nil, "1234", "") }, err: "'scale' must be an integer, instead got ''"},
+		{name: "parse - european", fn: func() (string, error) { return ParseNumber(nil, "-1.234.567,89", ",", ".") }, expected: "-1234567.89"},
+		{name: "parse - us", fn: func() (string, error) { return ParseNumber(nil, "1,234.5", ".", ",") }, expected: "1234.5"},
+		{name: "parse - no grouping", fn: func() (string, error) { return ParseNumber(nil, "1234,5", ",", "") }, expected: "1234.5"},
+		{name: "parse - empty", fn: func() (string, error) { return ParseNumber(nil, "", ",", ".") }, expected: ""},
+		{name: "parse - invalid", fn: func() (string, error) { return ParseNumber(nil, "1,2,3", ",", ".") }, err: "'1,2,3' is not a valid number"},
+		{name: "parse - empty decimal sep", fn: func() (string, error) { return ParseNumber(nil, "1", "", ".") }, err: "decimal separator must not be empty"},
+		{name: "parse - same seps", fn: func() (string, error) { return ParseNumber(nil, "1", ".", ".") }, err: "decimal separator and grouping separator must be different, instead both are '.'"},
+		{name: "format - european", fn: func() (string, error) { return FormatNumber(nil, "-1234567.891", ",", ".") }, expected: "-1.234.567,891"},
+		{name: "format - with scale", fn: func() (string, error) { return FormatNumber(nil, "1234567.895", ".", ",", "2") }, expected: "1,234,567.90"},
+		{name: "format - small", fn: func() (string, error) { return FormatNumber(nil, "123", ".", ",", "2") }, expected: "123.00"},
+		{name: "format - no grouping", fn: func() (string, error) { return FormatNumber(nil, "1234.5", ",", "") }, expected: "1234,5"},
+		{name: "format - empty", fn: func() (string, error) { return FormatNumber(nil, "", ",", "") }, expected: ""},
+		{name: "format - invalid number", fn: func() (string, error) { return FormatNumber(nil, "1,5", ",", "") }, err: "'1,5' is not a valid decimal number"},
+		{name: "format - invalid scale", fn: func() (string, error) { return FormatNumber(nil, "1", ",", "", "-2") }, err: "'scale' must not be negative, instead got -2"},
+		{name: "format - too many scales", fn: func() (string, error) { return FormatNumber(nil, "1", ",", "", "1", "2") }, err: "at most one 'scale' can be specified, instead got 2"},
+		{name: "format - invalid seps", fn: func() (string, error) { return FormatNumber(nil, "1", "", "") }, err: "decimal separator must not be empty"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := test.fn()
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Equal(t, "", r)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, r)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package customfuncs
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+func getHashAlgorithm(algorithm string) (func() hash.Hash, error) {
+	h, found := hashAlgorithms[strings.ToLower(strings.TrimSpace(algorithm))]
+	if !found {
+		return nil, fmt.Errorf("unknown hash algorithm '%s'", algorithm)
+	}
+	return h, nil
+}
+
+// well-known uuid namespaces defined in RFC 4122, appendix C.
+var uuidNamespaces = map[string]uuid.UUID{
+	"dns":  uuid.NameSpaceDNS,
+	"url":  uuid.NameSpaceURL,
+	"oid":  uuid.NameSpaceOID,
+	"x500": uuid.NameSpaceX500,
+}
+
+func getUUIDNamespace(namespace string) (uuid.UUID, error) {
+	if ns, found := uuidNamespaces[strings.ToLower(strings.TrimSpace(namespace))]; found {
+		return ns, nil
+	}
+	ns, err := uuid.Parse(namespace)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("'%s' is not a valid uuid namespace", namespace)
+	}
+	return ns, nil
+}
+
+func validateHMACArg(argIndex int, arg string) error {
+	if argIndex != 2 {
+		return nil
+	}
+	_, err := getHashAlgorithm(arg)
+	return err
+}
+
+func validateUUIDv5Arg(argIndex int, arg string) error {
+	if argIndex != 1 {
+		return nil
+	}
+	_, err := getUUIDNamespace(arg)
+	return err
+}
+
+// Base32Encode encodes s into a standard (RFC 4648) base32 string.
+func Base32Encode(_ *transformctx.Ctx, s string) (string, error) {
+	return base32.StdEncoding.EncodeToString([]byte(s)), nil
+}
+
+// Base32Decode decodes a standard (RFC 4648) base32 string.
+func Base32Decode(_ *transformctx.Ctx, s string) (string, error) {
+	b, err := base32.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a valid base32 string", s)
+	}
+	return string(b), nil
+}
+
+// Base64Encode encodes s into a standard (RFC 4648) base64 string.
+func Base64Encode(_ *transformctx.Ctx, s string) (string, error) {
+	return base64.StdEncoding.EncodeToString([]byte(s)), nil
+}
+
+// Base64Decode decodes a standard (RFC 4648) base64 string.
+func Base64Decode(_ *transformctx.Ctx, s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a valid base64 string", s)
+	}
+	return string(b), nil
+}
+
+// HexEncode encodes s into a lower-case hex string.
+func HexEncode(_ *transformctx.Ctx, s string) (string, error) {
+	return hex.EncodeToString([]byte(s)), nil
+}
+
+// HexDecode decodes a hex string, case-insensitively.
+func HexDecode(_ *transformctx.Ctx, s string) (string, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a valid hex string", s)
+	}
+	return string(b), nil
+}
+
+// HMAC computes the HMAC of s, in lower-case hex, using the key from the external property keyName.
+// The hash algorithm can be 'sha1', 'sha256' or 'sha512'; 'sha256' is used if not specified. The key is
+// deliberately looked up from an external property so it never needs to be hardcoded in a schema.
+func HMAC(ctx *transformctx.Ctx, s, keyName string, algorithm ...string) (string, error) {
+	if len(algorithm) > 1 {
+		return "", fmt.Errorf("at most one 'algorithm' can be specified, instead got %d", len(algorithm))
+	}
+	h := sha256.New
+	if len(algorithm) > 0 {
+		var err error
+		if h, err = getHashAlgorithm(algorithm[0]); err != nil {
+			return "", err
+		}
+	}
+	key, found := ctx.External(keyName)
+	if !found {
+		return "", fmt.Errorf("cannot find external property '%s' for hmac key", keyName)
+	}
+	mac := hmac.New(h, []byte(key))
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// SHA1 computes the SHA-1 hash of s, in lower-case hex.
+func SHA1(_ *transformctx.Ctx, s string) (string, error) {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// SHA256 computes the SHA-256 hash of s, in lower-case hex.
+func SHA256(_ *transformctx.Ctx, s string) (string, error) {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// URLEscape escapes s so it can be safely placed inside a URL query.
+func URLEscape(_ *transformctx.Ctx, s string) (string, error) {
+	return url.QueryEscape(s), nil
+}
+
+// URLUnescape does the inverse of URLEscape.
+func URLUnescape(_ *transformctx.Ctx, s string) (string, error) {
+	r, err := url.QueryUnescape(s)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a valid url escaped string", s)
+	}
+	return r, nil
+}
+
+// UUIDv5 uses SHA-1 to produce a consistent/stable UUID for an input string. The namespace can be
+// one of the well-known 'dns', 'url', 'oid', 'x500', or any UUID string; the nil UUID is used if not
+// specified.
+func UUIDv5(_ *transformctx.Ctx, s string, namespace ...string) (string, error) {
+	if len(namespace) > 1 {
+		return "", fmt.Errorf("at most one 'namespace' can be specified, instead got %d", len(namespace))
+	}
+	ns := uuid.Nil
+	if len(namespace) > 0 {
+		var err error
+		if ns, err = getUUIDNamespace(namespace[0]); err != nil {
+			return "", err
+		}
+	}
+	return uuid.NewSHA1(ns, []byte(s)).String(), nil
+}
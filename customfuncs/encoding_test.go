@@ -0,0 +1,65 @@
+package customfuncs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+func TestEncodingFuncs(t *testing.T) {
+	ctx := &transformctx.Ctx{ExternalProperties: map[string]string{"hmac_key": "secret"}}
+	for _, test := range []struct {
+		name     string
+		fn       func() (string, error)
+		err      string
+		expected string
+	}{
+		{name: "base32Encode", fn: func() (string, error) { return Base32Encode(ctx, "hello") }, expected: "NBSWY3DP"},
+		{name: "base32Decode", fn: func() (string, error) { return Base32Decode(ctx, "NBSWY3DP") }, expected: "hello"},
+		{name: "base32Decode - invalid", fn: func() (string, error) { return Base32Decode(ctx, "NBSWY3D!") }, err: "'NBSWY3D!' is not a valid base32 string"},
+		{name: "base64Encode", fn: func() (string, error) { return Base64Encode(ctx, "hello?") }, expected: "aGVsbG8/"},
+		{name: "base64Decode", fn: func() (string, error) { return Base64Decode(ctx, "aGVsbG8/") }, expected: "hello?"},
+		{name: "base64Decode - invalid", fn: func() (string, error) { return Base64Decode(ctx, "aGVsbG8") }, err: "'aGVsbG8' is not a valid base64 string"},
+		{name: "hexEncode", fn: func() (string, error) { return HexEncode(ctx, "hi!") }, expected: "686921"},
+		{name: "hexDecode", fn: func() (string, error) { return HexDecode(ctx, "68692A") }, expected: "hi*"},
+		{name: "hexDecode - invalid", fn: func() (string, error) { return HexDecode(ctx, "6g") }, err: "'6g' is not a valid hex string"},
+		{name: "hmac - default sha256", fn: func() (string, error) { return HMAC(ctx, "hello", "hmac_key") }, expected: "88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b"},
+		{name: "hmac - sha1", fn: func() (string, error) { return HMAC(ctx, "hello", "hmac_key", "SHA1") }, expected: "5112055c05f944f85755efc5cd8970e194e9f45b"},
+		{name: "hmac - unknown algorithm", fn: func() (string, error) { return HMAC(ctx, "hello", "hmac_key", "md5") }, err: "unknown hash algorithm 'md5'"},
+		{name: "hmac - too many algorithms", fn: func() (string, error) { return HMAC(ctx, "hello", "hmac_key", "sha1", "sha256") }, err: "at most one 'algorithm' can be specified, instead got 2"},
+		{name: "hmac - key not found", fn: func() (string, error) { return HMAC(ctx, "hello", "no_key") }, err: "cannot find external property 'no_key' for hmac key"},
+		{name: "sha1", fn: func() (string, error) { return SHA1(ctx, "hello") }, expected: "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"},
+		{name: "sha256", fn: func() (string, error) { return SHA256(ctx, "hello") }, expected: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{name: "urlEscape", fn: func() (string, error) { return URLEscape(ctx, "a b&c=d/é") }, expected: "a+b%26c%3Dd%2F%C3%A9"},
+		{name: "urlUnescape", fn: func() (string, error) { return URLUnescape(ctx, "a+b%26c%3Dd%2F%C3%A9") }, expected: "a b&c=d/é"},
+		{name: "urlUnescape - invalid", fn: func() (string, error) { return URLUnescape(ctx, "100%") }, err: "'100%' is not a valid url escaped string"},
+		{name: "uuidv5 - nil namespace", fn: func() (string, error) { return UUIDv5(ctx, "hello") }, expected: "b7502f40-1152-59f2-ba10-69aeed522cdf"},
+		{name: "uuidv5 - well-known namespace", fn: func() (string, error) { return UUIDv5(ctx, "example.com", "DNS") }, expected: "cfbff0d1-9375-5685-968c-48ce8b15ae17"},
+		{name: "uuidv5 - uuid namespace", fn: func() (string, error) { return UUIDv5(ctx, "x", "6ba7b811-9dad-11d1-80b4-00c04fd430c8") }, expected: "4cd605e7-afa2-5360-b5b9-c5e9fb5c76f4"},
+		{name: "uuidv5 - invalid namespace", fn: func() (string, error) { return UUIDv5(ctx, "x", "abc") }, err: "'abc' is not a valid uuid namespace"},
+		{name: "uuidv5 - too many namespaces", fn: func() (string, error) { return UUIDv5(ctx, "x", "dns", "url") }, err: "at most one 'namespace' can be specified, instead got 2"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := test.fn()
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Equal(t, "", r)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, r)
+		})
+	}
+}
+
+func TestEncodingConstArgValidators(t *testing.T) {
+	assert.NoError(t, ConstArgValidators["hmac"](1, "not the algorithm arg"))
+	assert.NoError(t, ConstArgValidators["hmac"](2, "sha512"))
+	assert.Equal(t, "unknown hash algorithm 'md4'", ConstArgValidators["hmac"](2, "md4").Error())
+	assert.NoError(t, ConstArgValidators["uuidv5"](0, "not the namespace arg"))
+	assert.NoError(t, ConstArgValidators["uuidv5"](1, "url"))
+	assert.Equal(t, "'x' is not a valid uuid namespace", ConstArgValidators["uuidv5"](1, "x").Error())
+}
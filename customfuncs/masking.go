@@ -0,0 +1,68 @@
+package customfuncs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+const (
+	// defaultMaskKeep is how many trailing runes Mask keeps unmasked, if not specified.
+	defaultMaskKeep = 4
+	maskRune        = '*'
+)
+
+func validateMaskArg(argIndex int, arg string) error {
+	if argIndex != 1 {
+		return nil
+	}
+	_, err := parseMaskKeep(arg)
+	return err
+}
+
+func parseMaskKeep(keep string) (int, error) {
+	n, err := parseIntArg("keep", keep)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("'keep' must not be negative, instead got %d", n)
+	}
+	return n, nil
+}
+
+// Mask replaces all the runes of s with '*', except the last keep runes, 4 if not specified, so that a
+// sensitive value, such as a card number, can be output as, e.g., '************1234'. If s isn't longer
+// than keep, it's masked entirely. Note the errors never contain s.
+func Mask(_ *transformctx.Ctx, s string, keep ...string) (string, error) {
+	if len(keep) > 1 {
+		return "", fmt.Errorf("at most one 'keep' can be specified, instead got %d", len(keep))
+	}
+	n := defaultMaskKeep
+	if len(keep) > 0 && keep[0] != "" {
+		var err error
+		if n, err = parseMaskKeep(keep[0]); err != nil {
+			return "", err
+		}
+	}
+	runes := []rune(s)
+	if len(runes) <= n {
+		return strings.Repeat(string(maskRune), len(runes)), nil
+	}
+	for i := 0; i < len(runes)-n; i++ {
+		runes[i] = maskRune
+	}
+	return string(runes), nil
+}
+
+// Tokenize replaces s with a stable token: its HMAC-SHA256, in lower-case hex, using the key from the
+// external property keyName. The same value always yields the same token under the same key, so that the
+// tokens can still be joined on, while the value can't be recovered without the key. An empty s yields an
+// empty token, so that a missing value doesn't turn into a token shared by all the missing values.
+func Tokenize(ctx *transformctx.Ctx, s, keyName string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	return HMAC(ctx, s, keyName)
+}
@@ -0,0 +1,51 @@
+package customfuncs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+func TestMaskingFuncs(t *testing.T) {
+	ctx := &transformctx.Ctx{ExternalProperties: map[string]string{"token_key": "secret"}}
+	for _, test := range []struct {
+		name     string
+		fn       func() (string, error)
+		err      string
+		expected string
+	}{
+		{name: "mask - default keep", fn: func() (string, error) { return Mask(ctx, "4111111111111111") }, expected: "************1111"},
+		{name: "mask - keep", fn: func() (string, error) { return Mask(ctx, "123-45-6789", "2") }, expected: "*********89"},
+		{name: "mask - keep none", fn: func() (string, error) { return Mask(ctx, "1990-01-31", "0") }, expected: "**********"},
+		{name: "mask - blank keep", fn: func() (string, error) { return Mask(ctx, "123456", "") }, expected: "**3456"},
+		{name: "mask - runes", fn: func() (string, error) { return Mask(ctx, "日本語の名前", "1") }, expected: "*****前"},
+		{name: "mask - not longer than keep", fn: func() (string, error) { return Mask(ctx, "1234") }, expected: "****"},
+		{name: "mask - empty", fn: func() (string, error) { return Mask(ctx, "") }, expected: ""},
+		{name: "mask - invalid keep", fn: func() (string, error) { return Mask(ctx, "123456", "x") }, err: "'keep' must be an integer, instead got 'x'"},
+		{name: "mask - negative keep", fn: func() (string, error) { return Mask(ctx, "123456", "-1") }, err: "'keep' must not be negative, instead got -1"},
+		{name: "mask - too many keeps", fn: func() (string, error) { return Mask(ctx, "123456", "1", "2") }, err: "at most one 'keep' can be specified, instead got 2"},
+		{name: "tokenize", fn: func() (string, error) { return Tokenize(ctx, "hello", "token_key") }, expected: "88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b"},
+		{name: "tokenize - empty", fn: func() (string, error) { return Tokenize(ctx, "", "token_key") }, expected: ""},
+		{name: "tokenize - key not found", fn: func() (string, error) { return Tokenize(ctx, "hello", "no_key") }, err: "cannot find external property 'no_key' for hmac key"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := test.fn()
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Equal(t, "", r)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, r)
+		})
+	}
+}
+
+func TestMaskingConstArgValidators(t *testing.T) {
+	assert.NoError(t, ConstArgValidators["mask"](0, "not the keep arg"))
+	assert.NoError(t, ConstArgValidators["mask"](1, "4"))
+	assert.Equal(t, "'keep' must be an integer, instead got 'x'", ConstArgValidators["mask"](1, "x").Error())
+}
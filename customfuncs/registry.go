@@ -0,0 +1,446 @@
+package customfuncs
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/jf-tech/omniparser/idr"
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+// FuncDoc contains the documentation metadata of a custom func that can't be discovered by reflection.
+type FuncDoc struct {
+	// Description is a short summary of what the custom func does.
+	Description string
+	// ArgNames are the names of the custom func's args, not counting the leading *transformctx.Ctx (and
+	// *idr.Node, if any).
+	ArgNames []string
+	// Example is an example invocation of the custom func, in the 'expr' syntax.
+	Example string
+}
+
+// FuncDocs is a map from custom func names to their FuncDoc's.
+type FuncDocs = map[string]FuncDoc
+
+// MergeDocs merges multiple custom func doc maps into one.
+func MergeDocs(docs ...FuncDocs) FuncDocs {
+	merged := make(FuncDocs)
+	for _, ds := range docs {
+		for name, d := range ds {
+			merged[name] = d
+		}
+	}
+	return merged
+}
+
+// FuncArg describes an arg of a custom func.
+type FuncArg struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Variadic bool   `json:"variadic,omitempty"`
+}
+
+// FuncInfo describes a custom func: its args and return type are discovered by reflection, and the rest
+// of the metadata comes from its FuncDoc, if any.
+type FuncInfo struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Args        []FuncArg `json:"args"`
+	Variadic    bool      `json:"variadic"`
+	// UsesNode indicates the custom func receives the current *idr.Node in addition to its args.
+	UsesNode   bool   `json:"uses_node"`
+	ReturnType string `json:"return_type"`
+	Example    string `json:"example,omitempty"`
+}
+
+var (
+	ctxType  = reflect.TypeOf((*transformctx.Ctx)(nil))
+	nodeType = reflect.TypeOf((*idr.Node)(nil))
+)
+
+func funcTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Interface:
+		return "any"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map:
+		return "object"
+	}
+	return t.String()
+}
+
+// Describe returns the FuncInfo's of the custom funcs, sorted by name, with the metadata from docs.
+// Entries of funcs that aren't functions are skipped.
+func Describe(funcs CustomFuncs, docs FuncDocs) []FuncInfo {
+	infos := []FuncInfo{}
+	for name, fn := range funcs {
+		fnType := reflect.TypeOf(fn)
+		if fnType == nil || fnType.Kind() != reflect.Func || fnType.NumOut() < 1 {
+			continue
+		}
+		doc := docs[name]
+		info := FuncInfo{
+			Name:        name,
+			Description: doc.Description,
+			Args:        []FuncArg{},
+			Variadic:    fnType.IsVariadic(),
+			ReturnType:  funcTypeName(fnType.Out(0)),
+			Example:     doc.Example,
+		}
+		first := 0
+		if fnType.NumIn() > first && fnType.In(first) == ctxType {
+			first++
+		}
+		if fnType.NumIn() > first && fnType.In(first) == nodeType {
+			info.UsesNode = true
+			first++
+		}
+		argCount := fnType.NumIn() - first
+		for i := 0; i < argCount; i++ {
+			argType := fnType.In(first + i)
+			arg := FuncArg{Name: "arg" + strconv.Itoa(i+1)}
+			if len(doc.ArgNames) == argCount {
+				arg.Name = doc.ArgNames[i]
+			}
+			if info.Variadic && i == argCount-1 {
+				arg.Variadic = true
+				argType = argType.Elem()
+			}
+			arg.Type = funcTypeName(argType)
+			info.Args = append(info.Args, arg)
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// CommonCustomFuncDocs contains the FuncDoc's of the custom funcs in CommonCustomFuncs.
+var CommonCustomFuncDocs = FuncDocs{
+	// keep these custom func docs lexically sorted
+	"base32Decode": {
+		Description: "Decodes a standard (RFC 4648) base32 string.",
+		ArgNames:    []string{"s"},
+		Example:     `base32Decode("NBSWY3DP") -> "hello"`,
+	},
+	"base32Encode": {
+		Description: "Encodes a string into standard (RFC 4648) base32.",
+		ArgNames:    []string{"s"},
+		Example:     `base32Encode("hello") -> "NBSWY3DP"`,
+	},
+	"base64Decode": {
+		Description: "Decodes a standard (RFC 4648) base64 string.",
+		ArgNames:    []string{"s"},
+		Example:     `base64Decode("aGVsbG8/") -> "hello?"`,
+	},
+	"base64Encode": {
+		Description: "Encodes a string into standard (RFC 4648) base64.",
+		ArgNames:    []string{"s"},
+		Example:     `base64Encode("hello?") -> "aGVsbG8/"`,
+	},
+	"coalesce": {
+		Description: "Returns the first non-empty string of the input strings.",
+		ArgNames:    []string{"strs"},
+		Example:     `coalesce("", "a", "b") -> "a"`,
+	},
+	"concat": {
+		Description: "Concatenates a number of strings together.",
+		ArgNames:    []string{"strs"},
+		Example:     `concat("a", "b", "c") -> "abc"`,
+	},
+	"contains": {
+		Description: "Returns true if substr is within s.",
+		ArgNames:    []string{"s", "substr"},
+		Example:     `contains("abcde", "bcd") -> true`,
+	},
+	"count": {
+		Description: "Counts the values, typically those of a 'multi' arg.",
+		ArgNames:    []string{"values"},
+		Example:     `count("a", "b", "c") -> 3`,
+	},
+	"dateTimeAdd": {
+		Description: "Adds an amount of a time unit (YEAR, QUARTER, MONTH, WEEK, DAY, HOUR, MINUTE, SECOND, MILLISECOND) to a datetime.",
+		ArgNames:    []string{"datetime", "amount", "unit"},
+		Example:     `dateTimeAdd("2021-01-31", "1", "MONTH") -> "2021-02-28T00:00:00"`,
+	},
+	"dateTimeDiff": {
+		Description: "Returns the number of whole time units datetime1 is after datetime2.",
+		ArgNames:    []string{"datetime1", "datetime2", "unit"},
+		Example:     `dateTimeDiff("2021-03-01", "2021-02-01", "DAY") -> "28"`,
+	},
+	"dateTimeFormat": {
+		Description: "Formats a datetime with a strftime style format or a golang time layout.",
+		ArgNames:    []string{"datetime", "layout", "toTZ"},
+		Example:     `dateTimeFormat("2026-01-16T12:34:56Z", "%Y%m%d") -> "20260116"`,
+	},
+	"dateTimeLayoutToRFC3339": {
+		Description: "Parses a datetime with a golang time layout and returns it in RFC3339 format.",
+		ArgNames:    []string{"datetime", "layout", "layoutTZ", "fromTZ", "toTZ"},
+		Example:     `dateTimeLayoutToRFC3339("09/22/2020 12:34", "01/02/2006 15:04", "", "", "") -> "2020-09-22T12:34:00"`,
+	},
+	"dateTimePart": {
+		Description: "Returns a part (YEAR, QUARTER, MONTH, DAY, HOUR, MINUTE, SECOND, WEEKDAY, WEEKDAY_NUMBER, DAY_OF_YEAR, ISO_WEEK, ISO_YEAR) of a datetime.",
+		ArgNames:    []string{"datetime", "part"},
+		Example:     `dateTimePart("2021-01-03", "ISO_WEEK") -> "53"`,
+	},
+	"dateTimeSub": {
+		Description: "Subtracts an amount of a time unit from a datetime.",
+		ArgNames:    []string{"datetime", "amount", "unit"},
+		Example:     `dateTimeSub("2021-03-31", "1", "MONTH") -> "2021-02-28T00:00:00"`,
+	},
+	"dateTimeToEpoch": {
+		Description: "Parses a datetime intelligently and returns its epoch number in SECOND or MILLISECOND.",
+		ArgNames:    []string{"datetime", "fromTZ", "unit"},
+		Example:     `dateTimeToEpoch("2020-09-22T12:34:56Z", "", "SECOND") -> "1600778096"`,
+	},
+	"dateTimeToRFC3339": {
+		Description: "Parses a datetime intelligently and returns it in RFC3339 format.",
+		ArgNames:    []string{"datetime", "fromTZ", "toTZ"},
+		Example:     `dateTimeToRFC3339("2020/09/22 12:34:56", "UTC", "") -> "2020-09-22T12:34:56Z"`,
+	},
+	"dateTimeTruncate": {
+		Description: "Truncates a datetime to the beginning of a time unit (YEAR, QUARTER, MONTH, WEEK, DAY, HOUR, MINUTE, SECOND).",
+		ArgNames:    []string{"datetime", "unit"},
+		Example:     `dateTimeTruncate("2021-08-16T12:34:56", "MONTH") -> "2021-08-01T00:00:00"`,
+	},
+	"decimalAdd": {
+		Description: "Adds up decimal numbers with arbitrary precision.",
+		ArgNames:    []string{"d1", "ds"},
+		Example:     `decimalAdd("10.10", "0.2") -> "10.30"`,
+	},
+	"decimalDiv": {
+		Description: "Divides a decimal number by another, rounded to a scale with an optional rounding mode.",
+		ArgNames:    []string{"d1", "d2", "scale", "mode"},
+		Example:     `decimalDiv("10", "3", "2") -> "3.33"`,
+	},
+	"decimalMul": {
+		Description: "Multiplies decimal numbers with arbitrary precision.",
+		ArgNames:    []string{"d1", "ds"},
+		Example:     `decimalMul("1.25", "3") -> "3.75"`,
+	},
+	"decimalRound": {
+		Description: "Rounds a decimal number to a scale with an optional rounding mode (HALF_UP, HALF_EVEN, UP, DOWN, CEILING, FLOOR).",
+		ArgNames:    []string{"d", "scale", "mode"},
+		Example:     `decimalRound("2.345", "2", "HALF_EVEN") -> "2.34"`,
+	},
+	"decimalSub": {
+		Description: "Subtracts a decimal number from another with arbitrary precision.",
+		ArgNames:    []string{"d1", "d2"},
+		Example:     `decimalSub("1.00", "0.999") -> "0.001"`,
+	},
+	"distinct": {
+		Description: "Removes the duplicates from the values, typically those of a 'multi' arg, keeping the order of their first occurrences.",
+		ArgNames:    []string{"values"},
+		Example:     `distinct("a", "b", "a") -> ["a", "b"]`,
+	},
+	"ediDateTimeToRFC3339": {
+		Description: "Combines an EDI date (CCYYMMDD or YYMMDD) and time (HHMM, HHMMSS, HHMMSSD or HHMMSSDD) into RFC3339 format.",
+		ArgNames:    []string{"date", "time", "fromTZ", "toTZ"},
+		Example:     `ediDateTimeToRFC3339("20260116", "1230", "", "") -> "2026-01-16T12:30:00"`,
+	},
+	"endsWith": {
+		Description: "Returns true if s ends with suffix.",
+		ArgNames:    []string{"s", "suffix"},
+		Example:     `endsWith("abcde", "de") -> true`,
+	},
+	"epochToDateTimeRFC3339": {
+		Description: "Translates an epoch number in SECOND or MILLISECOND into an RFC3339 datetime in an optional tz.",
+		ArgNames:    []string{"epoch", "unit", "tz"},
+		Example:     `epochToDateTimeRFC3339("1600778096", "SECOND") -> "2020-09-22T12:34:56Z"`,
+	},
+	"formatNumber": {
+		Description: "Formats a decimal number with a decimal separator, a grouping separator and an optional scale.",
+		ArgNames:    []string{"s", "decimalSep", "groupSep", "scale"},
+		Example:     `formatNumber("1234567.895", ",", ".", "2") -> "1.234.567,90"`,
+	},
+	"hexDecode": {
+		Description: "Decodes a hex string.",
+		ArgNames:    []string{"s"},
+		Example:     `hexDecode("686921") -> "hi!"`,
+	},
+	"hexEncode": {
+		Description: "Encodes a string into lower-case hex.",
+		ArgNames:    []string{"s"},
+		Example:     `hexEncode("hi!") -> "686921"`,
+	},
+	"hmac": {
+		Description: "Computes the HMAC of s in hex, keyed by the value of an external property, with an optional hash algorithm (sha1, sha256, sha512).",
+		ArgNames:    []string{"s", "keyName", "algorithm"},
+		Example:     `hmac("hello", "signing_key", "sha256")`,
+	},
+	"impliedDecimal": {
+		Description: "Converts an integer string with an implied decimal point into a decimal number of a scale.",
+		ArgNames:    []string{"s", "scale"},
+		Example:     `impliedDecimal("0001234", "2") -> "12.34"`,
+	},
+	"join": {
+		Description: "Concatenates a number of strings with a separator placed in between.",
+		ArgNames:    []string{"sep", "strs"},
+		Example:     `join(", ", "a", "b") -> "a, b"`,
+	},
+	"lower": {
+		Description: "Lowers the case of a string.",
+		ArgNames:    []string{"s"},
+		Example:     `lower("AbC") -> "abc"`,
+	},
+	"mask": {
+		Description: "Masks all but the last keep (4 by default) chars of s with '*'.",
+		ArgNames:    []string{"s", "keep"},
+		Example:     `mask("4111111111111111") -> "************1111"`,
+	},
+	"max": {
+		Description: "Returns the largest of the values, typically those of a 'multi' arg: numerically if all of them are numbers, otherwise as strings.",
+		ArgNames:    []string{"values"},
+		Example:     `max("9", "10", "2") -> "10"`,
+	},
+	"min": {
+		Description: "Returns the smallest of the values, typically those of a 'multi' arg: numerically if all of them are numbers, otherwise as strings.",
+		ArgNames:    []string{"values"},
+		Example:     `min("b", "a", "c") -> "a"`,
+	},
+	"now": {
+		Description: "Returns the current time in UTC in RFC3339 format.",
+		Example:     `now() -> "2021-01-02T03:04:05Z"`,
+	},
+	"padLeft": {
+		Description: "Left-pads s with padding to a length.",
+		ArgNames:    []string{"s", "length", "padding"},
+		Example:     `padLeft("7", "3", "0") -> "007"`,
+	},
+	"padRight": {
+		Description: "Right-pads s with padding to a length.",
+		ArgNames:    []string{"s", "length", "padding"},
+		Example:     `padRight("7", "3", "0") -> "700"`,
+	},
+	"parseNumber": {
+		Description: "Parses a locale-specific number string into a canonical decimal number.",
+		ArgNames:    []string{"s", "decimalSep", "groupSep"},
+		Example:     `parseNumber("1.234,56", ",", ".") -> "1234.56"`,
+	},
+	"regexExtract": {
+		Description: "Returns the first match, or a capture group of it, of a regex in s.",
+		ArgNames:    []string{"s", "pattern", "group"},
+		Example:     `regexExtract("order #123", "#(\\d+)", "1") -> "123"`,
+	},
+	"regexMatch": {
+		Description: "Returns true if s contains any match of a regex.",
+		ArgNames:    []string{"s", "pattern"},
+		Example:     `regexMatch("ab12", "\\d+") -> true`,
+	},
+	"regexReplace": {
+		Description: "Replaces all the matches of a regex in s.",
+		ArgNames:    []string{"s", "pattern", "repl"},
+		Example:     `regexReplace("2021-03-04", "(\\d+)-(\\d+)-(\\d+)", "$2/$3/$1") -> "03/04/2021"`,
+	},
+	"repeat": {
+		Description: "Returns a string consisting of count copies of s.",
+		ArgNames:    []string{"s", "count"},
+		Example:     `repeat("ab", "3") -> "ababab"`,
+	},
+	"replace": {
+		Description: "Replaces all the occurrences of old in s with new.",
+		ArgNames:    []string{"s", "old", "new"},
+		Example:     `replace("a-b-c", "-", "+") -> "a+b+c"`,
+	},
+	"sha1": {
+		Description: "Computes the SHA-1 hash of a string in hex.",
+		ArgNames:    []string{"s"},
+		Example:     `sha1("hello") -> "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"`,
+	},
+	"sha256": {
+		Description: "Computes the SHA-256 hash of a string in hex.",
+		ArgNames:    []string{"s"},
+		Example:     `sha256("hello") -> "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"`,
+	},
+	"split": {
+		Description: "Splits s by a separator into an array of strings.",
+		ArgNames:    []string{"s", "sep"},
+		Example:     `split("a,b", ",") -> ["a", "b"]`,
+	},
+	"sprintf": {
+		Description: "Formats args according to a golang fmt format.",
+		ArgNames:    []string{"format", "args"},
+		Example:     `sprintf("%s-%s", "a", "b") -> "a-b"`,
+	},
+	"startsWith": {
+		Description: "Returns true if s starts with prefix.",
+		ArgNames:    []string{"s", "prefix"},
+		Example:     `startsWith("abcde", "ab") -> true`,
+	},
+	"substring": {
+		Description: "Returns the part of s between the rune indexes start (inclusive) and end (exclusive).",
+		ArgNames:    []string{"s", "start", "end"},
+		Example:     `substring("hello", "1", "3") -> "el"`,
+	},
+	"sum": {
+		Description: "Adds up the decimal values, typically those of a 'multi' arg, with arbitrary precision.",
+		ArgNames:    []string{"values"},
+		Example:     `sum("1.5", "2.25", "3") -> "6.75"`,
+	},
+	"tokenize": {
+		Description: "Replaces s with a stable token, its HMAC-SHA256 in hex keyed by the value of an external property; empty stays empty.",
+		ArgNames:    []string{"s", "keyName"},
+		Example:     `tokenize("123-45-6789", "token_key")`,
+	},
+	"trim": {
+		Description: "Removes the leading and trailing whitespaces, or chars in cutset, of s.",
+		ArgNames:    []string{"s", "cutset"},
+		Example:     `trim("  abc ") -> "abc"`,
+	},
+	"trimLeft": {
+		Description: "Removes the leading whitespaces, or chars in cutset, of s.",
+		ArgNames:    []string{"s", "cutset"},
+		Example:     `trimLeft("00120", "0") -> "120"`,
+	},
+	"trimPrefix": {
+		Description: "Removes prefix from s.",
+		ArgNames:    []string{"s", "prefix"},
+		Example:     `trimPrefix("ID-123", "ID-") -> "123"`,
+	},
+	"trimRight": {
+		Description: "Removes the trailing whitespaces, or chars in cutset, of s.",
+		ArgNames:    []string{"s", "cutset"},
+		Example:     `trimRight("1.500", "0") -> "1.5"`,
+	},
+	"trimSuffix": {
+		Description: "Removes suffix from s.",
+		ArgNames:    []string{"s", "suffix"},
+		Example:     `trimSuffix("file.txt", ".txt") -> "file"`,
+	},
+	"upper": {
+		Description: "Uppers the case of a string.",
+		ArgNames:    []string{"s"},
+		Example:     `upper("abc") -> "ABC"`,
+	},
+	"urlEscape": {
+		Description: "Escapes a string so it can be safely placed inside a URL query.",
+		ArgNames:    []string{"s"},
+		Example:     `urlEscape("a b&c") -> "a+b%26c"`,
+	},
+	"urlUnescape": {
+		Description: "Unescapes a URL query escaped string.",
+		ArgNames:    []string{"s"},
+		Example:     `urlUnescape("a+b%26c") -> "a b&c"`,
+	},
+	"uuidv3": {
+		Description: "Uses MD5 to produce a consistent/stable UUID for a string.",
+		ArgNames:    []string{"s"},
+		Example:     `uuidv3("abc") -> "522ec739-ca63-3ec5-b082-08ce08ad65e2"`,
+	},
+	"uuidv5": {
+		Description: "Uses SHA-1 to produce a consistent/stable UUID for a string in an optional namespace (dns, url, oid, x500, or any UUID).",
+		ArgNames:    []string{"s", "namespace"},
+		Example:     `uuidv5("example.com", "dns") -> "cfbff0d1-9375-5685-968c-48ce8b15ae17"`,
+	},
+}
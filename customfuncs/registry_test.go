@@ -0,0 +1,60 @@
+package customfuncs
+
+import (
+	"testing"
+
+	"github.com/bradleyjkemp/cupaloy"
+	"github.com/jf-tech/go-corelib/jsons"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jf-tech/omniparser/idr"
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+func TestCommonCustomFuncDocs(t *testing.T) {
+	assert.Equal(t, len(CommonCustomFuncs), len(CommonCustomFuncDocs))
+	for _, info := range Describe(CommonCustomFuncs, CommonCustomFuncDocs) {
+		doc, found := CommonCustomFuncDocs[info.Name]
+		assert.True(t, found, info.Name)
+		assert.NotEmpty(t, doc.Description, info.Name)
+		assert.NotEmpty(t, doc.Example, info.Name)
+		assert.Equal(t, len(info.Args), len(doc.ArgNames), info.Name)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	infos := Describe(
+		CustomFuncs{
+			"with_node": func(_ *transformctx.Ctx, _ *idr.Node, s string, args ...interface{}) (interface{}, error) {
+				return nil, nil
+			},
+			"no_doc": func(_ *transformctx.Ctx, n int, f float64, b bool, m map[string]string) ([]string, error) {
+				return nil, nil
+			},
+			"not_func": "not a func",
+		},
+		MergeDocs(
+			FuncDocs{"with_node": {Description: "wrong", ArgNames: []string{"s", "args"}}},
+			FuncDocs{"with_node": {Description: "desc", ArgNames: []string{"s", "args"}, Example: "with_node('a')"}},
+			FuncDocs{"no_doc": {ArgNames: []string{"wrong count"}}}))
+	assert.Equal(t, []FuncInfo{
+		{
+			Name:       "no_doc",
+			Args:       []FuncArg{{Name: "arg1", Type: "int"}, {Name: "arg2", Type: "float"}, {Name: "arg3", Type: "boolean"}, {Name: "arg4", Type: "object"}},
+			ReturnType: "array",
+		},
+		{
+			Name:        "with_node",
+			Description: "desc",
+			Args:        []FuncArg{{Name: "s", Type: "string"}, {Name: "args", Type: "any", Variadic: true}},
+			Variadic:    true,
+			UsesNode:    true,
+			ReturnType:  "any",
+			Example:     "with_node('a')",
+		},
+	}, infos)
+}
+
+func TestDescribeCommonCustomFuncs(t *testing.T) {
+	cupaloy.SnapshotT(t, jsons.BPM(Describe(CommonCustomFuncs, CommonCustomFuncDocs)))
+}
@@ -0,0 +1,230 @@
+package customfuncs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jf-tech/go-corelib/caches"
+
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+// ConstArgValidator validates, at schema loading time, a const arg of a custom func. argIndex is the
+// 0-based index of the arg, not counting the leading *transformctx.Ctx (and *idr.Node, if any).
+type ConstArgValidator func(argIndex int, arg string) error
+
+// ConstArgValidators contains the ConstArgValidator's of the custom funcs in CommonCustomFuncs, keyed
+// by the custom func names, such that invalid const args (such as a malformed regex) fail schema loading
+// instead of every transform.
+var ConstArgValidators = map[string]ConstArgValidator{
+	"hmac":         validateHMACArg,
+	"mask":         validateMaskArg,
+	"regexExtract": validateRegexArg,
+	"regexMatch":   validateRegexArg,
+	"regexReplace": validateRegexArg,
+	"uuidv5":       validateUUIDv5Arg,
+}
+
+// all regex custom funcs take the regex pattern as their 2nd arg.
+func validateRegexArg(argIndex int, arg string) error {
+	if argIndex != 1 {
+		return nil
+	}
+	_, err := caches.GetRegex(arg)
+	return err
+}
+
+func parseIntArg(name, arg string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil {
+		return 0, fmt.Errorf("'%s' must be an integer, instead got '%s'", name, arg)
+	}
+	return n, nil
+}
+
+// Contains returns true if substr is within s.
+func Contains(_ *transformctx.Ctx, s, substr string) (bool, error) {
+	return strings.Contains(s, substr), nil
+}
+
+// EndsWith returns true if s ends with suffix.
+func EndsWith(_ *transformctx.Ctx, s, suffix string) (bool, error) {
+	return strings.HasSuffix(s, suffix), nil
+}
+
+// Join concatenates a number of strings with sep placed in between.
+func Join(_ *transformctx.Ctx, sep string, strs ...string) (string, error) {
+	return strings.Join(strs, sep), nil
+}
+
+func pad(s, length, padding string, left bool) (string, error) {
+	n, err := parseIntArg("length", length)
+	if err != nil {
+		return "", err
+	}
+	if padding == "" {
+		return "", fmt.Errorf("'padding' must not be empty")
+	}
+	count := n - utf8.RuneCountInString(s)
+	if count <= 0 {
+		return s, nil
+	}
+	// padding can be multi-char, so build enough of it then cut by runes.
+	paddingRunes := []rune(strings.Repeat(padding, count/utf8.RuneCountInString(padding)+1))[:count]
+	if left {
+		return string(paddingRunes) + s, nil
+	}
+	return s + string(paddingRunes), nil
+}
+
+// PadLeft left-pads s with padding to length (in runes). s is returned as is if it's already long enough.
+func PadLeft(_ *transformctx.Ctx, s, length, padding string) (string, error) {
+	return pad(s, length, padding, true)
+}
+
+// PadRight right-pads s with padding to length (in runes). s is returned as is if it's already long enough.
+func PadRight(_ *transformctx.Ctx, s, length, padding string) (string, error) {
+	return pad(s, length, padding, false)
+}
+
+// RegexExtract returns the first match of the regex pattern in s, or "" if no match. If group is
+// specified, either by index or by name, then the capture group of the first match is returned instead.
+func RegexExtract(_ *transformctx.Ctx, s, pattern string, group ...string) (string, error) {
+	if len(group) > 1 {
+		return "", fmt.Errorf("at most one 'group' can be specified, instead got %d", len(group))
+	}
+	r, err := caches.GetRegex(pattern)
+	if err != nil {
+		return "", err
+	}
+	groupIndex := 0
+	if len(group) > 0 && group[0] != "" {
+		if groupIndex, err = strconv.Atoi(group[0]); err != nil {
+			groupIndex = r.SubexpIndex(group[0])
+		}
+		if groupIndex < 0 || groupIndex > r.NumSubexp() {
+			return "", fmt.Errorf("regex '%s' has no capture group '%s'", pattern, group[0])
+		}
+	}
+	matches := r.FindStringSubmatch(s)
+	if matches == nil {
+		return "", nil
+	}
+	return matches[groupIndex], nil
+}
+
+// RegexMatch returns true if s contains any match of the regex pattern.
+func RegexMatch(_ *transformctx.Ctx, s, pattern string) (bool, error) {
+	r, err := caches.GetRegex(pattern)
+	if err != nil {
+		return false, err
+	}
+	return r.MatchString(s), nil
+}
+
+// RegexReplace replaces all the matches of the regex pattern in s with repl, inside which '$1' or
+// '${name}' references the capture groups.
+func RegexReplace(_ *transformctx.Ctx, s, pattern, repl string) (string, error) {
+	r, err := caches.GetRegex(pattern)
+	if err != nil {
+		return "", err
+	}
+	return r.ReplaceAllString(s, repl), nil
+}
+
+// Repeat returns a string consisting of count copies of s.
+func Repeat(_ *transformctx.Ctx, s, count string) (string, error) {
+	n, err := parseIntArg("count", count)
+	if err != nil {
+		return "", err
+	}
+	if n < 0 {
+		return "", fmt.Errorf("'count' must not be negative, instead got %d", n)
+	}
+	return strings.Repeat(s, n), nil
+}
+
+// Replace replaces all the occurrences of old in s with new.
+func Replace(_ *transformctx.Ctx, s, old, new string) (string, error) {
+	return strings.ReplaceAll(s, old, new), nil
+}
+
+// Split splits s by sep into an array of strings. An empty s results in an empty array.
+func Split(_ *transformctx.Ctx, s, sep string) ([]string, error) {
+	if s == "" {
+		return []string{}, nil
+	}
+	return strings.Split(s, sep), nil
+}
+
+// Sprintf formats the args according to a golang fmt format.
+func Sprintf(_ *transformctx.Ctx, format string, args ...interface{}) (string, error) {
+	return fmt.Sprintf(format, args...), nil
+}
+
+// StartsWith returns true if s starts with prefix.
+func StartsWith(_ *transformctx.Ctx, s, prefix string) (bool, error) {
+	return strings.HasPrefix(s, prefix), nil
+}
+
+// Substring returns the part of s between the rune indexes start (inclusive) and end (exclusive). If end
+// is not specified, the rest of s from start is returned.
+func Substring(_ *transformctx.Ctx, s, start string, end ...string) (string, error) {
+	if len(end) > 1 {
+		return "", fmt.Errorf("at most one 'end' can be specified, instead got %d", len(end))
+	}
+	runes := []rune(s)
+	startIndex, err := parseIntArg("start", start)
+	if err != nil {
+		return "", err
+	}
+	endIndex := len(runes)
+	if len(end) > 0 && end[0] != "" {
+		if endIndex, err = parseIntArg("end", end[0]); err != nil {
+			return "", err
+		}
+	}
+	if startIndex < 0 || startIndex > endIndex || endIndex > len(runes) {
+		return "", fmt.Errorf(
+			"start %d and end %d are out of bounds for '%s' of %d runes", startIndex, endIndex, s, len(runes))
+	}
+	return string(runes[startIndex:endIndex]), nil
+}
+
+// Trim removes the leading and trailing whitespaces of s or, if cutset is specified, the leading
+// and trailing chars contained in cutset.
+func Trim(_ *transformctx.Ctx, s string, cutset ...string) (string, error) {
+	if len(cutset) == 0 {
+		return strings.TrimSpace(s), nil
+	}
+	return strings.Trim(s, strings.Join(cutset, "")), nil
+}
+
+// TrimLeft is similar to Trim, except it only removes the leading chars.
+func TrimLeft(_ *transformctx.Ctx, s string, cutset ...string) (string, error) {
+	if len(cutset) == 0 {
+		return strings.TrimLeftFunc(s, unicode.IsSpace), nil
+	}
+	return strings.TrimLeft(s, strings.Join(cutset, "")), nil
+}
+
+// TrimRight is similar to Trim, except it only removes the trailing chars.
+func TrimRight(_ *transformctx.Ctx, s string, cutset ...string) (string, error) {
+	if len(cutset) == 0 {
+		return strings.TrimRightFunc(s, unicode.IsSpace), nil
+	}
+	return strings.TrimRight(s, strings.Join(cutset, "")), nil
+}
+
+// TrimPrefix removes prefix from s, if s starts with it.
+func TrimPrefix(_ *transformctx.Ctx, s, prefix string) (string, error) {
+	return strings.TrimPrefix(s, prefix), nil
+}
+
+// TrimSuffix removes suffix from s, if s ends with it.
+func TrimSuffix(_ *transformctx.Ctx, s, suffix string) (string, error) {
+	return strings.TrimSuffix(s, suffix), nil
+}
@@ -0,0 +1,116 @@
+package customfuncs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstArgValidators(t *testing.T) {
+	for name := range ConstArgValidators {
+		_, found := CommonCustomFuncs[name]
+		assert.True(t, found, name)
+	}
+	validate := ConstArgValidators["regexMatch"]
+	assert.NoError(t, validate(0, "[not a regex but not the pattern arg"))
+	assert.NoError(t, validate(1, "^[a-z]+$"))
+	err := validate(1, "[a-z")
+	assert.Error(t, err)
+	assert.Equal(t, "error parsing regexp: missing closing ]: `[a-z`", err.Error())
+}
+
+func TestStringPredicates(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		fn       func() (bool, error)
+		expected bool
+	}{
+		{name: "contains - true", fn: func() (bool, error) { return Contains(nil, "abcde", "bcd") }, expected: true},
+		{name: "contains - false", fn: func() (bool, error) { return Contains(nil, "abcde", "x") }, expected: false},
+		{name: "endsWith - true", fn: func() (bool, error) { return EndsWith(nil, "abcde", "de") }, expected: true},
+		{name: "endsWith - false", fn: func() (bool, error) { return EndsWith(nil, "abcde", "cd") }, expected: false},
+		{name: "startsWith - true", fn: func() (bool, error) { return StartsWith(nil, "abcde", "ab") }, expected: true},
+		{name: "startsWith - false", fn: func() (bool, error) { return StartsWith(nil, "abcde", "b") }, expected: false},
+		{name: "regexMatch - true", fn: func() (bool, error) { return RegexMatch(nil, "ab12", `\d+`) }, expected: true},
+		{name: "regexMatch - false", fn: func() (bool, error) { return RegexMatch(nil, "abc", `^\d+$`) }, expected: false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := test.fn()
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, r)
+		})
+	}
+	r, err := RegexMatch(nil, "abc", "[")
+	assert.Error(t, err)
+	assert.Equal(t, "error parsing regexp: missing closing ]: `[`", err.Error())
+	assert.False(t, r)
+}
+
+func TestStringFuncs(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		fn       func() (string, error)
+		err      string
+		expected string
+	}{
+		{name: "join", fn: func() (string, error) { return Join(nil, ", ", "a", "b", "c") }, expected: "a, b, c"},
+		{name: "join - nothing", fn: func() (string, error) { return Join(nil, ",") }, expected: ""},
+		{name: "padLeft", fn: func() (string, error) { return PadLeft(nil, "7", "3", "0") }, expected: "007"},
+		{name: "padLeft - multi-char padding", fn: func() (string, error) { return PadLeft(nil, "x", "6", "ab") }, expected: "ababax"},
+		{name: "padLeft - long enough", fn: func() (string, error) { return PadLeft(nil, "1234", "3", "0") }, expected: "1234"},
+		{name: "padLeft - invalid length", fn: func() (string, error) { return PadLeft(nil, "1", "x", "0") }, err: "'length' must be an integer, instead got 'x'"},
+		{name: "padLeft - empty padding", fn: func() (string, error) { return PadLeft(nil, "1", "3", "") }, err: "'padding' must not be empty"},
+		{name: "padRight - runes", fn: func() (string, error) { return PadRight(nil, "日本", "4", "*") }, expected: "日本**"},
+		{name: "regexExtract - whole match", fn: func() (string, error) { return RegexExtract(nil, "order #123-45", `\d+-\d+`) }, expected: "123-45"},
+		{name: "regexExtract - group index", fn: func() (string, error) { return RegexExtract(nil, "order #123-45", `(\d+)-(\d+)`, "2") }, expected: "45"},
+		{name: "regexExtract - group name", fn: func() (string, error) { return RegexExtract(nil, "order #123-45", `(?P<id>\d+)-\d+`, "id") }, expected: "123"},
+		{name: "regexExtract - no match", fn: func() (string, error) { return RegexExtract(nil, "order", `\d+`) }, expected: ""},
+		{name: "regexExtract - unknown group", fn: func() (string, error) { return RegexExtract(nil, "1", `(\d)`, "x") }, err: `regex '(\d)' has no capture group 'x'`},
+		{name: "regexExtract - group index out of range", fn: func() (string, error) { return RegexExtract(nil, "1", `(\d)`, "2") }, err: `regex '(\d)' has no capture group '2'`},
+		{name: "regexExtract - too many groups", fn: func() (string, error) { return RegexExtract(nil, "1", `(\d)`, "1", "2") }, err: "at most one 'group' can be specified, instead got 2"},
+		{name: "regexExtract - invalid regex", fn: func() (string, error) { return RegexExtract(nil, "1", "(") }, err: "error parsing regexp: missing closing ): `(`"},
+		{name: "regexReplace", fn: func() (string, error) { return RegexReplace(nil, "2021-03-04", `(\d+)-(\d+)-(\d+)`, "$2/$3/$1") }, expected: "03/04/2021"},
+		{name: "regexReplace - invalid regex", fn: func() (string, error) { return RegexReplace(nil, "1", "(", "") }, err: "error parsing regexp: missing closing ): `(`"},
+		{name: "repeat", fn: func() (string, error) { return Repeat(nil, "ab", "3") }, expected: "ababab"},
+		{name: "repeat - negative count", fn: func() (string, error) { return Repeat(nil, "ab", "-1") }, err: "'count' must not be negative, instead got -1"},
+		{name: "repeat - invalid count", fn: func() (string, error) { return Repeat(nil, "ab", "") }, err: "'count' must be an integer, instead got ''"},
+		{name: "replace", fn: func() (string, error) { return Replace(nil, "a-b-c", "-", "+") }, expected: "a+b+c"},
+		{name: "sprintf", fn: func() (string, error) { return Sprintf(nil, "%s=%05.1f", "pi", 3.14159) }, expected: "pi=003.1"},
+		{name: "substring", fn: func() (string, error) { return Substring(nil, "héllo wörld", "1", "5") }, expected: "éllo"},
+		{name: "substring - to the end", fn: func() (string, error) { return Substring(nil, "héllo wörld", "6") }, expected: "wörld"},
+		{name: "substring - empty end", fn: func() (string, error) { return Substring(nil, "héllo", "5", "") }, expected: ""},
+		{name: "substring - out of bounds", fn: func() (string, error) { return Substring(nil, "héllo", "2", "6") }, err: "start 2 and end 6 are out of bounds for 'héllo' of 5 runes"},
+		{name: "substring - invalid start", fn: func() (string, error) { return Substring(nil, "héllo", "a") }, err: "'start' must be an integer, instead got 'a'"},
+		{name: "substring - invalid end", fn: func() (string, error) { return Substring(nil, "héllo", "1", "b") }, err: "'end' must be an integer, instead got 'b'"},
+		{name: "substring - too many ends", fn: func() (string, error) { return Substring(nil, "héllo", "1", "2", "3") }, err: "at most one 'end' can be specified, instead got 2"},
+		{name: "trim", fn: func() (string, error) { return Trim(nil, " \t abc \n") }, expected: "abc"},
+		{name: "trim - cutset", fn: func() (string, error) { return Trim(nil, "--abc-+", "-", "+") }, expected: "abc"},
+		{name: "trimLeft", fn: func() (string, error) { return TrimLeft(nil, " \t abc \n") }, expected: "abc \n"},
+		{name: "trimLeft - cutset", fn: func() (string, error) { return TrimLeft(nil, "00120", "0") }, expected: "120"},
+		{name: "trimRight", fn: func() (string, error) { return TrimRight(nil, " \t abc \n") }, expected: " \t abc"},
+		{name: "trimRight - cutset", fn: func() (string, error) { return TrimRight(nil, "1.500", "0") }, expected: "1.5"},
+		{name: "trimPrefix", fn: func() (string, error) { return TrimPrefix(nil, "ID-123", "ID-") }, expected: "123"},
+		{name: "trimSuffix", fn: func() (string, error) { return TrimSuffix(nil, "file.txt", ".txt") }, expected: "file"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := test.fn()
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Equal(t, "", r)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, r)
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	r, err := Split(nil, "a,b,,c", ",")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "", "c"}, r)
+	r, err = Split(nil, "", ",")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, r)
+}
@@ -0,0 +1,302 @@
+package omniparser
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"crypto/md5"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/jf-tech/omniparser/schemahandler"
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+// dedupDigest is what the dedup stage keeps of each record key, so that the memory it takes is bounded
+// by the number of keys, regardless of how long they are.
+type dedupDigest [md5.Size]byte
+
+type deduper struct {
+	key       transformctx.DedupKey
+	report    bool
+	capacity  int
+	digests   map[dedupDigest]*list.Element
+	lru       *list.List // the digests in memory, the most recently seen first.
+	spill     *dedupSpill
+	spillDir  string
+	duplicate int
+}
+
+func newDeduper(settings *transformctx.DedupSettings, ingester schemahandler.Ingester) (*deduper, error) {
+	d := &deduper{
+		key:      settings.Key,
+		report:   settings.ReportDuplicates,
+		capacity: settings.Capacity,
+		digests:  map[dedupDigest]*list.Element{},
+		lru:      list.New(),
+		spillDir: settings.SpillDir,
+	}
+	if d.key == "" {
+		d.key = transformctx.DedupByChecksum
+	}
+	switch d.key {
+	case transformctx.DedupByChecksum:
+	case transformctx.DedupBySchemaKey:
+		if _, ok := ingester.(schemahandler.RecordKeyer); !ok {
+			return nil, errors.New("dedup by schema key is not supported by the schema handler")
+		}
+	default:
+		return nil, errors.New("unknown dedup key '" + string(d.key) + "'")
+	}
+	if d.capacity < 0 {
+		return nil, errors.New("dedup capacity must not be negative")
+	}
+	if d.capacity == 0 {
+		d.capacity = transformctx.DefaultDedupCapacity
+	}
+	return d, nil
+}
+
+// isDuplicate records the key of a record just read and tells whether the record is a duplicate of any
+// record read earlier.
+func (d *deduper) isDuplicate(ingester schemahandler.Ingester, rawRecord schemahandler.RawRecord) (bool, error) {
+	var key string
+	if d.key == transformctx.DedupBySchemaKey {
+		var err error
+		key, err = ingester.(schemahandler.RecordKeyer).RecordKey()
+		if err != nil || key == "" {
+			return false, err
+		}
+	} else {
+		key = rawRecord.Checksum()
+	}
+	digest := dedupDigest(md5.Sum([]byte(key)))
+	if e, found := d.digests[digest]; found {
+		d.lru.MoveToFront(e)
+		d.duplicate++
+		return true, nil
+	}
+	if d.spill != nil {
+		found, err := d.spill.contains(digest)
+		if err != nil {
+			return false, err
+		}
+		if found {
+			d.duplicate++
+			return true, nil
+		}
+	}
+	d.digests[digest] = d.lru.PushFront(digest)
+	if len(d.digests) <= d.capacity {
+		return false, nil
+	}
+	if d.spillDir == "" {
+		oldest := d.lru.Back()
+		d.lru.Remove(oldest)
+		delete(d.digests, oldest.Value.(dedupDigest))
+		return false, nil
+	}
+	return false, d.spillAll()
+}
+
+// spillAll moves all the digests in memory into the spill files.
+func (d *deduper) spillAll() error {
+	if d.spill == nil {
+		d.spill = &dedupSpill{dir: d.spillDir}
+	}
+	digests := make([]dedupDigest, 0, len(d.digests))
+	for digest := range d.digests {
+		digests = append(digests, digest)
+	}
+	if err := d.spill.add(digests); err != nil {
+		return err
+	}
+	d.digests = map[dedupDigest]*list.Element{}
+	d.lru.Init()
+	return nil
+}
+
+func (d *deduper) close() {
+	if d.spill != nil {
+		d.spill.close()
+		d.spill = nil
+	}
+}
+
+// dedupSpill is a set of temp files, each a run of sorted digests looked up by binary search. Each spill
+// of the digests in memory is written as a new run, and only merged into the earlier runs once it grows
+// as large as the last of them, so that each digest is rewritten a logarithmic number of times, and the
+// number of runs to look up stays logarithmic too.
+type dedupSpill struct {
+	dir  string
+	runs []*dedupRun // the earlier, the larger.
+}
+
+type dedupRun struct {
+	f     *os.File
+	count int64
+}
+
+func (s *dedupSpill) count() int64 {
+	var count int64
+	for _, run := range s.runs {
+		count += run.count
+	}
+	return count
+}
+
+func (s *dedupSpill) contains(digest dedupDigest) (bool, error) {
+	// the most recently spilled digests are the most likely to be seen again.
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		found, err := s.runs[i].contains(digest)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// add writes the digests as a new run, then merges the last runs as long as the last is no smaller than
+// the one before it.
+func (s *dedupSpill) add(digests []dedupDigest) error {
+	sort.Slice(digests, func(i, j int) bool {
+		return bytes.Compare(digests[i][:], digests[j][:]) < 0
+	})
+	run, err := s.writeRun(func(w *bufio.Writer) error {
+		for _, digest := range digests {
+			if _, err := w.Write(digest[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	run.count = int64(len(digests))
+	s.runs = append(s.runs, run)
+	for n := len(s.runs); n >= 2 && s.runs[n-1].count >= s.runs[n-2].count; n = len(s.runs) {
+		merged, err := s.merge(s.runs[n-2], s.runs[n-1])
+		if err != nil {
+			return err
+		}
+		s.runs[n-2].close()
+		s.runs[n-1].close()
+		s.runs = append(s.runs[:n-2], merged)
+	}
+	return nil
+}
+
+// merge writes a new run with the digests of both runs, which are disjoint, merged.
+func (s *dedupSpill) merge(run1, run2 *dedupRun) (*dedupRun, error) {
+	r1, err := run1.reader()
+	if err != nil {
+		return nil, err
+	}
+	r2, err := run2.reader()
+	if err != nil {
+		return nil, err
+	}
+	merged, err := s.writeRun(func(w *bufio.Writer) error {
+		var d1, d2 dedupDigest
+		err1, err2 := r1(&d1), r2(&d2)
+		for {
+			switch {
+			case err1 != nil && err1 != io.EOF:
+				return err1
+			case err2 != nil && err2 != io.EOF:
+				return err2
+			case err1 == io.EOF && err2 == io.EOF:
+				return nil
+			}
+			var err error
+			if err2 != nil || (err1 == nil && bytes.Compare(d1[:], d2[:]) < 0) {
+				_, err = w.Write(d1[:])
+				err1 = r1(&d1)
+			} else {
+				_, err = w.Write(d2[:])
+				err2 = r2(&d2)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	merged.count = run1.count + run2.count
+	return merged, nil
+}
+
+// writeRun writes a new run into a temp file by write.
+func (s *dedupSpill) writeRun(write func(w *bufio.Writer) error) (run *dedupRun, err error) {
+	f, err := ioutil.TempFile(s.dir, "omniparser-dedup-")
+	if err != nil {
+		return nil, err
+	}
+	run = &dedupRun{f: f}
+	defer func() {
+		if err != nil {
+			run.close()
+			run = nil
+		}
+	}()
+	w := bufio.NewWriter(f)
+	if err = write(w); err != nil {
+		return run, err
+	}
+	return run, w.Flush()
+}
+
+func (s *dedupSpill) close() {
+	for _, run := range s.runs {
+		run.close()
+	}
+	s.runs = nil
+}
+
+func (r *dedupRun) contains(digest dedupDigest) (bool, error) {
+	var buf dedupDigest
+	lo, hi := int64(0), r.count
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if _, err := r.f.ReadAt(buf[:], mid*int64(len(buf))); err != nil {
+			return false, err
+		}
+		switch c := bytes.Compare(buf[:], digest[:]); {
+		case c == 0:
+			return true, nil
+		case c < 0:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return false, nil
+}
+
+// reader returns a func reading the digests of the run one by one, which returns io.EOF once all read.
+func (r *dedupRun) reader() (func(digest *dedupDigest) error, error) {
+	if _, err := r.f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(r.f)
+	remaining := r.count
+	return func(digest *dedupDigest) error {
+		if remaining == 0 {
+			return io.EOF
+		}
+		remaining--
+		_, err := io.ReadFull(br, digest[:])
+		return err
+	}, nil
+}
+
+func (r *dedupRun) close() {
+	_ = r.f.Close()
+	_ = os.Remove(r.f.Name())
+}
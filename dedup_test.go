@@ -0,0 +1,305 @@
+package omniparser
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jf-tech/omniparser/errs"
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+// testKeyedIngester keys each record by the first word of its result.
+type testKeyedIngester struct {
+	testIngester
+	keyErr error
+}
+
+func (g *testKeyedIngester) RecordKey() (string, error) {
+	if g.keyErr != nil {
+		return "", g.keyErr
+	}
+	result := string(g.readCalls[g.readCalled-1].result)
+	return strings.SplitN(result, " ", 2)[0], nil
+}
+
+func testDedupReadCalls(results ...string) []testReadCall {
+	var calls []testReadCall
+	for _, result := range results {
+		calls = append(calls, testReadCall{result: []byte(result)})
+	}
+	return append(calls, testReadCall{err: io.EOF})
+}
+
+func readAll(t *testing.T, tfm Transform) []string {
+	var records []string
+	for {
+		b, err := tfm.Read()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			assert.True(t, errs.IsErrTransformFailed(err))
+			records = append(records, "error: "+err.Error())
+			continue
+		}
+		records = append(records, string(b))
+	}
+}
+
+func TestTransform_Dedup(t *testing.T) {
+	results := []string{"a 1", "b 1", "a 1", "c 1", "a 2", "b 1", "d 1", "a 1"}
+	for _, test := range []struct {
+		name       string
+		settings   transformctx.DedupSettings
+		spill      bool
+		expected   []string
+		duplicates int
+	}{
+		{
+			name:       "by checksum",
+			settings:   transformctx.DedupSettings{},
+			expected:   []string{"a 1", "b 1", "c 1", "a 2", "d 1"},
+			duplicates: 3,
+		},
+		{
+			name:       "by schema key",
+			settings:   transformctx.DedupSettings{Key: transformctx.DedupBySchemaKey},
+			expected:   []string{"a 1", "b 1", "c 1", "d 1"},
+			duplicates: 4,
+		},
+		{
+			name:     "reported",
+			settings: transformctx.DedupSettings{ReportDuplicates: true},
+			expected: []string{
+				"a 1", "b 1", "error: ctx formatted: duplicate record",
+				"c 1", "a 2", "error: ctx formatted: duplicate record",
+				"d 1", "error: ctx formatted: duplicate record"},
+			duplicates: 3,
+		},
+		{
+			name:     "capacity reached, least recently seen keys forgotten",
+			settings: transformctx.DedupSettings{Capacity: 2},
+			// only the 2 most recently seen keys are kept, so only the 1st duplicate 'a 1' is detected.
+			expected:   []string{"a 1", "b 1", "c 1", "a 2", "b 1", "d 1", "a 1"},
+			duplicates: 1,
+		},
+		{
+			name:       "capacity reached, keys spilled",
+			settings:   transformctx.DedupSettings{Capacity: 2},
+			spill:      true,
+			expected:   []string{"a 1", "b 1", "c 1", "a 2", "d 1"},
+			duplicates: 3,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			settings := test.settings
+			if test.spill {
+				dir, err := ioutil.TempDir("", "dedup")
+				assert.NoError(t, err)
+				defer os.RemoveAll(dir)
+				settings.SpillDir = dir
+				defer func() {
+					// the spill file is removed at the end of the input.
+					files, err := ioutil.ReadDir(dir)
+					assert.NoError(t, err)
+					assert.Empty(t, files)
+				}()
+			}
+			ingester := &testKeyedIngester{testIngester: testIngester{readCalls: testDedupReadCalls(results...)}}
+			dedup, err := newDeduper(&settings, ingester)
+			assert.NoError(t, err)
+			tfm := &transform{ingester: ingester, dedup: dedup}
+			assert.Equal(t, test.expected, readAll(t, tfm))
+			assert.Equal(t, test.duplicates, tfm.Duplicates())
+		})
+	}
+}
+
+func TestTransform_Dedup_WriteRecordTo(t *testing.T) {
+	ingester := &testRecordWriterIngester{testIngester{readCalls: testDedupReadCalls("a", "a", "b")}}
+	dedup, err := newDeduper(&transformctx.DedupSettings{}, ingester)
+	assert.NoError(t, err)
+	tfm := &transform{ingester: ingester, dedup: dedup}
+	var w strings.Builder
+	for i := 0; i < 2; i++ {
+		_, err := tfm.WriteRecordTo(&w)
+		assert.NoError(t, err)
+	}
+	_, err = tfm.WriteRecordTo(&w)
+	assert.Equal(t, io.EOF, err)
+	// the records are read and deduped, before being written, instead of written by the ingester.
+	assert.Equal(t, "ab", w.String())
+	assert.Equal(t, 1, tfm.Duplicates())
+}
+
+func TestTransform_Dedup_Close(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ingester := &testIngester{readCalls: testDedupReadCalls("a", "b", "c", "d")}
+	dedup, err := newDeduper(&transformctx.DedupSettings{Capacity: 1, SpillDir: dir}, ingester)
+	assert.NoError(t, err)
+	tfm := &transform{ingester: ingester, dedup: dedup}
+	// stop reading before the end of the input, with the keys spilled.
+	for i := 0; i < 2; i++ {
+		_, err := tfm.Read()
+		assert.NoError(t, err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+	assert.NoError(t, tfm.Close())
+	files, err = ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+	b, err := tfm.Read()
+	assert.Equal(t, errTransformClosed, err)
+	assert.Nil(t, b)
+	// closing again, or after the end of the input, is a no-op.
+	assert.NoError(t, tfm.Close())
+	assert.Equal(t, errTransformClosed, tfm.lastErr)
+}
+
+func TestTransform_Dedup_RecordKeyFailure(t *testing.T) {
+	keyErr := errors.New("key failure")
+	ingester := &testKeyedIngester{
+		testIngester: testIngester{
+			readCalls:       testDedupReadCalls("a"),
+			continuableErrs: map[error]bool{keyErr: true},
+		},
+		keyErr: keyErr,
+	}
+	dedup, err := newDeduper(&transformctx.DedupSettings{Key: transformctx.DedupBySchemaKey}, ingester)
+	assert.NoError(t, err)
+	tfm := &transform{ingester: ingester, dedup: dedup}
+	assert.Equal(t, []string{"error: key failure"}, readAll(t, tfm))
+	assert.Equal(t, 0, tfm.Duplicates())
+}
+
+func TestNewDeduper_Failure(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		settings transformctx.DedupSettings
+		err      string
+	}{
+		{
+			name:     "schema key not supported",
+			settings: transformctx.DedupSettings{Key: transformctx.DedupBySchemaKey},
+			err:      "dedup by schema key is not supported by the schema handler",
+		},
+		{
+			name:     "unknown key",
+			settings: transformctx.DedupSettings{Key: "unknown"},
+			err:      "unknown dedup key 'unknown'",
+		},
+		{
+			name:     "negative capacity",
+			settings: transformctx.DedupSettings{Capacity: -1},
+			err:      "dedup capacity must not be negative",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := newDeduper(&test.settings, &testIngester{})
+			assert.Error(t, err)
+			assert.Equal(t, test.err, err.Error())
+			assert.Nil(t, d)
+		})
+	}
+}
+
+func TestDedupSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	s := &dedupSpill{dir: dir}
+	digest := func(b byte) dedupDigest { return dedupDigest{b} }
+	runCounts := func() []int64 {
+		var counts []int64
+		for _, run := range s.runs {
+			counts = append(counts, run.count)
+		}
+		files, err := ioutil.ReadDir(dir)
+		assert.NoError(t, err)
+		assert.Len(t, files, len(s.runs))
+		return counts
+	}
+	assert.NoError(t, s.add([]dedupDigest{digest(5), digest(1), digest(3)}))
+	assert.Equal(t, []int64{3}, runCounts())
+	assert.NoError(t, s.add([]dedupDigest{digest(9)}))
+	assert.Equal(t, []int64{3, 1}, runCounts())
+	// the last run is merged only once as large as the one before it.
+	assert.NoError(t, s.add([]dedupDigest{digest(0)}))
+	assert.Equal(t, []int64{3, 2}, runCounts())
+	assert.NoError(t, s.add([]dedupDigest{digest(7), digest(4)}))
+	assert.Equal(t, []int64{7}, runCounts())
+	assert.Equal(t, int64(7), s.count())
+	for b := byte(0); b < 10; b++ {
+		found, err := s.contains(digest(b))
+		assert.NoError(t, err)
+		assert.Equal(t, b != 2 && b != 6 && b != 8, found, "digest %d", b)
+	}
+	s.close()
+	assert.Empty(t, runCounts())
+}
+
+func TestSchema_NewTransform_Dedup(t *testing.T) {
+	s, err := NewSchema("test-schema", strings.NewReader(`{
+		"parser_settings": { "version": "omni.2.1", "file_format_type": "json" },
+		"transform_declarations": {
+			"FINAL_OUTPUT": { "xpath": "/*", "object": {
+				"id": { "xpath": "id" },
+				"name": { "xpath": "name" }
+			}},
+			"DEDUP_KEY": { "object": {
+				"id": { "xpath": "id", "type": "int" },
+				"type": { "xpath": "type" }
+			}}
+		}
+	}`))
+	assert.NoError(t, err)
+	input := `[
+		{ "id": 1, "type": "a", "name": "x" },
+		{ "id": 1, "type": "a", "name": "resent x" },
+		{ "id": 1, "type": "b", "name": "y" },
+		{ "id": "bad", "name": "z" }
+	]`
+	tfm, err := s.NewTransform("test-input", strings.NewReader(input), &transformctx.Ctx{
+		Dedup: &transformctx.DedupSettings{Key: transformctx.DedupBySchemaKey, ReportDuplicates: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t,
+		[]string{
+			`{"id":"1","name":"x"}`,
+			"error: input 'test-input' before/near line 4: duplicate record",
+			`{"id":"1","name":"y"}`,
+			"error: input 'test-input' before/near line 6: fail to transform. err: unable to convert value " +
+				`'bad' to type 'int' on 'DEDUP_KEY.id', err: strconv.ParseInt: parsing "bad": invalid syntax`,
+		},
+		readAll(t, tfm))
+	assert.Equal(t, 1, tfm.(DedupReporter).Duplicates())
+
+	tfm, err = s.NewTransform("test-input", strings.NewReader(input), &transformctx.Ctx{})
+	assert.NoError(t, err)
+	assert.Len(t, readAll(t, tfm), 4)
+	assert.Equal(t, 0, tfm.(DedupReporter).Duplicates())
+}
+
+func TestSchema_NewTransform_Dedup_NoSchemaKey(t *testing.T) {
+	s, err := NewSchema("test-schema", strings.NewReader(`{
+		"parser_settings": { "version": "omni.2.1", "file_format_type": "json" },
+		"transform_declarations": { "FINAL_OUTPUT": { "xpath": "/*" } }
+	}`))
+	assert.NoError(t, err)
+	tfm, err := s.NewTransform("test-input", strings.NewReader(`[]`), &transformctx.Ctx{
+		Dedup: &transformctx.DedupSettings{Key: transformctx.DedupBySchemaKey},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "schema 'test-schema' has no 'DEDUP_KEY' to dedup records by", err.Error())
+	assert.Nil(t, tfm)
+}
@@ -14,6 +14,25 @@ var OmniV21CustomFuncs = map[string]customfuncs.CustomFuncType{
 	"javascript_with_context": JavaScriptWithContext,
 }
 
+// OmniV21CustomFuncDocs contains the FuncDoc's of the custom funcs in OmniV21CustomFuncs.
+var OmniV21CustomFuncDocs = customfuncs.FuncDocs{
+	// keep these custom func docs lexically sorted
+	"copy": {
+		Description: "Copies the current IDR node and returns it as a JSON value.",
+		Example:     `copy()`,
+	},
+	"javascript": {
+		Description: "Runs a javascript with optional args given as name and value pairs.",
+		ArgNames:    []string{"js", "args"},
+		Example:     `javascript("a + b", "a", 1, "b", 2) -> 3`,
+	},
+	"javascript_with_context": {
+		Description: "Runs a javascript with optional args given as name and value pairs, and the current IDR node as JSON in '_node'.",
+		ArgNames:    []string{"js", "args"},
+		Example:     `javascript_with_context("JSON.parse(_node).id")`,
+	},
+}
+
 // CopyFunc copies the current contextual idr.Node and returns it as a JSON marshaling friendly interface{}.
 func CopyFunc(_ *transformctx.Ctx, n *idr.Node) (interface{}, error) {
 	return idr.J2NodeToInterface(n, true), nil
@@ -9,6 +9,7 @@ import (
 	"github.com/jf-tech/go-corelib/jsons"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/jf-tech/omniparser/customfuncs"
 	"github.com/jf-tech/omniparser/idr"
 )
 
@@ -21,6 +22,16 @@ func TestDumpOmniV21CustomFuncNames(t *testing.T) {
 	cupaloy.SnapshotT(t, jsons.BPM(names))
 }
 
+func TestOmniV21CustomFuncDocs(t *testing.T) {
+	assert.Equal(t, len(OmniV21CustomFuncs), len(OmniV21CustomFuncDocs))
+	for _, info := range customfuncs.Describe(OmniV21CustomFuncs, OmniV21CustomFuncDocs) {
+		doc, found := OmniV21CustomFuncDocs[info.Name]
+		assert.True(t, found, info.Name)
+		assert.NotEmpty(t, doc.Description, info.Name)
+		assert.Equal(t, len(info.Args), len(doc.ArgNames), info.Name)
+	}
+}
+
 func TestCopyFunc(t *testing.T) {
 	j := `{ "a": 1, "b": "2", "c": true, "d": null, "e": { "f": "three" }, "g": [ 1, "2", "three"] }`
 	r, err := idr.NewJSONStreamReader(strings.NewReader(j), ".")
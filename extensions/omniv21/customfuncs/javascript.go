@@ -2,17 +2,21 @@ package customfuncs
 
 import (
 	"fmt"
+	"math"
 	"sync"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/jf-tech/go-corelib/caches"
 
+	"github.com/jf-tech/omniparser/customfuncs"
 	"github.com/jf-tech/omniparser/idr"
 	"github.com/jf-tech/omniparser/transformctx"
 )
 
 const (
-	argNameNode = "_node"
+	argNameNode   = "_node"
+	jsLibraryName = "js_library"
 )
 
 // JSProgramCache caches *goja.Program. A *goja.Program is compiled javascript, and it can be used
@@ -70,13 +74,147 @@ func getNodeJSON(n *idr.Node) string {
 	return j.(string)
 }
 
-func execProgram(program *goja.Program, args map[string]interface{}) (goja.Value, error) {
+// JSSandbox specifies the limits and restrictions imposed on the javascript custom_funcs, which
+// matters when schemas come from untrusted sources.
+type JSSandbox struct {
+	// Disabled disables the javascript custom_funcs entirely: schemas using them fail to load.
+	Disabled bool
+	// Timeout is the max execution time of a single javascript custom_func call. 0 means no limit.
+	Timeout time.Duration
+	// MaxCallStackSize is the max javascript call stack depth. 0 means no limit.
+	MaxCallStackSize int
+	// RemovedGlobals lists the javascript global objects and functions (such as "eval") that are
+	// removed from the runtime before any javascript, including the library, is run.
+	RemovedGlobals []string
+}
+
+// JSLibrary is a piece of javascript code, such as a set of helper functions, that is compiled once
+// and preloaded into every *goja.Runtime used by the custom_funcs returned by its CustomFuncs, so
+// that javascript snippets in those custom_funcs can call into it. Optionally, all the javascript
+// executions are sandboxed.
+type JSLibrary struct {
+	program *goja.Program
+	sandbox *JSSandbox
+	// runtimePool is the counterpart of jsRuntimePool, except all the *goja.Runtime in it have
+	// the sandbox applied and the library preloaded.
+	runtimePool sync.Pool
+}
+
+// NewJSLibrary compiles a javascript library and test-runs it once within the optional sandbox.
+// Any compilation or runtime error is reported with line number.
+func NewJSLibrary(code string, sandbox *JSSandbox) (*JSLibrary, error) {
+	program, err := goja.Compile(jsLibraryName, code, false)
+	if err != nil {
+		return nil, err
+	}
+	lib := &JSLibrary{sandbox: sandbox}
+	if _, err = lib.runWithTimeout(lib.newRuntime(), program); err != nil {
+		return nil, err
+	}
+	lib.program = program
+	lib.runtimePool = sync.Pool{
+		New: func() interface{} {
+			return lib.newRuntime()
+		},
+	}
+	return lib, nil
+}
+
+func (l *JSLibrary) newRuntime() *goja.Runtime {
+	vm := goja.New()
+	if l == nil {
+		return vm
+	}
+	if l.sandbox != nil {
+		if l.sandbox.MaxCallStackSize > 0 {
+			vm.SetMaxCallStackSize(l.sandbox.MaxCallStackSize)
+		}
+		for _, global := range l.sandbox.RemovedGlobals {
+			_ = vm.GlobalObject().Delete(global)
+		}
+	}
+	if l.program != nil {
+		// NewJSLibrary has already verified the library runs successfully.
+		_, _ = vm.RunProgram(l.program)
+	}
+	return vm
+}
+
+func (l *JSLibrary) pool() *sync.Pool {
+	if l == nil {
+		return &jsRuntimePool
+	}
+	return &l.runtimePool
+}
+
+func (l *JSLibrary) timeout() time.Duration {
+	if l == nil || l.sandbox == nil {
+		return 0
+	}
+	return l.sandbox.Timeout
+}
+
+func (l *JSLibrary) maxCallStackSize() int {
+	if l == nil || l.sandbox == nil || l.sandbox.MaxCallStackSize <= 0 {
+		// goja's default.
+		return math.MaxInt32
+	}
+	return l.sandbox.MaxCallStackSize
+}
+
+func (l *JSLibrary) runWithTimeout(vm *goja.Runtime, program *goja.Program) (goja.Value, error) {
+	timeout := l.timeout()
+	if timeout <= 0 {
+		return l.checkErr(vm.RunProgram(program))
+	}
+	interrupted := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() {
+		vm.Interrupt(nil)
+		close(interrupted)
+	})
+	v, err := vm.RunProgram(program)
+	if !timer.Stop() {
+		// make sure the interrupt has landed before clearing it, so that the runtime can be
+		// safely reused.
+		<-interrupted
+		vm.ClearInterrupt()
+	}
+	if _, ok := err.(*goja.InterruptedError); ok {
+		return nil, fmt.Errorf("javascript execution timed out after %s", timeout)
+	}
+	return l.checkErr(v, err)
+}
+
+func (l *JSLibrary) checkErr(v goja.Value, err error) (goja.Value, error) {
+	if _, ok := err.(*goja.StackOverflowError); ok {
+		// goja's StackOverflowError has no message.
+		return nil, fmt.Errorf("javascript max call stack size %d exceeded", l.maxCallStackSize())
+	}
+	return v, err
+}
+
+// CustomFuncs returns the 'javascript' and 'javascript_with_context' custom_funcs with the library
+// preloaded, which are meant to replace the default ones in OmniV21CustomFuncs.
+func (l *JSLibrary) CustomFuncs() customfuncs.CustomFuncs {
+	return customfuncs.CustomFuncs{
+		"javascript": func(_ *transformctx.Ctx, js string, args ...interface{}) (interface{}, error) {
+			return l.javaScriptWithContext(nil, js, args...)
+		},
+		"javascript_with_context": func(
+			_ *transformctx.Ctx, n *idr.Node, js string, args ...interface{}) (interface{}, error) {
+			return l.javaScriptWithContext(n, js, args...)
+		},
+	}
+}
+
+func execProgram(lib *JSLibrary, program *goja.Program, args map[string]interface{}) (goja.Value, error) {
 	var vm *goja.Runtime
 	var poolObj interface{}
+	pool := lib.pool()
 	if disableCaching {
-		vm = goja.New()
+		vm = lib.newRuntime()
 	} else {
-		poolObj = jsRuntimePool.Get()
+		poolObj = pool.Get()
 		vm = poolObj.(*goja.Runtime)
 	}
 	defer func() {
@@ -87,18 +225,22 @@ func execProgram(program *goja.Program, args map[string]interface{}) (goja.Value
 			}
 		}
 		if poolObj != nil {
-			jsRuntimePool.Put(poolObj)
+			pool.Put(poolObj)
 		}
 	}()
 	for arg, val := range args {
 		vm.Set(arg, val)
 	}
-	return vm.RunProgram(program)
+	return lib.runWithTimeout(vm, program)
 }
 
 // JavaScriptWithContext is a custom_func that runs a javascript with optional arguments and
 // with contextual '_node' JSON, if idr.Node is provided.
 func JavaScriptWithContext(_ *transformctx.Ctx, n *idr.Node, js string, args ...interface{}) (interface{}, error) {
+	return (*JSLibrary)(nil).javaScriptWithContext(n, js, args...)
+}
+
+func (l *JSLibrary) javaScriptWithContext(n *idr.Node, js string, args ...interface{}) (interface{}, error) {
 	if len(args)%2 != 0 {
 		return nil, fmt.Errorf("number of args must be even, but got %d", len(args))
 	}
@@ -113,7 +255,7 @@ func JavaScriptWithContext(_ *transformctx.Ctx, n *idr.Node, js string, args ...
 	if n != nil {
 		vmArgs[argNameNode] = getNodeJSON(n)
 	}
-	v, err := execProgram(program, vmArgs)
+	v, err := execProgram(l, program, vmArgs)
 	if err != nil {
 		return nil, err
 	}
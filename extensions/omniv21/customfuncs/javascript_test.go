@@ -4,10 +4,13 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/dop251/goja"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/jf-tech/omniparser/idr"
+	"github.com/jf-tech/omniparser/transformctx"
 )
 
 const (
@@ -226,10 +229,10 @@ func concurrentBenchmarkJavaScript(b *testing.B) {
 					"title", benchTitles[index%len(benchTitles)],
 					"name", benchNames[index%len(benchNames)])
 				if err != nil {
-					b.FailNow()
+					b.Fail()
 				}
 				if ret != benchResults[index%len(benchResults)] {
-					b.FailNow()
+					b.Fail()
 				}
 			}()
 		}
@@ -246,3 +249,145 @@ func BenchmarkConcurrentJavaScriptWithCache(b *testing.B) {
 	prepCachesForTest(withCache)
 	concurrentBenchmarkJavaScript(b)
 }
+
+// the signatures of the javascript custom funcs returned by JSLibrary.CustomFuncs.
+type (
+	testJSFunc        = func(*transformctx.Ctx, string, ...interface{}) (interface{}, error)
+	testJSWithCtxFunc = func(*transformctx.Ctx, *idr.Node, string, ...interface{}) (interface{}, error)
+)
+
+func TestNewJSLibrary(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		code string
+		err  string
+	}{
+		{
+			name: "success",
+			code: "function greet(name) { return 'hello ' + name; }",
+			err:  "",
+		},
+		{
+			name: "syntax error",
+			code: "function greet(name) {\n  return 'hello ' + ;\n}",
+			err:  "SyntaxError: js_library: Line 2:21 Unexpected token ; (and 1 more errors)",
+		},
+		{
+			name: "runtime error",
+			code: "function greet(name) { return name; }\nundefinedFunc();",
+			err:  "ReferenceError: undefinedFunc is not defined at js_library:2:14(3)",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			lib, err := NewJSLibrary(test.code, nil)
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Nil(t, lib)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, lib)
+			}
+		})
+	}
+}
+
+func TestJSLibrary_CustomFuncs(t *testing.T) {
+	sp, err := idr.NewJSONStreamReader(strings.NewReader(`{"name": "john"}`), ".")
+	assert.NoError(t, err)
+	testNode, err := sp.Read()
+	assert.NoError(t, err)
+	lib, err := NewJSLibrary(`
+		var greeting = 'hello';
+		function greet(name) { return greeting + ' ' + name; }`, nil)
+	assert.NoError(t, err)
+	for _, cache := range []bool{noCache, withCache} {
+		prepCachesForTest(cache)
+		fns := lib.CustomFuncs()
+		js := fns["javascript"].(testJSFunc)
+		r, err := js(nil, "greet(name)", "name", "jane")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello jane", r)
+		jsWithCtx := fns["javascript_with_context"].(testJSWithCtxFunc)
+		r, err = jsWithCtx(nil, testNode, "greet(JSON.parse(_node).name)")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello john", r)
+		// the default javascript custom_func doesn't have the library preloaded.
+		r, err = JavaScript(nil, "greet(name)", "name", "jane")
+		assert.Error(t, err)
+		assert.Equal(t, "ReferenceError: greet is not defined at <eval>:1:6(2)", err.Error())
+		assert.Nil(t, r)
+	}
+}
+
+func TestJSLibrary_Sandbox(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		sandbox *JSSandbox
+		js      string
+		err     string
+	}{
+		{
+			name:    "within timeout",
+			sandbox: &JSSandbox{Timeout: time.Minute},
+			js:      "1+2",
+			err:     "",
+		},
+		{
+			name:    "timed out",
+			sandbox: &JSSandbox{Timeout: 50 * time.Millisecond},
+			js:      "while (true) {}",
+			err:     "javascript execution timed out after 50ms",
+		},
+		{
+			name:    "max call stack size exceeded",
+			sandbox: &JSSandbox{MaxCallStackSize: 100},
+			js:      "function f(n) { return n <= 0 ? 0 : 1 + f(n-1); } f(1000)",
+			err:     "javascript max call stack size 100 exceeded",
+		},
+		{
+			name:    "global removed",
+			sandbox: &JSSandbox{RemovedGlobals: []string{"eval"}},
+			js:      "eval('1+2')",
+			err:     "ReferenceError: eval is not defined at <eval>:1:5(2)",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			for _, cache := range []bool{noCache, withCache} {
+				prepCachesForTest(cache)
+				lib, err := NewJSLibrary("", test.sandbox)
+				assert.NoError(t, err)
+				js := lib.CustomFuncs()["javascript"].(testJSFunc)
+				v, err := js(nil, test.js)
+				if test.err != "" {
+					assert.Error(t, err)
+					assert.Equal(t, test.err, err.Error())
+					assert.Nil(t, v)
+				} else {
+					assert.NoError(t, err)
+					assert.Equal(t, int64(3), v)
+				}
+				// the runtime must still be usable after a sandbox violation.
+				v, err = js(nil, "1+2")
+				assert.NoError(t, err)
+				assert.Equal(t, int64(3), v)
+			}
+		})
+	}
+}
+
+func TestJSLibrary_CheckErr_StackOverflowWithoutLimit(t *testing.T) {
+	for _, lib := range []*JSLibrary{nil, {}, {sandbox: &JSSandbox{}}} {
+		v, err := lib.checkErr(nil, &goja.StackOverflowError{})
+		assert.Error(t, err)
+		assert.Equal(t, "javascript max call stack size 2147483647 exceeded", err.Error())
+		assert.Nil(t, v)
+	}
+}
+
+func TestNewJSLibrary_SandboxTimeout(t *testing.T) {
+	lib, err := NewJSLibrary("while (true) {}", &JSSandbox{Timeout: 50 * time.Millisecond})
+	assert.Error(t, err)
+	assert.Equal(t, "javascript execution timed out after 50ms", err.Error())
+	assert.Nil(t, lib)
+}
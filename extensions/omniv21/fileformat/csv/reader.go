@@ -36,6 +36,7 @@ type reader struct {
 	xpath         *xpath.Expr
 	r             *ios.LineNumReportingCsvReader
 	headerChecked bool
+	recordLine    int // 1-based line number where the last read record starts.
 }
 
 func (r *reader) Read() (*idr.Node, error) {
@@ -47,6 +48,7 @@ func (r *reader) Read() (*idr.Node, error) {
 		}
 	}
 read:
+	r.recordLine = r.r.LineNum() + 1
 	record, err := r.r.Read()
 	if err == io.EOF {
 		return nil, io.EOF
@@ -125,6 +127,11 @@ func (r *reader) recordToNode(record []string) *idr.Node {
 	return root
 }
 
+// RecordLineStart implements fileformat.RecordLineReporter interface.
+func (r *reader) RecordLineStart() int {
+	return r.recordLine
+}
+
 func (r *reader) Release(n *idr.Node) {
 	if n != nil {
 		idr.RemoveAndReleaseTree(n)
@@ -172,6 +172,20 @@ func TestReader(t *testing.T) {
 	}
 }
 
+func TestReader_RecordLineStart(t *testing.T) {
+	r, err := NewReader("test-input",
+		strings.NewReader(lf("a,b")+lf("1,2")+lf(`"multi`)+lf(`line",3`)+lf("4,5")),
+		&FileDecl{Delimiter: ",", HeaderRowIndex: testlib.IntPtr(1), DataRowIndex: 2, Columns: []Column{{Name: "a"}, {Name: "b"}}},
+		"")
+	assert.NoError(t, err)
+	for _, expected := range []int{2, 3, 5} {
+		n, err := r.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, r.RecordLineStart())
+		r.Release(n)
+	}
+}
+
 func TestIsContinuableError(t *testing.T) {
 	r := &reader{}
 	assert.True(t, r.IsContinuableError(errors.New("some error")))
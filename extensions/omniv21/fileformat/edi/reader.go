@@ -34,8 +34,12 @@ type ediReader struct {
 	r                 *NonValidatingReader
 	stack             []stackEntry
 	target            *idr.Node
+	targetLine        int // the line where the current target starts.
 	targetXPath       *xpath.Expr
 	unprocessedRawSeg RawSeg
+	limits            idr.Limits
+	recordNodes       int   // the number of nodes created for the current record.
+	recordBytes       int64 // the number of input bytes read for the current record.
 }
 
 func inRange(i, lowerBoundInclusive, upperBoundInclusive int) bool {
@@ -95,6 +99,7 @@ func (r *ediReader) getUnprocessedRawSeg() (RawSeg, error) {
 	}
 	r.unprocessedRawSeg = rawSeg
 	r.unprocessedRawSeg.valid = true
+	r.recordBytes += int64(len(rawSeg.Raw))
 	return r.unprocessedRawSeg, nil
 }
 
@@ -103,6 +108,7 @@ func (r *ediReader) rawSegToNode(segDecl *SegDecl) (*idr.Node, error) {
 		panic("unprocessedRawSeg is not valid")
 	}
 	n := idr.CreateNode(idr.ElementNode, segDecl.Name)
+	r.recordNodes++
 	for _, elemDecl := range segDecl.Elems {
 		found := false
 		for _, rawElem := range r.unprocessedRawSeg.Elems {
@@ -112,6 +118,7 @@ func (r *ediReader) rawSegToNode(segDecl *SegDecl) (*idr.Node, error) {
 				data := string(strs.ByteUnescape(rawElem.Data, r.releaseChar.b, true))
 				elemV := idr.CreateNode(idr.TextNode, data)
 				idr.AddChild(elemN, elemV)
+				r.recordNodes += 2
 				found = true
 			}
 		}
@@ -127,6 +134,7 @@ func (r *ediReader) rawSegToNode(segDecl *SegDecl) (*idr.Node, error) {
 			}
 			elemV := idr.CreateNode(idr.TextNode, data)
 			idr.AddChild(elemN, elemV)
+			r.recordNodes += 2
 			continue
 		}
 		return nil, ErrInvalidEDI(
@@ -209,7 +217,15 @@ func (r *ediReader) Read() (*idr.Node, error) {
 		idr.RemoveAndReleaseTree(r.target)
 		r.target = nil
 	}
+	r.recordNodes, r.recordBytes = 0, 0
+	if r.unprocessedRawSeg.valid {
+		// the segment read but not processed by the last Read belongs to this record.
+		r.recordBytes = int64(len(r.unprocessedRawSeg.Raw))
+	}
 	for {
+		if err := r.checkLimits(); err != nil {
+			return nil, err
+		}
 		if r.target != nil {
 			return r.target, nil
 		}
@@ -248,6 +264,10 @@ func (r *ediReader) Read() (*idr.Node, error) {
 			}
 			continue
 		}
+		if cur.segDecl.IsTarget {
+			// the segment unprocessed so far is the first one of the target.
+			r.targetLine = r.r.SegLine()
+		}
 		if !cur.segDecl.isGroup() {
 			cur.segNode, err = r.rawSegToNode(cur.segDecl)
 			if err != nil {
@@ -256,6 +276,7 @@ func (r *ediReader) Read() (*idr.Node, error) {
 			r.resetRawSeg()
 		} else {
 			cur.segNode = idr.CreateNode(idr.ElementNode, cur.segDecl.Name)
+			r.recordNodes++
 		}
 		if len(r.stack) > 1 {
 			idr.AddChild(r.stackTop(1).segNode, cur.segNode)
@@ -268,6 +289,23 @@ func (r *ediReader) Read() (*idr.Node, error) {
 	}
 }
 
+func (r *ediReader) checkLimits() error {
+	err := r.limits.CheckNodesPerRecord(r.recordNodes)
+	if err == nil {
+		err = r.limits.CheckRecordBytes(r.recordBytes)
+	}
+	if err != nil {
+		return ErrInvalidEDI(r.fmtErrStr(err.Error()))
+	}
+	return nil
+}
+
+// SetLimits implements fileformat.LimitsEnforcer interface. It must be called before the first Read.
+func (r *ediReader) SetLimits(limits idr.Limits) {
+	r.limits = limits
+	r.r.SetMaxSegmentLength(limits.MaxLineLength)
+}
+
 func (r *ediReader) Release(n *idr.Node) {
 	if r.target == n {
 		r.target = nil
@@ -275,6 +313,11 @@ func (r *ediReader) Release(n *idr.Node) {
 	idr.RemoveAndReleaseTree(n)
 }
 
+// RecordLineStart implements fileformat.RecordLineReporter interface.
+func (r *ediReader) RecordLineStart() int {
+	return r.targetLine
+}
+
 func (r *ediReader) IsContinuableError(err error) bool {
 	return !IsErrInvalidEDI(err) && err != io.EOF
 }
@@ -9,6 +9,8 @@ import (
 
 	"github.com/jf-tech/go-corelib/ios"
 	"github.com/jf-tech/go-corelib/strs"
+
+	"github.com/jf-tech/omniparser/idr"
 )
 
 // ErrInvalidEDI indicates the EDI content is corrupted. This is a fatal, non-continuable error.
@@ -118,6 +120,11 @@ type NonValidatingReader struct {
 	runeBegin, runeEnd int
 	segCount           int
 	rawSeg             RawSeg
+	maxSegLen          int                // 0 means unlimited.
+	lfStripper         *lfStrippingReader // only set if CR/LF are ignored.
+	offset             int64              // the (CR/LF stripped) input offset of the next token.
+	lfCount            int                // the number of LFs in the tokens scanned so far.
+	segLine            int                // the line where the current segment starts.
 }
 
 // Read returns a raw segment of an EDI document. Note all the []byte are not a copy, so READONLY,
@@ -131,10 +138,19 @@ func (r *NonValidatingReader) Read() (RawSeg, error) {
 		count, onlyCRLF := runeCountAndHasOnlyCRLF(b)
 		r.runeBegin = r.runeEnd
 		r.runeEnd += count
+		start := r.offset
+		r.offset += int64(len(b))
 		if onlyCRLF {
+			r.lfCount += bytes.Count(b, lfBytes)
 			continue
 		}
 		token = b
+		leadingCRLF := len(b) - len(bytes.TrimLeft(b, "\r\n"))
+		r.segLine = 1 + r.lfCount + bytes.Count(b[:leadingCRLF], lfBytes)
+		if r.lfStripper != nil {
+			r.segLine += r.lfStripper.lfsUpTo(start)
+		}
+		r.lfCount += bytes.Count(b, lfBytes)
 		break
 	}
 	r.segCount++
@@ -144,12 +160,18 @@ func (r *NonValidatingReader) Read() (RawSeg, error) {
 	//    on Scan() and Err() returns nil). We need to return EOF, OR
 	// 3. r.scanner.Scan() returns false Err() returns err, need to return the `err` wrapped.
 	err := r.scanner.Err()
+	if err == bufio.ErrTooLong && r.maxSegLen > 0 {
+		return RawSeg{}, r.segTooLongErr()
+	}
 	if err != nil {
 		return RawSeg{}, ErrInvalidEDI(fmt.Sprintf("cannot read segment, err: %s", err.Error()))
 	}
 	if token == nil {
 		return RawSeg{}, io.EOF
 	}
+	if r.maxSegLen > 0 && len(token)-len(r.segDelim.b) > r.maxSegLen {
+		return RawSeg{}, r.segTooLongErr()
+	}
 	if err = r.readToken(token, &r.rawSeg); err != nil {
 		return RawSeg{}, err
 	}
@@ -211,6 +233,21 @@ func (r *NonValidatingReader) readToken(token []byte, rawSeg *RawSeg) error {
 	return nil
 }
 
+// SetMaxSegmentLength sets the max length in bytes of a segment (excluding the segment delimiter) the
+// reader accepts; 0 means unlimited, in which case segments are capped at bufio.MaxScanTokenSize. It must
+// be called before the first Read.
+func (r *NonValidatingReader) SetMaxSegmentLength(maxSegLen int) {
+	r.maxSegLen = maxSegLen
+	if maxSegLen > 0 {
+		// the scanner fails with bufio.ErrTooLong, instead of buffering up a segment way too long.
+		r.scanner.Buffer(make([]byte, ReaderBufSize), maxSegLen+len(r.segDelim.b))
+	}
+}
+
+func (r *NonValidatingReader) segTooLongErr() error {
+	return idr.LimitExceeded("segment is longer than %d bytes", r.maxSegLen)
+}
+
 // RuneBegin returns the current reader's beginning rune position.
 func (r *NonValidatingReader) RuneBegin() int {
 	return r.runeBegin
@@ -226,6 +263,52 @@ func (r *NonValidatingReader) SegCount() int {
 	return r.segCount
 }
 
+// SegLine returns the 1-based line number of the input where the current segment starts.
+func (r *NonValidatingReader) SegLine() int {
+	return r.segLine
+}
+
+// lfStrippingReader removes all the LFs from the input, while remembering where they were removed, so
+// that the line numbers of the segments can still be found.
+type lfStrippingReader struct {
+	r       io.Reader
+	n       int64   // the number of bytes returned so far.
+	lfs     []int64 // the offsets, in the stripped output, of the LFs removed but not yet counted.
+	counted int     // the number of LFs counted by lfsUpTo.
+}
+
+func (s *lfStrippingReader) Read(p []byte) (int, error) {
+	for {
+		n, err := s.r.Read(p)
+		w := 0
+		for i := 0; i < n; i++ {
+			if p[i] == '\n' {
+				s.lfs = append(s.lfs, s.n+int64(w))
+				continue
+			}
+			p[w] = p[i]
+			w++
+		}
+		s.n += int64(w)
+		// don't return nothing read without an error, which bufio.Scanner only tolerates a few times in
+		// a row, for a chunk of input full of LFs.
+		if w > 0 || err != nil {
+			return w, err
+		}
+	}
+}
+
+// lfsUpTo returns the number of LFs removed before the stripped output offset, which must be no less
+// than the offsets passed in before.
+func (s *lfStrippingReader) lfsUpTo(offset int64) int {
+	counted := 0
+	for ; counted < len(s.lfs) && s.lfs[counted] <= offset; counted++ {
+	}
+	s.counted += counted
+	s.lfs = s.lfs[counted:]
+	return s.counted
+}
+
 // NewNonValidatingReader creates an instance of NonValidatingReader.
 func NewNonValidatingReader(r io.Reader, decl *FileDecl) *NonValidatingReader {
 	segDelim := newStrPtrByte(&decl.SegDelim)
@@ -233,12 +316,15 @@ func NewNonValidatingReader(r io.Reader, decl *FileDecl) *NonValidatingReader {
 	compDelim := newStrPtrByte(decl.CompDelim)
 	repDelim := newStrPtrByte(decl.RepDelim)
 	releaseChar := newStrPtrByte(decl.ReleaseChar)
+	var lfStripper *lfStrippingReader
 	if decl.IgnoreCRLF {
 		r = ios.NewBytesReplacingReader(r, crBytes, nil)
-		r = ios.NewBytesReplacingReader(r, lfBytes, nil)
+		lfStripper = &lfStrippingReader{r: r}
+		r = lfStripper
 	}
 	scanner := ios.NewScannerByDelim3(r, segDelim.b, releaseChar.b, scannerFlags, make([]byte, ReaderBufSize))
 	return &NonValidatingReader{
+		lfStripper:  lfStripper,
 		scanner:     scanner,
 		segDelim:    segDelim,
 		elemDelim:   elemDelim,
@@ -3,6 +3,7 @@ package edi
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
@@ -961,6 +962,161 @@ func TestRead(t *testing.T) {
 	}
 }
 
+func TestRead_Limits(t *testing.T) {
+	var decl FileDecl
+	err := json.Unmarshal([]byte(`
+		{
+			"segment_delimiter": "\n",
+			"element_delimiter": "*",
+			"segment_declarations": [
+				{
+					"name": "ISA",
+					"is_target": true,
+					"max": -1,
+					"elements": [
+						{ "name": "e1", "index": 1 },
+						{ "name": "e2", "index": 2 },
+						{ "name": "e3", "index": 3 }
+					]
+				}
+			]
+		}`), &decl)
+	assert.NoError(t, err)
+	for _, test := range []struct {
+		name   string
+		input  string
+		limits idr.Limits
+		count  int
+		err    string
+	}{
+		{
+			name:   "no limits",
+			input:  "ISA*0*1*2\nISA*3*4*567\n",
+			limits: idr.Limits{},
+			count:  2,
+		},
+		{
+			name:   "within limits",
+			input:  "ISA*0*1*2\nISA*3*4*567\n",
+			limits: idr.Limits{MaxNodesPerRecord: 7, MaxRecordBytes: 12, MaxLineLength: 11},
+			count:  2,
+		},
+		{
+			name:   "segment too long",
+			input:  "ISA*0*1*2\nISA*3*4*567\n",
+			limits: idr.Limits{MaxLineLength: 10},
+			count:  1,
+			err:    "input 'test' at segment no.2 (char[11,23]): limit exceeded: segment is longer than 10 bytes",
+		},
+		{
+			name:   "segment too long for the scanner buffer",
+			input:  "ISA*0*1*" + strings.Repeat("2", ReaderBufSize*4) + "\n",
+			limits: idr.Limits{MaxLineLength: ReaderBufSize * 2},
+			count:  0,
+			err:    "input 'test' at segment no.1 (char[1,1]): limit exceeded: segment is longer than 256 bytes",
+		},
+		{
+			name:   "too many nodes",
+			input:  "ISA*0*1*2\nISA*3*4*567\n",
+			limits: idr.Limits{MaxNodesPerRecord: 6},
+			count:  0,
+			err:    "input 'test' at segment no.1 (char[1,11]): limit exceeded: record has more than 6 nodes",
+		},
+		{
+			name:   "too many bytes",
+			input:  "ISA*0*1*2\nISA*3*4*567\n",
+			limits: idr.Limits{MaxRecordBytes: 11},
+			count:  1,
+			err:    "input 'test' at segment no.2 (char[11,23]): limit exceeded: record is larger than 11 bytes",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := NewReader("test", strings.NewReader(test.input), &decl, "")
+			assert.NoError(t, err)
+			reader.SetLimits(test.limits)
+			count := 0
+			for {
+				n, err := reader.Read()
+				if err == io.EOF {
+					assert.Equal(t, "", test.err)
+					break
+				}
+				if err != nil {
+					assert.Equal(t, test.err, err.Error())
+					assert.True(t, IsErrInvalidEDI(err))
+					assert.False(t, reader.IsContinuableError(err))
+					break
+				}
+				count++
+				reader.Release(n)
+			}
+			assert.Equal(t, test.count, count)
+		})
+	}
+}
+
+func TestRead_RecordLineStart(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		segDelim   string
+		ignoreCRLF bool
+		input      string
+		lines      []int
+	}{
+		{
+			name:     "lf as segment delimiter",
+			segDelim: "\\n",
+			input:    "ISA*1\nIEA\n\nISA*2\r\nIEA\r\n\r\n\r\nISA*3\nIEA\n\n",
+			lines:    []int{1, 4, 8},
+		},
+		{
+			name:       "crlf ignored",
+			segDelim:   "~",
+			ignoreCRLF: true,
+			input:      "ISA*1~\nIEA~\nISA\n*2~\n\nIEA~\r\nISA*3~IEA~\n",
+			lines:      []int{1, 3, 7},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var decl FileDecl
+			err := json.Unmarshal([]byte(fmt.Sprintf(`
+				{
+					"segment_delimiter": "%s",
+					"element_delimiter": "*",
+					"ignore_crlf": %t,
+					"segment_declarations": [
+						{
+							"name": "group1",
+							"type": "segment_group",
+							"is_target": true,
+							"max": -1,
+							"child_segments": [
+								{ "name": "ISA", "elements": [ { "name": "e1", "index": 1 } ] },
+								{ "name": "IEA" }
+							]
+						}
+					]
+				}`, test.segDelim, test.ignoreCRLF)), &decl)
+			assert.NoError(t, err)
+			reader, err := NewReader("test", strings.NewReader(test.input), &decl, "")
+			assert.NoError(t, err)
+			var lines []int
+			for {
+				n, err := reader.Read()
+				if err == io.EOF {
+					break
+				}
+				if !assert.NoError(t, err) {
+					break
+				}
+				lines = append(lines, reader.RecordLineStart())
+				reader.Release(n)
+			}
+			assert.Equal(t, test.lines, lines)
+		})
+	}
+}
+
 func TestRelease(t *testing.T) {
 	var decl FileDecl
 	err := json.Unmarshal([]byte(`
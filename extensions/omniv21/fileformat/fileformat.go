@@ -39,3 +39,17 @@ type FormatReader interface {
 	// file name and (approx.) error location, such as line number)
 	errs.CtxAwareErr
 }
+
+// RecordLineReporter is an optional interface a FormatReader can implement to report where in the input
+// the record last returned by its Read() starts, which is then available to the 'meta' decl 'line_start'.
+type RecordLineReporter interface {
+	// RecordLineStart returns the 1-based line number of the input where the last read record starts.
+	RecordLineStart() int
+}
+
+// LimitsEnforcer is an optional interface a FormatReader can implement to guard against hostile inputs by
+// enforcing the idr.Limits on the input it reads. If a limit is exceeded, Read returns a fatal error.
+type LimitsEnforcer interface {
+	// SetLimits sets the limits to enforce. It's called before the first Read.
+	SetLimits(limits idr.Limits)
+}
@@ -38,6 +38,7 @@ type reader struct {
 	target        *idr.Node
 	envelopeIndex int
 	line          int // 1-based
+	envelopeLine  int // 1-based line number where the last read envelope starts.
 }
 
 // Note the returned []byte is only valid before the next readLine() call.
@@ -71,6 +72,10 @@ func (r *reader) readByRowsEnvelope() (*idr.Node, error) {
 			return nil, ErrInvalidEnvelope(
 				r.fmtErrStr("incomplete envelope, missing %d row(s)", envelopeDecl.byRows()-i))
 		}
+		if i == 0 {
+			// r.line has already moved past the line just read.
+			r.envelopeLine = r.line - 1
+		}
 		for col := range envelopeDecl.Columns {
 			if columnsDone[col] {
 				continue
@@ -97,6 +102,7 @@ func (r *reader) readByHeaderFooterEnvelope() (*idr.Node, error) {
 		}
 		return nil, ErrInvalidEnvelope(r.fmtErrStr("incomplete envelope: %s", err.Error()))
 	}
+	r.envelopeLine = r.line - 1
 	for ; r.envelopeIndex < len(r.decl.Envelopes); r.envelopeIndex++ {
 		// regex is already validated
 		headerRegex, _ := caches.GetRegex(r.decl.Envelopes[r.envelopeIndex].ByHeaderFooter.Header)
@@ -172,6 +178,11 @@ readEnvelope:
 	return node, err
 }
 
+// RecordLineStart implements fileformat.RecordLineReporter interface.
+func (r *reader) RecordLineStart() int {
+	return r.envelopeLine
+}
+
 func (r *reader) Release(n *idr.Node) {
 	if r.target == n {
 		r.target = nil
@@ -377,6 +377,7 @@ func TestRead_ByRows(t *testing.T) {
 	assert.Equal(t,
 		`{"data":{"a001_first2chars":"ab","a001_last1char":"c","a003_last2chars":"hi"}}`, idr.JSONify2(r.root))
 
+	assert.Equal(t, 1, r.RecordLineStart())
 	n, err = r.Read()
 	assert.NoError(t, err)
 	assert.Equal(t,
@@ -384,6 +385,7 @@ func TestRead_ByRows(t *testing.T) {
 	assert.Equal(t,
 		`{"data":{"a001_first2chars":"01","a001_last1char":"2","a003_last2chars":"78"}}`, idr.JSONify2(r.root))
 
+	assert.Equal(t, 7, r.RecordLineStart())
 	n, err = r.Read()
 	assert.Equal(t, io.EOF, err)
 	assert.Nil(t, n)
@@ -444,6 +446,7 @@ func TestRead_ByHeaderFooter(t *testing.T) {
 		`{"begin":{},"data":{"a001_first2chars":"ab","a001_last1char":"c","a003_last2chars":"hi"}}`,
 		idr.JSONify2(r.root))
 
+	assert.Equal(t, 2, r.RecordLineStart())
 	n, err = r.Read()
 	assert.NoError(t, err)
 	assert.Equal(t,
@@ -452,6 +455,7 @@ func TestRead_ByHeaderFooter(t *testing.T) {
 		`{"begin":{},"data":{"a001_first2chars":"01","a001_last1char":"2","a003_last2chars":"78"}}`,
 		idr.JSONify2(r.root))
 
+	assert.Equal(t, 12, r.RecordLineStart())
 	n, err = r.Read()
 	assert.Equal(t, io.EOF, err)
 	assert.Nil(t, n)
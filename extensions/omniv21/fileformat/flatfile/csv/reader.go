@@ -19,17 +19,22 @@ type line struct {
 }
 
 type reader struct {
-	inputName string
-	fileDecl  *FileDecl
-	r         *ios.LineNumReportingCsvReader
-	hr        *flatfile.HierarchyReader
-	linesBuf  []line // linesBuf contains all the unprocessed lines
-	records   []string
+	inputName   string
+	fileDecl    *FileDecl
+	input       *flatfile.LineLimiter
+	r           *ios.LineNumReportingCsvReader
+	hr          *flatfile.HierarchyReader
+	linesBuf    []line // linesBuf contains all the unprocessed lines
+	records     []string
+	limits      idr.Limits
+	recordBytes int64 // the number of bytes of the lines read for the current target.
 }
 
 // NewReader creates an FormatReader for csv file format.
 func NewReader(
 	inputName string, r io.Reader, decl *FileDecl, targetXPathExpr *xpath.Expr) *reader {
+	input := flatfile.NewLineLimiter(r)
+	r = input
 	if decl.ReplaceDoubleQuotes {
 		r = ios.NewBytesReplacingReader(r, []byte(`"`), []byte(`'`))
 	}
@@ -45,6 +50,7 @@ func NewReader(
 	reader := &reader{
 		inputName: inputName,
 		fileDecl:  decl,
+		input:     input,
 		r:         csv,
 	}
 	reader.hr = flatfile.NewHierarchyReader(
@@ -55,18 +61,25 @@ func NewReader(
 // Read implements fileformat.FormatReader interface, reading in data from input and returns
 // target IDR node.
 func (r *reader) Read() (*idr.Node, error) {
+	// the lines read but not processed by the last Read belong to this target.
+	r.recordBytes = 0
+	for i := range r.linesBuf {
+		r.recordBytes += r.lineBytes(&r.linesBuf[i])
+	}
 	n, err := r.hr.Read()
 	switch {
 	case err == nil:
 		return n, nil
+	case idr.IsErrLimitExceeded(err):
+		return nil, ErrInvalidCSV(r.fmtErrStr(r.UnprocessedLineNum(), err.Error()))
 	case flatfile.IsErrFewerThanMinOccurs(err):
 		e := err.(flatfile.ErrFewerThanMinOccurs)
 		decl := e.RecDecl.(*RecordDecl)
-		return nil, ErrInvalidCSV(r.fmtErrStr(r.unprocessedLineNum(),
+		return nil, ErrInvalidCSV(r.fmtErrStr(r.UnprocessedLineNum(),
 			"record/record_group '%s' needs min occur %d, but only got %d",
 			decl.fqdn, decl.MinOccurs(), e.ActualOcccurs))
 	case flatfile.IsErrUnexpectedData(err):
-		return nil, ErrInvalidCSV(r.fmtErrStr(r.unprocessedLineNum(), "unexpected data"))
+		return nil, ErrInvalidCSV(r.fmtErrStr(r.UnprocessedLineNum(), "unexpected data"))
 	default:
 		return nil, err
 	}
@@ -177,9 +190,22 @@ func (r *reader) readLine() error {
 		recordStart: start,
 		recordNum:   num,
 	})
+	r.recordBytes += r.lineBytes(&r.linesBuf[len(r.linesBuf)-1])
+	if err = r.limits.CheckRecordBytes(r.recordBytes); err != nil {
+		return ErrInvalidCSV(r.fmtErrStr(lineStart, err.Error()))
+	}
 	return nil
 }
 
+// lineBytes returns the size of a line, as in the total length of its columns and delimiters.
+func (r *reader) lineBytes(line *line) int64 {
+	size := (line.recordNum - 1) * len(r.fileDecl.Delimiter)
+	for _, col := range r.records[line.recordStart : line.recordStart+line.recordNum] {
+		size += len(col)
+	}
+	return int64(size)
+}
+
 func (r *reader) linesToNode(decl *RecordDecl, n int) *idr.Node {
 	if len(r.linesBuf) < n {
 		panic(fmt.Sprintf(
@@ -225,13 +251,27 @@ func (r *reader) popFrontLinesBuf(n int) {
 	r.linesBuf = r.linesBuf[:newLinesBufLen]
 }
 
-func (r *reader) unprocessedLineNum() int {
+// UnprocessedLineNum implements flatfile.RecLineReporter interface, returning the line number of the
+// first unprocessed line.
+func (r *reader) UnprocessedLineNum() int {
 	if len(r.linesBuf) > 0 {
 		return r.linesBuf[0].lineNum
 	}
 	return r.r.LineNum() + 1
 }
 
+// SetLimits implements fileformat.LimitsEnforcer interface.
+func (r *reader) SetLimits(limits idr.Limits) {
+	r.limits = limits
+	r.input.SetMaxLineLength(limits.MaxLineLength)
+	r.hr.SetLimits(limits)
+}
+
+// RecordLineStart implements fileformat.RecordLineReporter interface.
+func (r *reader) RecordLineStart() int {
+	return r.hr.TargetLineStart()
+}
+
 // Release implements fileformat.FormatReader interface, releasing a finished IDR target node.
 func (r *reader) Release(n *idr.Node) {
 	r.hr.Release(n)
@@ -246,7 +286,7 @@ func (r *reader) IsContinuableError(err error) bool {
 // FmtErr implements errs.CtxAwareErr embedded in fileformat.FormatReader, formatting an error
 // with line info.
 func (r *reader) FmtErr(format string, args ...interface{}) error {
-	return errors.New(r.fmtErrStr(r.unprocessedLineNum(), format, args...))
+	return errors.New(r.fmtErrStr(r.UnprocessedLineNum(), format, args...))
 }
 
 func (r *reader) fmtErrStr(line int, format string, args ...interface{}) string {
@@ -28,7 +28,9 @@ func TestRead(t *testing.T) {
 		fileDecl    string
 		targetXPath string
 		input       io.Reader
+		limits      idr.Limits
 		expErrs     []string
+		expLines    []int // the line starts of the successfully read targets.
 	}{
 		{
 			name: "header row min occurs not satisfied",
@@ -113,6 +115,66 @@ func TestRead(t *testing.T) {
 				"",
 				"input 'test-input' line 10: record/record_group 'r5' needs min occur 1, but only got 0",
 			},
+			expLines: []int{1, 3},
+		},
+		{
+			name: "within limits",
+			fileDecl: `{
+						"delimiter": ",",
+						"records": [
+							{ "name": "r1", "min": 0, "columns": [ { "name": "c1", "index": 1 }, { "name": "c2", "index": 2 } ] }
+						]
+					}`,
+			input:  strings.NewReader(lf("a,b") + lf("cc,dd")),
+			limits: idr.Limits{MaxNodesPerRecord: 5, MaxRecordBytes: 5, MaxLineLength: 5},
+			expErrs: []string{
+				"",
+				"",
+			},
+		},
+		{
+			name: "line too long",
+			fileDecl: `{
+						"delimiter": ",",
+						"records": [
+							{ "name": "r1", "min": 0, "columns": [ { "name": "c1", "index": 1 }, { "name": "c2", "index": 2 } ] }
+						]
+					}`,
+			input:  strings.NewReader(lf("a,b") + lf("cc,dd")),
+			limits: idr.Limits{MaxLineLength: 4},
+			expErrs: []string{
+				"",
+				"input 'test-input' line 2: limit exceeded: line is longer than 4 bytes",
+			},
+		},
+		{
+			name: "too many nodes",
+			fileDecl: `{
+						"delimiter": ",",
+						"records": [
+							{ "name": "r1", "min": 0, "columns": [ { "name": "c1", "index": 1 }, { "name": "c2", "index": 2 } ] }
+						]
+					}`,
+			input:  strings.NewReader(lf("a,b") + lf("cc,dd")),
+			limits: idr.Limits{MaxNodesPerRecord: 4},
+			expErrs: []string{
+				"input 'test-input' line 2: limit exceeded: record has more than 4 nodes",
+			},
+		},
+		{
+			name: "too many bytes",
+			fileDecl: `{
+						"delimiter": ",",
+						"records": [
+							{ "name": "r1", "min": 0, "columns": [ { "name": "c1", "index": 1 }, { "name": "c2", "index": 2 } ] }
+						]
+					}`,
+			input:  strings.NewReader(lf("a,b") + lf("cc,dd")),
+			limits: idr.Limits{MaxRecordBytes: 4},
+			expErrs: []string{
+				"",
+				"input 'test-input' line 2: limit exceeded: record is larger than 4 bytes",
+			},
 		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
@@ -126,7 +188,9 @@ func TestRead(t *testing.T) {
 				assert.NoError(t, err)
 			}
 			r := NewReader("test-input", test.input, &fd, targetXPathExpr)
+			r.SetLimits(test.limits)
 			var nodes []string
+			var lines []int
 			for _, expErr := range test.expErrs {
 				node, err := r.Read()
 				if expErr != "" {
@@ -138,12 +202,16 @@ func TestRead(t *testing.T) {
 					assert.NoError(t, err)
 					assert.NotNil(t, node)
 					nodes = append(nodes, idr.JSONify1(node))
+					lines = append(lines, r.RecordLineStart())
 				}
 				r.Release(node)
 			}
 			if len(nodes) > 0 {
 				cupaloy.SnapshotT(t, strings.Join(nodes, ",\n"))
 			}
+			if test.expLines != nil {
+				assert.Equal(t, test.expLines, lines)
+			}
 		})
 	}
 }
@@ -20,19 +20,24 @@ type line struct {
 }
 
 type reader struct {
-	inputName string
-	r         *bufio.Reader
-	hr        *flatfile.HierarchyReader
-	linesRead int    // total number of lines read in so far
-	linesBuf  []line // linesBuf contains all the unprocessed lines
+	inputName   string
+	input       *flatfile.LineLimiter
+	r           *bufio.Reader
+	hr          *flatfile.HierarchyReader
+	linesRead   int    // total number of lines read in so far
+	linesBuf    []line // linesBuf contains all the unprocessed lines
+	limits      idr.Limits
+	recordBytes int64 // the number of bytes of the lines read for the current target.
 }
 
 // NewReader creates an FormatReader for fixed-length file format.
 func NewReader(
 	inputName string, r io.Reader, decl *FileDecl, targetXPathExpr *xpath.Expr) *reader {
+	input := flatfile.NewLineLimiter(r)
 	reader := &reader{
 		inputName: inputName,
-		r:         bufio.NewReader(r),
+		input:     input,
+		r:         bufio.NewReader(input),
 	}
 	reader.hr = flatfile.NewHierarchyReader(
 		toFlatFileRecDecls(decl.Envelopes), reader, targetXPathExpr)
@@ -42,18 +47,25 @@ func NewReader(
 // Read implements fileformat.FormatReader interface, reading in data from input and returns
 // target IDR node.
 func (r *reader) Read() (*idr.Node, error) {
+	// the lines read but not processed by the last Read belong to this target.
+	r.recordBytes = 0
+	for i := range r.linesBuf {
+		r.recordBytes += int64(len(r.linesBuf[i].b))
+	}
 	n, err := r.hr.Read()
 	switch {
 	case err == nil:
 		return n, nil
+	case idr.IsErrLimitExceeded(err):
+		return nil, ErrInvalidFixedLength(r.fmtErrStr(r.UnprocessedLineNum(), err.Error()))
 	case flatfile.IsErrFewerThanMinOccurs(err):
 		e := err.(flatfile.ErrFewerThanMinOccurs)
 		envelopeDecl := e.RecDecl.(*EnvelopeDecl)
-		return nil, ErrInvalidFixedLength(r.fmtErrStr(r.unprocessedLineNum(),
+		return nil, ErrInvalidFixedLength(r.fmtErrStr(r.UnprocessedLineNum(),
 			"envelope/envelope_group '%s' needs min occur %d, but only got %d",
 			envelopeDecl.fqdn, envelopeDecl.MinOccurs(), e.ActualOcccurs))
 	case flatfile.IsErrUnexpectedData(err):
-		return nil, ErrInvalidFixedLength(r.fmtErrStr(r.unprocessedLineNum(), "unexpected data"))
+		return nil, ErrInvalidFixedLength(r.fmtErrStr(r.UnprocessedLineNum(), "unexpected data"))
 	default:
 		return nil, err
 	}
@@ -190,6 +202,10 @@ func (r *reader) readLine() error {
 		r.linesRead++
 		if len(b) > 0 {
 			r.linesBuf = append(r.linesBuf, line{lineNum: r.linesRead, b: b})
+			r.recordBytes += int64(len(b))
+			if err = r.limits.CheckRecordBytes(r.recordBytes); err != nil {
+				return ErrInvalidFixedLength(r.fmtErrStr(r.linesRead, err.Error()))
+			}
 			return nil
 		}
 	}
@@ -231,13 +247,27 @@ func (r *reader) popFrontLinesBuf(n int) {
 	r.linesBuf = r.linesBuf[:newLen]
 }
 
-func (r *reader) unprocessedLineNum() int {
+// UnprocessedLineNum implements flatfile.RecLineReporter interface, returning the line number of the
+// first unprocessed line.
+func (r *reader) UnprocessedLineNum() int {
 	if len(r.linesBuf) > 0 {
 		return r.linesBuf[0].lineNum
 	}
 	return r.linesRead + 1
 }
 
+// SetLimits implements fileformat.LimitsEnforcer interface.
+func (r *reader) SetLimits(limits idr.Limits) {
+	r.limits = limits
+	r.input.SetMaxLineLength(limits.MaxLineLength)
+	r.hr.SetLimits(limits)
+}
+
+// RecordLineStart implements fileformat.RecordLineReporter interface.
+func (r *reader) RecordLineStart() int {
+	return r.hr.TargetLineStart()
+}
+
 // Release implements fileformat.FormatReader interface, releasing a finished IDR target node.
 func (r *reader) Release(n *idr.Node) {
 	r.hr.Release(n)
@@ -252,7 +282,7 @@ func (r *reader) IsContinuableError(err error) bool {
 // FmtErr implements errs.CtxAwareErr embedded in fileformat.FormatReader, formatting an error
 // with line info.
 func (r *reader) FmtErr(format string, args ...interface{}) error {
-	return errors.New(r.fmtErrStr(r.unprocessedLineNum(), format, args...))
+	return errors.New(r.fmtErrStr(r.UnprocessedLineNum(), format, args...))
 }
 
 func (r *reader) fmtErrStr(line int, format string, args ...interface{}) string {
@@ -11,6 +11,7 @@ import (
 	"github.com/bradleyjkemp/cupaloy"
 	"github.com/jf-tech/go-corelib/strs"
 	"github.com/jf-tech/go-corelib/testlib"
+	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat"
 	"github.com/jf-tech/omniparser/extensions/omniv21/transform"
 	"github.com/jf-tech/omniparser/idr"
 	"github.com/stretchr/testify/assert"
@@ -55,9 +56,10 @@ func TestRead(t *testing.T) {
 		&transform.Decl{})
 	assert.NoError(t, err)
 	for _, test := range []struct {
-		name string
-		r    io.Reader
-		err  string
+		name   string
+		r      io.Reader
+		limits idr.Limits
+		err    string
 	}{
 		{
 			name: "empty input",
@@ -79,10 +81,35 @@ func TestRead(t *testing.T) {
 			r:    strings.NewReader("e2h\ne2b:2\ne2t\n1-line\n2-line\n3-line\n4-line\ne4h:4\n"),
 			err:  "",
 		},
+		{
+			name:   "within limits",
+			r:      strings.NewReader("e2h\ne2b:2\ne2t\n1-line\n2-line\n3-line\n4-line\ne4h:4\n"),
+			limits: idr.Limits{MaxNodesPerRecord: 17, MaxRecordBytes: 40, MaxLineLength: 6},
+			err:    "",
+		},
+		{
+			name:   "line too long",
+			r:      strings.NewReader("e2h\ne2b:2\ne2t\n1-line\n2-line\n3-line\n4-line\ne4h:4\n"),
+			limits: idr.Limits{MaxLineLength: 5},
+			err:    "input 'test-input' line 4: limit exceeded: line is longer than 5 bytes",
+		},
+		{
+			name:   "too many nodes",
+			r:      strings.NewReader("e2h\ne2b:2\ne2t\n1-line\n2-line\n3-line\n4-line\ne4h:4\n"),
+			limits: idr.Limits{MaxNodesPerRecord: 16},
+			err:    "input 'test-input' line 9: limit exceeded: record has more than 16 nodes",
+		},
+		{
+			name:   "too many bytes",
+			r:      strings.NewReader("e2h\ne2b:2\ne2t\n1-line\n2-line\n3-line\n4-line\ne4h:4\n"),
+			limits: idr.Limits{MaxRecordBytes: 39},
+			err:    "input 'test-input' line 8: limit exceeded: record is larger than 39 bytes",
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			r, err := format.CreateFormatReader("test-input", test.r, rt)
 			assert.NoError(t, err)
+			r.(fileformat.LimitsEnforcer).SetLimits(test.limits)
 			n, err := r.Read()
 			if strs.IsStrNonBlank(test.err) {
 				assert.Nil(t, n)
@@ -92,6 +119,7 @@ func TestRead(t *testing.T) {
 				assert.NotNil(t, n)
 				assert.NoError(t, err)
 				cupaloy.SnapshotT(t, idr.JSONify1(n))
+				assert.Equal(t, 1, r.(fileformat.RecordLineReporter).RecordLineStart())
 			}
 		})
 	}
@@ -515,9 +543,9 @@ func TestPopFrontLinesBuf(t *testing.T) {
 
 func TestUnprocessedLineNum(t *testing.T) {
 	r := &reader{linesRead: 42}
-	assert.Equal(t, 42+1, r.unprocessedLineNum())
+	assert.Equal(t, 42+1, r.UnprocessedLineNum())
 	r.linesBuf = []line{{lineNum: 13}}
-	assert.Equal(t, 13, r.unprocessedLineNum())
+	assert.Equal(t, 13, r.UnprocessedLineNum())
 }
 
 func TestIsContinuableError(t *testing.T) {
@@ -16,7 +16,10 @@ type HierarchyReader struct {
 	r               RecReader
 	stack           []stackEntry
 	target          *idr.Node
+	targetLine      int
 	targetXPathExpr *xpath.Expr
+	limits          idr.Limits
+	recordNodes     int // the number of nodes created for the current target.
 }
 
 // NewHierarchyReader creates a new instance of a HierarchyReader.
@@ -53,6 +56,7 @@ func (r *HierarchyReader) Read() (*idr.Node, error) {
 		idr.RemoveAndReleaseTree(r.target)
 		r.target = nil
 	}
+	r.recordNodes = 0
 	for {
 		if r.target != nil {
 			return r.target, nil
@@ -89,12 +93,16 @@ func (r *HierarchyReader) Read() (*idr.Node, error) {
 			return nil, ErrUnexpectedData{}
 		}
 		curRecEntry := r.stackTop()
+		line := r.unprocessedLineNum()
 		node, err := r.readRec(curRecEntry.recDecl)
 		// Note given we have unprocessed data, r.readRec should never return
 		// io.EOF. So any error encountered, we directly bail out.
 		if err != nil {
 			return nil, err
 		}
+		if err = r.limits.CheckNodesPerRecord(r.recordNodes); err != nil {
+			return nil, err
+		}
 		// if no err returned from r.readRec, but node returned is nil, that means
 		// the current data isn't a match for the curRecEntry.recDecl. So the
 		// curRecEntry.recDecl instance is considered done.
@@ -106,6 +114,7 @@ func (r *HierarchyReader) Read() (*idr.Node, error) {
 			continue
 		}
 		curRecEntry.recNode = node
+		curRecEntry.recLine = line
 		// the new idr node is a new instance of the current RecDecl thus when we add it to
 		// the IDR tree, we need to add it as a child of the current RecDecl's parent, thus
 		// adding it to stackTop(1), not (0).
@@ -130,6 +139,25 @@ func (r *HierarchyReader) Release(n *idr.Node) {
 	idr.RemoveAndReleaseTree(n)
 }
 
+// SetLimits sets the limits on the number of nodes created for a target node. If exceeded, Read returns
+// an idr.ErrLimitExceeded. The other limits are up to the RecReader to enforce.
+func (r *HierarchyReader) SetLimits(limits idr.Limits) {
+	r.limits = limits
+}
+
+// TargetLineStart returns the 1-based line number where the target node last returned by Read starts,
+// or 0 if the RecReader doesn't report line numbers.
+func (r *HierarchyReader) TargetLineStart() int {
+	return r.targetLine
+}
+
+func (r *HierarchyReader) unprocessedLineNum() int {
+	if lr, ok := r.r.(RecLineReporter); ok {
+		return lr.UnprocessedLineNum()
+	}
+	return 0
+}
+
 // readRec tries to read/match unprocessed data against the passed-in record decl.
 func (r *HierarchyReader) readRec(recDecl RecDecl) (*idr.Node, error) {
 	// If the decl is a Group(), the matching should be using the recursive algorithm
@@ -157,16 +185,27 @@ func (r *HierarchyReader) readRec(recDecl RecDecl) (*idr.Node, error) {
 		return nil, nil
 	}
 	if recDecl.Group() {
+		r.recordNodes++
 		return idr.CreateNode(idr.ElementNode, recDecl.DeclName()), nil
 	}
+	r.recordNodes += countNodes(node)
 	return node, nil
 }
 
+func countNodes(n *idr.Node) int {
+	count := 1
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		count += countNodes(c)
+	}
+	return count
+}
+
 type stackEntry struct {
 	recDecl  RecDecl   // the current stack entry's record decl
 	recNode  *idr.Node // the current stack entry record's IDR node
 	curChild int       // which child record is the current record is processing.
 	occurred int       // how many instances the current record has encountered/processed.
+	recLine  int       // the line number where the current stack entry record starts, if known.
 }
 
 const (
@@ -229,6 +268,7 @@ func (r *HierarchyReader) recDone() {
 		}
 		if r.targetXPathExpr == nil || idr.MatchAny(cur.recNode, r.targetXPathExpr) {
 			r.target = cur.recNode
+			r.targetLine = cur.recLine
 		} else {
 			idr.RemoveAndReleaseTree(cur.recNode)
 			cur.recNode = nil
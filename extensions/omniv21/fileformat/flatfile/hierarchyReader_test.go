@@ -362,9 +362,9 @@ func TestRecDoneRecNext(t *testing.T) {
 		{
 			name: "root-A-B, B recDone, moves to C, no target",
 			stack: []stackEntry{
-				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0},
-				{recDeclA, idr.CreateNode(idr.ElementNode, "A"), 0, 0},
-				{recDeclB, idr.CreateNode(idr.ElementNode, "B"), 0, 0},
+				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0, 0},
+				{recDeclA, idr.CreateNode(idr.ElementNode, "A"), 0, 0, 0},
+				{recDeclB, idr.CreateNode(idr.ElementNode, "B"), 0, 0, 0},
 			},
 			target:      nil,
 			targetXPath: nil,
@@ -375,9 +375,9 @@ func TestRecDoneRecNext(t *testing.T) {
 		{
 			name: "root-A-C, C recDone, stay, no target",
 			stack: []stackEntry{
-				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0},
-				{recDeclA, idr.CreateNode(idr.ElementNode, "A"), 1, 0},
-				{recDeclC, idr.CreateNode(idr.ElementNode, "C"), 0, 0},
+				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0, 0},
+				{recDeclA, idr.CreateNode(idr.ElementNode, "A"), 1, 0, 0},
+				{recDeclC, idr.CreateNode(idr.ElementNode, "C"), 0, 0, 0},
 			},
 			target:      nil,
 			targetXPath: nil,
@@ -388,9 +388,9 @@ func TestRecDoneRecNext(t *testing.T) {
 		{
 			name: "root-A-C, C recDone, C over max, A becomes target",
 			stack: []stackEntry{
-				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0},
-				{recDeclA, idr.CreateNode(idr.ElementNode, "A"), 1, 0},
-				{recDeclC, idr.CreateNode(idr.ElementNode, "C"), 0, 1},
+				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0, 0},
+				{recDeclA, idr.CreateNode(idr.ElementNode, "A"), 1, 0, 0},
+				{recDeclC, idr.CreateNode(idr.ElementNode, "C"), 0, 1, 0},
 			},
 			target:      nil,
 			targetXPath: nil,
@@ -401,8 +401,8 @@ func TestRecDoneRecNext(t *testing.T) {
 		{
 			name: "root-D, D recDone",
 			stack: []stackEntry{
-				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 1, 0},
-				{recDeclD, idr.CreateNode(idr.ElementNode, "D"), 0, 0},
+				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 1, 0, 0},
+				{recDeclD, idr.CreateNode(idr.ElementNode, "D"), 0, 0, 0},
 			},
 			target:      nil,
 			targetXPath: nil,
@@ -413,9 +413,9 @@ func TestRecDoneRecNext(t *testing.T) {
 		{
 			name: "root-A-C, C.occurred = 1, C recNext",
 			stack: []stackEntry{
-				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0},
-				{recDeclA, idr.CreateNode(idr.ElementNode, "A"), 1, 0},
-				{recDeclC, idr.CreateNode(idr.ElementNode, "C"), 0, 0},
+				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0, 0},
+				{recDeclA, idr.CreateNode(idr.ElementNode, "A"), 1, 0, 0},
+				{recDeclC, idr.CreateNode(idr.ElementNode, "C"), 0, 0, 0},
 			},
 			target:      nil,
 			targetXPath: nil,
@@ -426,9 +426,9 @@ func TestRecDoneRecNext(t *testing.T) {
 		{
 			name: "root-A-C, C recDone, C over max, A becomes target, but r.target not nil",
 			stack: []stackEntry{
-				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0},
-				{recDeclA, idr.CreateNode(idr.ElementNode, "A"), 1, 0},
-				{recDeclC, idr.CreateNode(idr.ElementNode, "C"), 0, 1},
+				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0, 0},
+				{recDeclA, idr.CreateNode(idr.ElementNode, "A"), 1, 0, 0},
+				{recDeclC, idr.CreateNode(idr.ElementNode, "C"), 0, 1, 0},
 			},
 			target:      idr.CreateNode(idr.ElementNode, ""),
 			targetXPath: nil,
@@ -439,9 +439,9 @@ func TestRecDoneRecNext(t *testing.T) {
 		{
 			name: "root-A-C, C recDone, C over max, A becomes target, but A.recNode is nil",
 			stack: []stackEntry{
-				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0},
-				{recDeclA, nil, 1, 0},
-				{recDeclC, idr.CreateNode(idr.ElementNode, "C"), 0, 1},
+				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0, 0},
+				{recDeclA, nil, 1, 0, 0},
+				{recDeclC, idr.CreateNode(idr.ElementNode, "C"), 0, 1, 0},
 			},
 			target:      nil,
 			targetXPath: nil,
@@ -452,9 +452,9 @@ func TestRecDoneRecNext(t *testing.T) {
 		{
 			name: "root-A-C, C recDone, C over max, A becomes target, but target xpath no match",
 			stack: []stackEntry{
-				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0},
-				{recDeclA, idr.CreateNode(idr.ElementNode, "A"), 1, 0},
-				{recDeclC, idr.CreateNode(idr.ElementNode, "C"), 0, 1},
+				{recDeclRoot, idr.CreateNode(idr.DocumentNode, rootName), 0, 0, 0},
+				{recDeclA, idr.CreateNode(idr.ElementNode, "A"), 1, 0, 0},
+				{recDeclC, idr.CreateNode(idr.ElementNode, "C"), 0, 1, 0},
 			},
 			target:      nil,
 			targetXPath: strs.StrPtr("no_match"),
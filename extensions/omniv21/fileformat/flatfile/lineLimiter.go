@@ -0,0 +1,74 @@
+package flatfile
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/jf-tech/omniparser/idr"
+)
+
+// LineLimiter wraps the input of a RecReader and fails the read once it sees a line longer than the
+// limit, before the RecReader could otherwise buffer up the entire line in memory.
+type LineLimiter struct {
+	r    io.Reader
+	br   *bufio.Reader // only used when there is a limit, for reading in the input line by line.
+	max  int           // 0 means unlimited.
+	buf  []byte        // the buffer of the current line, reused across lines.
+	line []byte        // the rest of the current line not yet returned by Read.
+	err  error
+}
+
+// NewLineLimiter creates a new LineLimiter, without a limit until SetMaxLineLength is called.
+func NewLineLimiter(r io.Reader) *LineLimiter {
+	return &LineLimiter{r: r}
+}
+
+// SetMaxLineLength sets the max length in bytes of a line, excluding the '\n' but including the '\r', if
+// any, of the line break; 0 means unlimited. It must be called before the first Read.
+func (l *LineLimiter) SetMaxLineLength(max int) {
+	l.max = max
+	l.br = nil
+	if max > 0 {
+		l.br = bufio.NewReader(l.r)
+	}
+}
+
+// Read implements io.Reader. A line is only returned once it is read in entirely and is within the
+// limit, so that an offending line is never partially returned. Once a line longer than the limit is
+// seen, Read returns an idr.ErrLimitExceeded, in this call and all the subsequent calls.
+func (l *LineLimiter) Read(p []byte) (int, error) {
+	if l.br == nil {
+		return l.r.Read(p)
+	}
+	for len(l.line) == 0 {
+		if l.err != nil {
+			return 0, l.err
+		}
+		l.readLine()
+	}
+	n := copy(p, l.line)
+	l.line = l.line[n:]
+	return n, nil
+}
+
+func (l *LineLimiter) readLine() {
+	l.buf = l.buf[:0]
+	for {
+		b, err := l.br.ReadSlice('\n')
+		l.buf = append(l.buf, b...)
+		lineLen := len(l.buf)
+		if lineLen > 0 && l.buf[lineLen-1] == '\n' {
+			lineLen--
+		}
+		if lineLen > l.max {
+			l.buf = l.buf[:0]
+			l.err = idr.LimitExceeded("line is longer than %d bytes", l.max)
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			l.err = err
+			break
+		}
+	}
+	l.line = l.buf
+}
@@ -0,0 +1,88 @@
+package flatfile
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jf-tech/omniparser/idr"
+)
+
+func TestLineLimiter(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		input   io.Reader
+		max     int
+		expData string
+		expErr  string
+	}{
+		{
+			name:    "unlimited",
+			input:   strings.NewReader("abc\ndefgh\n"),
+			max:     0,
+			expData: "abc\ndefgh\n",
+		},
+		{
+			name:    "within limit",
+			input:   strings.NewReader("abc\r\ndefg\nhijk"),
+			max:     4,
+			expData: "abc\r\ndefg\nhijk",
+		},
+		{
+			name:    "line too long",
+			input:   strings.NewReader("abc\ndefgh\nij\n"),
+			max:     4,
+			expData: "abc\n",
+			expErr:  "limit exceeded: line is longer than 4 bytes",
+		},
+		{
+			name:    "line too long across reads",
+			input:   iotest.OneByteReader(strings.NewReader("abc\ndefgh\nij\n")),
+			max:     4,
+			expData: "abc\n",
+			expErr:  "limit exceeded: line is longer than 4 bytes",
+		},
+		{
+			name:    "long lines within limit",
+			input:   iotest.OneByteReader(strings.NewReader(strings.Repeat("a", 5000) + "\n" + strings.Repeat("b", 5000))),
+			max:     5000,
+			expData: strings.Repeat("a", 5000) + "\n" + strings.Repeat("b", 5000),
+		},
+		{
+			name:    "long line too long",
+			input:   strings.NewReader("abc\n" + strings.Repeat("a", 5001) + "\n"),
+			max:     5000,
+			expData: "abc\n",
+			expErr:  "limit exceeded: line is longer than 5000 bytes",
+		},
+		{
+			name:    "last line too long",
+			input:   strings.NewReader("abc\ndefgh"),
+			max:     4,
+			expData: "abc\n",
+			expErr:  "limit exceeded: line is longer than 4 bytes",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			l := NewLineLimiter(test.input)
+			l.SetMaxLineLength(test.max)
+			b, err := ioutil.ReadAll(l)
+			assert.Equal(t, test.expData, string(b))
+			if test.expErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.True(t, idr.IsErrLimitExceeded(err))
+				assert.Equal(t, test.expErr, err.Error())
+				// the error is sticky.
+				n, err2 := l.Read(make([]byte, 10))
+				assert.Equal(t, 0, n)
+				assert.Equal(t, err, err2)
+			}
+		})
+	}
+}
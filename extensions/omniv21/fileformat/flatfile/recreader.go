@@ -28,3 +28,10 @@ type RecReader interface {
 	// - If a non io.EOF error encountered during IO, return (false, nil, err).
 	ReadAndMatch(decl RecDecl, createIDR bool) (matched bool, node *idr.Node, err error)
 }
+
+// RecLineReporter is an optional interface a RecReader can implement to report the line number of its
+// unprocessed data, such that HierarchyReader knows where each record starts.
+type RecLineReporter interface {
+	// UnprocessedLineNum returns the 1-based line number of the first unprocessed data.
+	UnprocessedLineNum() int
+}
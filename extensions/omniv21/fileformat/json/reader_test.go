@@ -47,6 +47,7 @@ func TestReader_Read_Success(t *testing.T) {
 	name, err := idr.MatchSingle(n, "name")
 	assert.NoError(t, err)
 	assert.Equal(t, "john", name.InnerText())
+	assert.Equal(t, 3, r.RecordLineStart())
 	// intentionally not calling r.Release(n) to verify that the
 	// stream node is freed up by a subsequent Read() call.
 
@@ -55,6 +56,7 @@ func TestReader_Read_Success(t *testing.T) {
 	name, err = idr.MatchSingle(n, "name")
 	assert.NoError(t, err)
 	assert.Equal(t, "jane", name.InnerText())
+	assert.Equal(t, 8, r.RecordLineStart())
 	r.Release(n)
 
 	n, err = r.Read()
@@ -76,6 +78,21 @@ func TestReader_Read_InvalidJSON(t *testing.T) {
 	assert.Nil(t, n)
 }
 
+func TestReader_Read_LimitExceeded(t *testing.T) {
+	r, err := NewReader("test-input", strings.NewReader(`{"A": {"B": ["c", "d"]}}`), "/A/B")
+	assert.NoError(t, err)
+	r.SetLimits(idr.Limits{MaxNodesPerRecord: 3})
+
+	n, err := r.Read()
+	assert.Error(t, err)
+	assert.True(t, IsErrNodeReadingFailed(err))
+	assert.False(t, r.IsContinuableError(err))
+	assert.Equal(t,
+		`input 'test-input' before/near line 1: limit exceeded: record has more than 3 nodes`,
+		err.Error())
+	assert.Nil(t, n)
+}
+
 func TestReader_FmtErr(t *testing.T) {
 	r, err := NewReader("test-input", strings.NewReader(""), "/A/B")
 	assert.NoError(t, err)
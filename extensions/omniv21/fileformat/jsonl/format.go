@@ -0,0 +1,52 @@
+package jsonl
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jf-tech/go-corelib/caches"
+	"github.com/jf-tech/go-corelib/strs"
+
+	"github.com/jf-tech/omniparser/errs"
+	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat"
+	"github.com/jf-tech/omniparser/extensions/omniv21/transform"
+)
+
+const (
+	fileFormatJSONL = "jsonl"
+)
+
+type jsonlFileFormat struct {
+	schemaName string
+}
+
+// NewJSONLFileFormat creates a FileFormat for JSON Lines (also known as NDJSON), i.e. one JSON value
+// per line.
+func NewJSONLFileFormat(schemaName string) fileformat.FileFormat {
+	return &jsonlFileFormat{schemaName: schemaName}
+}
+
+func (f *jsonlFileFormat) ValidateSchema(
+	format string, _ []byte, finalOutputDecl *transform.Decl) (interface{}, error) {
+	if format != fileFormatJSONL {
+		return nil, errs.ErrSchemaNotSupported
+	}
+	if finalOutputDecl == nil {
+		return nil, f.FmtErr("'FINAL_OUTPUT' is missing")
+	}
+	xpath := strs.StrPtrOrElse(finalOutputDecl.XPath, ".")
+	_, err := caches.GetXPathExpr(xpath)
+	if err != nil {
+		return nil, f.FmtErr("'FINAL_OUTPUT.xpath' (value: '%s') is invalid, err: %s", xpath, err.Error())
+	}
+	return xpath, nil
+}
+
+func (f *jsonlFileFormat) CreateFormatReader(
+	name string, r io.Reader, runtime interface{}) (fileformat.FormatReader, error) {
+	return NewReader(name, r, runtime.(string))
+}
+
+func (f *jsonlFileFormat) FmtErr(format string, args ...interface{}) error {
+	return fmt.Errorf("schema '%s': %s", f.schemaName, fmt.Sprintf(format, args...))
+}
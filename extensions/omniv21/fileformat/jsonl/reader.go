@@ -0,0 +1,132 @@
+package jsonl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jf-tech/go-corelib/caches"
+	"github.com/jf-tech/go-corelib/ios"
+
+	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat/flatfile"
+	"github.com/jf-tech/omniparser/idr"
+)
+
+// ErrLineReadingFailed indicates the reader fails to read out a line of the input, or a line exceeds
+// the limits. This is a fatal, non-continuable error. A line that isn't valid JSON, on the other hand,
+// is a continuable error, and the reader moves on to the next line.
+type ErrLineReadingFailed string
+
+func (e ErrLineReadingFailed) Error() string { return string(e) }
+
+// IsErrLineReadingFailed checks if the `err` is of ErrLineReadingFailed type.
+func IsErrLineReadingFailed(err error) bool {
+	switch err.(type) {
+	case ErrLineReadingFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+type reader struct {
+	inputName   string
+	lineLimiter *flatfile.LineLimiter
+	r           *bufio.Reader
+	xpath       string
+	limits      idr.Limits
+	line        int                   // the 1-based number of the current line.
+	lineReader  *idr.JSONStreamReader // reads the records out of the current line.
+}
+
+func (r *reader) Read() (*idr.Node, error) {
+	for {
+		if r.lineReader != nil {
+			n, err := r.lineReader.Read()
+			if err == nil {
+				return n, nil
+			}
+			if err != io.EOF {
+				// the line is valid JSON, so only exceeding a limit could fail it.
+				return nil, ErrLineReadingFailed(r.fmtErrStr(err.Error()))
+			}
+			r.lineReader = nil
+		}
+		r.line++
+		line, err := ios.ByteReadLine(r.r)
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, ErrLineReadingFailed(r.fmtErrStr(err.Error()))
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			// a malformed line only fails itself: the next Read moves on to the next line.
+			var v json.RawMessage
+			return nil, r.FmtErr("invalid JSON: %s", json.Unmarshal(line, &v).Error())
+		}
+		// the line is consumed entirely by the line reader before the next line is read, so it's safe
+		// to not copy the line out of the bufio.Reader's buffer.
+		r.lineReader, err = idr.NewJSONStreamReader(bytes.NewReader(line), r.xpath)
+		if err != nil {
+			return nil, ErrLineReadingFailed(r.fmtErrStr(err.Error()))
+		}
+		r.lineReader.SetLimits(r.limits)
+	}
+}
+
+func (r *reader) Release(n *idr.Node) {
+	if n == nil {
+		return
+	}
+	if r.lineReader != nil {
+		r.lineReader.Release(n)
+		return
+	}
+	idr.RemoveAndReleaseTree(n)
+}
+
+// SetLimits implements fileformat.LimitsEnforcer interface. MaxLineLength applies to the lines, while
+// the other limits apply to the records read out of each line.
+func (r *reader) SetLimits(limits idr.Limits) {
+	r.limits = limits
+	r.lineLimiter.SetMaxLineLength(limits.MaxLineLength)
+}
+
+// RecordLineStart implements fileformat.RecordLineReporter interface.
+func (r *reader) RecordLineStart() int {
+	return r.line
+}
+
+func (r *reader) IsContinuableError(err error) bool {
+	return !IsErrLineReadingFailed(err) && err != io.EOF
+}
+
+func (r *reader) FmtErr(format string, args ...interface{}) error {
+	return errors.New(r.fmtErrStr(format, args...))
+}
+
+func (r *reader) fmtErrStr(format string, args ...interface{}) string {
+	return fmt.Sprintf("input '%s' line %d: %s", r.inputName, r.line, fmt.Sprintf(format, args...))
+}
+
+// NewReader creates an FormatReader for JSON Lines file format, reading the records matching the xpath
+// out of each line.
+func NewReader(inputName string, src io.Reader, xpath string) (*reader, error) {
+	if _, err := caches.GetXPathExpr(xpath); err != nil {
+		return nil, fmt.Errorf("invalid xpath '%s', err: %s", xpath, err.Error())
+	}
+	lineLimiter := flatfile.NewLineLimiter(src)
+	return &reader{
+		inputName:   inputName,
+		lineLimiter: lineLimiter,
+		r:           bufio.NewReader(lineLimiter),
+		xpath:       xpath,
+	}, nil
+}
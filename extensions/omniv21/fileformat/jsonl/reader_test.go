@@ -0,0 +1,172 @@
+package jsonl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jf-tech/omniparser/errs"
+	"github.com/jf-tech/omniparser/idr"
+)
+
+func TestIsErrLineReadingFailed(t *testing.T) {
+	assert.True(t, IsErrLineReadingFailed(ErrLineReadingFailed("test")))
+	assert.Equal(t, "test", ErrLineReadingFailed("test").Error())
+	assert.False(t, IsErrLineReadingFailed(errors.New("test")))
+}
+
+func TestReader_Read(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		input    string
+		xpath    string
+		expected []string
+	}{
+		{
+			name: "one record per line",
+			input: "{\"id\": 1, \"name\": \"john\"}\n" +
+				"\n" +
+				"  \t\r\n" +
+				"{\"id\": 2, \"name\": \"jane\"}\r\n" +
+				"[1, 2]\n" +
+				"\"scalar\"\n" +
+				"{\"id\": 3}",
+			xpath: ".",
+			expected: []string{
+				`line 1: {"id":1,"name":"john"}`,
+				`line 4: {"id":2,"name":"jane"}`,
+				`line 5: [1,2]`,
+				`line 6: "scalar"`,
+				`line 7: {"id":3}`,
+			},
+		},
+		{
+			name: "malformed lines skipped",
+			input: "{\"id\": 1}\n" +
+				"{\"id\": 2\n" +
+				"{\"id\": 3} {\"id\": 4}\n" +
+				"not json\n" +
+				"{\"id\": 5}\n",
+			xpath: ".",
+			expected: []string{
+				`line 1: {"id":1}`,
+				`error: input 'test-input' line 2: invalid JSON: unexpected end of JSON input`,
+				`error: input 'test-input' line 3: invalid JSON: invalid character '{' after top-level value`,
+				`error: input 'test-input' line 4: invalid JSON: invalid character 'o' in literal null (expecting 'u')`,
+				`line 5: {"id":5}`,
+			},
+		},
+		{
+			name: "records filtered and selected by xpath",
+			input: "{\"type\": \"a\", \"items\": [1, 2]}\n" +
+				"{\"type\": \"b\", \"items\": [3]}\n" +
+				"{\"type\": \"a\", \"items\": []}\n" +
+				"{\"type\": \"a\", \"items\": [4]}\n",
+			xpath: "/items/*[../../type='a']",
+			expected: []string{
+				`line 1: 1`,
+				`line 1: 2`,
+				`line 4: 4`,
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := NewReader("test-input", strings.NewReader(test.input), test.xpath)
+			assert.NoError(t, err)
+			var actual []string
+			for {
+				n, err := r.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					assert.True(t, r.IsContinuableError(err))
+					actual = append(actual, "error: "+err.Error())
+					continue
+				}
+				actual = append(actual, fmt.Sprintf("line %d: %s", r.RecordLineStart(), idr.JSONify2(n)))
+				r.Release(n)
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestReader_Read_LimitExceeded(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		limits idr.Limits
+		err    string
+	}{
+		{
+			name:   "line too long",
+			limits: idr.Limits{MaxLineLength: 20},
+			err:    "input 'test-input' line 2: limit exceeded: line is longer than 20 bytes",
+		},
+		{
+			name:   "too many nodes",
+			limits: idr.Limits{MaxNodesPerRecord: 3},
+			err:    "input 'test-input' line 2: limit exceeded: record has more than 3 nodes",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := NewReader("test-input",
+				strings.NewReader("{\"id\": 1}\n{\"id\": 2, \"name\": \"a long name\"}\n{\"id\": 3}\n"), ".")
+			assert.NoError(t, err)
+			r.SetLimits(test.limits)
+			n, err := r.Read()
+			assert.NoError(t, err)
+			assert.Equal(t, `{"id":1}`, idr.JSONify2(n))
+			n, err = r.Read()
+			assert.Error(t, err)
+			assert.True(t, IsErrLineReadingFailed(err))
+			assert.False(t, r.IsContinuableError(err))
+			assert.Equal(t, test.err, err.Error())
+			assert.Nil(t, n)
+		})
+	}
+}
+
+type testFailingReader struct{}
+
+func (testFailingReader) Read([]byte) (int, error) { return 0, errors.New("read failure") }
+
+func TestReader_Read_ReadFailure(t *testing.T) {
+	r, err := NewReader("test-input", testFailingReader{}, ".")
+	assert.NoError(t, err)
+	n, err := r.Read()
+	assert.Error(t, err)
+	assert.True(t, IsErrLineReadingFailed(err))
+	assert.Equal(t, "input 'test-input' line 1: read failure", err.Error())
+	assert.Nil(t, n)
+}
+
+func TestReader_FmtErr(t *testing.T) {
+	r, err := NewReader("test-input", strings.NewReader(""), ".")
+	assert.NoError(t, err)
+	err = r.FmtErr("golang is %s", "fun")
+	assert.Error(t, err)
+	assert.Equal(t, `input 'test-input' line 0: golang is fun`, err.Error())
+}
+
+func TestReader_IsContinuableError(t *testing.T) {
+	r, err := NewReader("test", strings.NewReader(""), ".")
+	assert.NoError(t, err)
+	assert.False(t, r.IsContinuableError(io.EOF))
+	assert.False(t, r.IsContinuableError(ErrLineReadingFailed("failure")))
+	assert.True(t, r.IsContinuableError(errs.ErrTransformFailed("failure")))
+	assert.True(t, r.IsContinuableError(errors.New("failure")))
+}
+
+func TestNewReader_InvalidXPath(t *testing.T) {
+	r, err := NewReader("test-input", strings.NewReader(""), "[not-valid")
+	assert.Error(t, err)
+	assert.Equal(t,
+		`invalid xpath '[not-valid', err: expression must evaluate to a node-set`,
+		err.Error())
+	assert.Nil(t, r)
+}
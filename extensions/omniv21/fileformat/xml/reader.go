@@ -46,6 +46,16 @@ func (r *reader) Release(n *idr.Node) {
 	}
 }
 
+// SetLimits implements fileformat.LimitsEnforcer interface.
+func (r *reader) SetLimits(limits idr.Limits) {
+	r.r.SetLimits(limits)
+}
+
+// RecordLineStart implements fileformat.RecordLineReporter interface.
+func (r *reader) RecordLineStart() int {
+	return r.r.RecordLineStart()
+}
+
 func (r *reader) IsContinuableError(err error) bool {
 	return !IsErrNodeReadingFailed(err) && err != io.EOF
 }
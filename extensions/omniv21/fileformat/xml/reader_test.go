@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/jf-tech/omniparser/errs"
+	"github.com/jf-tech/omniparser/idr"
 )
 
 func TestIsErrNodeReadingFailed(t *testing.T) {
@@ -35,6 +36,7 @@ func TestReader_Read_Success(t *testing.T) {
 	assert.Equal(t, "1", n.InnerText())
 	// xml.Decoder seems to keeps line at the end of whatever inside an element closing tag.
 	assert.Equal(t, 3, r.r.AtLine())
+	assert.Equal(t, 3, r.RecordLineStart())
 	// intentionally not calling r.Release(n) to verify that the
 	// stream node is freed up by a subsequent Read() call.
 
@@ -42,6 +44,7 @@ func TestReader_Read_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "3", n.InnerText())
 	assert.Equal(t, 5, r.r.AtLine())
+	assert.Equal(t, 5, r.RecordLineStart())
 	r.Release(n)
 
 	n, err = r.Read()
@@ -71,6 +74,27 @@ func TestReader_Read_InvalidXML(t *testing.T) {
 	assert.Nil(t, n)
 }
 
+func TestReader_Read_LimitExceeded(t *testing.T) {
+	r, err := NewReader(
+		"test-input",
+		strings.NewReader(`
+			<Root>
+				<Node><A><B>1</B></A></Node>
+			</Root>`),
+		"Root/Node")
+	assert.NoError(t, err)
+	r.SetLimits(idr.Limits{MaxDepth: 3})
+
+	n, err := r.Read()
+	assert.Error(t, err)
+	assert.True(t, IsErrNodeReadingFailed(err))
+	assert.False(t, r.IsContinuableError(err))
+	assert.Equal(t,
+		`input 'test-input' near line 3: limit exceeded: nesting is deeper than 3 levels`,
+		err.Error())
+	assert.Nil(t, n)
+}
+
 func TestReader_FmtErr(t *testing.T) {
 	r, err := NewReader("test-input", strings.NewReader(""), "Root/Node")
 	assert.NoError(t, err)
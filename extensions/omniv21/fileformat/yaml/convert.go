@@ -0,0 +1,293 @@
+package yaml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/jf-tech/omniparser/idr"
+)
+
+const (
+	yamlTagNull  = "!!null"
+	yamlTagBool  = "!!bool"
+	yamlTagInt   = "!!int"
+	yamlTagFloat = "!!float"
+	yamlTagMerge = "!!merge"
+)
+
+// The IDR nodes a document converts into are capped at expansionRatio times the number of YAML nodes of
+// the document, but no less than minExpansionBudget, regardless of the limits, so that a small document
+// of nested aliases (a "billion laughs" attack) can't blow up into an exponentially large IDR tree.
+const (
+	expansionRatio     = 10
+	minExpansionBudget = 10000
+)
+
+// converter converts a YAML document into an IDR tree of the exact same shape idr.JSONStreamReader
+// builds out of the equivalent JSON document, so that xpath queries and transforms work the same way
+// on both. Aliases are expanded in place, and merge keys ('<<') are merged into their mappings.
+type converter struct {
+	limits    idr.Limits
+	nodes     int                   // the number of nodes created, the root excluded, as in JSONStreamReader.
+	maxNodes  int                   // the number of nodes the aliases and merge keys can expand to.
+	depth     int                   // the current nesting depth of mappings and sequences.
+	expanding map[*yamlv3.Node]bool // the anchored nodes whose aliases are being expanded.
+	lines     map[*idr.Node]int     // the lines of the element nodes created.
+	line      int                   // the line of the YAML node being converted.
+}
+
+type yamlPair struct {
+	key, value *yamlv3.Node
+}
+
+func newConverter(limits idr.Limits) *converter {
+	return &converter{
+		limits:    limits,
+		expanding: map[*yamlv3.Node]bool{},
+		lines:     map[*idr.Node]int{},
+	}
+}
+
+// convert converts the YAML document into an IDR tree. In case of error, the partially converted tree
+// is released, and c.line is the line of the YAML node the error is about.
+func (c *converter) convert(doc *yamlv3.Node) (*idr.Node, error) {
+	value := doc.Content[0]
+	c.maxNodes = expansionRatio * countYAMLNodes(value)
+	if c.maxNodes < minExpansionBudget {
+		c.maxNodes = minExpansionBudget
+	}
+	root := idr.CreateJSONNode(idr.DocumentNode, "", idr.JSONRoot)
+	c.lines[root] = value.Line
+	if err := c.addValue(root, value); err != nil {
+		idr.RemoveAndReleaseTree(root)
+		return nil, err
+	}
+	return root, nil
+}
+
+// countYAMLNodes returns the number of nodes of the YAML node tree y, with aliases not expanded.
+func countYAMLNodes(y *yamlv3.Node) int {
+	count := 1
+	for _, child := range y.Content {
+		count += countYAMLNodes(child)
+	}
+	return count
+}
+
+// isEmptyDoc checks if the YAML document has no content at all, e.g. the ones a stream starts or ends with
+// a '---' in, as opposed to an explicit null, such as '~'.
+func isEmptyDoc(doc *yamlv3.Node) bool {
+	if len(doc.Content) == 0 {
+		return true
+	}
+	y := doc.Content[0]
+	return y.Kind == yamlv3.ScalarNode && y.Value == "" && y.Style == 0 && y.Anchor == "" &&
+		y.ShortTag() == yamlTagNull
+}
+
+// addValue adds the value of the YAML node y to host, which is the root, a property or an array element,
+// the same way idr.JSONStreamReader does for a JSON value.
+func (c *converter) addValue(host *idr.Node, y *yamlv3.Node) error {
+	c.line = y.Line
+	switch y.Kind {
+	case yamlv3.AliasNode:
+		return c.expand(y, func(anchored *yamlv3.Node) error { return c.addValue(host, anchored) })
+	case yamlv3.MappingNode:
+		host.FormatSpecific = idr.JSONTypeOf(host) | idr.JSONObj
+		if err := c.enter(); err != nil {
+			return err
+		}
+		pairs, err := c.mappingPairs(y)
+		if err != nil {
+			return err
+		}
+		for _, pair := range pairs {
+			prop, err := c.addElement(host, pair.key.Value, idr.JSONProp, pair.key.Line)
+			if err != nil {
+				return err
+			}
+			if err = c.addValue(prop, pair.value); err != nil {
+				return err
+			}
+		}
+		c.depth--
+	case yamlv3.SequenceNode:
+		host.FormatSpecific = idr.JSONTypeOf(host) | idr.JSONArr
+		if err := c.enter(); err != nil {
+			return err
+		}
+		for _, item := range y.Content {
+			// just like idr.JSONStreamReader, an array element is an anonymous obj or arr element
+			// node if it's an object or an array, or an anonymous property hosting it otherwise.
+			jtype := idr.JSONProp
+			switch anchored(item).Kind {
+			case yamlv3.MappingNode:
+				jtype = idr.JSONObj
+			case yamlv3.SequenceNode:
+				jtype = idr.JSONArr
+			}
+			elem, err := c.addElement(host, "", jtype, item.Line)
+			if err != nil {
+				return err
+			}
+			if err = c.addValue(elem, item); err != nil {
+				return err
+			}
+		}
+		c.depth--
+	default:
+		data, jtype := scalarValue(y)
+		if err := c.addNode(host, idr.CreateJSONNode(idr.TextNode, data, jtype)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mappingPairs returns the key/value pairs of a mapping, with its merge keys expanded: the pairs of the
+// mappings merged in are placed where the merge key is, unless their keys are overridden by the mapping's
+// own keys, or by the mappings merged in earlier.
+func (c *converter) mappingPairs(y *yamlv3.Node) ([]yamlPair, error) {
+	own := map[string]bool{}
+	for i := 0; i+1 < len(y.Content); i += 2 {
+		key := anchored(y.Content[i])
+		if key.Kind != yamlv3.ScalarNode {
+			c.line = y.Content[i].Line
+			return nil, fmt.Errorf("mapping key must be a scalar")
+		}
+		if key.ShortTag() != yamlTagMerge {
+			own[key.Value] = true
+		}
+	}
+	var pairs []yamlPair
+	merged := map[string]bool{}
+	for i := 0; i+1 < len(y.Content); i += 2 {
+		key, value := anchored(y.Content[i]), y.Content[i+1]
+		if key.ShortTag() != yamlTagMerge {
+			pairs = append(pairs, yamlPair{key: key, value: value})
+			continue
+		}
+		mergeInto := func(m *yamlv3.Node) error {
+			if m.Kind != yamlv3.MappingNode {
+				c.line = m.Line
+				return fmt.Errorf("merge key value must be a mapping or a sequence of mappings")
+			}
+			mPairs, err := c.mappingPairs(m)
+			if err != nil {
+				return err
+			}
+			for _, pair := range mPairs {
+				if !own[pair.key.Value] && !merged[pair.key.Value] {
+					merged[pair.key.Value] = true
+					pairs = append(pairs, pair)
+				}
+			}
+			return nil
+		}
+		var err error
+		if anchored(value).Kind == yamlv3.SequenceNode {
+			err = c.expandIfAlias(value, func(seq *yamlv3.Node) error {
+				for _, m := range seq.Content {
+					if err := c.expandIfAlias(m, mergeInto); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		} else {
+			err = c.expandIfAlias(value, mergeInto)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pairs, nil
+}
+
+// expand calls f with the node the alias y refers to, failing if y refers to a node that contains it.
+func (c *converter) expand(y *yamlv3.Node, f func(*yamlv3.Node) error) error {
+	if c.expanding[y.Alias] {
+		c.line = y.Line
+		return fmt.Errorf("alias '*%s' refers to a node that contains it", y.Value)
+	}
+	c.expanding[y.Alias] = true
+	defer delete(c.expanding, y.Alias)
+	return f(y.Alias)
+}
+
+func (c *converter) expandIfAlias(y *yamlv3.Node, f func(*yamlv3.Node) error) error {
+	if y.Kind == yamlv3.AliasNode {
+		return c.expand(y, f)
+	}
+	return f(y)
+}
+
+func (c *converter) addElement(parent *idr.Node, name string, jtype idr.JSONType, line int) (*idr.Node, error) {
+	c.line = line
+	n := idr.CreateJSONNode(idr.ElementNode, name, jtype)
+	if err := c.addNode(parent, n); err != nil {
+		return nil, err
+	}
+	c.lines[n] = line
+	return n, nil
+}
+
+// addNode adds n to parent, and checks the number of nodes created against the expansion budget and
+// MaxNodesPerRecord, which thus also caps the expansion of aliases.
+func (c *converter) addNode(parent, n *idr.Node) error {
+	c.nodes++
+	idr.AddChild(parent, n)
+	if c.nodes > c.maxNodes {
+		return idr.LimitExceeded("aliases and merge keys expand to more than %d nodes", c.maxNodes)
+	}
+	return c.limits.CheckNodesPerRecord(c.nodes)
+}
+
+func (c *converter) enter() error {
+	c.depth++
+	if c.limits.MaxDepth > 0 && c.depth > c.limits.MaxDepth {
+		return idr.LimitExceeded("nesting is deeper than %d levels", c.limits.MaxDepth)
+	}
+	return nil
+}
+
+// anchored returns the node y refers to if it's an alias, or y itself otherwise.
+func anchored(y *yamlv3.Node) *yamlv3.Node {
+	if y.Kind == yamlv3.AliasNode && y.Alias != nil {
+		return y.Alias
+	}
+	return y
+}
+
+// scalarValue returns the data and the type of the IDR value node of a YAML scalar. Numbers are written the
+// way idr.JSONStreamReader does, e.g. "0x1F" becomes "31", while the values with no JSON equivalent, such as
+// timestamps or '.inf', are strings.
+func scalarValue(y *yamlv3.Node) (string, idr.JSONType) {
+	switch y.ShortTag() {
+	case yamlTagNull:
+		return "", idr.JSONValueNull
+	case yamlTagBool:
+		var b bool
+		if y.Decode(&b) == nil {
+			return strconv.FormatBool(b), idr.JSONValueBool
+		}
+	case yamlTagInt:
+		var i int64
+		if y.Decode(&i) == nil {
+			return strconv.FormatInt(i, 10), idr.JSONValueNum
+		}
+		var u uint64
+		if y.Decode(&u) == nil {
+			return strconv.FormatUint(u, 10), idr.JSONValueNum
+		}
+	case yamlTagFloat:
+		var f float64
+		if y.Decode(&f) == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return strconv.FormatFloat(f, 'f', -1, 64), idr.JSONValueNum
+		}
+	}
+	return y.Value, idr.JSONValueStr
+}
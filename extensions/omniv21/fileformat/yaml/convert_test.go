@@ -0,0 +1,142 @@
+package yaml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/jf-tech/omniparser/idr"
+)
+
+func decodeYAML(t *testing.T, s string) *yamlv3.Node {
+	var y yamlv3.Node
+	assert.NoError(t, yamlv3.NewDecoder(strings.NewReader(s)).Decode(&y))
+	return &y
+}
+
+func TestConverter_SameShapeAsJSON(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		yaml string
+		json string
+	}{
+		{
+			name: "object",
+			yaml: "a: 1\nb: x\nc: {d: true, e: ~}\nf: []\n",
+			json: `{"a": 1, "b": "x", "c": {"d": true, "e": null}, "f": []}`,
+		},
+		{
+			name: "array",
+			yaml: "- 1\n- [2, 3]\n- {a: [4]}\n- - {}\n",
+			json: `[1, [2, 3], {"a": [4]}, [{}]]`,
+		},
+		{
+			name: "value",
+			yaml: "'1'\n",
+			json: `"1"`,
+		},
+		{
+			name: "scalars",
+			yaml: "[0x1F, 0o17, 1_000, -1.5e3, .inf, .nan, TRUE, Null, 2020-01-02, '2', !!str 3, !!int x]",
+			json: `[31, 15, 1000, -1500, ".inf", ".nan", true, null, "2020-01-02", "2", "3", "x"]`,
+		},
+		{
+			name: "aliases and merge keys",
+			yaml: "base: &base {a: 1, b: 2}\n" +
+				"extra: &extra {b: 3, c: 4}\n" +
+				"list: &list [*base]\n" +
+				"x: {<<: *base, a: 5}\n" +
+				"y: {d: 6, <<: [*extra, *base], c: 7}\n" +
+				"z: *list\n",
+			json: `{
+				"base": {"a": 1, "b": 2},
+				"extra": {"b": 3, "c": 4},
+				"list": [{"a": 1, "b": 2}],
+				"x": {"b": 2, "a": 5},
+				"y": {"d": 6, "b": 3, "a": 1, "c": 7},
+				"z": [{"a": 1, "b": 2}]
+			}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			n, err := newConverter(idr.Limits{}).convert(decodeYAML(t, test.yaml))
+			assert.NoError(t, err)
+			sp, err := idr.NewJSONStreamReader(strings.NewReader(test.json), ".")
+			assert.NoError(t, err)
+			expected, err := sp.Read()
+			assert.NoError(t, err)
+			assert.Equal(t, idr.JSONify1(expected), idr.JSONify1(n))
+		})
+	}
+}
+
+func TestConverter_Failure(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		yaml   string
+		limits idr.Limits
+		err    string
+		line   int
+	}{
+		{
+			name: "non scalar key",
+			yaml: "a: 1\n? [b]\n: 2\n",
+			err:  "mapping key must be a scalar",
+			line: 2,
+		},
+		{
+			name: "merge key value not mapping",
+			yaml: "a:\n  <<: [x]\n",
+			err:  "merge key value must be a mapping or a sequence of mappings",
+			line: 2,
+		},
+		{
+			name: "recursive alias",
+			yaml: "a: &a\n  b: [1, *a]\n",
+			err:  "alias '*a' refers to a node that contains it",
+			line: 2,
+		},
+		{
+			name:   "too many nodes",
+			yaml:   "a: &a [1, 2, 3]\nb: [*a, *a, *a]\n",
+			limits: idr.Limits{MaxNodesPerRecord: 20},
+			err:    "limit exceeded: record has more than 20 nodes",
+			// the line of the node being created, which is where the alias expanded is anchored.
+			line: 1,
+		},
+		{
+			name: "alias bomb",
+			yaml: aliasBomb(),
+			err:  "limit exceeded: aliases and merge keys expand to more than 10000 nodes",
+			line: 1,
+		},
+		{
+			name: "merge key bomb",
+			yaml: "a0: &a0 {x: 1}\n" +
+				"a1: &a1 [{<<: *a0}, {<<: *a0}, {<<: *a0}, {<<: *a0}, {<<: *a0}, {<<: *a0}, {<<: *a0}, {<<: *a0}]\n" +
+				"a2: &a2 [*a1, *a1, *a1, *a1, *a1, *a1, *a1, *a1, *a1, *a1]\n" +
+				"a3: &a3 [*a2, *a2, *a2, *a2, *a2, *a2, *a2, *a2, *a2, *a2]\n" +
+				"a4: &a4 [*a3, *a3, *a3, *a3, *a3, *a3, *a3, *a3, *a3, *a3]\n",
+			err:  "limit exceeded: aliases and merge keys expand to more than 10000 nodes",
+			line: 1,
+		},
+		{
+			name:   "too deep",
+			yaml:   "a:\n  b:\n    - c: 1\n",
+			limits: idr.Limits{MaxDepth: 3},
+			err:    "limit exceeded: nesting is deeper than 3 levels",
+			line:   3,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := newConverter(test.limits)
+			n, err := c.convert(decodeYAML(t, test.yaml))
+			assert.Error(t, err)
+			assert.Equal(t, test.err, err.Error())
+			assert.Equal(t, test.line, c.line)
+			assert.Nil(t, n)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package yaml
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jf-tech/go-corelib/caches"
+	"github.com/jf-tech/go-corelib/strs"
+
+	"github.com/jf-tech/omniparser/errs"
+	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat"
+	"github.com/jf-tech/omniparser/extensions/omniv21/transform"
+)
+
+const (
+	fileFormatYAML = "yaml"
+)
+
+type yamlFileFormat struct {
+	schemaName string
+}
+
+// NewYAMLFileFormat creates a FileFormat for YAML, including multi-document streams.
+func NewYAMLFileFormat(schemaName string) fileformat.FileFormat {
+	return &yamlFileFormat{schemaName: schemaName}
+}
+
+func (f *yamlFileFormat) ValidateSchema(
+	format string, _ []byte, finalOutputDecl *transform.Decl) (interface{}, error) {
+	if format != fileFormatYAML {
+		return nil, errs.ErrSchemaNotSupported
+	}
+	if finalOutputDecl == nil {
+		return nil, f.FmtErr("'FINAL_OUTPUT' is missing")
+	}
+	xpath := strs.StrPtrOrElse(finalOutputDecl.XPath, ".")
+	_, err := caches.GetXPathExpr(xpath)
+	if err != nil {
+		return nil, f.FmtErr("'FINAL_OUTPUT.xpath' (value: '%s') is invalid, err: %s", xpath, err.Error())
+	}
+	return xpath, nil
+}
+
+func (f *yamlFileFormat) CreateFormatReader(
+	name string, r io.Reader, runtime interface{}) (fileformat.FormatReader, error) {
+	return NewReader(name, r, runtime.(string))
+}
+
+func (f *yamlFileFormat) FmtErr(format string, args ...interface{}) error {
+	return fmt.Errorf("schema '%s': %s", f.schemaName, fmt.Sprintf(format, args...))
+}
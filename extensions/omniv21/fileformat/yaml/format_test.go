@@ -0,0 +1,95 @@
+package yaml
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jf-tech/go-corelib/strs"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jf-tech/omniparser/errs"
+	"github.com/jf-tech/omniparser/extensions/omniv21/transform"
+	"github.com/jf-tech/omniparser/idr"
+)
+
+func TestValidateSchema(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		format      string
+		decl        *transform.Decl
+		expected    interface{}
+		expectedErr string
+	}{
+		{
+			name:        "not supported format",
+			format:      "json",
+			decl:        nil,
+			expected:    nil,
+			expectedErr: errs.ErrSchemaNotSupported.Error(),
+		},
+		{
+			name:        "FINAL_OUTPUT decl is nil",
+			format:      fileFormatYAML,
+			decl:        nil,
+			expected:    nil,
+			expectedErr: `schema 'test-schema': 'FINAL_OUTPUT' is missing`,
+		},
+		{
+			name:        "FINAL_OUTPUT 'xpath' is invalid",
+			format:      fileFormatYAML,
+			decl:        &transform.Decl{XPath: strs.StrPtr("[invalid")},
+			expected:    nil,
+			expectedErr: `schema 'test-schema': 'FINAL_OUTPUT.xpath' (value: '[invalid') is invalid, err: expression must evaluate to a node-set`,
+		},
+		{
+			name:        "success with xpath",
+			format:      fileFormatYAML,
+			decl:        &transform.Decl{XPath: strs.StrPtr(".[level!='debug']")},
+			expected:    ".[level!='debug']",
+			expectedErr: "",
+		},
+		{
+			name:        "success without xpath",
+			format:      fileFormatYAML,
+			decl:        &transform.Decl{},
+			expected:    ".",
+			expectedErr: "",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			runtime, err := NewYAMLFileFormat("test-schema").ValidateSchema(test.format, nil, test.decl)
+			if test.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+				assert.Nil(t, runtime)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, runtime)
+			}
+		})
+	}
+}
+
+func TestCreateFormatReader(t *testing.T) {
+	r, err := NewYAMLFileFormat("test-schema").CreateFormatReader(
+		"test-input",
+		strings.NewReader("level: info\n---\nlevel: debug\n---\nlevel: error\n"),
+		".[level!='debug']")
+	assert.NoError(t, err)
+	assert.NotNil(t, r)
+	n, err := r.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"level":"info"}`, idr.JSONify2(n))
+	n, err = r.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"level":"error"}`, idr.JSONify2(n))
+	n, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+	assert.Nil(t, n)
+
+	r, err = NewYAMLFileFormat("test-schema").CreateFormatReader("test-input", strings.NewReader(""), "[invalid")
+	assert.Error(t, err)
+	assert.Equal(t, `invalid xpath '[invalid', err: expression must evaluate to a node-set`, err.Error())
+	assert.Nil(t, r)
+}
@@ -0,0 +1,210 @@
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jf-tech/go-corelib/caches"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/jf-tech/omniparser/idr"
+)
+
+// ErrDocReadingFailed indicates the reader fails to read out a complete non-corrupted YAML document, or
+// a document exceeds the limits. This is a fatal, non-continuable error. A document that is valid YAML
+// but can't be converted into IDR, on the other hand, is a continuable error, and the reader moves on
+// to the next document.
+type ErrDocReadingFailed string
+
+func (e ErrDocReadingFailed) Error() string { return string(e) }
+
+// IsErrDocReadingFailed checks if the `err` is of ErrDocReadingFailed type.
+func IsErrDocReadingFailed(err error) bool {
+	switch err.(type) {
+	case ErrDocReadingFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// yamlErrLineRegexp matches the line number in the errors of the YAML decoder, e.g.
+// "yaml: line 3: mapping values are not allowed in this context".
+var yamlErrLineRegexp = regexp.MustCompile(`^yaml: line ([0-9]+): `)
+
+type reader struct {
+	inputName string
+	input     *limitedInput
+	d         *yamlv3.Decoder
+	xpath     string
+	limits    idr.Limits
+	doc       *idr.Node         // the IDR tree of the current document.
+	lines     map[*idr.Node]int // the lines of the element nodes of the current document.
+	targets   []*idr.Node       // the targets of the current document yet to be read.
+	line      int               // the line of the current target, or of the current error.
+	err       error
+}
+
+func (r *reader) Read() (*idr.Node, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	for len(r.targets) == 0 {
+		r.releaseDoc()
+		if err := r.readDoc(); err != nil {
+			if !r.IsContinuableError(err) {
+				r.err = err
+			}
+			return nil, err
+		}
+	}
+	n := r.targets[0]
+	r.targets = r.targets[1:]
+	r.line = r.lines[n]
+	return n, nil
+}
+
+// readDoc reads the next document of the input, converts it into IDR, and finds its targets.
+func (r *reader) readDoc() error {
+	r.input.startDoc()
+	var y yamlv3.Node
+	err := r.decode(&y)
+	switch {
+	case err == io.EOF:
+		return io.EOF
+	case r.input.err != nil:
+		r.line = r.input.atLine()
+		return ErrDocReadingFailed(r.fmtErrStr(r.input.err.Error()))
+	case err != nil:
+		// not all the errors of the decoder come with a line, in which case the line the decoder has read
+		// up to is the best we know.
+		r.line = r.input.atLine()
+		msg := err.Error()
+		if m := yamlErrLineRegexp.FindStringSubmatch(msg); m != nil {
+			r.line, _ = strconv.Atoi(m[1])
+			msg = msg[len(m[0]):]
+		}
+		return ErrDocReadingFailed(r.fmtErrStr(strings.TrimPrefix(msg, "yaml: ")))
+	}
+	if isEmptyDoc(&y) {
+		// like blank lines in JSON Lines, empty documents are skipped.
+		return nil
+	}
+	c := newConverter(r.limits)
+	doc, err := c.convert(&y)
+	if err != nil {
+		r.line = c.line
+		if idr.IsErrLimitExceeded(err) {
+			return ErrDocReadingFailed(r.fmtErrStr(err.Error()))
+		}
+		// the decoder is done with the document, so it only fails itself.
+		return r.FmtErr(err.Error())
+	}
+	r.doc, r.lines = doc, c.lines
+	r.line = r.lines[doc]
+	r.targets, err = idr.MatchAll(doc, r.xpath)
+	if err != nil {
+		return r.FmtErr(err.Error())
+	}
+	return nil
+}
+
+// decode decodes the next document of the input, with any panic of the decoder, which isn't supposed
+// to happen but has happened on some malformed inputs in the past, turned into an error.
+func (r *reader) decode(y *yamlv3.Node) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("yaml: decoder panic: %v", p)
+		}
+	}()
+	return r.d.Decode(y)
+}
+
+func (r *reader) releaseDoc() {
+	if r.doc != nil {
+		idr.RemoveAndReleaseTree(r.doc)
+		r.doc, r.lines, r.targets = nil, nil, nil
+	}
+}
+
+// Release is a no-op: given a document is fully decoded before it's converted into IDR, its IDR tree is
+// released as a whole, once all its targets are read.
+func (r *reader) Release(_ *idr.Node) {}
+
+// SetLimits implements fileformat.LimitsEnforcer interface. The limits apply to each document, no matter
+// how many targets are read out of it.
+func (r *reader) SetLimits(limits idr.Limits) {
+	r.limits = limits
+	r.input.maxRecordBytes = limits.MaxRecordBytes
+}
+
+// RecordLineStart implements fileformat.RecordLineReporter interface.
+func (r *reader) RecordLineStart() int {
+	return r.line
+}
+
+func (r *reader) IsContinuableError(err error) bool {
+	return !IsErrDocReadingFailed(err) && err != io.EOF
+}
+
+func (r *reader) FmtErr(format string, args ...interface{}) error {
+	return errors.New(r.fmtErrStr(format, args...))
+}
+
+func (r *reader) fmtErrStr(format string, args ...interface{}) string {
+	return fmt.Sprintf("input '%s' line %d: %s", r.inputName, r.line, fmt.Sprintf(format, args...))
+}
+
+// yamlReadAhead is how many more bytes than MaxRecordBytes limitedInput lets the YAML decoder read for
+// a document, which is generous enough for its read-ahead buffering.
+const yamlReadAhead = 1024
+
+// limitedInput is the input of the YAML decoder. Given the decoder reads a document entirely before
+// returning it, limitedInput stops it once it reads too much for the current document.
+type limitedInput struct {
+	r              io.Reader
+	maxRecordBytes int64
+	n              int64 // the number of bytes read for the current document.
+	newlines       int   // the number of newlines read in total.
+	err            error
+}
+
+func (in *limitedInput) startDoc() {
+	in.n = 0
+}
+
+func (in *limitedInput) Read(p []byte) (int, error) {
+	if in.maxRecordBytes > 0 && in.n > in.maxRecordBytes+yamlReadAhead {
+		in.err = idr.LimitExceeded("record is larger than %d bytes", in.maxRecordBytes)
+		return 0, in.err
+	}
+	n, err := in.r.Read(p)
+	in.n += int64(n)
+	in.newlines += bytes.Count(p[:n], []byte{'\n'})
+	return n, err
+}
+
+// atLine returns the **rough** line number the decoder has read up to, given it reads ahead.
+func (in *limitedInput) atLine() int {
+	return in.newlines + 1
+}
+
+// NewReader creates an FormatReader for YAML file format, reading the records matching the xpath out of
+// each document of the input.
+func NewReader(inputName string, src io.Reader, xpath string) (*reader, error) {
+	if _, err := caches.GetXPathExpr(xpath); err != nil {
+		return nil, fmt.Errorf("invalid xpath '%s', err: %s", xpath, err.Error())
+	}
+	input := &limitedInput{r: src}
+	return &reader{
+		inputName: inputName,
+		input:     input,
+		d:         yamlv3.NewDecoder(input),
+		xpath:     xpath,
+	}, nil
+}
@@ -0,0 +1,280 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jf-tech/omniparser/errs"
+	"github.com/jf-tech/omniparser/idr"
+)
+
+func TestIsErrDocReadingFailed(t *testing.T) {
+	assert.True(t, IsErrDocReadingFailed(ErrDocReadingFailed("test")))
+	assert.Equal(t, "test", ErrDocReadingFailed("test").Error())
+	assert.False(t, IsErrDocReadingFailed(errors.New("test")))
+}
+
+func readAll(t *testing.T, r *reader) []string {
+	var actual []string
+	for {
+		n, err := r.Read()
+		if err == io.EOF {
+			return actual
+		}
+		if err != nil {
+			actual = append(actual, "error: "+err.Error())
+			if !r.IsContinuableError(err) {
+				// a fatal error is sticky.
+				_, err2 := r.Read()
+				assert.Equal(t, err, err2)
+				return actual
+			}
+			continue
+		}
+		actual = append(actual, fmt.Sprintf("line %d: %s", r.RecordLineStart(), idr.JSONify2(n)))
+		r.Release(n)
+	}
+}
+
+func TestReader_Read(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		input    string
+		xpath    string
+		expected []string
+	}{
+		{
+			name: "one record per document",
+			input: "---\n" +
+				"id: 1\n" +
+				"name: john\n" +
+				"---\n" +
+				"# nothing but a comment\n" +
+				"---\n" +
+				"- 1\n" +
+				"- 2\n" +
+				"--- scalar\n" +
+				"---\n" +
+				"~\n" +
+				"...\n" +
+				"---\n",
+			xpath: ".",
+			expected: []string{
+				`line 2: {"id":1,"name":"john"}`,
+				`line 7: [1,2]`,
+				`line 9: "scalar"`,
+				`line 11: null`,
+			},
+		},
+		{
+			name: "records filtered and selected by xpath",
+			input: "type: a\n" +
+				"items:\n" +
+				"  - 1\n" +
+				"  - 2\n" +
+				"---\n" +
+				"type: b\n" +
+				"items: [3]\n" +
+				"---\n" +
+				"type: a\n" +
+				"items:\n" +
+				"  - id: 4\n",
+			xpath: "/items/*[../../type='a']",
+			expected: []string{
+				`line 3: 1`,
+				`line 4: 2`,
+				`line 11: {"id":4}`,
+			},
+		},
+		{
+			name: "documents that can't be converted skipped",
+			input: "a: 1\n" +
+				"---\n" +
+				"? [b]\n" +
+				": 2\n" +
+				"---\n" +
+				"c: &c [*c]\n" +
+				"---\n" +
+				"d: 4\n",
+			xpath: ".",
+			expected: []string{
+				`line 1: {"a":1}`,
+				`error: input 'test-input' line 3: mapping key must be a scalar`,
+				`error: input 'test-input' line 6: alias '*c' refers to a node that contains it`,
+				`line 8: {"d":4}`,
+			},
+		},
+		{
+			name: "malformed document",
+			input: "a: 1\n" +
+				"---\n" +
+				"b: 2\n" +
+				"  c: 3\n" +
+				"---\n" +
+				"d: 4\n",
+			xpath: ".",
+			expected: []string{
+				`line 1: {"a":1}`,
+				`error: input 'test-input' line 4: mapping values are not allowed in this context`,
+			},
+		},
+		{
+			name:  "unknown anchor",
+			input: "a: 1\n---\nb: *b\n",
+			xpath: ".",
+			expected: []string{
+				`line 1: {"a":1}`,
+				`error: input 'test-input' line 4: unknown anchor 'b' referenced`,
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := NewReader("test-input", strings.NewReader(test.input), test.xpath)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, readAll(t, r))
+		})
+	}
+}
+
+func TestReader_Read_LimitExceeded(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		input    string
+		limits   idr.Limits
+		expected []string
+	}{
+		{
+			name:   "too many nodes",
+			input:  "id: 1\n---\nid: 2\nname: a long name\n---\nid: 3\n",
+			limits: idr.Limits{MaxNodesPerRecord: 3},
+			expected: []string{
+				`line 1: {"id":1}`,
+				`error: input 'test-input' line 4: limit exceeded: record has more than 3 nodes`,
+			},
+		},
+		{
+			name:   "too deep",
+			input:  "a: [1]\n---\na: [[2]]\n",
+			limits: idr.Limits{MaxDepth: 2},
+			expected: []string{
+				`line 1: {"a":[1]}`,
+				`error: input 'test-input' line 3: limit exceeded: nesting is deeper than 2 levels`,
+			},
+		},
+		{
+			name:   "alias bomb with no limits",
+			input:  "a: 1\n---\n" + aliasBomb(),
+			limits: idr.Limits{},
+			expected: []string{
+				`line 1: {"a":1}`,
+				`error: input 'test-input' line 3: limit exceeded: aliases and merge keys expand to more than 10000 nodes`,
+			},
+		},
+		{
+			name:   "too large",
+			input:  "a: 1\n---\na: " + strings.Repeat("x", 4096) + "\n",
+			limits: idr.Limits{MaxRecordBytes: 100},
+			expected: []string{
+				`line 1: {"a":1}`,
+				`error: input 'test-input' line 3: limit exceeded: record is larger than 100 bytes`,
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := NewReader("test-input", strings.NewReader(test.input), ".")
+			assert.NoError(t, err)
+			r.SetLimits(test.limits)
+			assert.Equal(t, test.expected, readAll(t, r))
+		})
+	}
+}
+
+// aliasBomb returns a document of 6 levels of anchored sequences, each with ten aliases to the previous
+// level, which would expand to a million nodes.
+func aliasBomb() string {
+	var sb strings.Builder
+	sb.WriteString("a0: &a0 [x]\n")
+	for i := 1; i <= 6; i++ {
+		sb.WriteString(fmt.Sprintf("a%d: &a%d [%s*a%d]\n", i, i, strings.Repeat(fmt.Sprintf("*a%d, ", i-1), 9), i-1))
+	}
+	return sb.String()
+}
+
+type testFailingReader struct{}
+
+func (testFailingReader) Read([]byte) (int, error) { return 0, errors.New("read failure") }
+
+func TestReader_Read_ReadFailure(t *testing.T) {
+	r, err := NewReader("test-input", testFailingReader{}, ".")
+	assert.NoError(t, err)
+	n, err := r.Read()
+	assert.Error(t, err)
+	assert.True(t, IsErrDocReadingFailed(err))
+	assert.Equal(t, "input 'test-input' line 1: input error: read failure", err.Error())
+	assert.Nil(t, n)
+}
+
+type testPanickingReader struct{}
+
+func (testPanickingReader) Read([]byte) (int, error) { panic("read panic") }
+
+func TestReader_Read_DecoderPanic(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		input io.Reader
+		err   string
+	}{
+		{
+			// the input that used to panic the decoder, see CVE-2022-28948.
+			name:  "malformed input",
+			input: strings.NewReader("0: [:!00 \xef"),
+			err:   "input 'test-input' line 1: incomplete UTF-8 octet sequence",
+		},
+		{
+			name:  "panic",
+			input: testPanickingReader{},
+			err:   "input 'test-input' line 1: decoder panic: read panic",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := NewReader("test-input", test.input, ".")
+			assert.NoError(t, err)
+			n, err := r.Read()
+			assert.Error(t, err)
+			assert.True(t, IsErrDocReadingFailed(err))
+			assert.Equal(t, test.err, err.Error())
+			assert.Nil(t, n)
+		})
+	}
+}
+
+func TestReader_FmtErr(t *testing.T) {
+	r, err := NewReader("test-input", strings.NewReader(""), ".")
+	assert.NoError(t, err)
+	err = r.FmtErr("golang is %s", "fun")
+	assert.Error(t, err)
+	assert.Equal(t, `input 'test-input' line 0: golang is fun`, err.Error())
+}
+
+func TestReader_IsContinuableError(t *testing.T) {
+	r, err := NewReader("test", strings.NewReader(""), ".")
+	assert.NoError(t, err)
+	assert.False(t, r.IsContinuableError(io.EOF))
+	assert.False(t, r.IsContinuableError(ErrDocReadingFailed("failure")))
+	assert.True(t, r.IsContinuableError(errs.ErrTransformFailed("failure")))
+	assert.True(t, r.IsContinuableError(errors.New("failure")))
+}
+
+func TestNewReader_InvalidXPath(t *testing.T) {
+	r, err := NewReader("test-input", strings.NewReader(""), "[not-valid")
+	assert.Error(t, err)
+	assert.Equal(t,
+		`invalid xpath '[not-valid', err: expression must evaluate to a node-set`,
+		err.Error())
+	assert.Nil(t, r)
+}
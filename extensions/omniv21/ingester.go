@@ -1,8 +1,11 @@
 package omniv21
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"io"
+	"time"
 
 	"github.com/jf-tech/omniparser/customfuncs"
 	"github.com/jf-tech/omniparser/errs"
@@ -14,13 +17,27 @@ import (
 )
 
 type rawRecord struct {
-	node *idr.Node
+	node            *idr.Node
+	sensitiveValues func() []string // the sensitive values of the record, if known.
 }
 
 func (rr *rawRecord) Raw() interface{} {
 	return rr.node
 }
 
+// Redacted implements schemahandler.RedactedRawRecord, returning the JSON dump of the rawRecord, with
+// the values of its 'sensitive_xpaths' and of the 'sensitive' decls redacted.
+func (rr *rawRecord) Redacted() string {
+	return redact(idr.JSONify2(rr.node), withJSONEscaped(rr.sensitive()))
+}
+
+func (rr *rawRecord) sensitive() []string {
+	if rr.sensitiveValues == nil {
+		return nil
+	}
+	return rr.sensitiveValues()
+}
+
 // Checksum returns a stable MD5(v3) hash of the rawRecord.
 func (rr *rawRecord) Checksum() string {
 	hash, _ := customfuncs.UUIDv3(nil, idr.JSONify2(rr.node))
@@ -29,46 +46,149 @@ func (rr *rawRecord) Checksum() string {
 
 type ingester struct {
 	finalOutputDecl  *transform.Decl
+	dedupKeyDecl     *transform.Decl
 	customFuncs      customfuncs.CustomFuncs
 	customParseFuncs transform.CustomParseFuncs // Deprecated.
 	ctx              *transformctx.Ctx
 	reader           fileformat.FormatReader
+	output           *outputEncoder
+	encodePlan       *transform.EncodePlan
+	recordBuf        bytes.Buffer // reused by WriteRecord across records.
 	rawRecord        rawRecord
+	recordIndex      int
+	sensitiveXPaths  []string
 }
 
 // Read ingests a raw record from the input stream, transforms it according the given schema and return
 // the raw record, transformed JSON bytes.
 func (g *ingester) Read() (schemahandler.RawRecord, []byte, error) {
+	n, err := g.readNode()
+	if err != nil {
+		return nil, nil, err
+	}
+	parseCtx := transform.NewParseCtx(g.ctx, g.customFuncs, g.customParseFuncs).WithRecordMeta(g.recordMeta())
+	if g.preserveOrder() {
+		parseCtx.WithKeyOrder()
+	}
+	g.rawRecord.sensitiveValues = parseCtx.SensitiveValues
+	if err := parseCtx.MarkSensitiveXPaths(n, g.sensitiveXPaths); err != nil {
+		return nil, nil, g.transformErr(err)
+	}
+	result, err := parseCtx.ParseNode(n, g.finalOutputDecl)
+	if err != nil {
+		return nil, nil, g.transformErr(err)
+	}
+	transformed, err := g.output.marshal(result, parseCtx.KeyOrder)
+	return &g.rawRecord, transformed, err
+}
+
+// WriteRecord is like Read, except it writes the transformed JSON directly into w, without building up
+// the record first.
+func (g *ingester) WriteRecord(w io.Writer) (schemahandler.RawRecord, int64, error) {
+	n, err := g.readNode()
+	if err != nil {
+		return nil, 0, err
+	}
+	if g.output == nil {
+		g.output = newOutputEncoder(nil)
+	}
+	if g.encodePlan == nil {
+		g.encodePlan = compileEncodePlan(g.finalOutputDecl, g.output.settings)
+	}
+	parseCtx := transform.NewParseCtx(g.ctx, g.customFuncs, g.customParseFuncs).WithRecordMeta(g.recordMeta())
+	if g.preserveOrder() {
+		parseCtx.WithKeyOrder()
+	}
+	g.rawRecord.sensitiveValues = parseCtx.SensitiveValues
+	if err := parseCtx.MarkSensitiveXPaths(n, g.sensitiveXPaths); err != nil {
+		return nil, 0, g.transformErr(err)
+	}
+	g.recordBuf.Reset()
+	err = parseCtx.EncodeNode(n, g.encodePlan, &g.recordBuf, func(buf *bytes.Buffer, v interface{}) error {
+		return g.output.encodeValue(buf, v, parseCtx.KeyOrder)
+	})
+	if err != nil {
+		return nil, 0, g.transformErr(err)
+	}
+	written, err := w.Write(g.output.indent(g.recordBuf.Bytes()))
+	return &g.rawRecord, int64(written), err
+}
+
+// RecordKey implements schemahandler.RecordKeyer, returning the value of the schema's 'DEDUP_KEY' for the
+// record last read: as is if it's a string, or its JSON otherwise.
+func (g *ingester) RecordKey() (string, error) {
+	if g.dedupKeyDecl == nil || g.rawRecord.node == nil {
+		return "", nil
+	}
+	parseCtx := transform.NewParseCtx(g.ctx, g.customFuncs, g.customParseFuncs).WithRecordMeta(g.recordMeta())
+	key, err := parseCtx.ParseNode(g.rawRecord.node, g.dedupKeyDecl)
+	if err != nil {
+		return "", g.transformErr(err)
+	}
+	switch key := key.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return key, nil
+	default:
+		b, err := json.Marshal(key)
+		if err != nil {
+			return "", g.transformErr(err)
+		}
+		return string(b), nil
+	}
+}
+
+func (g *ingester) readNode() (*idr.Node, error) {
 	if g.rawRecord.node != nil {
 		g.reader.Release(g.rawRecord.node)
 		g.rawRecord.node = nil
 	}
+	g.rawRecord.sensitiveValues = nil
 	n, err := g.reader.Read()
 	if n != nil {
 		g.rawRecord.node = n
 	}
 	if err != nil {
 		// Read() supposed to have already done CtxAwareErr error wrapping. So directly return.
-		return nil, nil, err
+		return nil, err
 	}
-	result, err := transform.NewParseCtx(g.ctx, g.customFuncs, g.customParseFuncs).ParseNode(n, g.finalOutputDecl)
-	if err != nil {
-		// ParseNode() error not CtxAwareErr wrapped, so wrap it.
-		// Note errs.ErrorTransformFailed is a continuable error.
-		return nil, nil, errs.ErrTransformFailed(g.fmtErrStr("fail to transform. err: %s", err.Error()))
+	g.recordIndex++
+	return n, nil
+}
+
+func (g *ingester) preserveOrder() bool {
+	return g.output != nil && g.output.settings.PreserveOrder
+}
+
+func (g *ingester) transformErr(err error) error {
+	// ParseNode()/EncodeNode() error not CtxAwareErr wrapped, so wrap it.
+	// Note errs.ErrorTransformFailed is a continuable error.
+	return errs.ErrTransformFailed(g.fmtErrStr("fail to transform. err: %s", err.Error()))
+}
+
+func (g *ingester) recordMeta() *transform.RecordMeta {
+	meta := &transform.RecordMeta{
+		RecordIndex:   g.recordIndex,
+		Checksum:      g.rawRecord.Checksum,
+		TransformTime: time.Now(),
 	}
-	transformed, err := json.Marshal(result)
-	return &g.rawRecord, transformed, err
+	if r, ok := g.reader.(fileformat.RecordLineReporter); ok {
+		meta.LineStart = r.RecordLineStart()
+	}
+	return meta
 }
 
 func (g *ingester) IsContinuableError(err error) bool {
 	return errs.IsErrTransformFailed(err) || g.reader.IsContinuableError(err)
 }
 
+// FmtErr formats an error with the context of the input, with the sensitive values of the current record,
+// if any, redacted.
 func (g *ingester) FmtErr(format string, args ...interface{}) error {
 	return errors.New(g.fmtErrStr(format, args...))
 }
 
 func (g *ingester) fmtErrStr(format string, args ...interface{}) string {
-	return g.reader.FmtErr(format, args...).Error()
+	return redact(g.reader.FmtErr(format, args...).Error(), g.rawRecord.sensitive())
 }
@@ -1,16 +1,20 @@
 package omniv21
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/jf-tech/omniparser/errs"
 	"github.com/jf-tech/omniparser/extensions/omniv21/transform"
 	"github.com/jf-tech/omniparser/idr"
+	"github.com/jf-tech/omniparser/transformctx"
 )
 
 var errContinuableInTest = errors.New("continuable error")
@@ -102,6 +106,62 @@ func TestIngester_Read_Success(t *testing.T) {
 	assert.Equal(t, 1, g.reader.(*testReader).releaseCalled)
 }
 
+type testLineReader struct {
+	testReader
+	lineStart int
+}
+
+func (r *testLineReader) RecordLineStart() int { return r.lineStart }
+
+func TestIngester_Read_RecordMeta(t *testing.T) {
+	finalOutputDecl, err := transform.ValidateTransformDeclarations(
+		[]byte(` {
+			"transform_declarations": {
+				"FINAL_OUTPUT": { "object": {
+					"record_index": { "meta": "record_index" },
+					"input_name": { "meta": "input_name" },
+					"line_start": { "meta": "line_start" },
+					"checksum": { "meta": "checksum" },
+					"transform_time": { "meta": "transform_time" }
+				}}
+			}
+		}`), nil, nil)
+	assert.NoError(t, err)
+	reader := &testLineReader{
+		testReader: testReader{
+			result: []*idr.Node{ingesterTestNode, ingesterTestNode},
+			err:    []error{nil, nil},
+		},
+		lineStart: 7,
+	}
+	g := &ingester{
+		finalOutputDecl: finalOutputDecl,
+		ctx:             &transformctx.Ctx{InputName: "test-input"},
+		reader:          reader,
+	}
+	for _, expectedIndex := range []int{1, 2} {
+		raw, b, err := g.Read()
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		assert.NoError(t, json.Unmarshal(b, &result))
+		_, err = time.Parse(time.RFC3339, result["transform_time"].(string))
+		assert.NoError(t, err)
+		delete(result, "transform_time")
+		assert.Equal(t, map[string]interface{}{
+			"record_index": float64(expectedIndex),
+			"input_name":   "test-input",
+			"line_start":   float64(7),
+			"checksum":     raw.Checksum(),
+		}, result)
+	}
+	// line_start is omitted if the reader doesn't report it.
+	g.reader = &testReader{result: []*idr.Node{ingesterTestNode}, err: []error{nil}}
+	_, b, err := g.Read()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "line_start")
+	assert.Contains(t, string(b), `"record_index":3`)
+}
+
 func TestIsContinuableError(t *testing.T) {
 	g := &ingester{reader: &testReader{}}
 	assert.False(t, g.IsContinuableError(errors.New("test failure")))
@@ -112,3 +172,151 @@ func TestFmtErr(t *testing.T) {
 	g := &ingester{reader: &testReader{}}
 	assert.Equal(t, "ctx: some 1 fruit", g.FmtErr("some %d %s", 1, "fruit").Error())
 }
+
+func TestIngester_WriteRecord(t *testing.T) {
+	finalOutputDecl, err := transform.ValidateTransformDeclarations(
+		[]byte(` {
+			"transform_declarations": {
+				"FINAL_OUTPUT": { "object": {
+					"name": { "meta": "input_name" },
+					"index": { "meta": "record_index" }
+				}}
+			}
+		}`), nil, nil)
+	assert.NoError(t, err)
+	g := &ingester{
+		finalOutputDecl: finalOutputDecl,
+		ctx:             &transformctx.Ctx{InputName: "test-input"},
+		reader: &testReader{
+			result: []*idr.Node{ingesterTestNode, nil, ingesterTestNode},
+			err:    []error{nil, errContinuableInTest, nil},
+		},
+	}
+	var buf bytes.Buffer
+	raw, n, err := g.WriteRecord(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"index":1,"name":"test-input"}`, buf.String())
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Equal(t, "{}", idr.JSONify2(raw.Raw().(*idr.Node)))
+
+	buf.Reset()
+	raw, n, err = g.WriteRecord(&buf)
+	assert.Equal(t, errContinuableInTest, err)
+	assert.Nil(t, raw)
+	assert.Equal(t, int64(0), n)
+
+	g.output = newOutputEncoder(&OutputSettings{Indent: " "})
+	g.encodePlan = nil
+	raw, n, err = g.WriteRecord(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n \"index\": 2,\n \"name\": \"test-input\"\n}", buf.String())
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.NotNil(t, raw)
+
+	buf.Reset()
+	raw, n, err = g.WriteRecord(&buf)
+	assert.Equal(t, io.EOF, err)
+	assert.Nil(t, raw)
+	assert.Equal(t, int64(0), n)
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestIngester_WriteRecord_TransformFailure(t *testing.T) {
+	finalOutputDecl, err := transform.ValidateTransformDeclarations(
+		[]byte(` {
+			"transform_declarations": {
+				"FINAL_OUTPUT": { "object": { "a": { "const": "abc", "type": "int" } } }
+			}
+		}`), nil, nil)
+	assert.NoError(t, err)
+	g := &ingester{
+		finalOutputDecl: finalOutputDecl,
+		reader:          &testReader{result: []*idr.Node{ingesterTestNode}, err: []error{nil}},
+	}
+	var buf bytes.Buffer
+	raw, n, err := g.WriteRecord(&buf)
+	assert.Error(t, err)
+	assert.True(t, errs.IsErrTransformFailed(err))
+	assert.True(t, g.IsContinuableError(err))
+	assert.Equal(t,
+		`ctx: fail to transform. err: unable to convert value 'abc' to type 'int' on 'FINAL_OUTPUT.a', err: strconv.ParseInt: parsing "abc": invalid syntax`,
+		err.Error())
+	assert.Nil(t, raw)
+	assert.Equal(t, int64(0), n)
+	assert.Equal(t, 0, buf.Len())
+}
+
+type benchReader struct {
+	node *idr.Node
+}
+
+func (r benchReader) Read() (*idr.Node, error)                   { return r.node, nil }
+func (r benchReader) Release(_ *idr.Node)                        {}
+func (r benchReader) IsContinuableError(_ error) bool            { return false }
+func (r benchReader) FmtErr(f string, args ...interface{}) error { return fmt.Errorf(f, args...) }
+
+func benchIngester(b *testing.B) *ingester {
+	// order
+	//    item (x50)
+	//       sku, qty, price, note
+	root := idr.CreateNode(idr.ElementNode, "order")
+	for i := 0; i < 50; i++ {
+		item := idr.CreateNode(idr.ElementNode, "item")
+		idr.AddChild(root, item)
+		for _, field := range [][2]string{
+			{"sku", fmt.Sprintf("SKU-%03d", i)}, {"qty", fmt.Sprint(i + 1)}, {"price", "12.5"}, {"note", " a & b "},
+		} {
+			n := idr.CreateNode(idr.ElementNode, field[0])
+			idr.AddChild(item, n)
+			idr.AddChild(n, idr.CreateNode(idr.TextNode, field[1]))
+		}
+	}
+	finalOutputDecl, err := transform.ValidateTransformDeclarations([]byte(`{
+		"transform_declarations": {
+			"FINAL_OUTPUT": { "object": {
+				"id": { "const": "order-1" },
+				"items": { "array": [ { "xpath": "item", "object": {
+					"sku": { "xpath": "sku" },
+					"qty": { "xpath": "qty", "type": "int" },
+					"price": { "xpath": "price", "type": "float" },
+					"note": { "xpath": "note" },
+					"missing": { "xpath": "missing" },
+					"flags": { "object": { "bulk": { "const": "true", "type": "boolean" } } }
+				}}]}
+			}}
+		}
+	}`), nil, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	settings := &OutputSettings{}
+	return &ingester{
+		finalOutputDecl: finalOutputDecl,
+		ctx:             &transformctx.Ctx{},
+		reader:          benchReader{node: root},
+		output:          newOutputEncoder(settings),
+		encodePlan:      compileEncodePlan(finalOutputDecl, settings),
+	}
+}
+
+func BenchmarkIngester_Read(b *testing.B) {
+	g := benchIngester(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := g.Read(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIngester_WriteRecord(b *testing.B) {
+	g := benchIngester(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := g.WriteRecord(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package omniv21
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/jf-tech/omniparser/extensions/omniv21/transform"
+)
+
+const (
+	floatFormatDefault = "default"
+	floatFormatDecimal = "decimal"
+)
+
+// OutputSettings controls how the transformed records are serialized into JSON. It's specified by the
+// 'output_settings' section of a schema, or by CreateParams, which takes precedence.
+type OutputSettings struct {
+	// PreserveOrder keeps the keys of an 'object' in the order they are declared in the schema, and the
+	// keys of an 'object_from' in the order they appear in the input, instead of sorting them.
+	PreserveOrder bool `json:"preserve_order,omitempty"`
+	// EscapeHTML escapes '<', '>' and '&' in strings, which is the default.
+	EscapeHTML *bool `json:"escape_html,omitempty"`
+	// Indent, if not empty, pretty-prints the records with it as the indentation.
+	Indent string `json:"indent,omitempty"`
+	// FloatFormat is either 'default', same as golang's encoding/json, which uses exponents for very large
+	// and very small numbers, or 'decimal', which never does.
+	FloatFormat string `json:"float_format,omitempty"`
+	// FloatDecimals, if specified, formats floats with the fixed number of decimals, without exponents.
+	FloatDecimals *int `json:"float_decimals,omitempty"`
+}
+
+func (s *OutputSettings) isDefault() bool {
+	return !s.PreserveOrder && (s.EscapeHTML == nil || *s.EscapeHTML) && s.Indent == "" &&
+		(s.FloatFormat == "" || s.FloatFormat == floatFormatDefault) && s.FloatDecimals == nil
+}
+
+func compileEncodePlan(decl *transform.Decl, settings *OutputSettings) *transform.EncodePlan {
+	return transform.CompileEncodePlan(decl, transform.EncodeOptions{
+		PreserveOrder: settings.PreserveOrder,
+		EscapeHTML:    settings.EscapeHTML == nil || *settings.EscapeHTML,
+	})
+}
+
+type keyOrderFunc func(obj map[string]interface{}) []string
+
+// outputEncoder serializes a transformed record according to the OutputSettings.
+type outputEncoder struct {
+	settings *OutputSettings
+	keyOrder keyOrderFunc
+	buf      *bytes.Buffer // the buffer being written into.
+	scratch  bytes.Buffer
+	enc      *json.Encoder // encodes the values outputEncoder doesn't natively handle into scratch.
+}
+
+func newOutputEncoder(settings *OutputSettings) *outputEncoder {
+	if settings == nil {
+		settings = &OutputSettings{}
+	}
+	e := &outputEncoder{settings: settings}
+	e.enc = json.NewEncoder(&e.scratch)
+	e.enc.SetEscapeHTML(settings.EscapeHTML == nil || *settings.EscapeHTML)
+	return e
+}
+
+// marshal serializes a transformed record. keyOrder, if not nil, returns the key order of an object in
+// the record. A nil outputEncoder uses the default settings.
+func (e *outputEncoder) marshal(v interface{}, keyOrder keyOrderFunc) ([]byte, error) {
+	if e == nil || e.settings.isDefault() {
+		return json.Marshal(v)
+	}
+	var buf bytes.Buffer
+	if err := e.encodeValue(&buf, v, keyOrder); err != nil {
+		return nil, err
+	}
+	return e.indent(buf.Bytes()), nil
+}
+
+// encodeValue writes the compact JSON of v into buf.
+func (e *outputEncoder) encodeValue(buf *bytes.Buffer, v interface{}, keyOrder keyOrderFunc) error {
+	e.buf, e.keyOrder = buf, keyOrder
+	defer func() { e.buf, e.keyOrder = nil, nil }()
+	return e.encode(v)
+}
+
+// indent returns the compact JSON b indented, if so specified by the settings; otherwise b as is.
+func (e *outputEncoder) indent(b []byte) []byte {
+	if e.settings.Indent == "" {
+		return b
+	}
+	var indented bytes.Buffer
+	// the compact encoding is valid JSON, so json.Indent won't fail.
+	_ = json.Indent(&indented, b, "", e.settings.Indent)
+	return indented.Bytes()
+}
+
+func (e *outputEncoder) encode(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf.WriteString("null")
+	case map[string]interface{}:
+		return e.encodeObject(v)
+	case []interface{}:
+		e.buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			if err := e.encode(elem); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte(']')
+	case float64:
+		return e.encodeFloat(v)
+	default:
+		return e.encodeOther(v)
+	}
+	return nil
+}
+
+func (e *outputEncoder) encodeObject(obj map[string]interface{}) error {
+	var keys []string
+	if e.settings.PreserveOrder && e.keyOrder != nil {
+		keys = e.keyOrder(obj)
+	}
+	if !keysCover(keys, obj) {
+		// not an object created by a transform (e.g. returned by a custom_func), or modified since, sort
+		// the keys just like encoding/json does.
+		keys = make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+	e.buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		if err := e.encodeOther(key); err != nil {
+			return err
+		}
+		e.buf.WriteByte(':')
+		if err := e.encode(obj[key]); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+// keysCover tells whether keys, which are distinct, are exactly the keys of obj.
+func keysCover(keys []string, obj map[string]interface{}) bool {
+	if len(keys) != len(obj) {
+		return false
+	}
+	for _, key := range keys {
+		if _, found := obj[key]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *outputEncoder) encodeFloat(f float64) error {
+	switch {
+	case math.IsNaN(f) || math.IsInf(f, 0):
+		// let encoding/json report the unsupported value.
+		return e.encodeOther(f)
+	case e.settings.FloatDecimals != nil:
+		e.buf.WriteString(strconv.FormatFloat(f, 'f', *e.settings.FloatDecimals, 64))
+	case e.settings.FloatFormat == floatFormatDecimal:
+		e.buf.WriteString(strconv.FormatFloat(f, 'f', -1, 64))
+	default:
+		return e.encodeOther(f)
+	}
+	return nil
+}
+
+func (e *outputEncoder) encodeOther(v interface{}) error {
+	e.scratch.Reset()
+	if err := e.enc.Encode(v); err != nil {
+		return err
+	}
+	// json.Encoder.Encode appends a newline.
+	e.buf.Write(bytes.TrimSuffix(e.scratch.Bytes(), []byte("\n")))
+	return nil
+}
@@ -0,0 +1,107 @@
+package omniv21
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jf-tech/go-corelib/strs"
+	"github.com/stretchr/testify/assert"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func intPtr(n int) *int { return &n }
+
+func TestOutputEncoder_Marshal(t *testing.T) {
+	obj := map[string]interface{}{
+		"z":     "<a&b>",
+		"a":     []interface{}{1.5e-7, float64(100), int64(3), nil, true},
+		"other": map[string]string{"y": "1", "x": "2"},
+	}
+	keyOrder := func(m map[string]interface{}) []string {
+		if _, ok := m["z"]; ok {
+			return []string{"z", "a", "other"}
+		}
+		return nil
+	}
+	for _, test := range []struct {
+		name     string
+		settings *OutputSettings
+		v        interface{}
+		err      string
+		expected string
+	}{
+		{
+			name:     "nil settings",
+			settings: nil,
+			v:        obj,
+			expected: `{"a":[1.5e-7,100,3,null,true],"other":{"x":"2","y":"1"},"z":"\u003ca\u0026b\u003e"}`,
+		},
+		{
+			name:     "default settings",
+			settings: &OutputSettings{EscapeHTML: boolPtr(true), FloatFormat: "default"},
+			v:        obj,
+			expected: `{"a":[1.5e-7,100,3,null,true],"other":{"x":"2","y":"1"},"z":"\u003ca\u0026b\u003e"}`,
+		},
+		{
+			name:     "preserve order",
+			settings: &OutputSettings{PreserveOrder: true},
+			v:        obj,
+			expected: `{"z":"\u003ca\u0026b\u003e","a":[1.5e-7,100,3,null,true],"other":{"x":"2","y":"1"}}`,
+		},
+		{
+			name:     "preserve order but no key order recorded",
+			settings: &OutputSettings{PreserveOrder: true},
+			v:        []interface{}{map[string]interface{}{"b": "1", "a": "2"}},
+			expected: `[{"a":"2","b":"1"}]`,
+		},
+		{
+			name:     "preserve order but key order stale",
+			settings: &OutputSettings{PreserveOrder: true},
+			v:        map[string]interface{}{"z": "1", "b": "2", "other": "3"},
+			expected: `{"b":"2","other":"3","z":"1"}`,
+		},
+		{
+			name:     "no html escaping",
+			settings: &OutputSettings{EscapeHTML: boolPtr(false)},
+			v:        map[string]interface{}{"<k>": "<a&b>", "other": map[string]string{"x": "&"}},
+			expected: `{"<k>":"<a&b>","other":{"x":"&"}}`,
+		},
+		{
+			name:     "indent",
+			settings: &OutputSettings{Indent: "  "},
+			v:        map[string]interface{}{"a": []interface{}{"1"}, "b": map[string]interface{}{}},
+			expected: "{\n  \"a\": [\n    \"1\"\n  ],\n  \"b\": {}\n}",
+		},
+		{
+			name:     "decimal floats",
+			settings: &OutputSettings{FloatFormat: "decimal"},
+			v:        []interface{}{1.5e-7, 1e21, 2.5, int64(7)},
+			expected: `[0.00000015,1000000000000000000000,2.5,7]`,
+		},
+		{
+			name:     "fixed decimals",
+			settings: &OutputSettings{FloatDecimals: intPtr(2)},
+			v:        []interface{}{1.5e-7, 1e21, 2.555, int64(7), strs.StrPtr("s")},
+			expected: `[0.00,1000000000000000000000.00,2.56,7,"s"]`,
+		},
+		{
+			name:     "unsupported float",
+			settings: &OutputSettings{FloatFormat: "decimal"},
+			v:        []interface{}{math.Inf(1)},
+			err:      "json: unsupported value: +Inf",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := newOutputEncoder(test.settings).marshal(test.v, keyOrder)
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Nil(t, b)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, string(b))
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package omniv21
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jf-tech/omniparser/idr"
+	"github.com/jf-tech/omniparser/schemahandler"
+)
+
+// redacted is what the sensitive values are replaced with in error messages and raw record dumps.
+const redacted = "[REDACTED]"
+
+// sensitiveXPaths returns the schema's 'sensitive_xpaths', the xpaths, relative to each record, of the
+// input values that are redacted just like the ones of 'sensitive' decls.
+func sensitiveXPaths(ctx *schemahandler.CreateCtx) ([]string, error) {
+	var schema struct {
+		SensitiveXPaths []string `json:"sensitive_xpaths"`
+	}
+	// We did json schema validation earlier, so this unmarshal guarantees to succeed.
+	_ = json.Unmarshal(ctx.Content, &schema)
+	for _, xpath := range schema.SensitiveXPaths {
+		if err := idr.ValidateXPathFuncs(xpath); err != nil {
+			return nil, err
+		}
+	}
+	return schema.SensitiveXPaths, nil
+}
+
+// redact replaces all the occurrences of the sensitive values in s. A value isn't replaced where it's part
+// of a longer word or number, so that, e.g., a sensitive value '1' doesn't garble 'line 12'. Longer values
+// are replaced first, so that a value that is part of another doesn't leave the rest of the other behind.
+func redact(s string, values []string) string {
+	if len(values) == 0 {
+		return s
+	}
+	values = append([]string(nil), values...)
+	sort.SliceStable(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	for i, value := range values {
+		if value == "" || (i > 0 && value == values[i-1]) {
+			continue
+		}
+		s = redactValue(s, value)
+	}
+	return s
+}
+
+func redactValue(s, value string) string {
+	var b strings.Builder
+	for start := 0; ; {
+		i := strings.Index(s[start:], value)
+		if i < 0 {
+			if b.Len() == 0 {
+				return s
+			}
+			b.WriteString(s[start:])
+			return b.String()
+		}
+		i += start
+		end := i + len(value)
+		if continuesWord(s[:i], value, false) || continuesWord(s[end:], value, true) {
+			b.WriteString(s[start : i+1])
+			start = i + 1
+			continue
+		}
+		b.WriteString(s[start:i])
+		b.WriteString(redacted)
+		start = end
+	}
+}
+
+// continuesWord checks if a value found in a string is part of a longer word or number, given the part of
+// the string before or after the value.
+func continuesWord(s, value string, after bool) bool {
+	var r, edge rune
+	if after {
+		r, _ = utf8.DecodeRuneInString(s)
+		edge, _ = utf8.DecodeLastRuneInString(value)
+	} else {
+		r, _ = utf8.DecodeLastRuneInString(s)
+		edge, _ = utf8.DecodeRuneInString(value)
+	}
+	return isWordRune(r) && isWordRune(edge)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// withJSONEscaped returns the values along with their JSON escaped forms, if different, so that they can
+// be redacted from JSON dumps as well.
+func withJSONEscaped(values []string) []string {
+	result := append([]string(nil), values...)
+	for _, value := range values {
+		b, _ := json.Marshal(value)
+		if escaped := string(b[1 : len(b)-1]); escaped != value {
+			result = append(result, escaped)
+		}
+	}
+	return result
+}
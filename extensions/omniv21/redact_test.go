@@ -0,0 +1,65 @@
+package omniv21
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		s        string
+		values   []string
+		expected string
+	}{
+		{
+			name:     "no values",
+			s:        "ssn '123-45-6789' is invalid",
+			values:   nil,
+			expected: "ssn '123-45-6789' is invalid",
+		},
+		{
+			name:     "all occurrences redacted",
+			s:        "ssn '123-45-6789' is invalid, 123-45-6789",
+			values:   []string{"123-45-6789"},
+			expected: "ssn '[REDACTED]' is invalid, [REDACTED]",
+		},
+		{
+			name:     "part of a longer number or word not redacted",
+			s:        "input 'test' line 12: value '1' is invalid, 1a and a1 neither",
+			values:   []string{"1"},
+			expected: "input 'test' line 12: value '[REDACTED]' is invalid, 1a and a1 neither",
+		},
+		{
+			name:     "values not starting or ending with letters or digits redacted anywhere",
+			s:        "a-12/30-b",
+			values:   []string{"-12/30-"},
+			expected: "a[REDACTED]b",
+		},
+		{
+			name:     "longer values redacted first",
+			s:        "card '4111 1111' / '4111'",
+			values:   []string{"4111", "4111 1111", "4111"},
+			expected: "card '[REDACTED]' / '[REDACTED]'",
+		},
+		{
+			name:     "empty values ignored",
+			s:        "abc",
+			values:   []string{""},
+			expected: "abc",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, redact(test.s, test.values))
+		})
+	}
+}
+
+func TestWithJSONEscaped(t *testing.T) {
+	values := []string{"plain", `"quoted"`, "<a&b>"}
+	assert.Equal(t,
+		[]string{"plain", `"quoted"`, "<a&b>", `\"quoted\"`, `\u003ca\u0026b\u003e`},
+		withJSONEscaped(values))
+	assert.Equal(t, []string{"plain", `"quoted"`, "<a&b>"}, values)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/jf-tech/omniparser/extensions/omniv21"
 	v21 "github.com/jf-tech/omniparser/extensions/omniv21/customfuncs"
 	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat"
+	"github.com/jf-tech/omniparser/extensions/omniv21/samples"
 	"github.com/jf-tech/omniparser/extensions/omniv21/samples/customfileformats/jsonlog/jsonlogformat"
 	"github.com/jf-tech/omniparser/transformctx"
 )
@@ -87,3 +88,22 @@ func TestSample(t *testing.T) {
 	}
 	cupaloy.SnapshotT(t, jsons.BPJ("["+strings.Join(records, ",")+"]"))
 }
+
+func TestSample_WriteRecordTo(t *testing.T) {
+	schemaFile := "./sample_schema.json"
+	samples.AssertWriteRecordToIdenticalToRead(t, schemaFile, "./sample.log",
+		omniparser.Extension{
+			CreateSchemaHandler: omniv21.CreateSchemaHandler,
+			CreateSchemaHandlerParams: &omniv21.CreateParams{
+				CustomFileFormats: []fileformat.FileFormat{
+					jsonlogformat.NewJSONLogFileFormat(filepath.Base(schemaFile)),
+				},
+			},
+			CustomFuncs: customfuncs.Merge(
+				customfuncs.CommonCustomFuncs,
+				v21.OmniV21CustomFuncs,
+				customfuncs.CustomFuncs{
+					"normalize_severity": normalizeSeverity,
+				}),
+		})
+}
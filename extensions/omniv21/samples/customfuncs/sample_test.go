@@ -16,6 +16,7 @@ import (
 	"github.com/jf-tech/omniparser/customfuncs"
 	"github.com/jf-tech/omniparser/extensions/omniv21"
 	v21 "github.com/jf-tech/omniparser/extensions/omniv21/customfuncs"
+	"github.com/jf-tech/omniparser/extensions/omniv21/samples"
 	"github.com/jf-tech/omniparser/idr"
 	"github.com/jf-tech/omniparser/transformctx"
 )
@@ -63,6 +64,21 @@ func TestSample(t *testing.T) {
 	cupaloy.SnapshotT(t, jsons.BPJ("["+strings.Join(records, ",")+"]"))
 }
 
+func TestSample_WriteRecordTo(t *testing.T) {
+	samples.AssertWriteRecordToIdenticalToRead(t, "./sample_schema.json", "./sample.xml",
+		omniparser.Extension{
+			CreateSchemaHandler: omniv21.CreateSchemaHandler,
+			CustomFuncs: customfuncs.Merge(
+				customfuncs.CommonCustomFuncs,
+				v21.OmniV21CustomFuncs,
+				customfuncs.CustomFuncs{
+					"employee_personal_details_lookup": employeePersonalDetailsLookup,
+					"employee_business_details_lookup": employeeBusinessDetailsLookup,
+					"employee_team_lookup":             employeeTempLookup,
+				}),
+		})
+}
+
 // Pretend we need to do some secure database look-up for employee's various info based on id.
 // The result can be one of these:
 // - you can directly return a string value, if this lookup yields a simple string value.
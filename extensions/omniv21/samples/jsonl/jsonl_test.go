@@ -0,0 +1,55 @@
+package jsonl
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bradleyjkemp/cupaloy"
+	"github.com/jf-tech/go-corelib/jsons"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jf-tech/omniparser"
+	"github.com/jf-tech/omniparser/errs"
+	"github.com/jf-tech/omniparser/extensions/omniv21/samples"
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+func Test1_Events(t *testing.T) {
+	cupaloy.SnapshotT(t, jsons.BPJ(samples.SampleTestCommon(
+		t, "./1_events.schema.json", "./1_events.input.jsonl")))
+}
+
+func TestMalformedLines(t *testing.T) {
+	schema, err := omniparser.NewSchema("test-schema", strings.NewReader(`{
+		"parser_settings": { "version": "omni.2.1", "file_format_type": "jsonl" },
+		"transform_declarations": { "FINAL_OUTPUT": { "object": { "id": { "xpath": "id", "type": "int" } } } }
+	}`))
+	assert.NoError(t, err)
+	transform, err := schema.NewTransform("test-input", strings.NewReader(
+		"{\"id\": 1}\n{\"id\": 2,\n{\"id\": \"x\"}\n{\"id\": 4}\n"), &transformctx.Ctx{})
+	assert.NoError(t, err)
+	var records []string
+	for {
+		record, err := transform.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// malformed lines, just like the records failing to transform, are skipped.
+			assert.True(t, errs.IsErrTransformFailed(err))
+			records = append(records, err.Error())
+			continue
+		}
+		records = append(records, string(record))
+	}
+	assert.Equal(t,
+		[]string{
+			`{"id":1}`,
+			`input 'test-input' line 2: invalid JSON: unexpected end of JSON input`,
+			`input 'test-input' line 3: fail to transform. err: unable to convert value 'x' to type 'int' on ` +
+				`'FINAL_OUTPUT.id', err: strconv.ParseInt: parsing "x": invalid syntax`,
+			`{"id":4}`,
+		},
+		records)
+}
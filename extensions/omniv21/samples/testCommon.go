@@ -1,6 +1,7 @@
 package samples
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
 	"os"
@@ -56,5 +57,45 @@ func SampleTestCommon(t *testing.T, schemaFile, inputFile string) string {
 			TransformedRecord: transformed,
 		})
 	}
+	AssertWriteRecordToIdenticalToRead(t, schemaFile, inputFile)
 	return jsons.BMM(records)
 }
+
+// AssertWriteRecordToIdenticalToRead verifies the omniparser.RecordWriter implemented by the Transform
+// writes exactly the same records as Read returns.
+func AssertWriteRecordToIdenticalToRead(t *testing.T, schemaFile, inputFile string, exts ...omniparser.Extension) {
+	newTransform := func() (omniparser.Transform, func()) {
+		schemaFileReader, err := os.Open(schemaFile)
+		assert.NoError(t, err)
+		defer schemaFileReader.Close()
+		inputFileReader, err := os.Open(inputFile)
+		assert.NoError(t, err)
+		schema, err := omniparser.NewSchema(filepath.Base(schemaFile), schemaFileReader, exts...)
+		assert.NoError(t, err)
+		transform, err := schema.NewTransform(filepath.Base(inputFile), inputFileReader, &transformctx.Ctx{})
+		assert.NoError(t, err)
+		return transform, func() { _ = inputFileReader.Close() }
+	}
+	reader, closeReader := newTransform()
+	defer closeReader()
+	transform, closeTransform := newTransform()
+	defer closeTransform()
+	writer, ok := transform.(omniparser.RecordWriter)
+	assert.True(t, ok)
+	var buf bytes.Buffer
+	for {
+		expected, expectedErr := reader.Read()
+		buf.Reset()
+		n, err := writer.WriteRecordTo(&buf)
+		assert.Equal(t, expectedErr, err)
+		if err != nil {
+			assert.Equal(t, int64(0), n)
+			if err == io.EOF {
+				return
+			}
+			continue
+		}
+		assert.Equal(t, int64(buf.Len()), n)
+		assert.Equal(t, string(expected), buf.String())
+	}
+}
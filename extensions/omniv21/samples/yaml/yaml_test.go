@@ -0,0 +1,60 @@
+package yaml
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bradleyjkemp/cupaloy"
+	"github.com/jf-tech/go-corelib/jsons"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jf-tech/omniparser"
+	"github.com/jf-tech/omniparser/errs"
+	"github.com/jf-tech/omniparser/extensions/omniv21/samples"
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+func Test1_Manifests(t *testing.T) {
+	cupaloy.SnapshotT(t, jsons.BPJ(samples.SampleTestCommon(
+		t, "./1_manifests.schema.json", "./1_manifests.input.yaml")))
+}
+
+func TestMalformedDocuments(t *testing.T) {
+	schema, err := omniparser.NewSchema("test-schema", strings.NewReader(`{
+		"parser_settings": { "version": "omni.2.1", "file_format_type": "yaml" },
+		"transform_declarations": { "FINAL_OUTPUT": { "object": { "id": { "xpath": "id", "type": "int" } } } }
+	}`))
+	assert.NoError(t, err)
+	transform, err := schema.NewTransform("test-input", strings.NewReader(
+		"id: 1\n---\n? [id]\n: 2\n---\nid: x\n---\nid: 4\n---\nid: [5\n---\nid: 6\n"), &transformctx.Ctx{})
+	assert.NoError(t, err)
+	var records []string
+	for {
+		record, err := transform.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && errs.IsErrTransformFailed(err) {
+			// documents that can't be converted, just like the records failing to transform, are skipped.
+			records = append(records, err.Error())
+			continue
+		}
+		if err != nil {
+			// while a malformed document stops the transform.
+			records = append(records, "fatal: "+err.Error())
+			break
+		}
+		records = append(records, string(record))
+	}
+	assert.Equal(t,
+		[]string{
+			`{"id":1}`,
+			`input 'test-input' line 3: mapping key must be a scalar`,
+			`input 'test-input' line 6: fail to transform. err: unable to convert value 'x' to type 'int' on ` +
+				`'FINAL_OUTPUT.id', err: strconv.ParseInt: parsing "x": invalid syntax`,
+			`{"id":4}`,
+			`fatal: input 'test-input' line 9: did not find expected ',' or ']'`,
+		},
+		records)
+}
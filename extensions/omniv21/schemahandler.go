@@ -1,20 +1,28 @@
 package omniv21
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
+	"github.com/jf-tech/omniparser/customfuncs"
 	"github.com/jf-tech/omniparser/errs"
+	v21customfuncs "github.com/jf-tech/omniparser/extensions/omniv21/customfuncs"
 	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat"
 	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat/csv"
 	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat/edi"
 	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat/fixedlength"
 	csv2 "github.com/jf-tech/omniparser/extensions/omniv21/fileformat/flatfile/csv"
 	fixedlength2 "github.com/jf-tech/omniparser/extensions/omniv21/fileformat/flatfile/fixedlength"
-	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat/json"
+	omnijson "github.com/jf-tech/omniparser/extensions/omniv21/fileformat/json"
+	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat/jsonl"
 	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat/xml"
+	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat/yaml"
 	"github.com/jf-tech/omniparser/extensions/omniv21/transform"
 	v21validation "github.com/jf-tech/omniparser/extensions/omniv21/validation"
+	"github.com/jf-tech/omniparser/idr"
 	"github.com/jf-tech/omniparser/schemahandler"
 	"github.com/jf-tech/omniparser/transformctx"
 	"github.com/jf-tech/omniparser/validation"
@@ -27,6 +35,13 @@ const (
 // CreateParams allows user of this 'omni.2.1' schema handler to provide creation customization.
 type CreateParams struct {
 	CustomFileFormats []fileformat.FileFormat
+	// JSSandbox, if specified, imposes limits and restrictions on the javascript custom_funcs.
+	JSSandbox *v21customfuncs.JSSandbox
+	// OutputSettings, if specified, overrides the schema's 'output_settings'.
+	OutputSettings *OutputSettings
+	// ReaderLimits, if specified, guards the input readers against hostile inputs. It's enforced by the
+	// builtin file formats, and by the custom ones whose readers implement fileformat.LimitsEnforcer.
+	ReaderLimits *idr.Limits
 	// Deprecated.
 	CustomParseFuncs transform.CustomParseFuncs
 }
@@ -42,6 +57,10 @@ func CreateSchemaHandler(ctx *schemahandler.CreateCtx) (schemahandler.SchemaHand
 		// err is already context formatted.
 		return nil, err
 	}
+	ctx, err = withJavaScript(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("schema '%s' 'js_library' validation failed: %s", ctx.Name, err.Error())
+	}
 	finalOutputDecl, err := transform.ValidateTransformDeclarations(
 		ctx.Content, ctx.CustomFuncs, customParseFuncs(ctx))
 	if err != nil {
@@ -49,6 +68,18 @@ func CreateSchemaHandler(ctx *schemahandler.CreateCtx) (schemahandler.SchemaHand
 			"schema '%s' 'transform_declarations' validation failed: %s",
 			ctx.Name, err.Error())
 	}
+	dedupKeyDecl, err := transform.ValidateDedupKeyDeclaration(
+		ctx.Content, ctx.CustomFuncs, customParseFuncs(ctx))
+	if err != nil {
+		return nil, fmt.Errorf(
+			"schema '%s' 'transform_declarations' validation failed: %s",
+			ctx.Name, err.Error())
+	}
+	sensitiveXPaths, err := sensitiveXPaths(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("schema '%s' 'sensitive_xpaths' validation failed: %s", ctx.Name, err.Error())
+	}
+	settings := outputSettings(ctx)
 	for _, fileFormat := range fileFormats(ctx) {
 		formatRuntime, err := fileFormat.ValidateSchema(
 			ctx.Header.ParserSettings.FileFormatType,
@@ -66,11 +97,85 @@ func CreateSchemaHandler(ctx *schemahandler.CreateCtx) (schemahandler.SchemaHand
 			fileFormat:      fileFormat,
 			formatRuntime:   formatRuntime,
 			finalOutputDecl: finalOutputDecl,
+			dedupKeyDecl:    dedupKeyDecl,
+			outputSettings:  settings,
+			encodePlan:      compileEncodePlan(finalOutputDecl, settings),
+			sensitiveXPaths: sensitiveXPaths,
 		}, nil
 	}
 	return nil, errs.ErrSchemaNotSupported
 }
 
+// withJavaScript returns a copy of the CreateCtx whose 'javascript' and 'javascript_with_context'
+// custom_funcs have the schema's 'js_library', if any, preloaded and the caller's JSSandbox, if any,
+// applied. If the JSSandbox disables javascript, the two custom_funcs are removed entirely.
+func withJavaScript(ctx *schemahandler.CreateCtx) (*schemahandler.CreateCtx, error) {
+	var schema struct {
+		JSLibrary json.RawMessage `json:"js_library"`
+	}
+	// We did json schema validation earlier, so this unmarshal guarantees to succeed.
+	_ = json.Unmarshal(ctx.Content, &schema)
+	sandbox := jsSandbox(ctx)
+	if len(schema.JSLibrary) == 0 && sandbox == nil {
+		return ctx, nil
+	}
+	ctxCopy := *ctx
+	if sandbox != nil && sandbox.Disabled {
+		if len(schema.JSLibrary) > 0 {
+			return ctx, errors.New("javascript is disabled")
+		}
+		ctxCopy.CustomFuncs = customfuncs.Merge(ctx.CustomFuncs)
+		delete(ctxCopy.CustomFuncs, "javascript")
+		delete(ctxCopy.CustomFuncs, "javascript_with_context")
+		return &ctxCopy, nil
+	}
+	var code string
+	if len(schema.JSLibrary) > 0 {
+		if err := json.Unmarshal(schema.JSLibrary, &code); err != nil {
+			// json schema validation guarantees if it's not a string, it's an array of strings.
+			var lines []string
+			_ = json.Unmarshal(schema.JSLibrary, &lines)
+			code = strings.Join(lines, "\n")
+		}
+	}
+	lib, err := v21customfuncs.NewJSLibrary(code, sandbox)
+	if err != nil {
+		return ctx, err
+	}
+	ctxCopy.CustomFuncs = customfuncs.Merge(ctx.CustomFuncs, lib.CustomFuncs())
+	return &ctxCopy, nil
+}
+
+func jsSandbox(ctx *schemahandler.CreateCtx) *v21customfuncs.JSSandbox {
+	if ctx.CreateParams == nil {
+		return nil
+	}
+	params, ok := ctx.CreateParams.(*CreateParams)
+	if !ok {
+		return nil
+	}
+	return params.JSSandbox
+}
+
+func outputSettings(ctx *schemahandler.CreateCtx) *OutputSettings {
+	if params, ok := ctx.CreateParams.(*CreateParams); ok && params.OutputSettings != nil {
+		return params.OutputSettings
+	}
+	var schema struct {
+		OutputSettings OutputSettings `json:"output_settings"`
+	}
+	// We did json schema validation earlier, so this unmarshal guarantees to succeed.
+	_ = json.Unmarshal(ctx.Content, &schema)
+	return &schema.OutputSettings
+}
+
+func readerLimits(ctx *schemahandler.CreateCtx) *idr.Limits {
+	if params, ok := ctx.CreateParams.(*CreateParams); ok {
+		return params.ReaderLimits
+	}
+	return nil
+}
+
 func customParseFuncs(ctx *schemahandler.CreateCtx) transform.CustomParseFuncs {
 	if ctx.CreateParams == nil {
 		return nil
@@ -92,8 +197,10 @@ func fileFormats(ctx *schemahandler.CreateCtx) []fileformat.FileFormat {
 		edi.NewEDIFileFormat(ctx.Name),
 		fixedlength.NewFixedLengthFileFormat(ctx.Name),
 		fixedlength2.NewFixedLengthFileFormat(ctx.Name),
-		json.NewJSONFileFormat(ctx.Name),
+		omnijson.NewJSONFileFormat(ctx.Name),
+		jsonl.NewJSONLFileFormat(ctx.Name),
 		xml.NewXMLFileFormat(ctx.Name),
+		yaml.NewYAMLFileFormat(ctx.Name),
 	}
 	if ctx.CreateParams == nil {
 		return formats
@@ -112,18 +219,34 @@ type schemaHandler struct {
 	fileFormat      fileformat.FileFormat
 	formatRuntime   interface{}
 	finalOutputDecl *transform.Decl
+	dedupKeyDecl    *transform.Decl // nil if the schema has no 'DEDUP_KEY'.
+	outputSettings  *OutputSettings
+	encodePlan      *transform.EncodePlan // compiled once, shared by all the ingesters.
+	sensitiveXPaths []string
 }
 
 func (h *schemaHandler) NewIngester(ctx *transformctx.Ctx, input io.Reader) (schemahandler.Ingester, error) {
+	if ctx.Dedup != nil && ctx.Dedup.Key == transformctx.DedupBySchemaKey && h.dedupKeyDecl == nil {
+		return nil, fmt.Errorf("schema '%s' has no 'DEDUP_KEY' to dedup records by", h.ctx.Name)
+	}
 	reader, err := h.fileFormat.CreateFormatReader(ctx.InputName, input, h.formatRuntime)
 	if err != nil {
 		return nil, err
 	}
+	if limits := readerLimits(h.ctx); limits != nil {
+		if enforcer, ok := reader.(fileformat.LimitsEnforcer); ok {
+			enforcer.SetLimits(*limits)
+		}
+	}
 	return &ingester{
 		finalOutputDecl:  h.finalOutputDecl,
+		dedupKeyDecl:     h.dedupKeyDecl,
 		customFuncs:      h.ctx.CustomFuncs,
 		customParseFuncs: customParseFuncs(h.ctx),
 		ctx:              ctx,
 		reader:           reader,
+		output:           newOutputEncoder(h.outputSettings),
+		encodePlan:       h.encodePlan,
+		sensitiveXPaths:  h.sensitiveXPaths,
 	}, nil
 }
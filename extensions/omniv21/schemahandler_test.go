@@ -6,11 +6,13 @@ import (
 	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/jf-tech/omniparser/customfuncs"
 	"github.com/jf-tech/omniparser/errs"
+	v21customfuncs "github.com/jf-tech/omniparser/extensions/omniv21/customfuncs"
 	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat"
 	"github.com/jf-tech/omniparser/extensions/omniv21/fileformat/json"
 	"github.com/jf-tech/omniparser/extensions/omniv21/transform"
@@ -129,6 +131,132 @@ func TestCreateHandler_TransformDeclarationsInCodeValidationFailed(t *testing.T)
 	assert.Nil(t, p)
 }
 
+func TestCreateHandler_JSLibraryValidationFailed(t *testing.T) {
+	p, err := CreateSchemaHandler(
+		&schemahandler.CreateCtx{
+			Name: "test-schema",
+			Header: header.Header{
+				ParserSettings: header.ParserSettings{
+					Version:        version,
+					FileFormatType: "json",
+				},
+			},
+			Content: []byte(`{
+					"js_library": [
+						"function double(x) {",
+						"    return x * ;",
+						"}"
+					],
+					"transform_declarations": { "FINAL_OUTPUT": { "xpath": "." }}
+				}`),
+		})
+	assert.Error(t, err)
+	assert.Equal(t,
+		`schema 'test-schema' 'js_library' validation failed: SyntaxError: js_library: Line 2:16 Unexpected token ; (and 1 more errors)`,
+		err.Error())
+	assert.Nil(t, p)
+}
+
+// testJSFunc is the signature of the 'javascript' custom func.
+type testJSFunc = func(*transformctx.Ctx, string, ...interface{}) (interface{}, error)
+
+func TestCreateHandler_JSLibrary_Success(t *testing.T) {
+	for _, jsLibrary := range []string{
+		`"function double(x) { return x * 2; }"`,
+		`[ "function double(x) {", "    return x * 2;", "}" ]`,
+	} {
+		p, err := CreateSchemaHandler(
+			&schemahandler.CreateCtx{
+				Header: header.Header{
+					ParserSettings: header.ParserSettings{
+						Version:        version,
+						FileFormatType: "json",
+					},
+				},
+				Content: []byte(`{
+					"js_library": ` + jsLibrary + `,
+					"transform_declarations": {
+						"FINAL_OUTPUT": { "custom_func": { "name": "javascript", "args": [ { "const": "double(2)" } ] } }
+					}
+				}`),
+				CustomFuncs: customfuncs.CommonCustomFuncs,
+			})
+		assert.NoError(t, err)
+		fn := p.(*schemaHandler).ctx.CustomFuncs["javascript"].(testJSFunc)
+		v, err := fn(nil, "double(21)")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(42), v)
+		assert.NotNil(t, p.(*schemaHandler).ctx.CustomFuncs["upper"])
+	}
+}
+
+func TestCreateHandler_JSSandbox(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		jsLibrary string
+		sandbox   *v21customfuncs.JSSandbox
+		err       string
+	}{
+		{
+			name:    "javascript disabled",
+			sandbox: &v21customfuncs.JSSandbox{Disabled: true},
+			err:     `schema 'test-schema' 'transform_declarations' validation failed: unknown custom_func 'javascript' on 'FINAL_OUTPUT'`,
+		},
+		{
+			name:      "javascript disabled with js_library",
+			jsLibrary: `"function double(x) { return x * 2; }"`,
+			sandbox:   &v21customfuncs.JSSandbox{Disabled: true},
+			err:       `schema 'test-schema' 'js_library' validation failed: javascript is disabled`,
+		},
+		{
+			name:      "js_library timed out",
+			jsLibrary: `"while (true) {}"`,
+			sandbox:   &v21customfuncs.JSSandbox{Timeout: 10 * time.Millisecond},
+			err:       `schema 'test-schema' 'js_library' validation failed: javascript execution timed out after 10ms`,
+		},
+		{
+			name:    "sandbox applied without js_library",
+			sandbox: &v21customfuncs.JSSandbox{Timeout: 10 * time.Millisecond},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			jsLibrary := ""
+			if test.jsLibrary != "" {
+				jsLibrary = `"js_library": ` + test.jsLibrary + `,`
+			}
+			p, err := CreateSchemaHandler(
+				&schemahandler.CreateCtx{
+					Name: "test-schema",
+					Header: header.Header{
+						ParserSettings: header.ParserSettings{
+							Version:        version,
+							FileFormatType: "json",
+						},
+					},
+					Content: []byte(`{` + jsLibrary + `
+						"transform_declarations": {
+							"FINAL_OUTPUT": { "custom_func": { "name": "javascript", "args": [ { "const": "1" } ] } }
+						}
+					}`),
+					CustomFuncs:  customfuncs.Merge(customfuncs.CommonCustomFuncs, v21customfuncs.OmniV21CustomFuncs),
+					CreateParams: &CreateParams{JSSandbox: test.sandbox},
+				})
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Nil(t, p)
+				return
+			}
+			assert.NoError(t, err)
+			fn := p.(*schemaHandler).ctx.CustomFuncs["javascript"].(testJSFunc)
+			v, err := fn(nil, "while (true) {}")
+			assert.Error(t, err)
+			assert.Equal(t, "javascript execution timed out after 10ms", err.Error())
+			assert.Nil(t, v)
+		})
+	}
+}
+
 func TestCreateHandler_HandlerParamsTypeNotRight_Fallback(t *testing.T) {
 	p, err := CreateSchemaHandler(
 		&schemahandler.CreateCtx{
@@ -267,3 +395,174 @@ func TestNewIngester_CustomFileFormat_Success(t *testing.T) {
 	assert.Equal(t, "test input", string(data))
 	assert.Equal(t, "test runtime", r.runtime.(string))
 }
+
+func TestNewIngester_ReaderLimits(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		limits *idr.Limits
+		err    string
+	}{
+		{
+			name:   "no limits",
+			limits: nil,
+			err:    "",
+		},
+		{
+			name:   "limit exceeded",
+			limits: &idr.Limits{MaxRecordBytes: 15},
+			err:    "input 'test-input' before/near line 1: limit exceeded: record is larger than 15 bytes",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			h, err := CreateSchemaHandler(&schemahandler.CreateCtx{
+				Name: "test-schema",
+				Header: header.Header{
+					ParserSettings: header.ParserSettings{Version: version, FileFormatType: "json"},
+				},
+				Content: []byte(`{
+					"transform_declarations": { "FINAL_OUTPUT": { "xpath": "/*", "object": {
+						"name": { "xpath": "name" }
+					}}}
+				}`),
+				CustomFuncs:  customfuncs.CommonCustomFuncs,
+				CreateParams: &CreateParams{ReaderLimits: test.limits},
+			})
+			assert.NoError(t, err)
+			ip, err := h.NewIngester(
+				&transformctx.Ctx{InputName: "test-input"},
+				strings.NewReader(`[{"name": "a"}, {"name": "a very long name"}]`))
+			assert.NoError(t, err)
+			_, b, err := ip.Read()
+			assert.NoError(t, err)
+			assert.Equal(t, `{"name":"a"}`, string(b))
+			_, _, err = ip.Read()
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.False(t, ip.IsContinuableError(err))
+				assert.Equal(t, test.err, err.Error())
+			}
+		})
+	}
+}
+
+func TestCreateHandler_OutputSettings(t *testing.T) {
+	content := []byte(`{
+		"output_settings": { "preserve_order": true, "escape_html": false },
+		"transform_declarations": { "FINAL_OUTPUT": { "object": {
+			"z": { "xpath": "name" },
+			"a": { "xpath": "price", "type": "float" }
+		}}}
+	}`)
+	for _, test := range []struct {
+		name         string
+		createParams *CreateParams
+		expected     string
+	}{
+		{
+			name:     "settings from schema",
+			expected: `{"z":"<x&y>","a":0.0000012}`,
+		},
+		{
+			name:         "settings from create params override the schema's",
+			createParams: &CreateParams{OutputSettings: &OutputSettings{FloatFormat: "decimal"}},
+			expected:     `{"a":0.0000012,"z":"\u003cx\u0026y\u003e"}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := &schemahandler.CreateCtx{
+				Name: "test-schema",
+				Header: header.Header{
+					ParserSettings: header.ParserSettings{Version: version, FileFormatType: "json"},
+				},
+				Content:     content,
+				CustomFuncs: customfuncs.CommonCustomFuncs,
+			}
+			if test.createParams != nil {
+				ctx.CreateParams = test.createParams
+			}
+			h, err := CreateSchemaHandler(ctx)
+			assert.NoError(t, err)
+			ip, err := h.NewIngester(
+				&transformctx.Ctx{InputName: "test-input"},
+				strings.NewReader(`{"name": "<x&y>", "price": 1.2e-6}`))
+			assert.NoError(t, err)
+			_, b, err := ip.Read()
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, string(b))
+		})
+	}
+}
+
+func TestCreateHandler_SensitiveXPathsValidationFailed(t *testing.T) {
+	_, err := CreateSchemaHandler(&schemahandler.CreateCtx{
+		Name: "test-schema",
+		Header: header.Header{
+			ParserSettings: header.ParserSettings{Version: version, FileFormatType: "json"},
+		},
+		Content: []byte(`{
+			"sensitive_xpaths": [ "ssn", "*[omni:unknown(.)]" ],
+			"transform_declarations": { "FINAL_OUTPUT": { "xpath": "." } }
+		}`),
+		CustomFuncs: customfuncs.CommonCustomFuncs,
+	})
+	assert.Error(t, err)
+	assert.Equal(t,
+		"schema 'test-schema' 'sensitive_xpaths' validation failed: "+
+			"xpath '*[omni:unknown(.)]' is invalid: unknown xpath function 'omni:unknown'",
+		err.Error())
+}
+
+func TestNewIngester_Redaction(t *testing.T) {
+	h, err := CreateSchemaHandler(&schemahandler.CreateCtx{
+		Name: "test-schema",
+		Header: header.Header{
+			ParserSettings: header.ParserSettings{Version: version, FileFormatType: "json"},
+		},
+		Content: []byte(`{
+			"sensitive_xpaths": [ "dob" ],
+			"transform_declarations": { "FINAL_OUTPUT": { "xpath": "/*", "object": {
+				"name": { "xpath": "name" },
+				"ssn": { "xpath": "ssn", "type": "int", "sensitive": true },
+				"ssn_last4": { "custom_func": { "name": "mask", "args": [ { "xpath": "ssn" } ] } }
+			}}}
+		}`),
+		CustomFuncs: customfuncs.CommonCustomFuncs,
+	})
+	assert.NoError(t, err)
+	for _, write := range []bool{false, true} {
+		ip, err := h.NewIngester(
+			&transformctx.Ctx{InputName: "test-input"},
+			strings.NewReader(`[
+				{"name": "a", "ssn": "123456789", "dob": "1990-01-31"},
+				{"name": "b", "ssn": "123-45-6789", "dob": "1990-02-28"}
+			]`))
+		assert.NoError(t, err)
+		var raw schemahandler.RawRecord
+		if write {
+			var b strings.Builder
+			raw, _, err = ip.(schemahandler.RecordWriter).WriteRecord(&b)
+			assert.Equal(t, `{"name":"a","ssn":123456789,"ssn_last4":"*****6789"}`, b.String())
+		} else {
+			var b []byte
+			raw, b, err = ip.Read()
+			assert.Equal(t, `{"name":"a","ssn":123456789,"ssn_last4":"*****6789"}`, string(b))
+		}
+		assert.NoError(t, err)
+		assert.Equal(t,
+			`{"dob":"[REDACTED]","name":"a","ssn":"[REDACTED]"}`,
+			raw.(schemahandler.RedactedRawRecord).Redacted())
+		assert.Equal(t,
+			"input 'test-input' before/near line 3: [REDACTED] and [REDACTED] and a and 12",
+			ip.FmtErr("%s and %s and %s and %d", "123456789", "1990-01-31", "a", 12).Error())
+		_, _, err = ip.Read()
+		assert.Error(t, err)
+		assert.True(t, ip.IsContinuableError(err))
+		assert.Equal(t,
+			"input 'test-input' before/near line 4: fail to transform. err: unable to convert value "+
+				"'[REDACTED]' to type 'int' on 'FINAL_OUTPUT.ssn', err: strconv.ParseInt: parsing \"[REDACTED]\": "+
+				"invalid syntax",
+			err.Error())
+	}
+}
@@ -14,12 +14,15 @@ type kind string
 const (
 	kindConst       kind = "const"
 	kindExternal    kind = "external"
+	kindMeta        kind = "meta"
 	kindField       kind = "field"
 	kindObject      kind = "object"
+	kindObjectFrom  kind = "object_from"
 	kindArray       kind = "array"
 	kindCustomFunc  kind = "custom_func"
 	kindCustomParse kind = "custom_parse" // Deprecated
 	kindTemplate    kind = "template"
+	kindExpr        kind = "expr"
 )
 
 // resultType specifies the types of omni schema's output elements.
@@ -37,6 +40,9 @@ const (
 	// finalOutput is the special name of a Decl that is designated for the output
 	// for an omni schema.
 	finalOutput = "FINAL_OUTPUT"
+	// dedupKey is the special name of an optional Decl that computes the key of each record, by which
+	// duplicate records are detected.
+	dedupKey = "DEDUP_KEY"
 )
 
 // CustomFuncDecl is the decl for a "custom_func".
@@ -71,12 +77,135 @@ func (d *CustomFuncDecl) deepCopy() *CustomFuncDecl {
 	return dest
 }
 
+// ExprDecl is the decl for an "expr".
+type ExprDecl struct {
+	Expression string           `json:"expression,omitempty"`
+	Vars       map[string]*Decl `json:"vars,omitempty"`
+	fqdn       string           // internal; never unmarshaled from a schema.
+	compiled   *compiledExpr    // internal; compiled at schema loading time.
+}
+
+// MarshalJSON is the custom JSON marshaler for ExprDecl.
+func (d ExprDecl) MarshalJSON() ([]byte, error) {
+	type Alias ExprDecl
+	return json.Marshal(&struct {
+		Alias
+		FQDN string `json:"fqdn,omitempty"` // Marshal into JSON for test snapshots.
+	}{
+		Alias: Alias(d),
+		FQDN:  d.fqdn,
+	})
+}
+
+// Note only deep-copy all the public fields, those internal computed fields are not copied.
+func (d *ExprDecl) deepCopy() *ExprDecl {
+	dest := &ExprDecl{}
+	dest.Expression = d.Expression
+	if len(d.Vars) > 0 {
+		dest.Vars = map[string]*Decl{}
+		for varName, varDecl := range d.Vars {
+			dest.Vars[varName] = varDecl.deepCopy()
+		}
+	}
+	return dest
+}
+
+const (
+	onDuplicateFirst = "first"
+	onDuplicateLast  = "last"
+	onDuplicateArray = "array"
+	onDuplicateError = "error"
+)
+
+// ObjectFromDecl is the decl for an "object_from", which builds an object out of the entries selected by
+// xpath, with the keys and values computed from each entry.
+type ObjectFromDecl struct {
+	XPath       string `json:"xpath,omitempty"`
+	Key         *Decl  `json:"key,omitempty"`
+	Value       *Decl  `json:"value,omitempty"`
+	OnDuplicate string `json:"on_duplicate,omitempty"`
+	fqdn        string // internal; never unmarshaled from a schema.
+}
+
+// MarshalJSON is the custom JSON marshaler for ObjectFromDecl.
+func (d ObjectFromDecl) MarshalJSON() ([]byte, error) {
+	type Alias ObjectFromDecl
+	return json.Marshal(&struct {
+		Alias
+		FQDN string `json:"fqdn,omitempty"` // Marshal into JSON for test snapshots.
+	}{
+		Alias: Alias(d),
+		FQDN:  d.fqdn,
+	})
+}
+
+// Note only deep-copy all the public fields, those internal computed fields are not copied.
+func (d *ObjectFromDecl) deepCopy() *ObjectFromDecl {
+	dest := &ObjectFromDecl{}
+	dest.XPath = d.XPath
+	if d.Key != nil {
+		dest.Key = d.Key.deepCopy()
+	}
+	if d.Value != nil {
+		dest.Value = d.Value.deepCopy()
+	}
+	dest.OnDuplicate = d.OnDuplicate
+	return dest
+}
+
+// ValidateDecl is the decl for a "validate" block, which specifies the rules a decl's output value must
+// satisfy. All the violations in a record are collected and reported together, failing the record.
+type ValidateDecl struct {
+	Required  bool     `json:"required,omitempty"`
+	Regex     *string  `json:"regex,omitempty"`
+	Enum      []string `json:"enum,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+	MinLength *int     `json:"min_length,omitempty"`
+	MaxLength *int     `json:"max_length,omitempty"`
+	Luhn      bool     `json:"luhn,omitempty"`
+}
+
+func (d *ValidateDecl) deepCopy() *ValidateDecl {
+	dest := &ValidateDecl{}
+	dest.Required = d.Required
+	dest.Regex = strs.CopyStrPtr(d.Regex)
+	if d.Enum != nil {
+		dest.Enum = strs.CopySlice(d.Enum)
+	}
+	if d.Min != nil {
+		min := *d.Min
+		dest.Min = &min
+	}
+	if d.Max != nil {
+		max := *d.Max
+		dest.Max = &max
+	}
+	if d.MinLength != nil {
+		minLength := *d.MinLength
+		dest.MinLength = &minLength
+	}
+	if d.MaxLength != nil {
+		maxLength := *d.MaxLength
+		dest.MaxLength = &maxLength
+	}
+	dest.Luhn = d.Luhn
+	return dest
+}
+
 // Decl is the type for omni schema's `transform_declarations` declarations.
 type Decl struct {
 	// Const indicates the input element is a cost.
 	Const *string `json:"const,omitempty"`
 	// External indicates the input element is from an external property.
 	External *string `json:"external,omitempty"`
+	// Path specifies a dot-separated path into a structured external property value, such as "a.b.0",
+	// where numeric segments index into arrays.
+	Path *string `json:"path,omitempty"`
+	// Default specifies the value used when an external property, or the path into it, is missing.
+	Default json.RawMessage `json:"default,omitempty"`
+	// Meta indicates the input element is a metadata of the record being transformed.
+	Meta *string `json:"meta,omitempty"`
 	// XPath specifies an xpath for an input element.
 	XPath *string `json:"xpath,omitempty"`
 	// XPathDynamic specifies a dynamically constructed xpath for an input element.
@@ -87,8 +216,18 @@ type Decl struct {
 	CustomParse *string `json:"custom_parse,omitempty"`
 	// Template specifies the input element is a template.
 	Template *string `json:"template,omitempty"`
+	// Params specifies the names of the parameters a template accepts.
+	Params []string `json:"params,omitempty"`
+	// Args specifies the arguments bound to the parameters of the template referenced.
+	Args map[string]*Decl `json:"args,omitempty"`
+	// MaxDepth specifies how many times at most a template can recursively reference itself.
+	MaxDepth *int `json:"max_depth,omitempty"`
+	// Expr specifies the input element is computed by an expression.
+	Expr *ExprDecl `json:"expr,omitempty"`
 	// Object specifies the input element is an object.
 	Object map[string]*Decl `json:"object,omitempty"`
+	// ObjectFrom specifies the input element is an object with keys and values from the input.
+	ObjectFrom *ObjectFromDecl `json:"object_from,omitempty"`
 	// Array specifies the input element is an array.
 	Array []*Decl `json:"array,omitempty"`
 	// ResultType specifies the desired output type of element.
@@ -97,6 +236,13 @@ type Decl struct {
 	NoTrim bool `json:"no_trim,omitempty"`
 	// KeepEmptyOrNull specifies whether to keep an empty/null output or not.
 	KeepEmptyOrNull bool `json:"keep_empty_or_null,omitempty"`
+	// Validate specifies the rules the output element must satisfy.
+	Validate *ValidateDecl `json:"validate,omitempty"`
+	// Multi specifies a custom_func arg takes all the nodes matching its xpath, instead of a single one.
+	Multi bool `json:"multi,omitempty"`
+	// Sensitive specifies the input element, and everything it's made of, carries sensitive data (such as
+	// SSNs), whose values must be redacted from error messages and raw record dumps.
+	Sensitive bool `json:"sensitive,omitempty"`
 
 	// Internal fields are computed at schema loading time.
 	fqdn     string
@@ -104,6 +250,18 @@ type Decl struct {
 	hash     string
 	children []*Decl
 	parent   *Decl
+	srcPath  []string // the JSON path to the decl in the schema, used for locating it in error messages.
+	multiArg bool     // the array decl wrapping a 'multi' custom_func arg.
+	// whether the decl or any of its ancestors is marked 'sensitive'.
+	sensitive bool
+	// the unmarshaled 'default' of an external decl.
+	defaultValue interface{}
+	// the child names of an object decl, in the order they are declared in the schema.
+	keyOrder []string
+	// the scope of the template instance the decl belongs to, if any.
+	scope *templateScope
+	// the recursive template reference, lazily expanded at transform time.
+	recursion *templateRecursion
 }
 
 // MarshalJSON is the custom JSON marshaler for Decl.
@@ -145,16 +303,22 @@ func (d *Decl) resolveKind() {
 		d.kind = kindConst
 	case d.External != nil:
 		d.kind = kindExternal
+	case d.Meta != nil:
+		d.kind = kindMeta
 	case d.CustomFunc != nil:
 		d.kind = kindCustomFunc
 	case d.CustomParse != nil:
 		d.kind = kindCustomParse
 	case d.Object != nil:
 		d.kind = kindObject
+	case d.ObjectFrom != nil:
+		d.kind = kindObjectFrom
 	case d.Array != nil:
 		d.kind = kindArray
 	case d.Template != nil:
 		d.kind = kindTemplate
+	case d.Expr != nil:
+		d.kind = kindExpr
 	default:
 		d.kind = kindField
 	}
@@ -170,6 +334,11 @@ func (d *Decl) deepCopy() *Decl {
 	dest := &Decl{}
 	dest.Const = strs.CopyStrPtr(d.Const)
 	dest.External = strs.CopyStrPtr(d.External)
+	dest.Path = strs.CopyStrPtr(d.Path)
+	if d.Default != nil {
+		dest.Default = append(json.RawMessage(nil), d.Default...)
+	}
+	dest.Meta = strs.CopyStrPtr(d.Meta)
 	dest.XPath = strs.CopyStrPtr(d.XPath)
 	if d.XPathDynamic != nil {
 		dest.XPathDynamic = d.XPathDynamic.deepCopy()
@@ -179,12 +348,31 @@ func (d *Decl) deepCopy() *Decl {
 	}
 	dest.CustomParse = strs.CopyStrPtr(d.CustomParse)
 	dest.Template = strs.CopyStrPtr(d.Template)
+	if d.Params != nil {
+		dest.Params = strs.CopySlice(d.Params)
+	}
+	if len(d.Args) > 0 {
+		dest.Args = map[string]*Decl{}
+		for argName, argDecl := range d.Args {
+			dest.Args[argName] = argDecl.deepCopy()
+		}
+	}
+	if d.MaxDepth != nil {
+		maxDepth := *d.MaxDepth
+		dest.MaxDepth = &maxDepth
+	}
+	if d.Expr != nil {
+		dest.Expr = d.Expr.deepCopy()
+	}
 	if len(d.Object) > 0 {
 		dest.Object = map[string]*Decl{}
 		for childName, childDecl := range d.Object {
 			dest.Object[childName] = childDecl.deepCopy()
 		}
 	}
+	if d.ObjectFrom != nil {
+		dest.ObjectFrom = d.ObjectFrom.deepCopy()
+	}
 	for _, childDecl := range d.Array {
 		dest.Array = append(dest.Array, childDecl.deepCopy())
 	}
@@ -194,5 +382,10 @@ func (d *Decl) deepCopy() *Decl {
 	}
 	dest.NoTrim = d.NoTrim
 	dest.KeepEmptyOrNull = d.KeepEmptyOrNull
+	if d.Validate != nil {
+		dest.Validate = d.Validate.deepCopy()
+	}
+	dest.Multi = d.Multi
+	dest.Sensitive = d.Sensitive
 	return dest
 }
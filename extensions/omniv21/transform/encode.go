@@ -0,0 +1,282 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jf-tech/go-corelib/strs"
+
+	"github.com/jf-tech/omniparser/idr"
+)
+
+// EncodeOptions customizes the JSON written by EncodeNode.
+type EncodeOptions struct {
+	// PreserveOrder writes the fields of an object in the order they are declared in the schema, instead
+	// of sorting them.
+	PreserveOrder bool
+	// EscapeHTML escapes '<', '>' and '&' in the object keys.
+	EscapeHTML bool
+}
+
+// ValueEncoder writes the JSON of a value into buf. It's used by EncodeNode for all the values other than
+// the objects and arrays it writes by itself.
+type ValueEncoder func(buf *bytes.Buffer, v interface{}) error
+
+// EncodePlan is a Decl tree compiled for EncodeNode, which writes the JSON of the objects and arrays
+// directly, instead of building them up as maps and slices first and then marshaling them.
+type EncodePlan struct {
+	root *encodePlan
+}
+
+type encodePlanKind int
+
+const (
+	// the value of the decl is computed by parseNode, and then written by ValueEncoder.
+	encodePlanValue encodePlanKind = iota
+	encodePlanObject
+	encodePlanArray
+)
+
+type encodePlan struct {
+	kind     encodePlanKind
+	decl     *Decl
+	children []*encodePlan // object fields or array elements, in the order parseObject/parseArray do them.
+	keys     [][]byte      // the encoded keys (each with the trailing ':') of the object fields.
+	// the indexes of the object fields in the order they're written, or nil if it's the same as children's.
+	order []int
+}
+
+// CompileEncodePlan compiles a validated Decl tree for EncodeNode.
+func CompileEncodePlan(decl *Decl, opts EncodeOptions) *EncodePlan {
+	return &EncodePlan{root: compileEncodePlan(decl, opts)}
+}
+
+func compileEncodePlan(decl *Decl, opts EncodeOptions) *encodePlan {
+	plan := &encodePlan{kind: encodePlanValue, decl: decl}
+	// decls whose values need to be fully computed (in order to be validated or type converted) are
+	// left to parseNode.
+	if decl.Validate != nil || decl.ResultType != nil {
+		return plan
+	}
+	switch decl.kind {
+	case kindObject:
+		plan.kind = encodePlanObject
+		names := make([]string, len(decl.children))
+		for i, child := range decl.children {
+			names[i] = strs.LastNameletOfFQDNWithEsc(child.fqdn)
+			plan.children = append(plan.children, compileEncodePlan(child, opts))
+			plan.keys = append(plan.keys, encodeKey(names[i], opts.EscapeHTML))
+		}
+		plan.order = encodeOrder(decl, names, opts.PreserveOrder)
+	case kindArray:
+		plan.kind = encodePlanArray
+		for _, child := range decl.children {
+			plan.children = append(plan.children, compileEncodePlan(child, opts))
+		}
+	}
+	return plan
+}
+
+func encodeKey(name string, escapeHTML bool) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(escapeHTML)
+	// encoding a string never fails.
+	_ = enc.Encode(name)
+	return append(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), ':')
+}
+
+// encodeOrder returns the indexes of the object fields in the order they're written, if different from
+// the order of decl.children, which is the order the fields are computed.
+func encodeOrder(decl *Decl, names []string, preserveOrder bool) []int {
+	indexes := map[string]int{}
+	for i, name := range names {
+		indexes[name] = i
+	}
+	ordered := append([]string(nil), names...)
+	if preserveOrder {
+		ordered = decl.keyOrder
+	} else {
+		sort.Strings(ordered)
+	}
+	order := make([]int, len(ordered))
+	inOrder := true
+	for i, name := range ordered {
+		order[i] = indexes[name]
+		inOrder = inOrder && order[i] == i
+	}
+	if inOrder {
+		return nil
+	}
+	return order
+}
+
+// EncodeNode transforms a *Node (and its sub-tree) into an output record, just like ParseNode, except it
+// writes the JSON of the record into buf, identical to the JSON marshaled from what ParseNode returns.
+// On error, buf may contain a partially written record.
+func (p *parseCtx) EncodeNode(n *idr.Node, plan *EncodePlan, buf *bytes.Buffer, encodeValue ValueEncoder) error {
+	start := buf.Len()
+	omit, err := p.encodeNode(n, plan.root, buf, encodeValue, nil)
+	if err != nil {
+		return err
+	}
+	if omit {
+		// ParseNode returns nil for an omitted value.
+		buf.Truncate(start)
+		if err := encodeValue(buf, nil); err != nil {
+			return err
+		}
+	}
+	if plan.root.decl.fqdn == finalOutput {
+		return p.violationsErr()
+	}
+	return nil
+}
+
+// encodeNode writes the value of the decl, as saved by its parent (or as returned by ParseNode, if
+// parentDecl is nil) into buf. If the parent would omit the value, omit is true, in which case whatever
+// written into buf is to be discarded by the caller.
+func (p *parseCtx) encodeNode(
+	n *idr.Node, plan *encodePlan, buf *bytes.Buffer, encodeValue ValueEncoder, parentDecl *Decl) (
+	omit bool, err error) {
+	decl := plan.decl
+	if plan.kind == encodePlanValue {
+		v, err := p.ParseNode(n, decl)
+		if err != nil {
+			return false, err
+		}
+		if parentDecl == nil {
+			return false, encodeValue(buf, v)
+		}
+		// just like parseObject/parseArray, the value is saved as normalized per the decl.
+		omit = true
+		var encodeErr error
+		err = normalizeAndSaveValue(decl, v, func(normalizedValue interface{}) {
+			omit = false
+			encodeErr = encodeValue(buf, normalizedValue)
+		})
+		if err != nil {
+			return false, err
+		}
+		return omit, encodeErr
+	}
+	if decl.scope != nil && decl.scope.root == decl {
+		exitTemplateScope, err := p.enterTemplateScope(n, decl.scope)
+		if err != nil {
+			return false, err
+		}
+		defer exitTemplateScope()
+	}
+	var empty bool
+	switch plan.kind {
+	case encodePlanObject:
+		empty, err = p.encodeObject(n, plan, buf, encodeValue)
+	default:
+		empty, err = p.encodeArray(n, plan, buf, encodeValue)
+	}
+	if err != nil || !empty {
+		return false, err
+	}
+	// an empty object or array is omitted unless 'keep_empty_or_null'; if kept, an object is written
+	// as '{}', while an array is written as 'null', since parseArray yields a nil slice.
+	return !decl.KeepEmptyOrNull, nil
+}
+
+func (p *parseCtx) encodeObject(n *idr.Node, plan *encodePlan, buf *bytes.Buffer, encodeValue ValueEncoder) (
+	empty bool, err error) {
+	n, err = p.querySingleNodeFromXPath(n, plan.decl)
+	if err != nil {
+		return false, err
+	}
+	if n == nil {
+		buf.WriteString("null")
+		return true, nil
+	}
+	start := buf.Len()
+	buf.WriteByte('{')
+	// with plan.order, the fields are written without separators first, and then rearranged.
+	var spans [][2]int
+	if plan.order != nil {
+		spans = make([][2]int, len(plan.children))
+	}
+	count := 0
+	for i, child := range plan.children {
+		mark := buf.Len()
+		if count > 0 && plan.order == nil {
+			buf.WriteByte(',')
+		}
+		buf.Write(plan.keys[i])
+		omit, err := p.encodeNode(n, child, buf, encodeValue, plan.decl)
+		if err != nil {
+			return false, err
+		}
+		if omit {
+			buf.Truncate(mark)
+			continue
+		}
+		if spans != nil {
+			spans[i] = [2]int{mark, buf.Len()}
+		}
+		count++
+	}
+	if spans != nil && count > 0 {
+		fields := append([]byte(nil), buf.Bytes()[start+1:]...)
+		buf.Truncate(start + 1)
+		written := 0
+		for _, i := range plan.order {
+			if spans[i][1] == 0 {
+				continue
+			}
+			if written > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(fields[spans[i][0]-start-1 : spans[i][1]-start-1])
+			written++
+		}
+	}
+	buf.WriteByte('}')
+	return count == 0, nil
+}
+
+func (p *parseCtx) encodeArray(n *idr.Node, plan *encodePlan, buf *bytes.Buffer, encodeValue ValueEncoder) (
+	empty bool, err error) {
+	start := buf.Len()
+	buf.WriteByte('[')
+	count := 0
+	for _, child := range plan.children {
+		// see parseArray for how the elements are selected.
+		xpath, dynamic, err := p.computeXPath(n, child.decl)
+		if err != nil {
+			continue
+		}
+		childNodes, err := idr.MatchAllWithVars(n, xpath, p.xpathVars(plan.decl), xpathMatchFlags(dynamic))
+		if err != nil {
+			return false, fmt.Errorf("xpath query '%s' on '%s' failed: %s", xpath, child.decl.fqdn, err.Error())
+		}
+		p.markSensitiveNodes(child.decl, childNodes)
+		for _, childNode := range childNodes {
+			mark := buf.Len()
+			if count > 0 {
+				buf.WriteByte(',')
+			}
+			omit, err := p.encodeNode(childNode, child, buf, encodeValue, plan.decl)
+			if err != nil {
+				return false, err
+			}
+			if omit {
+				buf.Truncate(mark)
+				continue
+			}
+			count++
+		}
+	}
+	if count == 0 {
+		buf.Truncate(start)
+		buf.WriteString("null")
+		return true, nil
+	}
+	buf.WriteByte(']')
+	return false, nil
+}
@@ -0,0 +1,142 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testValueEncoder(buf *bytes.Buffer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+func TestEncodeNode(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		declJSON string
+		err      string
+	}{
+		{
+			name: "object with omitted and kept fields",
+			declJSON: `"FINAL_OUTPUT": { "object": {
+                "b": { "xpath": "B" },
+                "none": { "xpath": "non-existing" },
+                "kept_none": { "xpath": "non-existing", "keep_empty_or_null": true },
+                "empty_str": { "const": "  " },
+                "kept_empty_str": { "const": "  ", "keep_empty_or_null": true },
+                "a.x": { "const": "escaped, thus computed before 'a-y' but written after it" },
+                "a-y": { "const": "a-y" },
+                "a.z": { "xpath": "non-existing" },
+                "<html>": { "const": "1", "type": "int" }
+            }}`,
+		},
+		{
+			name: "nested empty objects and arrays",
+			declJSON: `"FINAL_OUTPUT": { "object": {
+                "empty_obj": { "object": { "x": { "xpath": "non-existing" } } },
+                "kept_empty_obj": { "keep_empty_or_null": true, "object": { "x": { "xpath": "non-existing" } } },
+                "unmatched_obj": { "xpath": "non-existing", "object": { "x": { "const": "x" } } },
+                "kept_unmatched_obj": { "xpath": "non-existing", "keep_empty_or_null": true, "object": {
+                    "x": { "const": "x" }
+                }},
+                "empty_array": { "array": [ { "xpath": "non-existing" } ] },
+                "kept_empty_array": { "keep_empty_or_null": true, "array": [ { "xpath": "non-existing" } ] },
+                "array": { "array": [
+                    { "xpath": "*" },
+                    { "const": "" },
+                    { "const": "", "keep_empty_or_null": true },
+                    { "xpath": "*", "object": { "v": { "xpath": "." }, "n": { "xpath": "non-existing" } } },
+                    { "array": [ { "xpath": "non-existing" } ] }
+                ]}
+            }}`,
+		},
+		{
+			name:     "empty final output",
+			declJSON: `"FINAL_OUTPUT": { "object": { "x": { "xpath": "non-existing" } } }`,
+		},
+		{
+			name:     "kept empty final output",
+			declJSON: `"FINAL_OUTPUT": { "keep_empty_or_null": true, "array": [] }`,
+		},
+		{
+			name:     "value final output",
+			declJSON: `"FINAL_OUTPUT": { "xpath": "C", "type": "string" }`,
+		},
+		{
+			name: "templates and validation",
+			declJSON: `"FINAL_OUTPUT": { "object": {
+                "t": { "template": "t", "args": { "name": { "const": "C" } } },
+                "validated": { "validate": { "max_length": 2 }, "object": { "b": { "xpath": "B" } } },
+                "typed": { "type": "string", "custom_func": { "name": "upper", "args": [ { "xpath": "B" } ] } }
+            }},
+            "t": { "params": [ "name" ], "object": {
+                "v": { "xpath": "*[name()=$name]" },
+                "list": { "array": [ { "xpath": "*[name()!=$name]" } ] }
+            }}`,
+		},
+		{
+			name: "transform failure",
+			declJSON: `"FINAL_OUTPUT": { "object": {
+                "a": { "const": "a" },
+                "x": { "object": { "y": { "xpath": "B", "type": "int" } } }
+            }}`,
+			err: `unable to convert value 'b' to type 'int' on 'FINAL_OUTPUT.x.y', err: strconv.ParseInt: parsing "b": invalid syntax`,
+		},
+		{
+			name: "validation failure",
+			declJSON: `"FINAL_OUTPUT": { "object": {
+                "b": { "xpath": "B", "validate": { "enum": [ "x" ] } }
+            }}`,
+			err: "validation failed: 'FINAL_OUTPUT.b' value 'b' is not one of ['x']",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := testParseCtx()
+			decl, err := ValidateTransformDeclarations(
+				[]byte(`{"transform_declarations": {`+test.declJSON+`}}`), ctx.customFuncs, nil)
+			assert.NoError(t, err)
+			v, parseErr := ctx.ParseNode(testNode(), decl)
+			var buf bytes.Buffer
+			err = testParseCtx().EncodeNode(testNode(), CompileEncodePlan(decl, EncodeOptions{EscapeHTML: true}),
+				&buf, testValueEncoder)
+			if test.err != "" {
+				assert.Error(t, parseErr)
+				assert.Equal(t, test.err, parseErr.Error())
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				return
+			}
+			assert.NoError(t, parseErr)
+			assert.NoError(t, err)
+			expected, err := json.Marshal(v)
+			assert.NoError(t, err)
+			assert.Equal(t, string(expected), buf.String())
+		})
+	}
+}
+
+func TestEncodeNode_PreserveOrder(t *testing.T) {
+	ctx := testParseCtx()
+	decl, err := ValidateTransformDeclarations([]byte(`{"transform_declarations": {
+        "FINAL_OUTPUT": { "object": {
+            "z": { "const": "1" },
+            "none": { "xpath": "non-existing" },
+            "<b>": { "object": { "y": { "const": "2" }, "x": { "const": "3" } } },
+            "a": { "array": [ { "xpath": "*" } ] }
+        }}
+    }}`), ctx.customFuncs, nil)
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	buf.WriteString("prefix:")
+	err = ctx.EncodeNode(testNode(), CompileEncodePlan(decl, EncodeOptions{PreserveOrder: true}),
+		&buf, testValueEncoder)
+	assert.NoError(t, err)
+	assert.Equal(t, `prefix:{"z":"1","<b>":{"y":"2","x":"3"},"a":["b","c"]}`, buf.String())
+}
@@ -0,0 +1,564 @@
+package transform
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jf-tech/omniparser/customfuncs"
+	"github.com/jf-tech/omniparser/idr"
+)
+
+// exprType is the static type of an 'expr' (sub-)expression, determined at schema loading time.
+type exprType string
+
+const (
+	exprTypeAny    exprType = "any" // type unknown until evaluation.
+	exprTypeNull   exprType = "null"
+	exprTypeInt    exprType = exprType(resultTypeInt)
+	exprTypeFloat  exprType = exprType(resultTypeFloat)
+	exprTypeBool   exprType = exprType(resultTypeBoolean)
+	exprTypeString exprType = exprType(resultTypeString)
+)
+
+func (t exprType) isNumeric() bool {
+	return t == exprTypeInt || t == exprTypeFloat
+}
+
+// isKnown returns false if the type of a value won't be known until evaluation, or the value is
+// null which is compatible with all types.
+func (t exprType) isKnown() bool {
+	return t != exprTypeAny && t != exprTypeNull
+}
+
+func exprTypeOfGoType(t reflect.Type) exprType {
+	switch t.Kind() {
+	case reflect.String:
+		return exprTypeString
+	case reflect.Bool:
+		return exprTypeBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return exprTypeInt
+	case reflect.Float32, reflect.Float64:
+		return exprTypeFloat
+	}
+	return exprTypeAny
+}
+
+// compiledExpr is a parsed and type-checked 'expr'.
+type compiledExpr struct {
+	root exprNode
+	typ  exprType
+}
+
+type exprTokenKind int
+
+const (
+	exprTokenEOF exprTokenKind = iota
+	exprTokenNumber
+	exprTokenString
+	exprTokenIdent
+	exprTokenExternal
+	exprTokenOp
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	val  interface{} // only for number and string literals.
+	pos  int
+}
+
+func (t exprToken) String() string {
+	if t.kind == exprTokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("'%s' at position %d", t.text, t.pos+1)
+}
+
+// exprOps are ordered such that multi-char operators are matched before their single-char prefixes.
+var exprOps = []string{"&&", "||", "==", "!=", "<=", ">=", "??", "+", "-", "*", "/", "%", "<", ">", "!", "(", ")", ",", "?", ":"}
+
+// exprKeywordOps are the word aliases of some operators.
+var exprKeywordOps = map[string]string{"and": "&&", "or": "||", "not": "!"}
+
+func isExprIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isExprIdentChar(c byte) bool {
+	return isExprIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+func isExprDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func tokenizeExpr(s string) ([]exprToken, error) {
+	var tokens []exprToken
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isExprDigit(c) || (c == '.' && i+1 < len(s) && isExprDigit(s[i+1])):
+			start := i
+			isFloat := false
+			for i < len(s) && (isExprDigit(s[i]) || s[i] == '.') {
+				isFloat = isFloat || s[i] == '.'
+				i++
+			}
+			if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+				isFloat = true
+				i++
+				if i < len(s) && (s[i] == '+' || s[i] == '-') {
+					i++
+				}
+				for i < len(s) && isExprDigit(s[i]) {
+					i++
+				}
+			}
+			text := s[start:i]
+			var val interface{}
+			var err error
+			if isFloat {
+				val, err = strconv.ParseFloat(text, 64)
+			} else {
+				val, err = strconv.ParseInt(text, 10, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid number '%s' at position %d", text, start+1)
+			}
+			tokens = append(tokens, exprToken{kind: exprTokenNumber, text: text, val: val, pos: start})
+		case c == '\'' || c == '"':
+			start := i
+			var sb strings.Builder
+			i++
+			for ; i < len(s) && s[i] != c; i++ {
+				if s[i] != '\\' {
+					sb.WriteByte(s[i])
+					continue
+				}
+				i++
+				if i >= len(s) {
+					break
+				}
+				switch s[i] {
+				case 'n':
+					sb.WriteByte('\n')
+				case 't':
+					sb.WriteByte('\t')
+				case 'r':
+					sb.WriteByte('\r')
+				default:
+					sb.WriteByte(s[i])
+				}
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated string literal at position %d", start+1)
+			}
+			i++
+			tokens = append(tokens, exprToken{kind: exprTokenString, text: s[start:i], val: sb.String(), pos: start})
+		case isExprIdentStart(c):
+			start := i
+			for i < len(s) && isExprIdentChar(s[i]) {
+				i++
+			}
+			text := s[start:i]
+			if op, isOp := exprKeywordOps[text]; isOp {
+				tokens = append(tokens, exprToken{kind: exprTokenOp, text: op, pos: start})
+			} else {
+				tokens = append(tokens, exprToken{kind: exprTokenIdent, text: text, pos: start})
+			}
+		case c == '$':
+			start := i
+			i++
+			for i < len(s) && isExprIdentChar(s[i]) {
+				i++
+			}
+			if i == start+1 {
+				return nil, fmt.Errorf("missing external property name after '$' at position %d", start+1)
+			}
+			tokens = append(tokens, exprToken{kind: exprTokenExternal, text: s[start:i], pos: start})
+		default:
+			found := false
+			for _, op := range exprOps {
+				if strings.HasPrefix(s[i:], op) {
+					tokens = append(tokens, exprToken{kind: exprTokenOp, text: op, pos: i})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected character '%c' at position %d", c, i+1)
+			}
+		}
+	}
+	return append(tokens, exprToken{kind: exprTokenEOF, pos: len(s)}), nil
+}
+
+type exprParser struct {
+	tokens      []exprToken
+	cur         int
+	varTypes    map[string]exprType
+	customFuncs customfuncs.CustomFuncs
+}
+
+// compileExpr parses and type-checks an 'expr'. varTypes contains the names and static types of all
+// the variables that can be referenced in the expression.
+func compileExpr(
+	expression string, varTypes map[string]exprType, customFuncs customfuncs.CustomFuncs) (*compiledExpr, error) {
+	tokens, err := tokenizeExpr(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens, varTypes: varTypes, customFuncs: customFuncs}
+	root, typ, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != exprTokenEOF {
+		return nil, fmt.Errorf("unexpected %s", t)
+	}
+	return &compiledExpr{root: root, typ: typ}, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.cur]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.cur]
+	if t.kind != exprTokenEOF {
+		p.cur++
+	}
+	return t
+}
+
+func (p *exprParser) peekOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != exprTokenOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expectOp(op string) error {
+	if _, ok := p.peekOp(op); !ok {
+		return fmt.Errorf("expected '%s', instead got %s", op, p.peek())
+	}
+	p.next()
+	return nil
+}
+
+func (p *exprParser) parseTernary() (exprNode, exprType, error) {
+	cond, condType, err := p.parseBinary(0)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, ok := p.peekOp("?"); !ok {
+		return cond, condType, nil
+	}
+	if condType.isKnown() && condType != exprTypeBool {
+		return nil, "", fmt.Errorf("condition of operator '?:' must be boolean, instead got %s", condType)
+	}
+	p.next()
+	yes, yesType, err := p.parseTernary()
+	if err != nil {
+		return nil, "", err
+	}
+	if err = p.expectOp(":"); err != nil {
+		return nil, "", err
+	}
+	no, noType, err := p.parseTernary()
+	if err != nil {
+		return nil, "", err
+	}
+	return &exprTernary{cond: cond, yes: yes, no: no}, mergeExprTypes(yesType, noType), nil
+}
+
+// exprBinaryOpLevels lists binary operators from the lowest precedence to the highest.
+var exprBinaryOpLevels = [][]string{
+	{"??"},
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) parseBinary(level int) (exprNode, exprType, error) {
+	if level >= len(exprBinaryOpLevels) {
+		return p.parseUnary()
+	}
+	left, leftType, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		op, ok := p.peekOp(exprBinaryOpLevels[level]...)
+		if !ok {
+			return left, leftType, nil
+		}
+		p.next()
+		right, rightType, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, "", err
+		}
+		typ, err := checkExprBinaryOp(op, leftType, rightType)
+		if err != nil {
+			return nil, "", err
+		}
+		left, leftType = &exprBinary{op: op, left: left, right: right}, typ
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, exprType, error) {
+	op, ok := p.peekOp("-", "!")
+	if !ok {
+		return p.parsePrimary()
+	}
+	p.next()
+	operand, typ, err := p.parseUnary()
+	if err != nil {
+		return nil, "", err
+	}
+	switch {
+	case op == "-" && typ.isKnown() && !typ.isNumeric():
+		return nil, "", fmt.Errorf("operator '-' cannot be applied to %s", typ)
+	case op == "!" && typ.isKnown() && typ != exprTypeBool:
+		return nil, "", fmt.Errorf("operator '!' cannot be applied to %s", typ)
+	case op == "!":
+		typ = exprTypeBool
+	}
+	return &exprUnary{op: op, operand: operand}, typ, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, exprType, error) {
+	t := p.next()
+	switch t.kind {
+	case exprTokenNumber:
+		if _, isInt := t.val.(int64); isInt {
+			return &exprLiteral{val: t.val}, exprTypeInt, nil
+		}
+		return &exprLiteral{val: t.val}, exprTypeFloat, nil
+	case exprTokenString:
+		return &exprLiteral{val: t.val}, exprTypeString, nil
+	case exprTokenExternal:
+		// the external value might be of any type, known only at evaluation, just like 'external' directives.
+		return &exprExternal{name: t.text[1:]}, exprTypeAny, nil
+	case exprTokenIdent:
+		switch t.text {
+		case "true", "false":
+			return &exprLiteral{val: t.text == "true"}, exprTypeBool, nil
+		case "null":
+			return &exprLiteral{val: nil}, exprTypeNull, nil
+		}
+		if _, ok := p.peekOp("("); ok {
+			return p.parseCall(t)
+		}
+		typ, found := p.varTypes[t.text]
+		if !found {
+			return nil, "", fmt.Errorf("unknown variable '%s' at position %d", t.text, t.pos+1)
+		}
+		return &exprVar{name: t.text}, typ, nil
+	case exprTokenOp:
+		if t.text == "(" {
+			node, typ, err := p.parseTernary()
+			if err != nil {
+				return nil, "", err
+			}
+			if err = p.expectOp(")"); err != nil {
+				return nil, "", err
+			}
+			return node, typ, nil
+		}
+	}
+	return nil, "", fmt.Errorf("unexpected %s", t)
+}
+
+func (p *exprParser) parseCall(nameToken exprToken) (exprNode, exprType, error) {
+	p.next() // skip '('
+	var args []exprNode
+	var argTypes []exprType
+	if _, ok := p.peekOp(")"); !ok {
+		for {
+			arg, argType, err := p.parseTernary()
+			if err != nil {
+				return nil, "", err
+			}
+			args = append(args, arg)
+			argTypes = append(argTypes, argType)
+			if _, ok := p.peekOp(","); !ok {
+				break
+			}
+			p.next()
+		}
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, "", err
+	}
+	name := nameToken.text
+	if builtin, found := exprBuiltinFuncs[name]; found {
+		if len(args) != 1 {
+			return nil, "", fmt.Errorf("function '%s' expects 1 argument, instead got %d", name, len(args))
+		}
+		if !builtin.accepts(argTypes[0]) {
+			return nil, "", fmt.Errorf("function '%s' cannot be applied to %s", name, argTypes[0])
+		}
+		return &exprBuiltinCall{name: name, arg: args[0]}, builtin.typ, nil
+	}
+	fn, found := p.customFuncs[name]
+	if !found {
+		return nil, "", fmt.Errorf("unknown function '%s' at position %d", name, nameToken.pos+1)
+	}
+	if err := validateCustomFuncSignature(name, fn); err != nil {
+		return nil, "", err
+	}
+	call := &exprCustomFuncCall{name: name, args: args}
+	fnType := reflect.TypeOf(fn)
+	call.firstArg = 1
+	if fnType.NumIn() >= 2 && fnType.In(1) == reflect.TypeOf((*idr.Node)(nil)) {
+		call.firstArg = 2
+	}
+	fixedArgs := fnType.NumIn() - call.firstArg
+	switch {
+	case fnType.IsVariadic() && len(args) < fixedArgs-1:
+		return nil, "", fmt.Errorf(
+			"function '%s' expects at least %d argument(s), instead got %d", name, fixedArgs-1, len(args))
+	case !fnType.IsVariadic() && len(args) != fixedArgs:
+		return nil, "", fmt.Errorf(
+			"function '%s' expects %d argument(s), instead got %d", name, fixedArgs, len(args))
+	}
+	for i, argType := range argTypes {
+		if err := validateExprConstArg(name, i, args[i]); err != nil {
+			return nil, "", err
+		}
+		paramType := exprTypeOfGoType(getFuncArgType(fnType, call.firstArg+i))
+		if !argType.isKnown() || !paramType.isKnown() || argType == paramType ||
+			(argType == exprTypeInt && paramType == exprTypeFloat) {
+			continue
+		}
+		return nil, "", fmt.Errorf(
+			"argument %d of function '%s' must be %s, instead got %s", i+1, name, paramType, argType)
+	}
+	return call, exprTypeOfGoType(fnType.Out(0)), nil
+}
+
+func validateExprConstArg(name string, argIndex int, arg exprNode) error {
+	validator, found := customfuncs.ConstArgValidators[name]
+	if !found {
+		return nil
+	}
+	literal, isLiteral := arg.(*exprLiteral)
+	if !isLiteral {
+		return nil
+	}
+	s, isStr := literal.val.(string)
+	if !isStr {
+		return nil
+	}
+	if err := validator(argIndex, s); err != nil {
+		return fmt.Errorf("argument %d of function '%s' has invalid value '%s': %s", argIndex+1, name, s, err.Error())
+	}
+	return nil
+}
+
+func checkExprBinaryOp(op string, left, right exprType) (exprType, error) {
+	invalid := func() (exprType, error) {
+		return "", fmt.Errorf("operator '%s' cannot be applied to %s and %s", op, left, right)
+	}
+	numericOrUnknown := func(t exprType) bool { return !t.isKnown() || t.isNumeric() }
+	switch op {
+	case "??":
+		return mergeExprTypes(left, right), nil
+	case "&&", "||":
+		if (left.isKnown() && left != exprTypeBool) || (right.isKnown() && right != exprTypeBool) {
+			return invalid()
+		}
+		return exprTypeBool, nil
+	case "==", "!=":
+		if left.isKnown() && right.isKnown() && left != right && !(left.isNumeric() && right.isNumeric()) {
+			return invalid()
+		}
+		return exprTypeBool, nil
+	case "<", "<=", ">", ">=":
+		if left == exprTypeBool || right == exprTypeBool ||
+			(left.isKnown() && right.isKnown() && left != right && !(left.isNumeric() && right.isNumeric())) {
+			return invalid()
+		}
+		return exprTypeBool, nil
+	case "+":
+		if left == exprTypeString || right == exprTypeString {
+			if left.isKnown() && right.isKnown() && left != right {
+				return invalid()
+			}
+			return exprTypeString, nil
+		}
+	}
+	// Now all the arithmetic operators.
+	if !numericOrUnknown(left) || !numericOrUnknown(right) {
+		return invalid()
+	}
+	switch {
+	case op == "/":
+		return exprTypeFloat, nil
+	case left == exprTypeAny || right == exprTypeAny || (op == "+" && (!left.isKnown() || !right.isKnown())):
+		// '+' with operand of unknown type can be a string concatenation.
+		return exprTypeAny, nil
+	case left == exprTypeFloat || right == exprTypeFloat:
+		return exprTypeFloat, nil
+	case left == exprTypeNull || right == exprTypeNull:
+		return exprTypeNull, nil
+	}
+	return exprTypeInt, nil
+}
+
+func mergeExprTypes(t1, t2 exprType) exprType {
+	switch {
+	case t1 == t2:
+		return t1
+	case t1 == exprTypeNull:
+		return t2
+	case t2 == exprTypeNull:
+		return t1
+	}
+	return exprTypeAny
+}
+
+type exprBuiltinFunc struct {
+	typ exprType
+	// from lists the known types of arguments the function can convert from.
+	from []exprType
+}
+
+func (f exprBuiltinFunc) accepts(t exprType) bool {
+	if !t.isKnown() {
+		return true
+	}
+	for _, from := range f.from {
+		if from == t {
+			return true
+		}
+	}
+	return false
+}
+
+// exprBuiltinFuncs are the type conversion functions built into 'expr'. They take precedence
+// over custom_funcs of the same names.
+var exprBuiltinFuncs = map[string]exprBuiltinFunc{
+	"int":     {typ: exprTypeInt, from: []exprType{exprTypeInt, exprTypeFloat, exprTypeString}},
+	"float":   {typ: exprTypeFloat, from: []exprType{exprTypeInt, exprTypeFloat, exprTypeString}},
+	"string":  {typ: exprTypeString, from: []exprType{exprTypeInt, exprTypeFloat, exprTypeBool, exprTypeString}},
+	"boolean": {typ: exprTypeBool, from: []exprType{exprTypeBool, exprTypeString}},
+}
@@ -0,0 +1,100 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jf-tech/omniparser/customfuncs"
+	"github.com/jf-tech/omniparser/idr"
+	"github.com/jf-tech/omniparser/transformctx"
+)
+
+func TestCompileExpr(t *testing.T) {
+	testCustomFuncs := customfuncs.CustomFuncs{
+		"upper":      func(_ *transformctx.Ctx, s string) (string, error) { return s, nil },
+		"concat":     func(_ *transformctx.Ctx, args ...string) (string, error) { return "", nil },
+		"half":       func(_ *transformctx.Ctx, f float64) (float64, error) { return f / 2, nil },
+		"with_node":  func(_ *transformctx.Ctx, _ *idr.Node, n int) (int, error) { return n, nil },
+		"anything":   func(_ *transformctx.Ctx) (interface{}, error) { return nil, nil },
+		"not_func":   "not a func",
+		"regexMatch": customfuncs.RegexMatch,
+	}
+	varTypes := map[string]exprType{
+		"i": exprTypeInt,
+		"f": exprTypeFloat,
+		"s": exprTypeString,
+		"b": exprTypeBool,
+		"a": exprTypeAny,
+	}
+	for _, test := range []struct {
+		name       string
+		expression string
+		typ        exprType
+		err        string
+	}{
+		{name: "int literal", expression: "123", typ: exprTypeInt},
+		{name: "float literal", expression: "1.5e3", typ: exprTypeFloat},
+		{name: "string literal", expression: `'it\'s' + "\"quoted\""`, typ: exprTypeString},
+		{name: "bool literal", expression: "true", typ: exprTypeBool},
+		{name: "null literal", expression: "null", typ: exprTypeNull},
+		{name: "external", expression: "$abc", typ: exprTypeAny},
+		{name: "int arithmetic", expression: "i * 2 + i % 3 - -i", typ: exprTypeInt},
+		{name: "float arithmetic", expression: "i * f", typ: exprTypeFloat},
+		{name: "division is always float", expression: "i / 2", typ: exprTypeFloat},
+		{name: "arithmetic with any", expression: "a - i", typ: exprTypeAny},
+		{name: "'+' with null can be anything", expression: "null + i", typ: exprTypeAny},
+		{name: "arithmetic with null", expression: "null * i", typ: exprTypeNull},
+		{name: "string concat", expression: "s + 'x' + a", typ: exprTypeString},
+		{name: "comparison", expression: "i < f and s >= 'a' or not b", typ: exprTypeBool},
+		{name: "equality", expression: "a == s && i != f && s != null", typ: exprTypeBool},
+		{name: "ternary", expression: "b ? i : null", typ: exprTypeInt},
+		{name: "ternary of different types", expression: "b ? i : s", typ: exprTypeAny},
+		{name: "coalesce", expression: "a ?? 'default'", typ: exprTypeAny},
+		{name: "precedence and parens", expression: "(i + 2) * 3 > 10 == !(b || false)", typ: exprTypeBool},
+		{name: "builtin conversion", expression: "int(s) + float('1.5')", typ: exprTypeFloat},
+		{name: "custom_func", expression: "upper(s + string(i))", typ: exprTypeString},
+		{name: "variadic custom_func", expression: "concat() + concat(s, s, s)", typ: exprTypeString},
+		{name: "custom_func int arg to float param", expression: "half(i)", typ: exprTypeFloat},
+		{name: "custom_func with node", expression: "with_node(i)", typ: exprTypeInt},
+		{name: "custom_func returning any", expression: "anything()", typ: exprTypeAny},
+		{name: "custom_func with valid const regex", expression: "regexMatch(s, '^[0-9]+$')", typ: exprTypeBool},
+		{name: "custom_func with invalid const regex", expression: "regexMatch(s, '[0-9')", err: "argument 2 of function 'regexMatch' has invalid value '[0-9': error parsing regexp: missing closing ]: `[0-9`"},
+		{name: "invalid char", expression: "i # 2", err: "unexpected character '#' at position 3"},
+		{name: "invalid number", expression: "1.2.3", err: "invalid number '1.2.3' at position 1"},
+		{name: "unterminated string", expression: "'abc", err: "unterminated string literal at position 1"},
+		{name: "missing external name", expression: "$ + 1", err: "missing external property name after '$' at position 1"},
+		{name: "unknown var", expression: "x + 1", err: "unknown variable 'x' at position 1"},
+		{name: "unknown func", expression: "x(1)", err: "unknown function 'x' at position 1"},
+		{name: "not a func", expression: "not_func()", err: "custom_func 'not_func' is not a function"},
+		{name: "trailing tokens", expression: "i i", err: "unexpected 'i' at position 3"},
+		{name: "incomplete", expression: "i +", err: "unexpected end of expression"},
+		{name: "missing ')'", expression: "(i + 1", err: "expected ')', instead got end of expression"},
+		{name: "missing ':'", expression: "b ? 1", err: "expected ':', instead got end of expression"},
+		{name: "non-bool condition", expression: "i ? 1 : 2", err: "condition of operator '?:' must be boolean, instead got int"},
+		{name: "string minus", expression: "s - 1", err: "operator '-' cannot be applied to string and int"},
+		{name: "string plus int", expression: "s + i", err: "operator '+' cannot be applied to string and int"},
+		{name: "negate string", expression: "-s", err: "operator '-' cannot be applied to string"},
+		{name: "not int", expression: "!i", err: "operator '!' cannot be applied to int"},
+		{name: "and with string", expression: "b && s", err: "operator '&&' cannot be applied to boolean and string"},
+		{name: "compare string with int", expression: "s == i", err: "operator '==' cannot be applied to string and int"},
+		{name: "order bools", expression: "b < a", err: "operator '<' cannot be applied to boolean and any"},
+		{name: "builtin arg count", expression: "int(1, 2)", err: "function 'int' expects 1 argument, instead got 2"},
+		{name: "builtin arg type", expression: "boolean(i)", err: "function 'boolean' cannot be applied to int"},
+		{name: "custom_func arg count", expression: "upper()", err: "function 'upper' expects 1 argument(s), instead got 0"},
+		{name: "custom_func arg type", expression: "upper(i)", err: "argument 1 of function 'upper' must be string, instead got int"},
+		{name: "custom_func float to int", expression: "with_node(f)", err: "argument 1 of function 'with_node' must be int, instead got float"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			compiled, err := compileExpr(test.expression, varTypes, testCustomFuncs)
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Nil(t, compiled)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.typ, compiled.typ)
+		})
+	}
+}
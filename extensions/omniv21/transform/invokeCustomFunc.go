@@ -45,24 +45,71 @@ func (p *parseCtx) prepArgValues(
 		if err != nil {
 			return nil, err
 		}
-		if val == nil {
-			argVals = append(argVals, reflect.Zero(getFuncArgType(fnType, fnArgIndex)))
-		} else {
-			argVals = append(argVals, reflect.ValueOf(val))
+		argType := getFuncArgType(fnType, fnArgIndex)
+		vals := []interface{}{val}
+		if isSpreadArg(fnType, fnArgIndex, argDecl) {
+			// val is either nil (no matching nodes) or []interface{}.
+			vals, _ = val.([]interface{})
+		}
+		for _, v := range vals {
+			argVal, err := convertFuncArg(v, argType)
+			if err != nil {
+				return nil, fmt.Errorf("'%s' %s", argDecl.fqdn, err.Error())
+			}
+			argVals = append(argVals, argVal)
 		}
 		fnArgIndex++
 	}
 	return argVals, nil
 }
 
+func isNumericKind(k reflect.Kind) bool {
+	return (k >= reflect.Int && k <= reflect.Uint64) || k == reflect.Float32 || k == reflect.Float64
+}
+
+// convertFuncArg converts an arg value to the custom_func param type, if needed: arg types are checked at
+// schema loading time, but only when statically known, and numeric values can be of a different width
+// (e.g. int64 from "type": "int") than the param.
+func convertFuncArg(val interface{}, argType reflect.Type) (reflect.Value, error) {
+	if val == nil {
+		return reflect.Zero(argType), nil
+	}
+	v := reflect.ValueOf(val)
+	switch {
+	case v.Type().AssignableTo(argType):
+		return v, nil
+	case isNumericKind(v.Kind()) && isNumericKind(argType.Kind()):
+		return v.Convert(argType), nil
+	case v.Kind() == reflect.Slice && argType.Kind() == reflect.Slice:
+		// e.g. the []interface{} value of a 'multi' arg passed to a []string param.
+		slice := reflect.MakeSlice(argType, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elem, err := convertFuncArg(v.Index(i).Interface(), argType.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			slice.Index(i).Set(elem)
+		}
+		return slice, nil
+	}
+	return reflect.Value{}, fmt.Errorf(
+		"value '%v' of type '%s' cannot be used as custom_func argument of type '%s'", val, v.Type(), argType)
+}
+
 func getFuncArgType(fnType reflect.Type, argIndex int) reflect.Type {
-	isVariadic := fnType.IsVariadic()
 	if argIndex >= fnType.NumIn() {
 		argIndex = fnType.NumIn() - 1
 	}
 	typ := fnType.In(argIndex)
-	if isVariadic {
+	// only the last param of a variadic func is a slice of the arg type.
+	if fnType.IsVariadic() && argIndex == fnType.NumIn()-1 {
 		typ = typ.Elem()
 	}
 	return typ
 }
+
+// isSpreadArg returns true if the arg is a 'multi' arg for the variadic param of a custom_func, in which
+// case each of its values is passed as an individual arg.
+func isSpreadArg(fnType reflect.Type, argIndex int, argDecl *Decl) bool {
+	return argDecl.multiArg && fnType.IsVariadic() && argIndex >= fnType.NumIn()-1
+}
@@ -98,6 +98,41 @@ func TestInvokeCustomFunc(t *testing.T) {
 			err:      ``,
 			expected: "a//b",
 		},
+		{
+			name: "numeric arg converted to param type",
+			n:    testNode(),
+			decl: &CustomFuncDecl{
+				Name: "test_int_func",
+				Args: []*Decl{
+					{
+						Const:      strs.StrPtr("21"),
+						ResultType: testResultType(resultTypeInt),
+						kind:       kindConst,
+						fqdn:       "test-arg-fqdn",
+					},
+				},
+				fqdn: "test-fqdn",
+			},
+			err:      ``,
+			expected: 42,
+		},
+		{
+			name: "arg not assignable to param type",
+			n:    testNode(),
+			decl: &CustomFuncDecl{
+				Name: "test_int_func",
+				Args: []*Decl{
+					{
+						Const: strs.StrPtr("abc"),
+						kind:  kindConst,
+						fqdn:  "test-arg-fqdn",
+					},
+				},
+				fqdn: "test-fqdn",
+			},
+			err:      `'test-arg-fqdn' value 'abc' of type 'string' cannot be used as custom_func argument of type 'int'`,
+			expected: nil,
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			r, err := testParseCtx().invokeCustomFunc(test.n, test.decl)
@@ -112,3 +147,112 @@ func TestInvokeCustomFunc(t *testing.T) {
 		})
 	}
 }
+
+func TestInvokeCustomFunc_MultiArg(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		funcDecl string
+		err      string
+		expected interface{}
+	}{
+		{
+			name:     "spread into variadic param",
+			funcDecl: `{ "name": "join", "args": [ { "const": "+" }, { "xpath": "*", "multi": true } ] }`,
+			expected: "b+c",
+		},
+		{
+			name:     "spread with other variadic args",
+			funcDecl: `{ "name": "join", "args": [ { "const": "+" }, { "const": "a" }, { "xpath": "*", "multi": true } ] }`,
+			expected: "a+b+c",
+		},
+		{
+			name:     "no matching nodes",
+			funcDecl: `{ "name": "count", "args": [ { "xpath": "X", "multi": true } ] }`,
+			expected: 0,
+		},
+		{
+			name:     "aggregate typed values",
+			funcDecl: `{ "name": "sum", "args": [ { "xpath": "*", "multi": true, "custom_func": { "name": "hexEncode", "args": [ { "xpath": "." } ] } } ] }`,
+			expected: "125",
+		},
+		{
+			name:     "slice param",
+			funcDecl: `{ "name": "test_slice_func", "args": [ { "xpath": "*", "multi": true, "custom_func": { "name": "upper", "args": [ { "xpath": "." } ] } }, { "const": "/" } ] }`,
+			expected: "B/C",
+		},
+		{
+			name:     "slice param with no matching nodes",
+			funcDecl: `{ "name": "test_slice_func", "args": [ { "xpath": "X", "multi": true }, { "const": "/" } ] }`,
+			expected: nil,
+		},
+		{
+			name:     "element not convertible",
+			funcDecl: `{ "name": "test_slice_func", "args": [ { "xpath": "*", "multi": true, "type": "int", "custom_func": { "name": "hexEncode", "args": [ { "xpath": "." } ] } }, { "const": "/" } ] }`,
+			err:      `'FINAL_OUTPUT.custom_func(test_slice_func).arg[1]' value '62' of type 'int64' cannot be used as custom_func argument of type 'string'`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := testParseCtx()
+			decl, err := ValidateTransformDeclarations(
+				[]byte(`{"transform_declarations": { "FINAL_OUTPUT": { "custom_func": `+test.funcDecl+` }}}`),
+				ctx.customFuncs, nil)
+			assert.NoError(t, err)
+			v, err := ctx.ParseNode(testNode(), decl)
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Nil(t, v)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, v)
+		})
+	}
+}
+
+func TestInvokeCustomFunc_MultiArgKeepsEmptyValues(t *testing.T) {
+	// A
+	//    B
+	//    D (empty)
+	//    C
+	n := idr.CreateNode(idr.ElementNode, "A")
+	for _, child := range []struct{ name, text string }{{"B", "b"}, {"D", ""}, {"C", "c"}} {
+		childNode := idr.CreateNode(idr.ElementNode, child.name)
+		idr.AddChild(n, childNode)
+		if child.text != "" {
+			idr.AddChild(childNode, idr.CreateNode(idr.TextNode, child.text))
+		}
+	}
+	for _, test := range []struct {
+		name     string
+		funcDecl string
+		expected interface{}
+	}{
+		{
+			name:     "count",
+			funcDecl: `{ "name": "count", "args": [ { "xpath": "*", "multi": true } ] }`,
+			expected: 3,
+		},
+		{
+			name:     "join",
+			funcDecl: `{ "name": "join", "args": [ { "const": "+" }, { "xpath": "*", "multi": true } ] }`,
+			expected: "b++c",
+		},
+		{
+			name:     "max skips empty values",
+			funcDecl: `{ "name": "max", "args": [ { "xpath": "*", "multi": true } ] }`,
+			expected: "c",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := testParseCtx()
+			decl, err := ValidateTransformDeclarations(
+				[]byte(`{"transform_declarations": { "FINAL_OUTPUT": { "custom_func": `+test.funcDecl+` }}}`),
+				ctx.customFuncs, nil)
+			assert.NoError(t, err)
+			v, err := ctx.ParseNode(n, decl)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, v)
+		})
+	}
+}
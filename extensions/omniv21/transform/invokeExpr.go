@@ -0,0 +1,385 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/jf-tech/omniparser/idr"
+)
+
+type exprEvalCtx struct {
+	p    *parseCtx
+	n    *idr.Node
+	decl *ExprDecl
+}
+
+type exprNode interface {
+	eval(c *exprEvalCtx) (interface{}, error)
+}
+
+// exprVarErr wraps an error from a variable's decl parsing, which is already context formatted.
+type exprVarErr struct {
+	err error
+}
+
+func (e exprVarErr) Error() string {
+	return e.err.Error()
+}
+
+func (p *parseCtx) invokeExpr(n *idr.Node, exprDecl *ExprDecl) (interface{}, error) {
+	// In validation, we've compiled the expr successfully.
+	v, err := exprDecl.compiled.root.eval(&exprEvalCtx{p: p, n: n, decl: exprDecl})
+	if err == nil {
+		return v, nil
+	}
+	var varErr exprVarErr
+	if errors.As(err, &varErr) {
+		return nil, varErr.err
+	}
+	return nil, fmt.Errorf("'%s' failed: %s", exprDecl.fqdn, err.Error())
+}
+
+// normalizeExprValue makes sure all integers are int64 and all floats are float64.
+func normalizeExprValue(v interface{}) interface{} {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		return value.Float()
+	}
+	return v
+}
+
+func exprTypeOfValue(v interface{}) exprType {
+	switch v.(type) {
+	case nil:
+		return exprTypeNull
+	case int64:
+		return exprTypeInt
+	case float64:
+		return exprTypeFloat
+	case bool:
+		return exprTypeBool
+	case string:
+		return exprTypeString
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return exprType(fmt.Sprintf("%T", v))
+}
+
+func exprToFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+type exprLiteral struct {
+	val interface{}
+}
+
+func (e *exprLiteral) eval(_ *exprEvalCtx) (interface{}, error) {
+	return e.val, nil
+}
+
+type exprVar struct {
+	name string
+}
+
+func (e *exprVar) eval(c *exprEvalCtx) (interface{}, error) {
+	// Variables are only parsed when referenced, and the parsing result is cached by ParseNode.
+	v, err := c.p.ParseNode(c.n, c.decl.Vars[e.name])
+	if err != nil {
+		return nil, exprVarErr{err: err}
+	}
+	return normalizeExprValue(v), nil
+}
+
+type exprExternal struct {
+	name string
+}
+
+func (e *exprExternal) eval(c *exprEvalCtx) (interface{}, error) {
+	if v, found := c.p.transformCtx.ExternalValue(e.name); found {
+		return normalizeExprValue(v), nil
+	}
+	return nil, fmt.Errorf("cannot find external property '%s'", e.name)
+}
+
+type exprUnary struct {
+	op      string
+	operand exprNode
+}
+
+func (e *exprUnary) eval(c *exprEvalCtx) (interface{}, error) {
+	v, err := e.operand.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == "!" {
+		b, err := exprToBool(e.op, v)
+		if err != nil {
+			return nil, err
+		}
+		return !b, nil
+	}
+	switch n := v.(type) {
+	case nil:
+		return nil, nil
+	case int64:
+		return -n, nil
+	case float64:
+		return -n, nil
+	}
+	return nil, fmt.Errorf("operator '-' cannot be applied to %s", exprTypeOfValue(v))
+}
+
+// exprToBool converts a value into a boolean for logical operators: null is treated as false.
+func exprToBool(op string, v interface{}) (bool, error) {
+	switch b := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return b, nil
+	}
+	return false, fmt.Errorf("operator '%s' cannot be applied to %s", op, exprTypeOfValue(v))
+}
+
+type exprTernary struct {
+	cond, yes, no exprNode
+}
+
+func (e *exprTernary) eval(c *exprEvalCtx) (interface{}, error) {
+	v, err := e.cond.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := exprToBool("?:", v)
+	if err != nil {
+		return nil, err
+	}
+	if cond {
+		return e.yes.eval(c)
+	}
+	return e.no.eval(c)
+}
+
+type exprBinary struct {
+	op          string
+	left, right exprNode
+}
+
+func (e *exprBinary) eval(c *exprEvalCtx) (interface{}, error) {
+	left, err := e.left.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	// short-circuit operators.
+	switch e.op {
+	case "??":
+		if left != nil {
+			return left, nil
+		}
+		return e.right.eval(c)
+	case "&&", "||":
+		l, err := exprToBool(e.op, left)
+		if err != nil {
+			return nil, err
+		}
+		if l == (e.op == "||") {
+			return l, nil
+		}
+		right, err := e.right.eval(c)
+		if err != nil {
+			return nil, err
+		}
+		return exprToBool(e.op, right)
+	}
+	right, err := e.right.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "==":
+		return exprEqual(left, right), nil
+	case "!=":
+		return !exprEqual(left, right), nil
+	case "<", "<=", ">", ">=":
+		return exprCompare(e.op, left, right)
+	}
+	return exprArithmetic(e.op, left, right)
+}
+
+func exprEqual(left, right interface{}) bool {
+	lf, lNum := exprToFloat(left)
+	rf, rNum := exprToFloat(right)
+	if lNum && rNum {
+		return lf == rf
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+func exprCompare(op string, left, right interface{}) (interface{}, error) {
+	if left == nil || right == nil {
+		return false, nil
+	}
+	var cmp int
+	ls, lStr := left.(string)
+	rs, rStr := right.(string)
+	lf, lNum := exprToFloat(left)
+	rf, rNum := exprToFloat(right)
+	switch {
+	case lStr && rStr:
+		cmp = compareOrdered(ls < rs, ls > rs)
+	case lNum && rNum:
+		cmp = compareOrdered(lf < rf, lf > rf)
+	default:
+		return nil, fmt.Errorf("operator '%s' cannot be applied to %s and %s",
+			op, exprTypeOfValue(left), exprTypeOfValue(right))
+	}
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+var errExprDivisionByZero = errors.New("division by zero")
+
+func exprArithmetic(op string, left, right interface{}) (interface{}, error) {
+	// null propagates through arithmetic, similar to a missing field resulting in no output.
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	if op == "+" {
+		ls, lStr := left.(string)
+		rs, rStr := right.(string)
+		if lStr && rStr {
+			return ls + rs, nil
+		}
+	}
+	lf, lNum := exprToFloat(left)
+	rf, rNum := exprToFloat(right)
+	if !lNum || !rNum {
+		return nil, fmt.Errorf("operator '%s' cannot be applied to %s and %s",
+			op, exprTypeOfValue(left), exprTypeOfValue(right))
+	}
+	if op == "/" {
+		if rf == 0 {
+			return nil, errExprDivisionByZero
+		}
+		return lf / rf, nil
+	}
+	li, lInt := left.(int64)
+	ri, rInt := right.(int64)
+	if lInt && rInt {
+		switch op {
+		case "+":
+			return li + ri, nil
+		case "-":
+			return li - ri, nil
+		case "*":
+			return li * ri, nil
+		}
+		if ri == 0 {
+			return nil, errExprDivisionByZero
+		}
+		return li % ri, nil
+	}
+	switch op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	}
+	if rf == 0 {
+		return nil, errExprDivisionByZero
+	}
+	return math.Mod(lf, rf), nil
+}
+
+type exprBuiltinCall struct {
+	name string
+	arg  exprNode
+}
+
+func (e *exprBuiltinCall) eval(c *exprEvalCtx) (interface{}, error) {
+	v, err := e.arg.eval(c)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	converted, err := resultTypeConversion(v, resultType(exprBuiltinFuncs[e.name].typ))
+	if err != nil {
+		return nil, fmt.Errorf("function '%s' cannot convert value '%v': %s", e.name, v, err.Error())
+	}
+	return converted, nil
+}
+
+type exprCustomFuncCall struct {
+	name string
+	args []exprNode
+	// firstArg is the index of the first custom_func param that takes the args, after the
+	// *transformctx.Ctx and the optional *idr.Node.
+	firstArg int
+}
+
+func (e *exprCustomFuncCall) eval(c *exprEvalCtx) (interface{}, error) {
+	// In validation, we've validated the custom func exists.
+	fn := c.p.customFuncs[e.name]
+	fnType := reflect.TypeOf(fn)
+	argVals := make([]reflect.Value, 0, e.firstArg+len(e.args))
+	argVals = append(argVals, reflect.ValueOf(c.p.transformCtx))
+	if e.firstArg == 2 {
+		argVals = append(argVals, reflect.ValueOf(c.n))
+	}
+	for i, arg := range e.args {
+		v, err := arg.eval(c)
+		if err != nil {
+			return nil, err
+		}
+		paramType := getFuncArgType(fnType, e.firstArg+i)
+		switch {
+		case v == nil:
+			argVals = append(argVals, reflect.Zero(paramType))
+		case reflect.TypeOf(v).AssignableTo(paramType):
+			argVals = append(argVals, reflect.ValueOf(v))
+		case exprTypeOfValue(v).isNumeric() && exprTypeOfGoType(paramType).isNumeric():
+			argVals = append(argVals, reflect.ValueOf(v).Convert(paramType))
+		default:
+			return nil, fmt.Errorf("argument %d of function '%s' must be %s, instead got %s",
+				i+1, e.name, paramType, exprTypeOfValue(v))
+		}
+	}
+	result := reflect.ValueOf(fn).Call(argVals)
+	if err := result[1].Interface(); err != nil {
+		return nil, fmt.Errorf("function '%s' failed: %s", e.name, err.(error).Error())
+	}
+	return normalizeExprValue(result[0].Interface()), nil
+}
@@ -0,0 +1,159 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvokeExpr(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		exprDecl string
+		err      string
+		expected interface{}
+	}{
+		{
+			name:     "int arithmetic",
+			exprDecl: `{ "expression": "7 * 3 - 10 % 4" }`,
+			expected: int64(19),
+		},
+		{
+			name:     "float arithmetic",
+			exprDecl: `{ "expression": "x / 4 + 0.5 * 2", "vars": { "x": { "const": "10", "type": "int" } } }`,
+			expected: 3.5,
+		},
+		{
+			name:     "string concat of xpath fields and externals",
+			exprDecl: `{ "expression": "b + '-' + c + '-' + $abc", "vars": { "b": { "xpath": "B" }, "c": { "xpath": "C" } } }`,
+			expected: "b-c-efg",
+		},
+		{
+			name:     "arithmetic on external value",
+			exprDecl: `{ "expression": "$n + 1" }`,
+			expected: int64(6),
+		},
+		{
+			name:     "comparison and logic",
+			exprDecl: `{ "expression": "b == 'b' and (1 < 2.5) and not (c >= 'd')", "vars": { "b": { "xpath": "B" }, "c": { "xpath": "C" } } }`,
+			expected: true,
+		},
+		{
+			name:     "ternary",
+			exprDecl: `{ "expression": "n > 1 ? 'many' : 'one'", "vars": { "n": { "const": "2", "type": "float" } } }`,
+			expected: "many",
+		},
+		{
+			name:     "missing field propagates null through arithmetic",
+			exprDecl: `{ "expression": "(x * 2) ?? 'n/a'", "vars": { "x": { "xpath": "X", "type": "int" } } }`,
+			expected: "n/a",
+		},
+		{
+			name:     "short-circuit skips unused vars",
+			exprDecl: `{ "expression": "true || e", "vars": { "e": { "external": "non-existing", "type": "boolean" } } }`,
+			expected: true,
+		},
+		{
+			name:     "builtin conversions",
+			exprDecl: `{ "expression": "int(b) + float('1.5') + int(2.9)", "vars": { "b": { "const": "3" } } }`,
+			expected: 6.5,
+		},
+		{
+			name:     "custom_funcs",
+			exprDecl: `{ "expression": "upper(concat(b, c)) + string(1 + 1)", "vars": { "b": { "xpath": "B" }, "c": { "xpath": "C" } } }`,
+			expected: "BC2",
+		},
+		{
+			name:     "custom_func with node",
+			exprDecl: `{ "expression": "copy()" }`,
+			expected: map[string]interface{}{"B": "b", "C": "c"},
+		},
+		{
+			name:     "nested expr var",
+			exprDecl: `{ "expression": "x * 2", "vars": { "x": { "expr": { "expression": "1 + 2" } } } }`,
+			expected: int64(6),
+		},
+		{
+			name:     "var failure",
+			exprDecl: `{ "expression": "e + 'x'", "vars": { "e": { "external": "non-existing" } } }`,
+			err:      `cannot find external property 'non-existing' on 'FINAL_OUTPUT.expr.e'`,
+		},
+		{
+			name:     "external not found",
+			exprDecl: `{ "expression": "$xyz" }`,
+			err:      `'FINAL_OUTPUT.expr' failed: cannot find external property 'xyz'`,
+		},
+		{
+			name:     "division by zero",
+			exprDecl: `{ "expression": "1 % 0" }`,
+			err:      `'FINAL_OUTPUT.expr' failed: division by zero`,
+		},
+		{
+			name:     "runtime type mismatch",
+			exprDecl: `{ "expression": "o + 1", "vars": { "o": { "object": { "b": { "xpath": "B" } } } } }`,
+			err:      `'FINAL_OUTPUT.expr' failed: operator '+' cannot be applied to object and int`,
+		},
+		{
+			name:     "builtin conversion failure",
+			exprDecl: `{ "expression": "int(b)", "vars": { "b": { "xpath": "B" } } }`,
+			err:      `'FINAL_OUTPUT.expr' failed: function 'int' cannot convert value 'b': strconv.ParseInt: parsing "b": invalid syntax`,
+		},
+		{
+			name:     "custom_func failure",
+			exprDecl: `{ "expression": "javascript('var;')" }`,
+			err:      `'FINAL_OUTPUT.expr' failed: function 'javascript' failed: invalid javascript: SyntaxError: (anonymous): Line 1:4 Unexpected token ; (and 1 more errors)`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := testParseCtx()
+			decl, err := ValidateTransformDeclarations(
+				[]byte(`{"transform_declarations": { "FINAL_OUTPUT": { "expr": `+test.exprDecl+` }}}`),
+				ctx.customFuncs, nil)
+			assert.NoError(t, err)
+			v, err := ctx.ParseNode(testNode(), decl)
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Nil(t, v)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, v)
+		})
+	}
+}
+
+func BenchmarkInvokeExpr(b *testing.B) {
+	ctx := testParseCtx()
+	decl, _ := ValidateTransformDeclarations(
+		[]byte(`{"transform_declarations": { "FINAL_OUTPUT": { "expr": {
+			"expression": "b + '-' + c + (n * 2 > 3 ? 'big' : 'small')",
+			"vars": { "b": { "xpath": "B" }, "c": { "xpath": "C" }, "n": { "const": "2", "type": "int" } }
+		}}}}`),
+		ctx.customFuncs, nil)
+	n := testNode()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = ctx.ParseNode(n, decl)
+	}
+}
+
+func BenchmarkInvokeExpr_JavaScriptEquivalent(b *testing.B) {
+	ctx := testParseCtx()
+	decl, _ := ValidateTransformDeclarations(
+		[]byte(`{"transform_declarations": { "FINAL_OUTPUT": { "custom_func": {
+			"name": "javascript",
+			"args": [
+				{ "const": "b + '-' + c + (n * 2 > 3 ? 'big' : 'small')" },
+				{ "const": "b" }, { "xpath": "B" },
+				{ "const": "c" }, { "xpath": "C" },
+				{ "const": "n" }, { "const": "2", "type": "int" }
+			]
+		}}}}`),
+		ctx.customFuncs, nil)
+	n := testNode()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = ctx.ParseNode(n, decl)
+	}
+}
@@ -0,0 +1,137 @@
+package transform
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jf-tech/go-corelib/caches"
+)
+
+// validateValue checks a decl's output value against its 'validate' rules and records all the
+// violations into the parseCtx, which are reported together once the entire record is parsed.
+func (p *parseCtx) validateValue(decl *Decl, v interface{}) {
+	rules := decl.Validate
+	if v == nil || isEmpty(v) {
+		if rules.Required {
+			p.violations = append(p.violations, fmt.Sprintf("'%s' is required", decl.fqdn))
+		}
+		// all other rules only apply to non-empty values.
+		return
+	}
+	violate := func(format string, args ...interface{}) {
+		p.violations = append(p.violations,
+			fmt.Sprintf("'%s' value '%v' ", decl.fqdn, v)+fmt.Sprintf(format, args...))
+	}
+	s := fmt.Sprintf("%v", v)
+	if rules.Regex != nil {
+		// regex is validated at schema loading time.
+		r, _ := caches.GetRegex(*rules.Regex)
+		if !r.MatchString(s) {
+			violate("does not match regex '%s'", *rules.Regex)
+		}
+	}
+	if len(rules.Enum) > 0 && !stringInSlice(s, rules.Enum) {
+		violate("is not one of %s", quoteJoin(rules.Enum))
+	}
+	if rules.Min != nil || rules.Max != nil {
+		f, ok := numericValue(v)
+		switch {
+		case !ok:
+			violate("is not a number")
+		case rules.Min != nil && f < *rules.Min:
+			violate("is less than min %v", *rules.Min)
+		case rules.Max != nil && f > *rules.Max:
+			violate("is greater than max %v", *rules.Max)
+		}
+	}
+	if rules.MinLength != nil || rules.MaxLength != nil {
+		length := valueLength(v)
+		switch {
+		case rules.MinLength != nil && length < *rules.MinLength:
+			violate("has length %d less than min_length %d", length, *rules.MinLength)
+		case rules.MaxLength != nil && length > *rules.MaxLength:
+			violate("has length %d greater than max_length %d", length, *rules.MaxLength)
+		}
+	}
+	if rules.Luhn && !luhnValid(s) {
+		violate("fails luhn checksum")
+	}
+}
+
+func (p *parseCtx) violationsErr() error {
+	if len(p.violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("validation failed: %s", strings.Join(p.violations, "; "))
+}
+
+func stringInSlice(s string, slice []string) bool {
+	for _, e := range slice {
+		if s == e {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteJoin(strs []string) string {
+	quoted := make([]string, len(strs))
+	for i, s := range strs {
+		quoted[i] = "'" + s + "'"
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+func numericValue(v interface{}) (float64, bool) {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	case reflect.String:
+		f, err := strconv.ParseFloat(value.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// valueLength returns the length of an array or object value, or the rune count of any other value in
+// string form.
+func valueLength(v interface{}) int {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return value.Len()
+	}
+	return utf8.RuneCountInString(fmt.Sprintf("%v", v))
+}
+
+// luhnValid checks a number, such as a credit card number, against the Luhn checksum. Spaces and
+// dashes are allowed as separators.
+func luhnValid(s string) bool {
+	sum, digits := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits > 1 && sum%10 == 0
+}
@@ -0,0 +1,103 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateValue(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		objDecl  string
+		err      string
+		expected interface{}
+	}{
+		{
+			name: "all rules satisfied",
+			objDecl: `{
+                "b": { "xpath": "B", "validate": { "required": true, "regex": "^[a-z]$", "enum": [ "a", "b" ] } },
+                "n": { "const": "12.5", "type": "float", "validate": { "min": 0, "max": 12.5 } },
+                "s": { "const": "héllo", "validate": { "min_length": 5, "max_length": 5 } },
+                "a": { "array": [ { "xpath": "*" } ], "validate": { "min_length": 2 } },
+                "card": { "const": "4111-1111-1111-1111", "validate": { "luhn": true } },
+                "optional": { "xpath": "X", "validate": { "regex": "^[0-9]+$", "min": 1 } }
+            }`,
+			expected: map[string]interface{}{
+				"b":    "b",
+				"n":    12.5,
+				"s":    "héllo",
+				"a":    []interface{}{"b", "c"},
+				"card": "4111-1111-1111-1111",
+			},
+		},
+		{
+			name: "all violations reported",
+			objDecl: `{
+                "missing": { "xpath": "X", "validate": { "required": true } },
+                "b": { "xpath": "B", "validate": { "regex": "^[0-9]+$", "enum": [ "x", "y" ] } },
+                "not_number": { "xpath": "C", "validate": { "min": 1 } },
+                "too_small": { "const": "-1", "type": "int", "validate": { "min": 0 } },
+                "too_big": { "const": "101", "validate": { "max": 100 } },
+                "too_short": { "const": "ab", "validate": { "min_length": 3 } },
+                "too_long": { "array": [ { "xpath": "*" } ], "validate": { "max_length": 1 } },
+                "card": { "const": "4111 1111 1111 1112", "validate": { "luhn": true } }
+            }`,
+			err: "validation failed: " +
+				"'FINAL_OUTPUT.b' value 'b' does not match regex '^[0-9]+$'; " +
+				"'FINAL_OUTPUT.b' value 'b' is not one of ['x', 'y']; " +
+				"'FINAL_OUTPUT.card' value '4111 1111 1111 1112' fails luhn checksum; " +
+				"'FINAL_OUTPUT.missing' is required; " +
+				"'FINAL_OUTPUT.not_number' value 'c' is not a number; " +
+				"'FINAL_OUTPUT.too_big' value '101' is greater than max 100; " +
+				"'FINAL_OUTPUT.too_long' value '[b c]' has length 2 greater than max_length 1; " +
+				"'FINAL_OUTPUT.too_short' value 'ab' has length 2 less than min_length 3; " +
+				"'FINAL_OUTPUT.too_small' value '-1' is less than min 0",
+		},
+		{
+			name: "identical decls all validated",
+			objDecl: `{
+                "x": { "xpath": "B", "validate": { "regex": "^[0-9]+$" } },
+                "y": { "xpath": "B", "validate": { "regex": "^[0-9]+$" } }
+            }`,
+			err: "validation failed: " +
+				"'FINAL_OUTPUT.x' value 'b' does not match regex '^[0-9]+$'; " +
+				"'FINAL_OUTPUT.y' value 'b' does not match regex '^[0-9]+$'",
+		},
+		{
+			name: "non-validation error takes precedence",
+			objDecl: `{
+                "missing": { "xpath": "X", "validate": { "required": true } },
+                "z": { "external": "non-existing" }
+            }`,
+			err: "cannot find external property 'non-existing' on 'FINAL_OUTPUT.z'",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := testParseCtx()
+			// the decls' hashes are computed, so identical decls share the cached values.
+			ctx.disableTransformCache = false
+			decl, err := ValidateTransformDeclarations(
+				[]byte(`{"transform_declarations": { "FINAL_OUTPUT": { "object": `+test.objDecl+` }}}`),
+				ctx.customFuncs, nil)
+			assert.NoError(t, err)
+			v, err := ctx.ParseNode(testNode(), decl)
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Nil(t, v)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, v)
+		})
+	}
+}
+
+func TestLuhnValid(t *testing.T) {
+	assert.True(t, luhnValid("79927398713"))
+	assert.True(t, luhnValid("4111 1111 1111 1111"))
+	assert.False(t, luhnValid("79927398710"))
+	assert.False(t, luhnValid("7992739871x"))
+	assert.False(t, luhnValid("0"))
+}
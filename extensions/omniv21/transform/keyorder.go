@@ -0,0 +1,112 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// schemaKeyOrders returns the keys, in the order they appear in the schema, of all the JSON objects in
+// the schema, keyed by the joined JSON paths to the objects.
+func schemaKeyOrders(content []byte) map[string][]string {
+	orders := map[string][]string{}
+	dec := json.NewDecoder(bytes.NewReader(content))
+	var walk func(path []string) bool
+	walk = func(path []string) bool {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		switch tok {
+		case json.Delim('{'):
+			keys := []string{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return false
+				}
+				keys = append(keys, key.(string))
+				if !walk(subPath(path, key.(string))) {
+					return false
+				}
+			}
+			orders[keyOrderPath(path)] = keys
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if !walk(subPath(path, strconv.Itoa(i))) {
+					return false
+				}
+			}
+		default:
+			return true
+		}
+		// consume the closing delim.
+		_, err = dec.Token()
+		return err == nil
+	}
+	walk(nil)
+	return orders
+}
+
+func keyOrderPath(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+// objectKeyOrder returns the child names of an object decl in the order they are declared in the schema.
+// Names not found in the schema as is, such as the ones resulted from template placeholders, go last,
+// sorted.
+func (ctx *validateCtx) objectKeyOrder(decl *Decl) []string {
+	if ctx.keyOrders == nil {
+		ctx.keyOrders = schemaKeyOrders(ctx.schemaContent)
+	}
+	order := make([]string, 0, len(decl.Object))
+	seen := map[string]bool{}
+	for _, key := range ctx.keyOrders[keyOrderPath(subPath(decl.srcPath, "object"))] {
+		if _, found := decl.Object[key]; found && !seen[key] {
+			order = append(order, key)
+			seen[key] = true
+		}
+	}
+	var rest []string
+	for key := range decl.Object {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	return append(order, rest...)
+}
+
+// keyOrder is the key order of an object created by a parseCtx, along with the object itself: holding
+// onto the object keeps its map pointer, by which it's identified, from being reused by another object
+// for as long as the parseCtx is alive.
+type keyOrder struct {
+	obj  map[string]interface{}
+	keys []string
+}
+
+// WithKeyOrder makes the parseCtx record the key order of the objects it creates, which can then be
+// retrieved by KeyOrder: for an 'object', the order its fields are declared in the schema; for an
+// 'object_from', the order its keys first appear in the input.
+func (p *parseCtx) WithKeyOrder() *parseCtx {
+	p.keyOrders = map[uintptr]keyOrder{}
+	return p
+}
+
+// KeyOrder returns the key order of an object created by the parseCtx, or nil if not recorded.
+func (p *parseCtx) KeyOrder(obj map[string]interface{}) []string {
+	if p.keyOrders == nil || obj == nil {
+		return nil
+	}
+	return p.keyOrders[reflect.ValueOf(obj).Pointer()].keys
+}
+
+func (p *parseCtx) recordKeyOrder(obj map[string]interface{}, keys []string) {
+	if p.keyOrders == nil {
+		return
+	}
+	p.keyOrders[reflect.ValueOf(obj).Pointer()] = keyOrder{obj: obj, keys: keys}
+}
@@ -0,0 +1,72 @@
+package transform
+
+import (
+	"time"
+)
+
+// The names of the record metadata that 'meta' decls can output.
+const (
+	metaChecksum      = "checksum"
+	metaInputName     = "input_name"
+	metaLineStart     = "line_start"
+	metaRecordIndex   = "record_index"
+	metaTransformTime = "transform_time"
+)
+
+// metaTypes contains the value types of the record metadata.
+var metaTypes = map[string]exprType{
+	metaChecksum:      exprTypeString,
+	metaInputName:     exprTypeString,
+	metaLineStart:     exprTypeInt,
+	metaRecordIndex:   exprTypeInt,
+	metaTransformTime: exprTypeString,
+}
+
+// RecordMeta contains the metadata of the record being transformed, for the 'meta' decls.
+type RecordMeta struct {
+	// RecordIndex is the 1-based index of the record in the input.
+	RecordIndex int
+	// LineStart is the 1-based line number of the input where the record starts, or 0 if the input
+	// format reader doesn't report it.
+	LineStart int
+	// Checksum returns a stable hash of the record. It's a func because computing the hash can be
+	// expensive and is only needed when a 'meta' decl asks for it.
+	Checksum func() string
+	// TransformTime is the time the record is transformed.
+	TransformTime time.Time
+}
+
+// WithRecordMeta sets the metadata of the record to be parsed and transformed.
+func (p *parseCtx) WithRecordMeta(meta *RecordMeta) *parseCtx {
+	p.recordMeta = meta
+	return p
+}
+
+func (p *parseCtx) metaValue(name string) interface{} {
+	if name == metaInputName {
+		if p.transformCtx == nil {
+			return nil
+		}
+		return p.transformCtx.InputName
+	}
+	if p.recordMeta == nil {
+		return nil
+	}
+	switch name {
+	case metaChecksum:
+		if p.recordMeta.Checksum != nil {
+			return p.recordMeta.Checksum()
+		}
+	case metaLineStart:
+		if p.recordMeta.LineStart > 0 {
+			return p.recordMeta.LineStart
+		}
+	case metaRecordIndex:
+		return p.recordMeta.RecordIndex
+	case metaTransformTime:
+		if !p.recordMeta.TransformTime.IsZero() {
+			return p.recordMeta.TransformTime.UTC().Format(time.RFC3339)
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/jf-tech/go-corelib/strs"
 
@@ -18,6 +19,12 @@ type parseCtx struct {
 	customParseFuncs      CustomParseFuncs // Deprecated.
 	disableTransformCache bool             // by default, we have caching on. only in some tests we turn caching off.
 	transformCache        map[string]interface{}
+	violations            []string // 'validate' violations found so far in the record.
+	recordMeta            *RecordMeta
+	templateBindings      map[*templateScope]*templateBinding // the args bound in the template scopes entered.
+	keyOrders             map[uintptr]keyOrder                // the key orders of the objects created, if recorded.
+	sensitiveValues       []string                            // the values of the 'sensitive' decls seen in the record.
+	externalVars          map[string]string                   // the external properties and values bound in xpath.
 }
 
 // NewParseCtx creates new context for parsing and transforming a *Node (and its sub-tree) into an output record.
@@ -35,14 +42,54 @@ func NewParseCtx(
 }
 
 func (p *parseCtx) ParseNode(n *idr.Node, decl *Decl) (interface{}, error) {
+	v, err := p.parseNode(n, decl)
+	if err != nil {
+		return nil, err
+	}
+	// Only when the entire record (or its dedup key) is parsed, do we report all the 'validate'
+	// violations found in it.
+	if decl.fqdn == finalOutput || decl.fqdn == dedupKey {
+		if err := p.violationsErr(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (p *parseCtx) parseNode(n *idr.Node, decl *Decl) (interface{}, error) {
+	if decl.scope != nil && decl.scope.root == decl {
+		exitTemplateScope, err := p.enterTemplateScope(n, decl.scope)
+		if err != nil {
+			return nil, err
+		}
+		defer exitTemplateScope()
+	}
 	var cacheKey string
 	if !p.disableTransformCache {
 		cacheKey = strconv.FormatInt(n.ID, 16) + "/" + decl.hash
+		// the same decl in template instances with different args yields different values.
+		if binding := p.templateBinding(decl); binding != nil && binding.key != "" {
+			cacheKey += "/" + binding.key
+		}
 		if cacheValue, found := p.transformCache[cacheKey]; found {
+			// the value might be cached by a decl that is identical, except not inheriting 'sensitive'.
+			if decl.sensitive {
+				p.markSensitive(cacheValue)
+			}
+			// the value might be cached by an identical decl elsewhere, whose violations name its own fqdn.
+			if decl.Validate != nil {
+				p.validateValue(decl, cacheValue)
+			}
 			return cacheValue, nil
 		}
 	}
 	saveIntoCache := func(value interface{}, err error) (interface{}, error) {
+		if err == nil && decl.sensitive {
+			p.markSensitive(value)
+		}
+		if err == nil && decl.Validate != nil {
+			p.validateValue(decl, value)
+		}
 		if !p.disableTransformCache {
 			if err != nil {
 				return value, err
@@ -56,16 +103,24 @@ func (p *parseCtx) ParseNode(n *idr.Node, decl *Decl) (interface{}, error) {
 		return saveIntoCache(p.parseConst(decl))
 	case kindExternal:
 		return saveIntoCache(p.parseExternal(decl))
+	case kindMeta:
+		return saveIntoCache(p.parseMeta(decl))
 	case kindField:
 		return saveIntoCache(p.parseField(n, decl))
 	case kindObject:
 		return saveIntoCache(p.parseObject(n, decl))
+	case kindObjectFrom:
+		return saveIntoCache(p.parseObjectFrom(n, decl))
 	case kindArray:
 		return saveIntoCache(p.parseArray(n, decl))
 	case kindCustomFunc:
 		return saveIntoCache(p.parseCustomFunc(n, decl))
 	case kindCustomParse:
 		return saveIntoCache(p.parseCustomParse(n, decl))
+	case kindExpr:
+		return saveIntoCache(p.parseExpr(n, decl))
+	case kindTemplate:
+		return saveIntoCache(p.parseRecursiveTemplate(n, decl))
 	default:
 		return nil, fmt.Errorf("unexpected decl kind '%s' on '%s'", decl.kind, decl.fqdn)
 	}
@@ -76,10 +131,53 @@ func (p *parseCtx) parseConst(decl *Decl) (interface{}, error) {
 }
 
 func (p *parseCtx) parseExternal(decl *Decl) (interface{}, error) {
-	if v, found := p.transformCtx.External(*decl.External); found {
-		return normalizeAndReturnValue(decl, v)
+	v, found := p.transformCtx.ExternalValue(*decl.External)
+	if found && decl.Path != nil {
+		v, found = externalPathValue(v, *decl.Path)
+		if !found && decl.Default == nil {
+			return nil, fmt.Errorf("cannot find path '%s' in external property '%s' on '%s'",
+				*decl.Path, *decl.External, decl.fqdn)
+		}
+	}
+	if !found {
+		if decl.Default == nil {
+			return nil, fmt.Errorf("cannot find external property '%s' on '%s'", *decl.External, decl.fqdn)
+		}
+		v = decl.defaultValue
+	}
+	return normalizeAndReturnValue(decl, v)
+}
+
+// externalPathValue walks down a structured external property value along a dot-separated path, where
+// each segment is either a key into a map, or an index into an array/slice.
+func externalPathValue(v interface{}, path string) (interface{}, bool) {
+	for _, segment := range strings.Split(path, ".") {
+		value := reflect.ValueOf(v)
+		switch value.Kind() {
+		case reflect.Map:
+			if value.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			elem := value.MapIndex(reflect.ValueOf(segment).Convert(value.Type().Key()))
+			if !elem.IsValid() {
+				return nil, false
+			}
+			v = elem.Interface()
+		case reflect.Slice, reflect.Array:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= value.Len() {
+				return nil, false
+			}
+			v = value.Index(index).Interface()
+		default:
+			return nil, false
+		}
 	}
-	return nil, fmt.Errorf("cannot find external property '%s' on '%s'", *decl.External, decl.fqdn)
+	return v, true
+}
+
+func (p *parseCtx) parseMeta(decl *Decl) (interface{}, error) {
+	return normalizeAndReturnValue(decl, p.metaValue(*decl.Meta))
 }
 
 func xpathQueryNeeded(decl *Decl) bool {
@@ -133,6 +231,55 @@ func xpathMatchFlags(dynamic bool) uint {
 	return 0
 }
 
+// externalProperties returns the scalar external properties and values, stringified, for binding the
+// variables in xpath queries. Each name is looked up by transformctx.Ctx.ExternalValue, so the precedence
+// between ExternalProperties and ExternalValues is the same as everywhere else.
+func (p *parseCtx) externalProperties() map[string]string {
+	if p.transformCtx == nil {
+		return nil
+	}
+	if p.externalVars == nil {
+		p.externalVars = map[string]string{}
+		bind := func(name string) {
+			if _, bound := p.externalVars[name]; bound {
+				return
+			}
+			v, _ := p.transformCtx.ExternalValue(name)
+			if s, ok := scalarString(v); ok {
+				p.externalVars[name] = s
+			}
+		}
+		for name := range p.transformCtx.ExternalValues {
+			bind(name)
+		}
+		for name := range p.transformCtx.ExternalProperties {
+			bind(name)
+		}
+	}
+	return p.externalVars
+}
+
+func scalarString(v interface{}) (string, bool) {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("%v", v), true
+	default:
+		return "", false
+	}
+}
+
+// xpathVars returns the bindings for the variables referenced in xpath queries of a decl: they're bound
+// to the external properties and values, and, if the decl is in a template, the template args.
+func (p *parseCtx) xpathVars(decl *Decl) map[string]string {
+	if binding := p.templateBinding(decl); binding != nil {
+		return binding.vars
+	}
+	return p.externalProperties()
+}
+
 func (p *parseCtx) querySingleNodeFromXPath(n *idr.Node, decl *Decl) (*idr.Node, error) {
 	if !xpathQueryNeeded(decl) {
 		return n, nil
@@ -141,7 +288,7 @@ func (p *parseCtx) querySingleNodeFromXPath(n *idr.Node, decl *Decl) (*idr.Node,
 	if err != nil {
 		return nil, nil
 	}
-	resultNode, err := idr.MatchSingle(n, xpath, xpathMatchFlags(dynamic))
+	resultNode, err := idr.MatchSingleWithVars(n, xpath, p.xpathVars(decl), xpathMatchFlags(dynamic))
 	switch {
 	case err == idr.ErrNoMatch:
 		return nil, nil
@@ -150,6 +297,9 @@ func (p *parseCtx) querySingleNodeFromXPath(n *idr.Node, decl *Decl) (*idr.Node,
 	case err != nil:
 		return nil, fmt.Errorf("xpath query '%s' on '%s' failed: %s", xpath, decl.fqdn, err.Error())
 	}
+	if decl.sensitive {
+		p.markSensitive(resultNode)
+	}
 	return resultNode, nil
 }
 
@@ -179,6 +329,21 @@ func (p *parseCtx) parseCustomFunc(n *idr.Node, decl *Decl) (interface{}, error)
 	return normalizeAndReturnValue(decl, funcResult)
 }
 
+func (p *parseCtx) parseExpr(n *idr.Node, decl *Decl) (interface{}, error) {
+	n, err := p.querySingleNodeFromXPath(n, decl)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, nil
+	}
+	v, err := p.invokeExpr(n, decl.Expr)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeAndReturnValue(decl, v)
+}
+
 func (p *parseCtx) parseCustomParse(n *idr.Node, decl *Decl) (interface{}, error) {
 	n, err := p.querySingleNodeFromXPath(n, decl)
 	if err != nil {
@@ -214,6 +379,80 @@ func (p *parseCtx) parseObject(n *idr.Node, decl *Decl) (interface{}, error) {
 			obj[strs.LastNameletOfFQDNWithEsc(childDecl.fqdn)] = normalizedValue
 		})
 	}
+	if p.keyOrders != nil {
+		keys := make([]string, 0, len(obj))
+		for _, key := range decl.keyOrder {
+			if _, found := obj[key]; found {
+				keys = append(keys, key)
+			}
+		}
+		p.recordKeyOrder(obj, keys)
+	}
+	return normalizeAndReturnValue(decl, obj)
+}
+
+func (p *parseCtx) parseObjectFrom(n *idr.Node, decl *Decl) (interface{}, error) {
+	n, err := p.querySingleNodeFromXPath(n, decl)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, nil
+	}
+	objectFrom := decl.ObjectFrom
+	entries, err := idr.MatchAllWithVars(n, objectFrom.XPath, p.xpathVars(decl))
+	if err != nil {
+		return nil, fmt.Errorf("xpath query '%s' on '%s' failed: %s", objectFrom.XPath, objectFrom.fqdn, err.Error())
+	}
+	obj := map[string]interface{}{}
+	var keys []string // in the order the keys first appear in the input.
+	for _, entry := range entries {
+		key, err := p.ParseNode(entry, objectFrom.Key)
+		if err != nil {
+			return nil, err
+		}
+		// entries without keys are skipped, just like fields without values are omitted in an object.
+		if key == nil || isEmpty(key) {
+			continue
+		}
+		keyStr := fmt.Sprintf("%v", key)
+		value, err := p.ParseNode(entry, objectFrom.Value)
+		if err != nil {
+			return nil, err
+		}
+		var saveErr error
+		// value returned by p.ParseNode is already normalized, thus this
+		// normalizeAndSaveValue won't fail.
+		_ = normalizeAndSaveValue(objectFrom.Value, value, func(normalizedValue interface{}) {
+			existing, dup := obj[keyStr]
+			if !dup {
+				keys = append(keys, keyStr)
+			}
+			switch objectFrom.OnDuplicate {
+			case onDuplicateFirst:
+				if !dup {
+					obj[keyStr] = normalizedValue
+				}
+			case onDuplicateArray:
+				if !dup {
+					existing = []interface{}{}
+				}
+				obj[keyStr] = append(existing.([]interface{}), normalizedValue)
+			case onDuplicateError:
+				if dup {
+					saveErr = fmt.Errorf("duplicate key '%s' on '%s'", keyStr, objectFrom.fqdn)
+					return
+				}
+				obj[keyStr] = normalizedValue
+			default:
+				obj[keyStr] = normalizedValue
+			}
+		})
+		if saveErr != nil {
+			return nil, saveErr
+		}
+	}
+	p.recordKeyOrder(obj, keys)
 	return normalizeAndReturnValue(decl, obj)
 }
 
@@ -231,10 +470,11 @@ func (p *parseCtx) parseArray(n *idr.Node, decl *Decl) (interface{}, error) {
 		if err != nil {
 			continue
 		}
-		childNodes, err := idr.MatchAll(n, xpath, xpathMatchFlags(dynamic))
+		childNodes, err := idr.MatchAllWithVars(n, xpath, p.xpathVars(decl), xpathMatchFlags(dynamic))
 		if err != nil {
 			return nil, fmt.Errorf("xpath query '%s' on '%s' failed: %s", xpath, childDecl.fqdn, err.Error())
 		}
+		p.markSensitiveNodes(childDecl, childNodes)
 		for _, childNode := range childNodes {
 			childValue, err := p.ParseNode(childNode, childDecl)
 			if err != nil {
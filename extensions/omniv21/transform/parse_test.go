@@ -2,7 +2,9 @@ package transform
 
 import (
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jf-tech/go-corelib/strs"
 	"github.com/stretchr/testify/assert"
@@ -33,13 +35,28 @@ func testParseCtx() *parseCtx {
 	ctx := NewParseCtx(
 		&transformctx.Ctx{
 			InputName:          "test-input",
-			ExternalProperties: map[string]string{"abc": "efg"},
+			ExternalProperties: map[string]string{"abc": "efg", "both": "prop"},
+			ExternalValues: map[string]interface{}{
+				"both": 2,
+				"num":  12.5,
+				"n":    5,
+				"obj": map[string]interface{}{
+					"list": []interface{}{"x", map[string]interface{}{"flag": true}},
+				},
+				"strs": map[string][]string{"k": {"v0", "v1"}},
+			},
 		},
 		customfuncs.Merge(
 			customfuncs.CustomFuncs{
 				"test_func": func(_ *transformctx.Ctx, args ...string) (string, error) {
 					return "test", nil
 				},
+				"test_int_func": func(_ *transformctx.Ctx, n int) (int, error) {
+					return n * 2, nil
+				},
+				"test_slice_func": func(_ *transformctx.Ctx, values []string, sep string) (string, error) {
+					return strings.Join(values, sep), nil
+				},
 			},
 			customfuncs.CommonCustomFuncs,
 			v21.OmniV21CustomFuncs),
@@ -323,6 +340,77 @@ func TestParseExternal(t *testing.T) {
 			expectedValue: nil,
 			expectedErr:   "cannot find external property 'efg' on 'test_fqdn'",
 		},
+		{
+			name:          "externalValues number",
+			decl:          &Decl{External: strs.StrPtr("num")},
+			expectedValue: 12.5,
+			expectedErr:   "",
+		},
+		{
+			name:          "externalValues number with type",
+			decl:          &Decl{External: strs.StrPtr("num"), ResultType: testResultType(resultTypeInt)},
+			expectedValue: int64(12),
+			expectedErr:   "",
+		},
+		{
+			name:          "externalValues takes precedence over externalProperties",
+			decl:          &Decl{External: strs.StrPtr("both")},
+			expectedValue: 2,
+			expectedErr:   "",
+		},
+		{
+			name:          "externalValues object",
+			decl:          &Decl{External: strs.StrPtr("obj"), Path: strs.StrPtr("list.1")},
+			expectedValue: map[string]interface{}{"flag": true},
+			expectedErr:   "",
+		},
+		{
+			name:          "externalValues path to leaf",
+			decl:          &Decl{External: strs.StrPtr("obj"), Path: strs.StrPtr("list.1.flag")},
+			expectedValue: true,
+			expectedErr:   "",
+		},
+		{
+			name:          "externalValues path into typed go values",
+			decl:          &Decl{External: strs.StrPtr("strs"), Path: strs.StrPtr("k.1")},
+			expectedValue: "v1",
+			expectedErr:   "",
+		},
+		{
+			name:          "externalValues path not found",
+			decl:          &Decl{External: strs.StrPtr("obj"), Path: strs.StrPtr("list.2"), fqdn: "test_fqdn"},
+			expectedValue: nil,
+			expectedErr:   "cannot find path 'list.2' in external property 'obj' on 'test_fqdn'",
+		},
+		{
+			name:          "externalValues path into scalar",
+			decl:          &Decl{External: strs.StrPtr("num"), Path: strs.StrPtr("a"), fqdn: "test_fqdn"},
+			expectedValue: nil,
+			expectedErr:   "cannot find path 'a' in external property 'num' on 'test_fqdn'",
+		},
+		{
+			name: "path not found with default",
+			decl: &Decl{
+				External: strs.StrPtr("obj"), Path: strs.StrPtr("list.x"),
+				Default: []byte(`"dft"`), defaultValue: "dft"},
+			expectedValue: "dft",
+			expectedErr:   "",
+		},
+		{
+			name: "not found with default and type",
+			decl: &Decl{
+				External: strs.StrPtr("efg"), ResultType: testResultType(resultTypeFloat),
+				Default: []byte(`"3.5"`), defaultValue: "3.5"},
+			expectedValue: 3.5,
+			expectedErr:   "",
+		},
+		{
+			name: "not found with null default",
+			decl: &Decl{
+				External: strs.StrPtr("efg"), Default: []byte(`null`), defaultValue: nil},
+			expectedValue: nil,
+			expectedErr:   "",
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			linkParent(test.decl)
@@ -339,6 +427,59 @@ func TestParseExternal(t *testing.T) {
 	}
 }
 
+func TestParseMeta(t *testing.T) {
+	meta := &RecordMeta{
+		RecordIndex:   3,
+		LineStart:     12,
+		Checksum:      func() string { return "test-checksum" },
+		TransformTime: time.Date(2021, 3, 4, 5, 6, 7, 8, time.FixedZone("PST", -8*3600)),
+	}
+	for _, test := range []struct {
+		name          string
+		decl          *Decl
+		meta          *RecordMeta
+		expectedValue interface{}
+	}{
+		{name: "input_name", decl: &Decl{Meta: strs.StrPtr(metaInputName)}, expectedValue: "test-input"},
+		{name: "input_name without record meta", decl: &Decl{Meta: strs.StrPtr(metaInputName)}, meta: nil, expectedValue: "test-input"},
+		{name: "record_index", decl: &Decl{Meta: strs.StrPtr(metaRecordIndex)}, meta: meta, expectedValue: 3},
+		{name: "record_index to string", decl: &Decl{Meta: strs.StrPtr(metaRecordIndex), ResultType: testResultType(resultTypeString)}, meta: meta, expectedValue: "3"},
+		{name: "line_start", decl: &Decl{Meta: strs.StrPtr(metaLineStart)}, meta: meta, expectedValue: 12},
+		{name: "line_start unknown", decl: &Decl{Meta: strs.StrPtr(metaLineStart)}, meta: &RecordMeta{}, expectedValue: nil},
+		{name: "checksum", decl: &Decl{Meta: strs.StrPtr(metaChecksum)}, meta: meta, expectedValue: "test-checksum"},
+		{name: "checksum unknown", decl: &Decl{Meta: strs.StrPtr(metaChecksum)}, meta: &RecordMeta{}, expectedValue: nil},
+		{name: "transform_time", decl: &Decl{Meta: strs.StrPtr(metaTransformTime)}, meta: meta, expectedValue: "2021-03-04T13:06:07Z"},
+		{name: "transform_time unknown", decl: &Decl{Meta: strs.StrPtr(metaTransformTime)}, meta: &RecordMeta{}, expectedValue: nil},
+		{name: "no record meta", decl: &Decl{Meta: strs.StrPtr(metaRecordIndex)}, meta: nil, expectedValue: nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := testParseCtx().WithRecordMeta(test.meta).parseMeta(test.decl)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedValue, value)
+		})
+	}
+}
+
+func TestParseCtx_ExternalProperties(t *testing.T) {
+	assert.Nil(t, NewParseCtx(nil, nil, nil).externalProperties())
+	p := NewParseCtx(
+		&transformctx.Ctx{
+			ExternalProperties: map[string]string{"a": "prop", "b": "prop", "arr": "prop"},
+			ExternalValues: map[string]interface{}{
+				"b":   "value",
+				"num": 12.5,
+				"int": 3,
+				"yes": true,
+				"obj": map[string]interface{}{"k": "v"},
+				"arr": []interface{}{"v"},
+				"nil": nil,
+			},
+		}, nil, nil)
+	assert.Equal(t,
+		map[string]string{"a": "prop", "b": "value", "num": "12.5", "int": "3", "yes": "true"},
+		p.externalProperties())
+}
+
 func TestParseCtx_ParseField(t *testing.T) {
 	for _, test := range []struct {
 		name          string
@@ -364,6 +505,24 @@ func TestParseCtx_ParseField(t *testing.T) {
 			expectedValue: "b",
 			expectedErr:   "",
 		},
+		{
+			name:          "matched with custom xpath func and variable bound to external property",
+			decl:          &Decl{XPath: strs.StrPtr("*[omni:upper(.) = omni:upper($abc) or . = 'c']"), kind: kindField},
+			expectedValue: "c",
+			expectedErr:   "",
+		},
+		{
+			name:          "matched with variable bound to scalar external value",
+			decl:          &Decl{XPath: strs.StrPtr("*[$num > 12 and . = 'c']"), kind: kindField},
+			expectedValue: "c",
+			expectedErr:   "",
+		},
+		{
+			name:          "matched with variable bound to external value taking precedence over external property",
+			decl:          &Decl{XPath: strs.StrPtr("*[$both = 2 and . = 'c']"), kind: kindField},
+			expectedValue: "c",
+			expectedErr:   "",
+		},
 		{
 			name:          "no nodes matched",
 			decl:          &Decl{XPath: strs.StrPtr("abc"), kind: kindField},
@@ -677,6 +836,85 @@ func TestParseCtx_ParseObject(t *testing.T) {
 	}
 }
 
+func TestParseCtx_ParseObjectFrom(t *testing.T) {
+	// R
+	//    E (k=a, v=1)
+	//    E (k=b, v=2)
+	//    E (k=a, v=3)
+	//    E (k=, v=4)
+	nodeR := idr.CreateNode(idr.ElementNode, "R")
+	for _, kv := range [][2]string{{"a", "1"}, {"b", "2"}, {"a", "3"}, {"", "4"}} {
+		nodeE := idr.CreateNode(idr.ElementNode, "E")
+		idr.AddChild(nodeR, nodeE)
+		for i, name := range []string{"k", "v"} {
+			attr := idr.CreateNode(idr.AttributeNode, name)
+			idr.AddChild(nodeE, attr)
+			idr.AddChild(attr, idr.CreateNode(idr.TextNode, kv[i]))
+		}
+	}
+	for _, test := range []struct {
+		name        string
+		onDuplicate string
+		value       string
+		err         string
+		expected    interface{}
+	}{
+		{
+			name:     "default to last",
+			expected: map[string]interface{}{"a": "3", "b": "2"},
+		},
+		{
+			name:        "first",
+			onDuplicate: `"first"`,
+			expected:    map[string]interface{}{"a": "1", "b": "2"},
+		},
+		{
+			name:        "array",
+			onDuplicate: `"array"`,
+			value:       `{ "xpath": "@v", "type": "int" }`,
+			expected: map[string]interface{}{
+				"a": []interface{}{int64(1), int64(3)},
+				"b": []interface{}{int64(2)},
+			},
+		},
+		{
+			name:        "error",
+			onDuplicate: `"error"`,
+			err:         "duplicate key 'a' on 'FINAL_OUTPUT.object_from'",
+		},
+		{
+			name:  "value failure",
+			value: `{ "xpath": "@k", "type": "int" }`,
+			err:   `unable to convert value 'a' to type 'int' on 'FINAL_OUTPUT.object_from.value', err: strconv.ParseInt: parsing "a": invalid syntax`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := testParseCtx()
+			onDuplicate, value := "", `{ "xpath": "@v" }`
+			if test.onDuplicate != "" {
+				onDuplicate = `, "on_duplicate": ` + test.onDuplicate
+			}
+			if test.value != "" {
+				value = test.value
+			}
+			decl, err := ValidateTransformDeclarations([]byte(`{"transform_declarations": {
+                "FINAL_OUTPUT": { "object_from": { "xpath": "E", "key": { "xpath": "@k" }, "value": `+
+				value+onDuplicate+` } }
+            }}`), ctx.customFuncs, nil)
+			assert.NoError(t, err)
+			v, err := ctx.ParseNode(nodeR, decl)
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Nil(t, v)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, v)
+		})
+	}
+}
+
 func TestParseCtx_ParseArray(t *testing.T) {
 	for _, test := range []struct {
 		name          string
@@ -803,3 +1041,34 @@ func TestParseCtx_ParseArray(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCtx_KeyOrder(t *testing.T) {
+	ctx := testParseCtx().WithKeyOrder()
+	decl, err := ValidateTransformDeclarations([]byte(`{"transform_declarations": {
+        "FINAL_OUTPUT": { "object": {
+            "z": { "const": "1" },
+            "b": { "template": "t", "args": { "p": { "const": "x" } } },
+            "a": { "object_from": { "xpath": "*", "value": { "const": "v" }, "key": { "custom_func": {
+                "name": "replace", "args": [ { "xpath": "." }, { "const": "b" }, { "const": "z" } ]
+            }}}},
+            "empty": { "xpath": "non-existing" }
+        }},
+        "t": { "params": [ "p" ], "object": {
+            "y": { "const": "2" },
+            "{{p}}": { "const": "3" },
+            "c": { "const": "4" }
+        }}
+    }}`), ctx.customFuncs, nil)
+	assert.NoError(t, err)
+	v, err := ctx.ParseNode(testNode(), decl)
+	assert.NoError(t, err)
+	obj := v.(map[string]interface{})
+	// 'empty' is omitted from the object, thus from the key order too.
+	assert.Equal(t, []string{"z", "b", "a"}, ctx.KeyOrder(obj))
+	// keys resulted from placeholders go last.
+	assert.Equal(t, []string{"y", "c", "x"}, ctx.KeyOrder(obj["b"].(map[string]interface{})))
+	// object_from keys are in the input order.
+	assert.Equal(t, []string{"z", "c"}, ctx.KeyOrder(obj["a"].(map[string]interface{})))
+	assert.Nil(t, ctx.KeyOrder(map[string]interface{}{}))
+	assert.Nil(t, testParseCtx().KeyOrder(obj))
+}
@@ -0,0 +1,75 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jf-tech/omniparser/idr"
+)
+
+// markSensitive records the values of a 'sensitive' decl, from either the node(s) it selects or the
+// value it yields, so that they can be redacted from the error messages and raw record dumps.
+func (p *parseCtx) markSensitive(v interface{}) {
+	switch v := v.(type) {
+	case nil:
+	case *idr.Node:
+		if v == nil {
+			return
+		}
+		p.addSensitiveValue(v.InnerText())
+		for c := v.FirstChild; c != nil; c = c.NextSibling {
+			p.markSensitive(c)
+		}
+	case map[string]interface{}:
+		for _, e := range v {
+			p.markSensitive(e)
+		}
+	case []interface{}:
+		for _, e := range v {
+			p.markSensitive(e)
+		}
+	case string:
+		p.addSensitiveValue(v)
+	default:
+		p.addSensitiveValue(fmt.Sprintf("%v", v))
+	}
+}
+
+// markSensitiveNodes marks the nodes selected by the xpath of a 'sensitive' decl.
+func (p *parseCtx) markSensitiveNodes(decl *Decl, nodes []*idr.Node) {
+	// without its own xpath, a decl selects the node of its parent, which isn't necessarily sensitive.
+	if !decl.sensitive || !decl.isXPathSet() {
+		return
+	}
+	for _, n := range nodes {
+		p.markSensitive(n)
+	}
+}
+
+func (p *parseCtx) addSensitiveValue(s string) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return
+	}
+	p.sensitiveValues = append(p.sensitiveValues, s)
+}
+
+// SensitiveValues returns the sensitive values seen so far in the record being parsed.
+func (p *parseCtx) SensitiveValues() []string {
+	return p.sensitiveValues
+}
+
+// MarkSensitiveXPaths marks the nodes matching the xpaths, relative to n, as sensitive, as if they were
+// selected by 'sensitive' decls.
+func (p *parseCtx) MarkSensitiveXPaths(n *idr.Node, xpaths []string) error {
+	for _, xpath := range xpaths {
+		nodes, err := idr.MatchAll(n, xpath)
+		if err != nil {
+			return fmt.Errorf("xpath query '%s' failed: %s", xpath, err.Error())
+		}
+		for _, node := range nodes {
+			p.markSensitive(node)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package transform
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jf-tech/omniparser/idr"
+)
+
+func sensitiveTestNode(t *testing.T) *idr.Node {
+	r, err := idr.NewJSONStreamReader(strings.NewReader(`
+		{
+			"name": "John",
+			"ssn": "123-45-6789",
+			"dob": "1990-01-31",
+			"cards": [
+				{ "no": "4111111111111111", "exp": "12/30" },
+				{ "no": "5500000000000004", "exp": "01/31" }
+			]
+		}`), "/")
+	assert.NoError(t, err)
+	n, err := r.Read()
+	assert.NoError(t, err)
+	return n
+}
+
+func uniqueSorted(values []string) []string {
+	set := map[string]bool{}
+	for _, v := range values {
+		set[v] = true
+	}
+	var result []string
+	for v := range set {
+		result = append(result, v)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestSensitiveValues(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		declJSON string
+		expected []string
+		err      string
+	}{
+		{
+			name: "no sensitive decls",
+			declJSON: `"FINAL_OUTPUT": { "object": {
+				"name": { "xpath": "name" },
+				"ssn": { "xpath": "ssn" }
+			}}`,
+			expected: nil,
+		},
+		{
+			name: "sensitive field",
+			declJSON: `"FINAL_OUTPUT": { "object": {
+				"name": { "xpath": "name" },
+				"ssn": { "xpath": "ssn", "sensitive": true },
+				"no_match": { "xpath": "no_match", "sensitive": true }
+			}}`,
+			expected: []string{"123-45-6789"},
+		},
+		{
+			name: "sensitive array",
+			declJSON: `"FINAL_OUTPUT": { "object": {
+				"cards": { "sensitive": true, "array": [
+					{ "xpath": "cards/*", "object": { "no": { "xpath": "no" } } }
+				]}
+			}}`,
+			// everything a selected node is made of is sensitive, even the parts not output.
+			expected: []string{"01/31", "12/30", "4111111111111111", "411111111111111112/30", "5500000000000004",
+				"550000000000000401/31"},
+		},
+		{
+			name: "sensitive custom_func",
+			declJSON: `"FINAL_OUTPUT": { "object": {
+				"ssn": { "sensitive": true, "custom_func": { "name": "concat", "args": [
+					{ "const": "ssn:" }, { "xpath": "ssn" }
+				]}}
+			}}`,
+			expected: []string{"123-45-6789", "ssn:", "ssn:123-45-6789"},
+		},
+		{
+			name: "sensitive decl same as a non-sensitive one evaluated earlier",
+			declJSON: `"FINAL_OUTPUT": { "object": {
+				"dob": { "xpath": "dob" },
+				"private": { "sensitive": true, "object": { "dob": { "xpath": "dob" } } }
+			}}`,
+			expected: []string{"1990-01-31"},
+		},
+		{
+			name: "failing sensitive field",
+			declJSON: `"FINAL_OUTPUT": { "object": {
+				"ssn": { "xpath": "ssn", "type": "int", "sensitive": true }
+			}}`,
+			expected: []string{"123-45-6789"},
+			err: `unable to convert value '123-45-6789' to type 'int' on 'FINAL_OUTPUT.ssn', ` +
+				`err: strconv.ParseInt: parsing "123-45-6789": invalid syntax`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := testParseCtx()
+			decl, err := ValidateTransformDeclarations(
+				[]byte(`{"transform_declarations": {`+test.declJSON+`}}`), ctx.customFuncs, nil)
+			assert.NoError(t, err)
+			_, err = ctx.ParseNode(sensitiveTestNode(t), decl)
+			encodeCtx := testParseCtx()
+			var buf bytes.Buffer
+			encodeErr := encodeCtx.EncodeNode(sensitiveTestNode(t), CompileEncodePlan(decl, EncodeOptions{}),
+				&buf, testValueEncoder)
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Error(t, encodeErr)
+			} else {
+				assert.NoError(t, err)
+				assert.NoError(t, encodeErr)
+			}
+			assert.Equal(t, test.expected, uniqueSorted(ctx.SensitiveValues()))
+			assert.Equal(t, test.expected, uniqueSorted(encodeCtx.SensitiveValues()))
+		})
+	}
+}
+
+func TestMarkSensitiveXPaths(t *testing.T) {
+	ctx := testParseCtx()
+	assert.NoError(t, ctx.MarkSensitiveXPaths(sensitiveTestNode(t), []string{"ssn", "cards/*/no", "no_match"}))
+	assert.Equal(t,
+		[]string{"123-45-6789", "4111111111111111", "5500000000000004"},
+		uniqueSorted(ctx.SensitiveValues()))
+	err := ctx.MarkSensitiveXPaths(sensitiveTestNode(t), []string{"["})
+	assert.Error(t, err)
+	assert.Equal(t,
+		"xpath query '[' failed: xpath '[' compilation failed: expression must evaluate to a node-set",
+		err.Error())
+}
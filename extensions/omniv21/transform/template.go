@@ -0,0 +1,245 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jf-tech/omniparser/idr"
+)
+
+// templateScope is the scope of a template instance, i.e. a template referenced at a particular site.
+// All the decls of the template body are in the scope, and can reference the template parameters as
+// xpath variables, which are bound to the values of the args, evaluated at the reference site. The
+// parameters of the scope the reference site is in, if any, are visible too, unless shadowed.
+type templateScope struct {
+	params []string
+	args   []*Decl // in the same order as params.
+	root   *Decl
+	site   *templateScope // the scope the reference site is in, if any.
+	// if the template is itself a reference to another template (e.g. a specialization of it, binding
+	// some of its args), the scope of the referencing template, which is entered before this one.
+	outer *templateScope
+}
+
+type templateBinding struct {
+	key  string            // appended to the transform cache keys of the decls in the scope.
+	vars map[string]string // the external properties overlaid with the args.
+}
+
+const (
+	// defaultTemplateMaxDepth is the max recursion depth of a template, if not specified by 'max_depth'.
+	defaultTemplateMaxDepth = 32
+)
+
+// templateRecursion is a recursive template reference, which is expanded lazily at transform time, only
+// when the recursion actually goes on, i.e. its xpath matches something. Each expansion contains the
+// recursive reference(s) of the next depth.
+type templateRecursion struct {
+	ctx              *validateCtx
+	ref              *Decl // the decl standing in for the template instance.
+	site             *Decl // a copy of the template reference site, validated upon expansion.
+	templateRefStack []string
+	depth            int
+	maxDepth         int
+	once             sync.Once
+	instance         *Decl
+	err              error
+}
+
+func (r *templateRecursion) expand() (*Decl, error) {
+	r.once.Do(func() {
+		r.ctx.recursionMu.Lock()
+		defer r.ctx.recursionMu.Unlock()
+		prevDepth := r.ctx.recursionDepth
+		r.ctx.recursionDepth = r.depth
+		defer func() { r.ctx.recursionDepth = prevDepth }()
+		site := r.site.deepCopy()
+		site.srcPath = r.ref.srcPath
+		instance, err := r.ctx.expandTemplate(r.ref.fqdn, site, r.ctx.Decls[*site.Template], r.templateRefStack)
+		if err != nil {
+			r.err = err
+			return
+		}
+		instance.sensitive = r.ref.sensitive
+		linkParent(instance)
+		instance.parent = r.ref.parent
+		if r.ref.scope != nil {
+			setTemplateScope(instance, r.ref.scope)
+		}
+		r.instance = instance
+	})
+	return r.instance, r.err
+}
+
+// isAdvancingXPath returns true if an xpath only goes down into the input, such that a recursive
+// template reference guarded by it doesn't recurse on the same node infinitely.
+func isAdvancingXPath(xpath string) bool {
+	xpath = strings.TrimSpace(xpath)
+	if xpath == "" || xpath == "." || strings.HasPrefix(xpath, "/") {
+		return false
+	}
+	for _, nonAdvancing := range []string{"..", "self::", "parent::", "ancestor::", "ancestor-or-self::"} {
+		if strings.Contains(xpath, nonAdvancing) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parseCtx) parseRecursiveTemplate(n *idr.Node, decl *Decl) (interface{}, error) {
+	r := decl.recursion
+	if r.depth > r.maxDepth {
+		// it's only an error if the recursion actually goes on.
+		if xpathQueryNeeded(decl) {
+			matched, err := p.querySingleNodeFromXPath(n, decl)
+			if err != nil || matched == nil {
+				return nil, err
+			}
+		}
+		return nil, fmt.Errorf("template '%s' on '%s' exceeds max recursion depth %d",
+			*decl.Template, decl.fqdn, r.maxDepth)
+	}
+	instance, err := r.expand()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseNode(n, instance)
+}
+
+func placeholder(param string) string {
+	return "{{" + param + "}}"
+}
+
+// substituteTemplatePlaceholders replaces all the '{{param}}' placeholders in a (copy of) template with
+// the values of the const args bound to the params.
+func substituteTemplatePlaceholders(
+	fqdn, templateName string, templateDecl *Decl, params []string, args map[string]*Decl) (*Decl, error) {
+	if len(params) == 0 {
+		return templateDecl, nil
+	}
+	b, _ := json.Marshal(templateDecl)
+	s := string(b)
+	for _, param := range params {
+		if !strings.Contains(s, placeholder(param)) {
+			continue
+		}
+		arg := args[param]
+		if arg.kind != kindConst {
+			return nil, fmt.Errorf(
+				"'%s' must bind a const to parameter '%s' of template '%s', as it is used as placeholder '%s'",
+				fqdn, param, templateName, placeholder(param))
+		}
+		// the arg value is substituted inside JSON strings, so it needs to be escaped as such.
+		escaped, _ := json.Marshal(*arg.Const)
+		s = strings.ReplaceAll(s, placeholder(param), string(escaped[1:len(escaped)-1]))
+	}
+	var declNew Decl
+	// the extra fields (such as 'fqdn') Decl.MarshalJSON produces are simply ignored.
+	if err := json.Unmarshal([]byte(s), &declNew); err != nil {
+		return nil, err
+	}
+	return &declNew, nil
+}
+
+func bindTemplateScope(instance *Decl, scope *templateScope) {
+	scope.root = instance
+	if instance.scope != nil && instance.scope.root == instance {
+		// the template is a reference to another template: the scope of which is already bound to the
+		// instance, so this scope becomes the outermost, within which the args of the next scope are evaluated.
+		next := outermostTemplateScope(instance.scope)
+		next.outer = scope
+		for _, arg := range next.args {
+			setTemplateScope(arg, scope)
+		}
+		return
+	}
+	setTemplateScope(instance, scope)
+}
+
+func outermostTemplateScope(scope *templateScope) *templateScope {
+	for scope.outer != nil {
+		scope = scope.outer
+	}
+	return scope
+}
+
+func setTemplateScope(decl *Decl, scope *templateScope) {
+	if decl.scope != nil {
+		// a nested template instance: its body is in its own scope, but its args are evaluated in this one.
+		outermost := outermostTemplateScope(decl.scope)
+		outermost.site = scope
+		for _, arg := range outermost.args {
+			setTemplateScope(arg, scope)
+		}
+		return
+	}
+	decl.scope = scope
+	if decl.XPathDynamic != nil {
+		setTemplateScope(decl.XPathDynamic, scope)
+	}
+	for _, child := range decl.children {
+		setTemplateScope(child, scope)
+	}
+}
+
+// enterTemplateScope evaluates the args of a template instance (and those of its outer scopes, if any)
+// against the node at the reference site, and binds them to the template params. The returned func
+// restores the bindings.
+func (p *parseCtx) enterTemplateScope(n *idr.Node, scope *templateScope) (func(), error) {
+	exitOuter := func() {}
+	base := &templateBinding{vars: p.externalProperties()}
+	switch {
+	case scope.outer != nil:
+		var err error
+		if exitOuter, err = p.enterTemplateScope(n, scope.outer); err != nil {
+			return nil, err
+		}
+		base = p.templateBindings[scope.outer]
+	case scope.site != nil:
+		if siteBinding, found := p.templateBindings[scope.site]; found {
+			base = siteBinding
+		}
+	}
+	binding := base
+	if len(scope.params) > 0 {
+		values := make([]string, len(scope.params))
+		binding = &templateBinding{vars: map[string]string{}}
+		for k, v := range base.vars {
+			binding.vars[k] = v
+		}
+		for i, arg := range scope.args {
+			v, err := p.parseNode(n, arg)
+			if err != nil {
+				exitOuter()
+				return nil, err
+			}
+			if v != nil {
+				values[i] = fmt.Sprintf("%v", v)
+			}
+			binding.vars[scope.params[i]] = values[i]
+		}
+		binding.key = base.key + fmt.Sprintf("%q", values)
+	}
+	if p.templateBindings == nil {
+		p.templateBindings = map[*templateScope]*templateBinding{}
+	}
+	prevBinding, prevFound := p.templateBindings[scope]
+	p.templateBindings[scope] = binding
+	return func() {
+		if prevFound {
+			p.templateBindings[scope] = prevBinding
+		} else {
+			delete(p.templateBindings, scope)
+		}
+		exitOuter()
+	}, nil
+}
+
+func (p *parseCtx) templateBinding(decl *Decl) *templateBinding {
+	if decl.scope == nil {
+		return nil
+	}
+	return p.templateBindings[decl.scope]
+}
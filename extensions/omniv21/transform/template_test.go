@@ -0,0 +1,209 @@
+package transform
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jf-tech/omniparser/idr"
+)
+
+func TestParseTemplateWithParams(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		declJSON string
+		err      string
+		expected interface{}
+	}{
+		{
+			name: "xpath variables bound to const args",
+			declJSON: `
+                "FINAL_OUTPUT": { "object": {
+                    "b": { "template": "t", "args": { "name": { "const": "B" } } },
+                    "c": { "template": "t", "args": { "name": { "const": "C" } } }
+                }},
+                "t": { "params": [ "name" ], "xpath": "*[name()=$name]" }`,
+			expected: map[string]interface{}{"b": "b", "c": "c"},
+		},
+		{
+			name: "xpath variable bound to xpath arg",
+			declJSON: `
+                "FINAL_OUTPUT": { "template": "t", "args": { "v": { "xpath": "C" } } },
+                "t": { "params": [ "v" ], "xpath": "*[.=$v]", "object": { "name": { "custom_func": { "name": "test_func" } } } }`,
+			expected: map[string]interface{}{"name": "test"},
+		},
+		{
+			name: "placeholders substituted with const args",
+			declJSON: `
+                "FINAL_OUTPUT": { "template": "t", "args": { "n": { "const": "B" }, "q": { "const": "'\"" } } },
+                "t": { "params": [ "n", "q" ], "object": {
+                    "{{n}}_key": { "xpath": "{{n}}" },
+                    "quote": { "const": "{{q}}" }
+                }}`,
+			expected: map[string]interface{}{"B_key": "b", "quote": `'"`},
+		},
+		{
+			name: "chained templates",
+			declJSON: `
+                "FINAL_OUTPUT": { "object": {
+                    "b": { "template": "t_b" },
+                    "c": { "template": "t_any", "args": { "x": { "xpath": "C" } } }
+                }},
+                "t_b": { "template": "t", "args": { "name": { "const": "B" } } },
+                "t_any": { "params": [ "x" ], "template": "t", "args": {
+                    "name": { "custom_func": { "name": "upper", "args": [ { "xpath": "*[.=$x]" } ] } }
+                }},
+                "t": { "params": [ "name" ], "xpath": "*[name()=$name]" }`,
+			expected: map[string]interface{}{"b": "b", "c": "c"},
+		},
+		{
+			name: "params visible in nested templates and args",
+			declJSON: `
+                "FINAL_OUTPUT": { "template": "outer", "args": { "name": { "const": "B" } } },
+                "outer": { "params": [ "name" ], "object": {
+                    "inner": { "template": "inner" },
+                    "shadowed": { "template": "shadowing", "args": {
+                        "name": { "custom_func": { "name": "upper", "args": [ { "xpath": "*[name()!=$name]" } ] } }
+                    }}
+                }},
+                "inner": { "xpath": "*[name()=$name]" },
+                "shadowing": { "params": [ "name" ], "xpath": "*[name()=$name]" }`,
+			expected: map[string]interface{}{"inner": "b", "shadowed": "c"},
+		},
+		{
+			name: "arg failure",
+			declJSON: `
+                "FINAL_OUTPUT": { "template": "t", "args": { "v": { "external": "non-existing" } } },
+                "t": { "params": [ "v" ], "xpath": "*[.=$v]" }`,
+			err: "cannot find external property 'non-existing' on 'FINAL_OUTPUT.template(t).arg(v)'",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := testParseCtx()
+			decl, err := ValidateTransformDeclarations(
+				[]byte(`{"transform_declarations": {`+test.declJSON+`}}`), ctx.customFuncs, nil)
+			assert.NoError(t, err)
+			v, err := ctx.ParseNode(testNode(), decl)
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Nil(t, v)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, v)
+			assert.Empty(t, ctx.templateBindings)
+		})
+	}
+}
+
+func TestParseRecursiveTemplate(t *testing.T) {
+	// A
+	//    N (1)
+	//       N (2)
+	//          N (3)
+	nodeA := idr.CreateNode(idr.ElementNode, "A")
+	parent := nodeA
+	for i := 1; i <= 3; i++ {
+		nodeN := idr.CreateNode(idr.ElementNode, "N")
+		idr.AddChild(parent, nodeN)
+		idr.AddChild(nodeN, idr.CreateNode(idr.AttributeNode, "id"))
+		idr.AddChild(nodeN.LastChild, idr.CreateNode(idr.TextNode, strconv.Itoa(i)))
+		parent = nodeN
+	}
+	for _, test := range []struct {
+		name     string
+		declJSON string
+		err      string
+		expected interface{}
+	}{
+		{
+			name: "recursion via array",
+			declJSON: `
+                "FINAL_OUTPUT": { "object": { "n": { "xpath": "N", "template": "t" } } },
+                "t": { "max_depth": 2, "object": {
+                    "id": { "xpath": "@id" },
+                    "children": { "array": [ { "xpath": "N", "template": "t" } ] }
+                }}`,
+			expected: map[string]interface{}{
+				"n": map[string]interface{}{
+					"id": "1",
+					"children": []interface{}{
+						map[string]interface{}{
+							"id": "2",
+							"children": []interface{}{
+								map[string]interface{}{"id": "3"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "recursion via object exceeds max depth",
+			declJSON: `
+                "FINAL_OUTPUT": { "xpath": "N", "template": "t" },
+                "t": { "max_depth": 1, "object": {
+                    "id": { "xpath": "@id" },
+                    "child": { "xpath": "N", "template": "t" }
+                }}`,
+			err: "template 't' on 'FINAL_OUTPUT.child.child' exceeds max recursion depth 1",
+		},
+		{
+			name: "mutual recursion with params",
+			declJSON: `
+                "FINAL_OUTPUT": { "template": "t1", "args": { "p": { "const": "x" } } },
+                "t1": { "params": [ "p" ], "object": {
+                    "p": { "const": "{{p}}" },
+                    "next": { "xpath": "N", "template": "t2", "args": { "q": { "xpath": "N/@id" } } }
+                }},
+                "t2": { "params": [ "q" ], "object": {
+                    "q": { "xpath": "@id[.=$q]" },
+                    "next": { "xpath": "N", "template": "t1", "args": { "p": { "const": "y" } } }
+                }}`,
+			expected: map[string]interface{}{
+				"p": "x",
+				"next": map[string]interface{}{
+					"q": "1",
+					"next": map[string]interface{}{
+						"p":    "y",
+						"next": map[string]interface{}{"q": "3"},
+					},
+				},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := testParseCtx()
+			decl, err := ValidateTransformDeclarations(
+				[]byte(`{"transform_declarations": {`+test.declJSON+`}}`), ctx.customFuncs, nil)
+			assert.NoError(t, err)
+			v, err := ctx.ParseNode(nodeA, decl)
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Nil(t, v)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, v)
+		})
+	}
+}
+
+func TestIsAdvancingXPath(t *testing.T) {
+	for xpath, expected := range map[string]bool{
+		"child":                  true,
+		"./child[@id='a']":       true,
+		"descendant::part":       true,
+		" . ":                    false,
+		"/root/child":            false,
+		"../sibling":             false,
+		"child[../@id]":          false,
+		"self::node()":           false,
+		"ancestor-or-self::part": false,
+	} {
+		assert.Equal(t, expected, isAdvancingXPath(xpath), xpath)
+	}
+}
@@ -1,44 +1,72 @@
 package transform
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/google/uuid"
+	"github.com/jf-tech/go-corelib/caches"
 	"github.com/jf-tech/go-corelib/strs"
 
 	"github.com/jf-tech/omniparser/customfuncs"
+	"github.com/jf-tech/omniparser/idr"
 )
 
 type validateCtx struct {
 	Decls            map[string]*Decl `json:"transform_declarations"`
+	schemaContent    []byte
+	keyOrders        map[string][]string // the key orders of the JSON objects in the schema, computed lazily.
 	customFuncs      customfuncs.CustomFuncs
 	customParseFuncs CustomParseFuncs // Deprecated.
 	declHashes       map[string]string
+	// recursive template references are expanded at transform time, possibly concurrently.
+	recursionMu    sync.Mutex
+	recursionDepth int // the recursion depth of the template instance being expanded.
 }
 
 // ValidateTransformDeclarations validates `transform_declarations` section of an omni schema and returns
 // the `FINAL_OUTPUT` corresponding Decl.
 func ValidateTransformDeclarations(
 	schemaContent []byte, customFuncs customfuncs.CustomFuncs, customParseFuncs CustomParseFuncs) (*Decl, error) {
+	// We did json schema validation earlier, so "FINAL_OUTPUT" must exist.
+	return validateRootDecl(schemaContent, finalOutput, customFuncs, customParseFuncs)
+}
+
+// ValidateDedupKeyDeclaration validates the optional `DEDUP_KEY` decl of the `transform_declarations`
+// section of an omni schema, which computes the key of each record by which duplicate records are
+// detected, and returns its Decl, or nil if the schema doesn't have one.
+func ValidateDedupKeyDeclaration(
+	schemaContent []byte, customFuncs customfuncs.CustomFuncs, customParseFuncs CustomParseFuncs) (*Decl, error) {
+	return validateRootDecl(schemaContent, dedupKey, customFuncs, customParseFuncs)
+}
 
-	var ctx validateCtx
+func validateRootDecl(schemaContent []byte, name string,
+	customFuncs customfuncs.CustomFuncs, customParseFuncs CustomParseFuncs) (*Decl, error) {
+	ctx := &validateCtx{}
 	// We did json schema validation earlier, so this unmarshal guarantees to succeed.
-	_ = json.Unmarshal(schemaContent, &ctx)
+	_ = json.Unmarshal(schemaContent, ctx)
+	ctx.schemaContent = schemaContent
 	ctx.customFuncs = customFuncs
 	ctx.customParseFuncs = customParseFuncs
 	ctx.declHashes = map[string]string{}
 
-	// We did json schema validation earlier, so "FINAL_OUTPUT" must exist.
-	finalOutputDecl, err := ctx.validateDecl(finalOutput, ctx.Decls[finalOutput], []string{finalOutput})
+	decl, found := ctx.Decls[name]
+	if !found {
+		return nil, nil
+	}
+	decl.srcPath = []string{"transform_declarations", name}
+	decl, err := ctx.validateDecl(name, decl, []string{name})
 	if err != nil {
 		return nil, err
 	}
-	linkParent(finalOutputDecl)
-	return finalOutputDecl, nil
+	linkParent(decl)
+	return decl, nil
 }
 
 // In order to detect circular template references (e.g. template A has a reference to template B which
@@ -52,12 +80,32 @@ func (ctx *validateCtx) validateDecl(fqdn string, decl *Decl, templateRefStack [
 	}
 	decl.fqdn = fqdn
 	decl.resolveKind()
+	if decl.Multi {
+		return nil, fmt.Errorf("'%s' can only set 'multi' as a custom_func argument", fqdn)
+	}
+	if decl.Params != nil {
+		return nil, fmt.Errorf("'%s' can only set 'params' as a template", fqdn)
+	}
+	if decl.Args != nil && decl.kind != kindTemplate {
+		return nil, fmt.Errorf("'%s' can only set 'args' as a template reference", fqdn)
+	}
+	if decl.MaxDepth != nil {
+		return nil, fmt.Errorf("'%s' can only set 'max_depth' as a template", fqdn)
+	}
+	if err := validateRules(fqdn, decl.Validate); err != nil {
+		return nil, err
+	}
 	switch decl.kind {
 	case kindObject:
 		err := ctx.validateObject(fqdn, decl, templateRefStack)
 		if err != nil {
 			return nil, err
 		}
+	case kindObjectFrom:
+		err := ctx.validateObjectFrom(fqdn, decl, templateRefStack)
+		if err != nil {
+			return nil, err
+		}
 	case kindArray:
 		err := ctx.validateArray(fqdn, decl, templateRefStack)
 		if err != nil {
@@ -68,11 +116,25 @@ func (ctx *validateCtx) validateDecl(fqdn string, decl *Decl, templateRefStack [
 		if err != nil {
 			return nil, err
 		}
+	case kindExpr:
+		err := ctx.validateExpr(fqdn, decl, templateRefStack)
+		if err != nil {
+			return nil, err
+		}
 	case kindCustomParse:
 		err := ctx.validateCustomParse(fqdn, decl)
 		if err != nil {
 			return nil, err
 		}
+	case kindExternal:
+		err := validateExternal(fqdn, decl)
+		if err != nil {
+			return nil, err
+		}
+	case kindMeta:
+		if _, found := metaTypes[*decl.Meta]; !found {
+			return nil, fmt.Errorf("unknown meta '%s' on '%s'", *decl.Meta, fqdn)
+		}
 	case kindTemplate:
 		decl, err = ctx.validateTemplate(fqdn, decl, templateRefStack)
 		if err != nil {
@@ -87,11 +149,17 @@ func (ctx *validateCtx) validateXPath(fqdn string, decl *Decl, templateRefStack
 	if decl.XPath != nil && decl.XPathDynamic != nil {
 		return fmt.Errorf("'%s' cannot set both 'xpath' and 'xpath_dynamic' at the same time", fqdn)
 	}
+	if decl.XPath != nil {
+		if err := idr.ValidateXPathFuncs(*decl.XPath); err != nil {
+			return fmt.Errorf("'%s' has invalid 'xpath': %s", fqdn, err.Error())
+		}
+	}
 	// unlike `xpath` which is a constant string, `xpath_dynamic` value comes from the computation of
 	// regular decl, and it can be of a const/field/custom_func/template/external, so we need to parse
 	// and validate the decl as well.
 	if decl.XPathDynamic != nil {
 		var err error
+		decl.XPathDynamic.srcPath = subPath(decl.srcPath, "xpath_dynamic")
 		decl.XPathDynamic, err = ctx.validateDecl(
 			strs.BuildFQDN(fqdn, "xpath_dynamic"), decl.XPathDynamic, templateRefStack)
 		if err != nil {
@@ -103,6 +171,7 @@ func (ctx *validateCtx) validateXPath(fqdn string, decl *Decl, templateRefStack
 
 func (ctx *validateCtx) validateObject(fqdn string, decl *Decl, templateRefStack []string) error {
 	for childName, childDecl := range decl.Object {
+		childDecl.srcPath = subPath(decl.srcPath, "object", childName)
 		childDecl, err := ctx.validateDecl(
 			// childName can contain '.' or '%', it needs to be escaped.
 			strs.BuildFQDN(fqdn, strs.BuildFQDNWithEsc(childName)), childDecl, templateRefStack)
@@ -112,6 +181,7 @@ func (ctx *validateCtx) validateObject(fqdn string, decl *Decl, templateRefStack
 		decl.Object[childName] = childDecl
 		decl.children = append(decl.children, childDecl)
 	}
+	decl.keyOrder = ctx.objectKeyOrder(decl)
 	// Sort the `children` array for unit test snapshot stability.
 	// Given this schema parsing/loading is usually done infrequently, the sorting here shouldn't
 	// incur too much latency penalty for production code path.
@@ -121,8 +191,38 @@ func (ctx *validateCtx) validateObject(fqdn string, decl *Decl, templateRefStack
 	return nil
 }
 
+func (ctx *validateCtx) validateObjectFrom(fqdn string, decl *Decl, templateRefStack []string) error {
+	objectFrom := decl.ObjectFrom
+	objectFrom.fqdn = strs.BuildFQDN(fqdn, "object_from")
+	if err := idr.ValidateXPathFuncs(objectFrom.XPath); err != nil {
+		return fmt.Errorf("'%s' has invalid 'xpath': %s", objectFrom.fqdn, err.Error())
+	}
+	switch objectFrom.OnDuplicate {
+	case "", onDuplicateFirst, onDuplicateLast, onDuplicateArray, onDuplicateError:
+	default:
+		return fmt.Errorf("'%s' has invalid 'on_duplicate' '%s'", objectFrom.fqdn, objectFrom.OnDuplicate)
+	}
+	var err error
+	objectFrom.Key.srcPath = subPath(decl.srcPath, "object_from", "key")
+	objectFrom.Key, err = ctx.validateDecl(strs.BuildFQDN(objectFrom.fqdn, "key"), objectFrom.Key, templateRefStack)
+	if err != nil {
+		return err
+	}
+	objectFrom.Value.srcPath = subPath(decl.srcPath, "object_from", "value")
+	objectFrom.Value, err = ctx.validateDecl(
+		strs.BuildFQDN(objectFrom.fqdn, "value"), objectFrom.Value, templateRefStack)
+	if err != nil {
+		return err
+	}
+	decl.children = []*Decl{objectFrom.Key, objectFrom.Value}
+	return nil
+}
+
 func (ctx *validateCtx) validateArray(fqdn string, decl *Decl, templateRefStack []string) error {
 	for i, childDecl := range decl.Array {
+		if !decl.multiArg {
+			childDecl.srcPath = subPath(decl.srcPath, "array", strconv.Itoa(i))
+		}
 		childDecl, err := ctx.validateDecl(
 			strs.BuildFQDN(fqdn, fmt.Sprintf("elem[%d]", i+1)), childDecl, templateRefStack)
 		if err != nil {
@@ -138,37 +238,286 @@ func (ctx *validateCtx) validateArray(fqdn string, decl *Decl, templateRefStack
 	return nil
 }
 
-func (ctx *validateCtx) validateCustomFunc(fqdn string, decl *Decl, templateRefStack []string) error {
-	fn, found := ctx.customFuncs[decl.CustomFunc.Name]
-	if !found {
-		return fmt.Errorf("unknown custom_func '%s' on '%s'", decl.CustomFunc.Name, fqdn)
+func validateRules(fqdn string, rules *ValidateDecl) error {
+	if rules == nil {
+		return nil
+	}
+	if rules.Regex != nil {
+		if _, err := caches.GetRegex(*rules.Regex); err != nil {
+			return fmt.Errorf("'%s' has invalid 'validate.regex' '%s': %s", fqdn, *rules.Regex, err.Error())
+		}
 	}
+	if rules.Min != nil && rules.Max != nil && *rules.Min > *rules.Max {
+		return fmt.Errorf("'%s' has 'validate.min' %v greater than 'validate.max' %v", fqdn, *rules.Min, *rules.Max)
+	}
+	if rules.MinLength != nil && rules.MaxLength != nil && *rules.MinLength > *rules.MaxLength {
+		return fmt.Errorf("'%s' has 'validate.min_length' %d greater than 'validate.max_length' %d",
+			fqdn, *rules.MinLength, *rules.MaxLength)
+	}
+	return nil
+}
+
+func validateCustomFuncSignature(name string, fn interface{}) error {
 	if reflect.ValueOf(fn).Kind() != reflect.Func {
-		return fmt.Errorf("custom_func '%s' is not a function", decl.CustomFunc.Name)
+		return fmt.Errorf("custom_func '%s' is not a function", name)
 	}
 	fnType := reflect.TypeOf(fn)
 	if fnType.NumIn() < 1 {
-		return fmt.Errorf("custom_func '%s' missing required ctx argument", decl.CustomFunc.Name)
+		return fmt.Errorf("custom_func '%s' missing required ctx argument", name)
 	}
 	if fnType.NumOut() != 2 {
-		return fmt.Errorf(
-			"custom_func '%s' must have 2 return values, instead got %d", decl.CustomFunc.Name, fnType.NumOut())
+		return fmt.Errorf("custom_func '%s' must have 2 return values, instead got %d", name, fnType.NumOut())
 	}
 	if !fnType.Out(1).Implements(reflect.TypeOf((*error)(nil)).Elem()) {
 		return fmt.Errorf("custom_func '%s' 2nd return value must be of error type, instead got %s",
-			decl.CustomFunc.Name, fnType.Out(1))
+			name, fnType.Out(1))
+	}
+	return nil
+}
+
+func (ctx *validateCtx) validateCustomFunc(fqdn string, decl *Decl, templateRefStack []string) error {
+	fn, found := ctx.customFuncs[decl.CustomFunc.Name]
+	if !found {
+		return fmt.Errorf("unknown custom_func '%s' on '%s'", decl.CustomFunc.Name, fqdn)
+	}
+	if err := validateCustomFuncSignature(decl.CustomFunc.Name, fn); err != nil {
+		return err
 	}
 	decl.CustomFunc.fqdn = strs.BuildFQDN(fqdn, fmt.Sprintf("custom_func(%s)", decl.CustomFunc.Name))
 	for i := 0; i < len(decl.CustomFunc.Args); i++ {
+		argDecl := decl.CustomFunc.Args[i]
+		argDecl.srcPath = subPath(decl.srcPath, "custom_func", "args", strconv.Itoa(i))
+		if argDecl.Multi {
+			// a 'multi' arg takes the values of all the nodes matching its xpath, which is exactly what
+			// an array decl with the arg as its only element does, except it keeps one value per node,
+			// empty or not, so that, say, 'count' counts the empty ones too.
+			argDecl.Multi = false
+			argDecl.KeepEmptyOrNull = true
+			argDecl = &Decl{Array: []*Decl{argDecl}, srcPath: argDecl.srcPath, multiArg: true}
+		}
 		argDecl, err := ctx.validateDecl(
-			strs.BuildFQDN(decl.CustomFunc.fqdn, fmt.Sprintf("arg[%d]", i+1)),
-			decl.CustomFunc.Args[i],
-			templateRefStack)
+			strs.BuildFQDN(decl.CustomFunc.fqdn, fmt.Sprintf("arg[%d]", i+1)), argDecl, templateRefStack)
 		if err != nil {
 			return err
 		}
 		decl.CustomFunc.Args[i] = argDecl
 		decl.children = append(decl.children, argDecl)
+		if err := validateCustomFuncConstArg(decl.CustomFunc.Name, i, argDecl); err != nil {
+			return err
+		}
+	}
+	return ctx.validateCustomFuncArgs(decl)
+}
+
+// validateCustomFuncArgs checks the arg count and the statically known arg types of a custom_func
+// against its signature.
+func (ctx *validateCtx) validateCustomFuncArgs(decl *Decl) error {
+	fnType := reflect.TypeOf(ctx.customFuncs[decl.CustomFunc.Name])
+	// skip the leading *transformctx.Ctx and the optional *idr.Node, which aren't from the schema.
+	firstArg := 1
+	if fnType.NumIn() >= 2 && fnType.In(1) == reflect.TypeOf((*idr.Node)(nil)) {
+		firstArg = 2
+	}
+	expected, actual := fnType.NumIn()-firstArg, len(decl.CustomFunc.Args)
+	switch {
+	case fnType.IsVariadic() && actual < expected-1:
+		return fmt.Errorf("custom_func '%s' on %s expects at least %d argument(s), instead got %d",
+			decl.CustomFunc.Name, ctx.declRef(decl), expected-1, actual)
+	case !fnType.IsVariadic() && actual != expected:
+		return fmt.Errorf("custom_func '%s' on %s expects %d argument(s), instead got %d",
+			decl.CustomFunc.Name, ctx.declRef(decl), expected, actual)
+	}
+	for i, argDecl := range decl.CustomFunc.Args {
+		paramType := valueTypeOfGoType(getFuncArgType(fnType, firstArg+i))
+		argType := ctx.staticValueType(argDecl)
+		if isSpreadArg(fnType, firstArg+i, argDecl) {
+			argType = ctx.staticValueType(argDecl.Array[0])
+		}
+		if !valueTypeAssignable(argType, paramType) {
+			return fmt.Errorf("%s is of type '%s', but custom_func '%s' expects argument %d of type '%s'",
+				ctx.declRef(argDecl), argType, decl.CustomFunc.Name, i+1, paramType)
+		}
+	}
+	return nil
+}
+
+// staticValueType returns the type of a decl's value known at schema loading time: one of the expr
+// types, or 'object', or 'array'.
+func (ctx *validateCtx) staticValueType(decl *Decl) string {
+	switch {
+	case decl.ResultType != nil:
+		return string(*decl.ResultType)
+	case decl.kind == kindObject, decl.kind == kindObjectFrom:
+		return valueTypeObject
+	case decl.kind == kindArray:
+		return valueTypeArray
+	case decl.kind == kindCustomFunc:
+		return valueTypeOfGoType(reflect.TypeOf(ctx.customFuncs[decl.CustomFunc.Name]).Out(0))
+	}
+	return string(ctx.staticExprType(decl))
+}
+
+const (
+	valueTypeObject = "object"
+	valueTypeArray  = "array"
+)
+
+func valueTypeOfGoType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Map:
+		return valueTypeObject
+	case reflect.Slice, reflect.Array:
+		return valueTypeArray
+	}
+	return string(exprTypeOfGoType(t))
+}
+
+func valueTypeAssignable(argType, paramType string) bool {
+	switch {
+	case argType == paramType,
+		paramType == string(exprTypeAny),
+		// the actual type is only known during transform.
+		argType == string(exprTypeAny),
+		argType == string(exprTypeNull),
+		// numeric values are converted during transform.
+		argType == string(exprTypeInt) && paramType == string(exprTypeFloat):
+		return true
+	}
+	return false
+}
+
+// declRef returns a reference of a decl for error messages: its fqdn and, if found, its line in the schema.
+func (ctx *validateCtx) declRef(decl *Decl) string {
+	if line := jsonValueLine(ctx.schemaContent, decl.srcPath); line > 0 {
+		return fmt.Sprintf("'%s' (line %d)", decl.fqdn, line)
+	}
+	return fmt.Sprintf("'%s'", decl.fqdn)
+}
+
+func subPath(path []string, names ...string) []string {
+	return append(strs.CopySlice(path), names...)
+}
+
+// jsonValueLine returns the 1-based line number of the value at the path in JSON content, or 0 if not
+// found. The elements of the path are either object keys or array indexes.
+func jsonValueLine(content []byte, path []string) int {
+	if len(path) == 0 {
+		return 0
+	}
+	dec := json.NewDecoder(bytes.NewReader(content))
+	var seek func(path []string) int
+	seek = func(path []string) int {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0
+		}
+		if len(path) == 0 {
+			return 1 + bytes.Count(content[:dec.InputOffset()], []byte("\n"))
+		}
+		var skip json.RawMessage
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return 0
+				}
+				if key == path[0] {
+					return seek(path[1:])
+				}
+				if dec.Decode(&skip) != nil {
+					return 0
+				}
+			}
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if strconv.Itoa(i) == path[0] {
+					return seek(path[1:])
+				}
+				if dec.Decode(&skip) != nil {
+					return 0
+				}
+			}
+		}
+		return 0
+	}
+	return seek(path)
+}
+
+func validateCustomFuncConstArg(name string, argIndex int, argDecl *Decl) error {
+	validator, found := customfuncs.ConstArgValidators[name]
+	if !found || argDecl.kind != kindConst {
+		return nil
+	}
+	// validate the const arg value as it will be passed to the custom_func.
+	v, err := normalizeAndReturnValue(argDecl, *argDecl.Const)
+	if err != nil {
+		return err
+	}
+	s, isStr := v.(string)
+	if !isStr {
+		return nil
+	}
+	if err := validator(argIndex, s); err != nil {
+		return fmt.Errorf("'%s' has invalid value '%s': %s", argDecl.fqdn, s, err.Error())
+	}
+	return nil
+}
+
+func (ctx *validateCtx) validateExpr(fqdn string, decl *Decl, templateRefStack []string) error {
+	decl.Expr.fqdn = strs.BuildFQDN(fqdn, "expr")
+	varTypes := map[string]exprType{}
+	for varName, varDecl := range decl.Expr.Vars {
+		varDecl.srcPath = subPath(decl.srcPath, "expr", "vars", varName)
+		varDecl, err := ctx.validateDecl(strs.BuildFQDN(decl.Expr.fqdn, varName), varDecl, templateRefStack)
+		if err != nil {
+			return err
+		}
+		decl.Expr.Vars[varName] = varDecl
+		decl.children = append(decl.children, varDecl)
+		varTypes[varName] = ctx.staticExprType(varDecl)
+	}
+	// sort the `children` array for unit test snapshot stability.
+	if len(decl.children) > 0 {
+		sort.Slice(decl.children, func(i, j int) bool { return decl.children[i].fqdn < decl.children[j].fqdn })
+	}
+	compiled, err := compileExpr(decl.Expr.Expression, varTypes, ctx.customFuncs)
+	if err != nil {
+		return fmt.Errorf("'%s' has invalid 'expr' '%s': %s", fqdn, decl.Expr.Expression, err.Error())
+	}
+	decl.Expr.compiled = compiled
+	return nil
+}
+
+// staticExprType returns the type of a decl's value known at schema loading time, if possible.
+func (ctx *validateCtx) staticExprType(decl *Decl) exprType {
+	if decl.ResultType != nil {
+		return exprType(*decl.ResultType)
+	}
+	switch decl.kind {
+	case kindConst, kindField:
+		return exprTypeString
+	case kindMeta:
+		return metaTypes[*decl.Meta]
+	case kindExpr:
+		return decl.Expr.compiled.typ
+	case kindCustomFunc:
+		return exprTypeOfGoType(reflect.TypeOf(ctx.customFuncs[decl.CustomFunc.Name]).Out(0))
+	}
+	return exprTypeAny
+}
+
+func validateExternal(fqdn string, decl *Decl) error {
+	if decl.Path != nil {
+		for _, segment := range strings.Split(*decl.Path, ".") {
+			if segment == "" {
+				return fmt.Errorf("'%s' has invalid 'path' '%s': empty segment", fqdn, *decl.Path)
+			}
+		}
+	}
+	if decl.Default != nil {
+		// We did json schema validation earlier, so 'default' is guaranteed to be valid JSON.
+		_ = json.Unmarshal(decl.Default, &decl.defaultValue)
 	}
 	return nil
 }
@@ -187,19 +536,87 @@ func (ctx *validateCtx) validateTemplate(fqdn string, decl *Decl, templateRefSta
 		return nil, fmt.Errorf(
 			"'%s' contains non-existing template reference '%s'", fqdn, templateName)
 	}
+	for i, name := range templateRefStack {
+		if name == templateName {
+			return ctx.validateRecursiveTemplateRef(fqdn, decl, templateDecl, templateRefStack, i)
+		}
+	}
+	return ctx.expandTemplate(fqdn, decl, templateDecl, templateRefStack)
+}
 
-	// need to make a copy otherwise slice is passed by reference and append might alter
-	// the slice in place.
-	templateRefStack = append(strs.CopySlice(templateRefStack), templateName)
-	if strs.HasDup(templateRefStack) {
+// validateRecursiveTemplateRef validates a reference to a template that is already on the template reference
+// stack. Such a reference must be guarded by an xpath advancing into the input, and is not expanded at schema
+// loading time, but lazily at transform time, until the max depth.
+func (ctx *validateCtx) validateRecursiveTemplateRef(
+	fqdn string, decl, templateDecl *Decl, templateRefStack []string, stackIndex int) (*Decl, error) {
+	templateName := *decl.Template
+	xpath := templateDecl.XPath
+	if decl.isXPathSet() {
+		xpath = decl.XPath
+	}
+	if templateName == finalOutput || xpath == nil || !isAdvancingXPath(*xpath) {
 		return nil, fmt.Errorf("template circular dependency detected on '%s': %s",
 			fqdn, strings.Join(
-				strs.NoErrMapSlice(templateRefStack, func(s string) string { return "'" + s + "'" }),
+				strs.NoErrMapSlice(
+					append(strs.CopySlice(templateRefStack), templateName),
+					func(s string) string { return "'" + s + "'" }),
 				"->"))
 	}
+	site := decl.deepCopy()
+	// args are validated (on a copy) here too, for the errors to surface at schema loading time.
+	if _, err := ctx.validateTemplateArgs(
+		fqdn, decl.deepCopy(), templateName, templateDecl.Params, templateRefStack); err != nil {
+		return nil, err
+	}
+	maxDepth := defaultTemplateMaxDepth
+	if templateDecl.MaxDepth != nil {
+		maxDepth = *templateDecl.MaxDepth
+	}
+	ref := &Decl{
+		Template:        strs.CopyStrPtr(decl.Template),
+		XPath:           strs.CopyStrPtr(xpath),
+		NoTrim:          templateDecl.NoTrim,
+		KeepEmptyOrNull: templateDecl.KeepEmptyOrNull,
+		fqdn:            fqdn,
+		kind:            kindTemplate,
+		srcPath:         decl.srcPath,
+	}
+	ref.recursion = &templateRecursion{
+		ctx:  ctx,
+		ref:  ref,
+		site: site,
+		// the expansion is validated as if it is referenced for the first time, for it to be of the same
+		// structure as the instance expanded at schema loading time.
+		templateRefStack: strs.CopySlice(templateRefStack[:stackIndex]),
+		depth:            ctx.recursionDepth + 1,
+		maxDepth:         maxDepth,
+	}
+	return ref, nil
+}
+
+// expandTemplate validates a copy of a template referenced, with the args bound and the xpath and
+// validate at the reference site merged in.
+func (ctx *validateCtx) expandTemplate(
+	fqdn string, decl, templateDecl *Decl, templateRefStack []string) (*Decl, error) {
+	templateName := *decl.Template
+	// args are evaluated at the reference site, thus validated before the template is pushed onto the stack.
+	args, err := ctx.validateTemplateArgs(fqdn, decl, templateName, templateDecl.Params, templateRefStack)
+	if err != nil {
+		return nil, err
+	}
+
+	// need to make a copy otherwise slice is passed by reference and append might alter
+	// the slice in place.
+	templateRefStack = append(strs.CopySlice(templateRefStack), templateName)
 
 	// Make a copy in case the template is referenced in multiple places.
-	declNew := templateDecl.deepCopy()
+	declNew, err := substituteTemplatePlaceholders(
+		fqdn, templateName, templateDecl.deepCopy(), templateDecl.Params, decl.Args)
+	if err != nil {
+		return nil, err
+	}
+	declNew.Params = nil
+	declNew.MaxDepth = nil
 	// between the template site and the template itself, there can only be one decl with xpath/xpath_dynamic set.
 	if declNew.isXPathSet() && decl.isXPathSet() {
 		return nil, fmt.Errorf(
@@ -210,8 +627,65 @@ func (ctx *validateCtx) validateTemplate(fqdn string, decl *Decl, templateRefSta
 		declNew.XPath = decl.XPath
 		declNew.XPathDynamic = decl.XPathDynamic
 	}
+	if declNew.Validate != nil && decl.Validate != nil {
+		return nil, fmt.Errorf(
+			"cannot specify 'validate' on both '%s' and the template '%s' it references", fqdn, templateName)
+	}
+	if decl.Validate != nil {
+		declNew.Validate = decl.Validate
+	}
 
-	return ctx.validateDecl(fqdn, declNew, templateRefStack)
+	declNew.srcPath = []string{"transform_declarations", templateName}
+	instance, err := ctx.validateDecl(fqdn, declNew, templateRefStack)
+	if err != nil {
+		return nil, err
+	}
+	bindTemplateScope(instance, &templateScope{params: templateDecl.Params, args: args})
+	return instance, nil
+}
+
+// validateTemplateArgs validates the args at a template reference site, which must bind all the
+// template params, and returns them in the same order as the params.
+func (ctx *validateCtx) validateTemplateArgs(
+	fqdn string, decl *Decl, templateName string, params []string, templateRefStack []string) ([]*Decl, error) {
+	paramSet := map[string]bool{}
+	for _, param := range params {
+		if paramSet[param] {
+			return nil, fmt.Errorf("template '%s' has duplicate parameter '%s'", templateName, param)
+		}
+		paramSet[param] = true
+	}
+	var argNames []string
+	for argName := range decl.Args {
+		argNames = append(argNames, argName)
+	}
+	// sort the arg names for error message stability.
+	sort.Strings(argNames)
+	for _, argName := range argNames {
+		if !paramSet[argName] {
+			return nil, fmt.Errorf(
+				"'%s' binds non-existing parameter '%s' of template '%s'", fqdn, argName, templateName)
+		}
+	}
+	var args []*Decl
+	for _, param := range params {
+		argDecl, found := decl.Args[param]
+		if !found {
+			return nil, fmt.Errorf(
+				"'%s' does not bind parameter '%s' of template '%s'", fqdn, param, templateName)
+		}
+		argDecl.srcPath = subPath(decl.srcPath, "args", param)
+		argDecl, err := ctx.validateDecl(
+			strs.BuildFQDN(fqdn, fmt.Sprintf("template(%s).arg(%s)", templateName, param)),
+			argDecl, templateRefStack)
+		if err != nil {
+			return nil, err
+		}
+		linkParent(argDecl)
+		decl.Args[param] = argDecl
+		args = append(args, argDecl)
+	}
+	return args, nil
 }
 
 func computeDeclHash(decl *Decl, declHashes map[string]string) string {
@@ -259,8 +733,11 @@ func computeDeclHash(decl *Decl, declHashes map[string]string) string {
 }
 
 func linkParent(decl *Decl) {
+	decl.sensitive = decl.sensitive || decl.Sensitive
 	for _, child := range decl.children {
 		child.parent = decl
+		// everything a sensitive decl is made of is sensitive, too.
+		child.sensitive = decl.sensitive
 		linkParent(child)
 	}
 }
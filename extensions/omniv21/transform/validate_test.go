@@ -41,7 +41,8 @@ func TestValidateTransformDeclarations(t *testing.T) {
                                 "name": "test_func",
                                 "args": [
                                     { "xpath": "Q/R/S" },
-                                    { "template": "template12" }
+                                    { "template": "template12" },
+                                    { "xpath": "Y/Z", "multi": true }
                                 ]
                             }
                         },
@@ -49,10 +50,17 @@ func TestValidateTransformDeclarations(t *testing.T) {
                         "field_10": { "xpath_dynamic": { "const": "X/Y/Z" }, "template": "template10" },
                         "field_11": { "template": "template11" },
                         "field_12": { "template": "template12" },
+                        "field_14": { "meta": "record_index", "type": "string" },
+                        "field_15": { "external": "ext", "path": "a.0", "default": { "b": [1, true] } },
+                        "field_17": { "xpath": "R", "template": "template17" },
+                        "field_18": { "xpath": "M", "object_from": {
+                            "xpath": "E", "key": { "xpath": "@k" }, "value": { "template": "template12" }, "on_duplicate": "array"
+                        }},
+                        "field_16": { "template": "template16", "args": { "p1": { "const": "v1" }, "p2": { "xpath": "P2" } } },
 						"$field_13 with space. and other non-alphanumeric chars": { "custom_parse": "test_custom_parse" }
                     }},
-                    "template9": { "xpath": "1/2/3", "object": {
-                        "field9": { "xpath": "4/5/6" }
+                    "template9": { "xpath": "X1/X2/X3", "object": {
+                        "field9": { "xpath": "X4/X5/X6" }
                     }},
                     "template10": { "object": {
                         "field10": { "const": "value10" }
@@ -63,6 +71,12 @@ func TestValidateTransformDeclarations(t *testing.T) {
                     "template12": { "custom_func": {
                         "name": "test_func",
                         "args": [ { "xpath": "W/X" } ]
+                    }},
+                    "template17": { "max_depth": 5, "object": {
+                        "children": { "array": [ { "xpath": "child", "template": "template17" } ] }
+                    }},
+                    "template16": { "params": [ "p1", "p2" ], "xpath": "X[@p2=$p2]", "object": {
+                        "{{p1}}": { "const": "{{p1}}-{{p1}}" }
                     }}
                 }
             }`,
@@ -79,6 +93,50 @@ func TestValidateTransformDeclarations(t *testing.T) {
             }`,
 			err: "'FINAL_OUTPUT.field1' cannot set both 'xpath' and 'xpath_dynamic' at the same time",
 		},
+		{
+			name: "failure - xpath calls unknown custom xpath function",
+			declJSON: `{
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field1": { "xpath": "A/B[omni:unknown(C)]" }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field1' has invalid 'xpath': xpath 'A/B[omni:unknown(C)]' is invalid: unknown xpath function 'omni:unknown'",
+		},
+		{
+			name: "failure - object_from xpath calls unknown custom xpath function",
+			declJSON: `{
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object_from": {
+                        "xpath": "E[omni:unknown(.)]", "key": { "xpath": "@k" }, "value": { "xpath": "@v" }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.object_from' has invalid 'xpath': xpath 'E[omni:unknown(.)]' is invalid: unknown xpath function 'omni:unknown'",
+		},
+		{
+			name: "failure - object_from invalid on_duplicate",
+			declJSON: `{
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object_from": {
+                        "xpath": "E", "key": { "xpath": "@k" }, "value": { "xpath": "@v" }, "on_duplicate": "merge"
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.object_from' has invalid 'on_duplicate' 'merge'",
+		},
+		{
+			name: "failure - object_from value fails",
+			declJSON: `{
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object_from": {
+                        "xpath": "E", "key": { "xpath": "@k" }, "value": { "template": "non-existing" }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.object_from.value' contains non-existing template reference 'non-existing'",
+		},
 		{
 			name: "failure - xpath_dynamic validate fails: custom_func non-existing",
 			declJSON: `{
@@ -197,6 +255,127 @@ func TestValidateTransformDeclarations(t *testing.T) {
             }`,
 			err: "template circular dependency detected on 'FINAL_OUTPUT.field_1.field_2.field_3.field_circular': 'FINAL_OUTPUT'->'template1'->'template2'->'template3'->'template1'",
 		},
+		{
+			name: "failure - template param not bound",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "template": "template1", "args": { "p1": { "const": "v1" } } }
+                    }},
+                    "template1": { "params": [ "p1", "p2" ], "xpath": "A[@p1=$p1][@p2=$p2]" }
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1' does not bind parameter 'p2' of template 'template1'",
+		},
+		{
+			name: "failure - template param non-existing",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "template": "template1", "args": { "p1": { "const": "v1" }, "p3": { "const": "v3" } } }
+                    }},
+                    "template1": { "params": [ "p1" ], "xpath": "A[@p1=$p1]" }
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1' binds non-existing parameter 'p3' of template 'template1'",
+		},
+		{
+			name: "failure - template param duplicate",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "template": "template1", "args": { "p1": { "const": "v1" } } }
+                    }},
+                    "template1": { "params": [ "p1", "p1" ], "xpath": "A[@p1=$p1]" }
+                }
+            }`,
+			err: "template 'template1' has duplicate parameter 'p1'",
+		},
+		{
+			name: "failure - template arg validation fails",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "template": "template1", "args": { "p1": { "template": "huh" } } }
+                    }},
+                    "template1": { "params": [ "p1" ], "xpath": "A[@p1=$p1]" }
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1.template(template1).arg(p1)' contains non-existing template reference 'huh'",
+		},
+		{
+			name: "failure - template placeholder bound to non-const",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "template": "template1", "args": { "p1": { "xpath": "B" } } }
+                    }},
+                    "template1": { "params": [ "p1" ], "xpath": "A/{{p1}}" }
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1' must bind a const to parameter 'p1' of template 'template1', as it is used as placeholder '{{p1}}'",
+		},
+		{
+			name: "failure - params on non-template",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "params": [ "p1" ], "object": {
+                        "field_1": { "xpath": "A" }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT' can only set 'params' as a template",
+		},
+		{
+			name: "failure - args on non-template reference",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "xpath": "A", "args": { "p1": { "const": "v1" } } }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1' can only set 'args' as a template reference",
+		},
+		{
+			name: "failure - recursive template ref not advancing",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "template": "template1" }
+                    }},
+                    "template1": { "object": {
+                        "parent": { "xpath": "../child", "template": "template1" }
+                    }}
+                }
+            }`,
+			err: "template circular dependency detected on 'FINAL_OUTPUT.field_1.parent': 'FINAL_OUTPUT'->'template1'->'template1'",
+		},
+		{
+			name: "failure - recursive template ref arg invalid",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "template": "template1", "args": { "p": { "const": "v" } } }
+                    }},
+                    "template1": { "params": [ "p" ], "object": {
+                        "child": { "xpath": "child", "template": "template1" }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1.child' does not bind parameter 'p' of template 'template1'",
+		},
+		{
+			name: "failure - max_depth on non-template",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "max_depth": 3, "object": {
+                        "field_1": { "xpath": "A" }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT' can only set 'max_depth' as a template",
+		},
 		{
 			name: "failure - xpath conflict for template reference",
 			declJSON: ` {
@@ -211,6 +390,227 @@ func TestValidateTransformDeclarations(t *testing.T) {
             }`,
 			err: "cannot specify 'xpath' or 'xpath_dynamic' on both 'FINAL_OUTPUT.field_1' and the template 'template1' it references",
 		},
+		{
+			name: "failure - invalid const regex arg",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "custom_func": { "name": "regexMatch", "args": [ { "xpath": "a" }, { "const": "[a-z" } ] } }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1.custom_func(regexMatch).arg[2]' has invalid value '[a-z': error parsing regexp: missing closing ]: `[a-z`",
+		},
+		{
+			name: "failure - invalid expr",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "expr": { "expression": "x * 2", "vars": { "x": { "xpath": "abc" } } } }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1' has invalid 'expr' 'x * 2': operator '*' cannot be applied to string and int",
+		},
+		{
+			name: "failure - invalid expr var",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "expr": { "expression": "x", "vars": { "x": { "template": "non-existing" } } } }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1.expr.x' contains non-existing template reference 'non-existing'",
+		},
+		{
+			name: "failure - invalid validate regex",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "xpath": "abc", "validate": { "regex": "[a-z" } }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1' has invalid 'validate.regex' '[a-z': error parsing regexp: missing closing ]: `[a-z`",
+		},
+		{
+			name: "failure - validate min greater than max",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "xpath": "abc", "validate": { "min": 10, "max": 1.5 } }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1' has 'validate.min' 10 greater than 'validate.max' 1.5",
+		},
+		{
+			name: "failure - validate min_length greater than max_length",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "xpath": "abc", "validate": { "min_length": 3, "max_length": 2 } }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1' has 'validate.min_length' 3 greater than 'validate.max_length' 2",
+		},
+		{
+			name: "failure - validate on both template site and template",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "template": "t", "validate": { "required": true } }
+                    }},
+                    "t": { "xpath": "abc", "validate": { "max_length": 2 } }
+                }
+            }`,
+			err: "cannot specify 'validate' on both 'FINAL_OUTPUT.field_1' and the template 't' it references",
+		},
+		{
+			name: "failure - custom_func too few args",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "custom_func": { "name": "upper", "args": [] } }
+                    }}
+                }
+            }`,
+			err: "custom_func 'upper' on 'FINAL_OUTPUT.field_1' (line 4) expects 1 argument(s), instead got 0",
+		},
+		{
+			name: "failure - custom_func too many args",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "template": "t" }
+                    }},
+                    "t": {
+                        "custom_func": { "name": "test_node_func", "args": [ { "const": "1" }, { "const": "2" } ] }
+                    }
+                }
+            }`,
+			err: "custom_func 'test_node_func' on 'FINAL_OUTPUT.field_1' (line 6) expects 1 argument(s), instead got 2",
+		},
+		{
+			name: "failure - variadic custom_func too few args",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "custom_func": { "name": "join" } }
+                    }}
+                }
+            }`,
+			err: "custom_func 'join' on 'FINAL_OUTPUT.field_1' (line 4) expects at least 1 argument(s), instead got 0",
+		},
+		{
+			name: "failure - custom_func arg type mismatch",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "custom_func": { "name": "upper", "args": [
+                            { "const": "1" }
+                        ]}},
+                        "field_2": { "custom_func": { "name": "join", "args": [
+                            { "const": "," },
+                            { "xpath": "a" },
+                            { "xpath": "b", "type": "float" }
+                        ]}}
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_2.custom_func(join).arg[3]' (line 10) is of type 'float', but custom_func 'join' expects argument 3 of type 'string'",
+		},
+		{
+			name: "failure - nested custom_func return type mismatch",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "custom_func": { "name": "test_node_func", "args": [
+                        { "custom_func": { "name": "upper", "args": [ { "xpath": "a" } ] } }
+                    ]}}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.custom_func(test_node_func).arg[1]' (line 4) is of type 'string', but custom_func 'test_node_func' expects argument 1 of type 'int'",
+		},
+		{
+			name: "failure - object arg to string param",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "custom_func": { "name": "upper", "args": [
+                        { "object": { "a": { "xpath": "a" } } }
+                    ]}}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.custom_func(upper).arg[1]' (line 4) is of type 'object', but custom_func 'upper' expects argument 1 of type 'string'",
+		},
+		{
+			name: "failure - object_from arg to string param",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "custom_func": { "name": "upper", "args": [
+                        { "object_from": { "xpath": "E", "key": { "xpath": "@k" }, "value": { "xpath": "@v" } } }
+                    ]}}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.custom_func(upper).arg[1]' (line 4) is of type 'object', but custom_func 'upper' expects argument 1 of type 'string'",
+		},
+		{
+			name: "failure - multi not on custom_func arg",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "xpath": "a", "multi": true }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1' can only set 'multi' as a custom_func argument",
+		},
+		{
+			name: "failure - multi arg to non-array param",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "custom_func": { "name": "upper", "args": [
+                        { "xpath": "a", "multi": true }
+                    ]}}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.custom_func(upper).arg[1]' (line 4) is of type 'array', but custom_func 'upper' expects argument 1 of type 'string'",
+		},
+		{
+			name: "failure - multi arg element type mismatch",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "custom_func": { "name": "join", "args": [
+                        { "const": "," },
+                        { "xpath": "a", "type": "int", "multi": true }
+                    ]}}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.custom_func(join).arg[2]' (line 5) is of type 'int', but custom_func 'join' expects argument 2 of type 'string'",
+		},
+		{
+			name: "failure - unknown meta",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "meta": "non-existing" }
+                    }}
+                }
+            }`,
+			err: "unknown meta 'non-existing' on 'FINAL_OUTPUT.field_1'",
+		},
+		{
+			name: "failure - external with invalid path",
+			declJSON: ` {
+                "transform_declarations": {
+                    "FINAL_OUTPUT": { "object": {
+                        "field_1": { "external": "ext", "path": "a..b" }
+                    }}
+                }
+            }`,
+			err: "'FINAL_OUTPUT.field_1' has invalid 'path' 'a..b': empty segment",
+		},
 		{
 			name: "failure - unknown custom_parse",
 			declJSON: ` {
@@ -227,7 +627,11 @@ func TestValidateTransformDeclarations(t *testing.T) {
 			finalOutputDecl, err := ValidateTransformDeclarations(
 				[]byte(test.declJSON),
 				customfuncs.CustomFuncs{
-					"test_func":                   func(*transformctx.Ctx) (interface{}, error) { return nil, nil },
+					"test_func":                   func(*transformctx.Ctx, ...interface{}) (interface{}, error) { return nil, nil },
+					"regexMatch":                  customfuncs.RegexMatch,
+					"upper":                       customfuncs.Upper,
+					"join":                        customfuncs.Join,
+					"test_node_func":              func(*transformctx.Ctx, *idr.Node, int) (int, error) { return 0, nil },
 					"invalid_func_not_a_func":     "not a func",
 					"invalid_func_missing_ctx":    func() {},
 					"invalid_func_missing_return": func(*transformctx.Ctx) {},
@@ -251,6 +655,51 @@ func TestValidateTransformDeclarations(t *testing.T) {
 	}
 }
 
+func TestValidateDedupKeyDeclaration(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		declJSON string
+		fqdn     string
+		err      string
+	}{
+		{
+			name:     "no dedup key",
+			declJSON: `"FINAL_OUTPUT": { "xpath": "." }`,
+		},
+		{
+			name: "dedup key with template",
+			declJSON: `"FINAL_OUTPUT": { "xpath": "." },
+                "DEDUP_KEY": { "template": "t", "args": { "n": { "xpath": "id" } } },
+                "t": { "params": [ "n" ], "custom_func": { "name": "upper", "args": [ { "xpath": "$n" } ] } }`,
+			fqdn: "DEDUP_KEY",
+		},
+		{
+			name: "failure - invalid dedup key",
+			declJSON: `"FINAL_OUTPUT": { "xpath": "." },
+                "DEDUP_KEY": { "object": { "id": { "xpath": "id", "multi": true } } }`,
+			err: "'DEDUP_KEY.id' can only set 'multi' as a custom_func argument",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			decl, err := ValidateDedupKeyDeclaration(
+				[]byte(`{"transform_declarations": {`+test.declJSON+`}}`),
+				customfuncs.CustomFuncs{"upper": customfuncs.Upper}, nil)
+			switch {
+			case test.err != "":
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Nil(t, decl)
+			case test.fqdn == "":
+				assert.NoError(t, err)
+				assert.Nil(t, decl)
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, test.fqdn, decl.fqdn)
+			}
+		})
+	}
+}
+
 func TestComputeDeclHash(t *testing.T) {
 	decl1 := &Decl{
 		Object: map[string]*Decl{
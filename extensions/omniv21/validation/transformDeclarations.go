@@ -18,13 +18,31 @@ const (
                     "oneOf": [
                         { "$ref": "#/definitions/const" },
                         { "$ref": "#/definitions/external" },
+                        { "$ref": "#/definitions/meta" },
                         { "$ref": "#/definitions/field" },
                         { "$ref": "#/definitions/object" },
+                        { "$ref": "#/definitions/object_from" },
                         { "$ref": "#/definitions/custom_func" },
                         { "$ref": "#/definitions/custom_parse", "$comment": "Deprecated. Use custom_func." },
                         { "$ref": "#/definitions/array" },
-                        { "$ref": "#/definitions/template" }
+                        { "$ref": "#/definitions/template" },
+                        { "$ref": "#/definitions/expr" }
                     ]
+                },
+                "DEDUP_KEY": {
+                    "oneOf": [
+                        { "$ref": "#/definitions/const" },
+                        { "$ref": "#/definitions/external" },
+                        { "$ref": "#/definitions/meta" },
+                        { "$ref": "#/definitions/field" },
+                        { "$ref": "#/definitions/object" },
+                        { "$ref": "#/definitions/object_from" },
+                        { "$ref": "#/definitions/custom_func" },
+                        { "$ref": "#/definitions/array" },
+                        { "$ref": "#/definitions/template" },
+                        { "$ref": "#/definitions/expr" }
+                    ],
+                    "$comment": "the key of each record, by which duplicate records are detected when dedup is enabled"
                 }
             },
             "patternProperties": {
@@ -32,17 +50,44 @@ const (
                     "oneOf": [
                         { "$ref": "#/definitions/const" },
                         { "$ref": "#/definitions/external" },
+                        { "$ref": "#/definitions/meta" },
                         { "$ref": "#/definitions/field" },
                         { "$ref": "#/definitions/object" },
+                        { "$ref": "#/definitions/object_from" },
                         { "$ref": "#/definitions/custom_func" },
                         { "$ref": "#/definitions/custom_parse", "$comment": "Deprecated. Use custom_func." },
                         { "$ref": "#/definitions/array" },
-                        { "$ref": "#/definitions/template" }
+                        { "$ref": "#/definitions/template" },
+                        { "$ref": "#/definitions/expr" }
                     ]
                 }
             },
             "required": [ "FINAL_OUTPUT" ],
             "additionalProperties": false
+        },
+        "js_library": {
+            "oneOf": [
+                { "type": "string" },
+                { "type": "array", "items": { "type": "string" } }
+            ],
+            "$comment": "javascript code, or lines of javascript code, preloaded for all javascript custom_funcs"
+        },
+        "output_settings": {
+            "type": "object",
+            "properties": {
+                "preserve_order": { "type": "boolean" },
+                "escape_html": { "type": "boolean" },
+                "indent": { "type": "string", "pattern": "^[ \\t]*$" },
+                "float_format": { "type": "string", "enum": [ "default", "decimal" ] },
+                "float_decimals": { "type": "integer", "minimum": 0 }
+            },
+            "additionalProperties": false,
+            "$comment": "controls how the transformed records are serialized into JSON"
+        },
+        "sensitive_xpaths": {
+            "type": "array",
+            "items": { "type": "string", "minLength": 1 },
+            "$comment": "xpaths, relative to each record, of the input values redacted from error messages and raw record dumps"
         }
     },
     "required": [ "transform_declarations" ],
@@ -51,6 +96,11 @@ const (
         "value_no_trim": { "type": "boolean" },
         "value_ignore_error": { "type": "boolean" },
         "value_keep_empty_or_null": { "type": "boolean" },
+        "value_multi": { "type": "boolean", "$comment": "only allowed on custom_func args" },
+        "value_sensitive": {
+            "type": "boolean",
+            "$comment": "the values of the decl and everything it's made of are redacted from error messages and raw record dumps"
+        },
         "value_name": {
             "type": "string",
             "minLength": 1,
@@ -60,11 +110,57 @@ const (
             "type": "string",
             "$comment": "const can be empty string"
         },
+        "value_meta": {
+            "type": "string",
+            "enum": [
+                "checksum",
+                "input_name",
+                "line_start",
+                "record_index",
+                "transform_time"
+            ]
+        },
         "value_external": {
             "type": "string",
             "minLength": 1,
             "$comment": "external can not be empty string"
         },
+        "value_external_path": {
+            "type": "string",
+            "minLength": 1,
+            "$comment": "dot-separated path into a structured external property value"
+        },
+        "value_params": {
+            "type": "array",
+            "items": { "type": "string", "pattern": "^[_a-zA-Z][_a-zA-Z0-9]*$" },
+            "$comment": "params of a template"
+        },
+        "value_max_depth": {
+            "type": "integer",
+            "minimum": 1,
+            "$comment": "max recursion depth of a template"
+        },
+        "value_args": {
+            "type": "object",
+            "patternProperties": {
+                "^[_a-zA-Z][_a-zA-Z0-9]*$": {
+                    "oneOf": [
+                        { "$ref": "#/definitions/const" },
+                        { "$ref": "#/definitions/external" },
+                        { "$ref": "#/definitions/meta" },
+                        { "$ref": "#/definitions/field" },
+                        { "$ref": "#/definitions/object" },
+                        { "$ref": "#/definitions/object_from" },
+                        { "$ref": "#/definitions/custom_func" },
+                        { "$ref": "#/definitions/array" },
+                        { "$ref": "#/definitions/template" },
+                        { "$ref": "#/definitions/expr" }
+                    ]
+                }
+            },
+            "additionalProperties": false,
+            "$comment": "args bound to the params of the template referenced"
+        },
         "value_xpath": {
             "type": "string",
             "minLength": 1,
@@ -76,10 +172,12 @@ const (
                 "oneOf": [
                     { "$ref": "#/definitions/const" },
                     { "$ref": "#/definitions/external" },
+                    { "$ref": "#/definitions/meta" },
                     { "$ref": "#/definitions/field" },
                     { "$ref": "#/definitions/custom_func" },
                     { "$ref": "#/definitions/custom_parse", "$comment": "Deprecated. Use custom_func." },
-                    { "$ref": "#/definitions/template" }
+                    { "$ref": "#/definitions/template" },
+                    { "$ref": "#/definitions/expr" }
                 ]
             }
         },
@@ -95,12 +193,15 @@ const (
                     "oneOf": [
                         { "$ref": "#/definitions/const" },
                         { "$ref": "#/definitions/external" },
+                        { "$ref": "#/definitions/meta" },
                         { "$ref": "#/definitions/field" },
                         { "$ref": "#/definitions/object" },
+                        { "$ref": "#/definitions/object_from" },
                         { "$ref": "#/definitions/custom_func" },
                         { "$ref": "#/definitions/custom_parse", "$comment": "Deprecated. Use custom_func." },
                         { "$ref": "#/definitions/array" },
-                        { "$ref": "#/definitions/template" }
+                        { "$ref": "#/definitions/template" },
+                        { "$ref": "#/definitions/expr" }
                     ],
                     "$comment": "object's field can be any kind of transform"
                 }
@@ -117,11 +218,13 @@ const (
                         "oneOf": [
                             { "$ref": "#/definitions/const" },
                             { "$ref": "#/definitions/external" },
+                            { "$ref": "#/definitions/meta" },
                             { "$ref": "#/definitions/field" },
                             { "$ref": "#/definitions/custom_func" },
                             { "$ref": "#/definitions/custom_parse", "$comment": "Deprecated. Use custom_func." },
                             { "$ref": "#/definitions/array" },
-                            { "$ref": "#/definitions/template" }
+                            { "$ref": "#/definitions/template" },
+                            { "$ref": "#/definitions/expr" }
                         ]
                     },
                     "$comment": "args length can be 0"
@@ -131,11 +234,101 @@ const (
             "required": [ "name" ],
             "additionalProperties": false
         },
+        "value_object_from": {
+            "type": "object",
+            "properties": {
+                "xpath": { "$ref": "#/definitions/value_xpath" },
+                "key": {
+                    "oneOf": [
+                        { "$ref": "#/definitions/const" },
+                        { "$ref": "#/definitions/external" },
+                        { "$ref": "#/definitions/meta" },
+                        { "$ref": "#/definitions/field" },
+                        { "$ref": "#/definitions/object" },
+                        { "$ref": "#/definitions/object_from" },
+                        { "$ref": "#/definitions/custom_func" },
+                        { "$ref": "#/definitions/array" },
+                        { "$ref": "#/definitions/template" },
+                        { "$ref": "#/definitions/expr" }
+                    ]
+                },
+                "value": {
+                    "oneOf": [
+                        { "$ref": "#/definitions/const" },
+                        { "$ref": "#/definitions/external" },
+                        { "$ref": "#/definitions/meta" },
+                        { "$ref": "#/definitions/field" },
+                        { "$ref": "#/definitions/object" },
+                        { "$ref": "#/definitions/object_from" },
+                        { "$ref": "#/definitions/custom_func" },
+                        { "$ref": "#/definitions/array" },
+                        { "$ref": "#/definitions/template" },
+                        { "$ref": "#/definitions/expr" }
+                    ]
+                },
+                "on_duplicate": {
+                    "type": "string",
+                    "enum": [ "first", "last", "array", "error" ],
+                    "$comment": "what to do with entries of duplicate keys, default 'last'"
+                }
+            },
+            "required": [ "xpath", "key", "value" ],
+            "additionalProperties": false
+        },
+        "value_expr": {
+            "type": "object",
+            "properties": {
+                "expression": {
+                    "type": "string",
+                    "minLength": 1,
+                    "$comment": "expression can not be empty string"
+                },
+                "vars": {
+                    "type": "object",
+                    "patternProperties": {
+                        "^[_a-zA-Z][_a-zA-Z0-9]*$": {
+                            "oneOf": [
+                                { "$ref": "#/definitions/const" },
+                                { "$ref": "#/definitions/external" },
+                                { "$ref": "#/definitions/meta" },
+                                { "$ref": "#/definitions/field" },
+                                { "$ref": "#/definitions/object" },
+                                { "$ref": "#/definitions/object_from" },
+                                { "$ref": "#/definitions/custom_func" },
+                                { "$ref": "#/definitions/custom_parse", "$comment": "Deprecated. Use custom_func." },
+                                { "$ref": "#/definitions/array" },
+                                { "$ref": "#/definitions/template" },
+                                { "$ref": "#/definitions/expr" }
+                            ]
+                        }
+                    },
+                    "additionalProperties": false,
+                    "$comment": "var names must be valid expression identifiers"
+                }
+            },
+            "required": [ "expression" ],
+            "additionalProperties": false
+        },
         "value_custom_parse": {
             "type": "string",
             "minLength": 1,
             "$comment": "custom_parse can not be empty string. Deprecated."
         },
+        "value_validate": {
+            "type": "object",
+            "properties": {
+                "required": { "type": "boolean" },
+                "regex": { "type": "string", "minLength": 1 },
+                "enum": { "type": "array", "items": { "type": "string" }, "minItems": 1 },
+                "min": { "type": "number" },
+                "max": { "type": "number" },
+                "min_length": { "type": "integer", "minimum": 0 },
+                "max_length": { "type": "integer", "minimum": 0 },
+                "luhn": { "type": "boolean" },
+                "_comment": { "$ref": "#/definitions/value_comment" }
+            },
+            "additionalProperties": false
+        },
         "value_type": {
             "type": "string",
             "enum": [
@@ -152,6 +345,10 @@ const (
                 "type": { "$ref": "#/definitions/value_type" },
                 "no_trim": { "$ref": "#/definitions/value_no_trim" },
                 "keep_empty_or_null": { "$ref": "#/definitions/value_keep_empty_or_null" },
+                "validate": { "$ref": "#/definitions/value_validate" },
+                "sensitive": { "$ref": "#/definitions/value_sensitive" },
+                "params": { "$ref": "#/definitions/value_params" },
+                "max_depth": { "$ref": "#/definitions/value_max_depth" },
                 "_comment": { "$ref": "#/definitions/value_comment" }
             },
             "required": [ "const" ],
@@ -161,14 +358,35 @@ const (
             "type": "object",
             "properties": {
                 "external": { "$ref": "#/definitions/value_external" },
+                "path": { "$ref": "#/definitions/value_external_path" },
+                "default": { "$comment": "any JSON value used when the external property or path is missing" },
                 "type": { "$ref": "#/definitions/value_type" },
                 "no_trim": { "$ref": "#/definitions/value_no_trim" },
                 "keep_empty_or_null": { "$ref": "#/definitions/value_keep_empty_or_null" },
+                "validate": { "$ref": "#/definitions/value_validate" },
+                "sensitive": { "$ref": "#/definitions/value_sensitive" },
+                "params": { "$ref": "#/definitions/value_params" },
+                "max_depth": { "$ref": "#/definitions/value_max_depth" },
                 "_comment": { "$ref": "#/definitions/value_comment" }
             },
             "required": [ "external" ],
             "additionalProperties": false
         },
+        "meta": {
+            "type": "object",
+            "properties": {
+                "meta": { "$ref": "#/definitions/value_meta" },
+                "type": { "$ref": "#/definitions/value_type" },
+                "keep_empty_or_null": { "$ref": "#/definitions/value_keep_empty_or_null" },
+                "validate": { "$ref": "#/definitions/value_validate" },
+                "sensitive": { "$ref": "#/definitions/value_sensitive" },
+                "params": { "$ref": "#/definitions/value_params" },
+                "max_depth": { "$ref": "#/definitions/value_max_depth" },
+                "_comment": { "$ref": "#/definitions/value_comment" }
+            },
+            "required": [ "meta" ],
+            "additionalProperties": false
+        },
         "field": {
             "type": "object",
             "properties": {
@@ -177,6 +395,11 @@ const (
                 "type": { "$ref": "#/definitions/value_type" },
                 "no_trim": { "$ref": "#/definitions/value_no_trim" },
                 "keep_empty_or_null": { "$ref": "#/definitions/value_keep_empty_or_null" },
+                "validate": { "$ref": "#/definitions/value_validate" },
+                "sensitive": { "$ref": "#/definitions/value_sensitive" },
+                "multi": { "$ref": "#/definitions/value_multi" },
+                "params": { "$ref": "#/definitions/value_params" },
+                "max_depth": { "$ref": "#/definitions/value_max_depth" },
                 "_comment": { "$ref": "#/definitions/value_comment" }
             },
             "additionalProperties": false
@@ -188,11 +411,32 @@ const (
                 "xpath_dynamic": { "$ref": "#/definitions/value_xpath_dynamic" },
                 "object": { "$ref": "#/definitions/value_object" },
                 "keep_empty_or_null": { "$ref": "#/definitions/value_keep_empty_or_null" },
+                "validate": { "$ref": "#/definitions/value_validate" },
+                "sensitive": { "$ref": "#/definitions/value_sensitive" },
+                "multi": { "$ref": "#/definitions/value_multi" },
+                "params": { "$ref": "#/definitions/value_params" },
+                "max_depth": { "$ref": "#/definitions/value_max_depth" },
                 "_comment": { "$ref": "#/definitions/value_comment" }
             },
             "required": [ "object" ],
             "additionalProperties": false
         },
+        "object_from": {
+            "type": "object",
+            "properties": {
+                "xpath": { "$ref": "#/definitions/value_xpath" },
+                "xpath_dynamic": { "$ref": "#/definitions/value_xpath_dynamic" },
+                "object_from": { "$ref": "#/definitions/value_object_from" },
+                "keep_empty_or_null": { "$ref": "#/definitions/value_keep_empty_or_null" },
+                "validate": { "$ref": "#/definitions/value_validate" },
+                "sensitive": { "$ref": "#/definitions/value_sensitive" },
+                "params": { "$ref": "#/definitions/value_params" },
+                "max_depth": { "$ref": "#/definitions/value_max_depth" },
+                "_comment": { "$ref": "#/definitions/value_comment" }
+            },
+            "required": [ "object_from" ],
+            "additionalProperties": false
+        },
         "array": {
             "type": "object",
             "properties": {
@@ -202,16 +446,23 @@ const (
                         "oneOf": [
                             { "$ref": "#/definitions/const" },
                             { "$ref": "#/definitions/external" },
+                            { "$ref": "#/definitions/meta" },
                             { "$ref": "#/definitions/field" },
                             { "$ref": "#/definitions/object" },
+                            { "$ref": "#/definitions/object_from" },
                             { "$ref": "#/definitions/custom_func" },
                             { "$ref": "#/definitions/custom_parse", "$comment": "Deprecated. Use custom_func." },
-                            { "$ref": "#/definitions/template" }
+                            { "$ref": "#/definitions/template" },
+                            { "$ref": "#/definitions/expr" }
                         ],
                         "$comment": "array's element can be any kind of transform, except array. might support in the future, but not now"
                     }
                 },
                 "keep_empty_or_null": { "$ref": "#/definitions/value_keep_empty_or_null" },
+                "validate": { "$ref": "#/definitions/value_validate" },
+                "sensitive": { "$ref": "#/definitions/value_sensitive" },
+                "params": { "$ref": "#/definitions/value_params" },
+                "max_depth": { "$ref": "#/definitions/value_max_depth" },
                 "_comment": { "$ref": "#/definitions/value_comment" }
             },
             "required": [ "array" ],
@@ -223,6 +474,12 @@ const (
                 "xpath": { "$ref": "#/definitions/value_xpath" },
                 "xpath_dynamic": { "$ref": "#/definitions/value_xpath_dynamic" },
                 "template": { "$ref": "#/definitions/value_template" },
+                "validate": { "$ref": "#/definitions/value_validate" },
+                "sensitive": { "$ref": "#/definitions/value_sensitive" },
+                "multi": { "$ref": "#/definitions/value_multi" },
+                "args": { "$ref": "#/definitions/value_args" },
+                "params": { "$ref": "#/definitions/value_params" },
+                "max_depth": { "$ref": "#/definitions/value_max_depth" },
                 "_comment": { "$ref": "#/definitions/value_comment" }
             },
             "required": [ "template" ],
@@ -237,11 +494,35 @@ const (
                 "type": { "$ref": "#/definitions/value_type" },
                 "no_trim": { "$ref": "#/definitions/value_no_trim" },
                 "keep_empty_or_null": { "$ref": "#/definitions/value_keep_empty_or_null" },
+                "validate": { "$ref": "#/definitions/value_validate" },
+                "sensitive": { "$ref": "#/definitions/value_sensitive" },
+                "multi": { "$ref": "#/definitions/value_multi" },
+                "params": { "$ref": "#/definitions/value_params" },
+                "max_depth": { "$ref": "#/definitions/value_max_depth" },
                 "_comment": { "$ref": "#/definitions/value_comment" }
             },
             "required": [ "custom_func" ],
             "additionalProperties": false
         },
+        "expr": {
+            "type": "object",
+            "properties": {
+                "xpath": { "$ref": "#/definitions/value_xpath" },
+                "xpath_dynamic": { "$ref": "#/definitions/value_xpath_dynamic" },
+                "expr": { "$ref": "#/definitions/value_expr" },
+                "type": { "$ref": "#/definitions/value_type" },
+                "no_trim": { "$ref": "#/definitions/value_no_trim" },
+                "keep_empty_or_null": { "$ref": "#/definitions/value_keep_empty_or_null" },
+                "validate": { "$ref": "#/definitions/value_validate" },
+                "sensitive": { "$ref": "#/definitions/value_sensitive" },
+                "multi": { "$ref": "#/definitions/value_multi" },
+                "params": { "$ref": "#/definitions/value_params" },
+                "max_depth": { "$ref": "#/definitions/value_max_depth" },
+                "_comment": { "$ref": "#/definitions/value_comment" }
+            },
+            "required": [ "expr" ],
+            "additionalProperties": false
+        },
         "custom_parse": {
             "type": "object",
             "properties": {
@@ -251,6 +532,10 @@ const (
                 "type": { "$ref": "#/definitions/value_type" },
                 "no_trim": { "$ref": "#/definitions/value_no_trim" },
                 "keep_empty_or_null": { "$ref": "#/definitions/value_keep_empty_or_null" },
+                "validate": { "$ref": "#/definitions/value_validate" },
+                "sensitive": { "$ref": "#/definitions/value_sensitive" },
+                "params": { "$ref": "#/definitions/value_params" },
+                "max_depth": { "$ref": "#/definitions/value_max_depth" },
                 "_comment": { "$ref": "#/definitions/value_comment" }
             },
             "required": [ "custom_parse" ],
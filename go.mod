@@ -16,4 +16,5 @@ require (
 	github.com/xeipuuv/gojsonschema v1.2.0
 	golang.org/x/net v0.0.0-20220722155237-a158d28d115b
 	golang.org/x/text v0.3.8
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -15,9 +15,13 @@ import (
 // JSONStreamReader is a streaming JSON to *Node reader.
 type JSONStreamReader struct {
 	r                          *ios.LineCountingReader
+	lines                      *lineTracker
 	d                          *json.Decoder
 	xpathExpr, xpathFilterExpr *xpath.Expr
 	root, cur, stream          *Node
+	streamLine                 int // the line where the current stream candidate starts.
+	limits                     streamLimits
+	err                        error
 }
 
 // streamCandidateCheck checks if sp.cur is a potential stream candidate.
@@ -42,6 +46,7 @@ type JSONStreamReader struct {
 func (sp *JSONStreamReader) streamCandidateCheck() {
 	if sp.xpathExpr != nil && sp.stream == nil && MatchAny(sp.root, sp.xpathExpr) {
 		sp.stream = sp.cur
+		sp.streamLine = sp.lines.lineAt(sp.d.InputOffset())
 	}
 }
 
@@ -75,6 +80,7 @@ func (sp *JSONStreamReader) addElementChild(data string, jtype JSONType) {
 	child := CreateJSONNode(ElementNode, data, jtype)
 	AddChild(sp.cur, child)
 	sp.cur = child
+	sp.limits.nodes++
 }
 
 func (sp *JSONStreamReader) addTextChild(tok interface{}) {
@@ -96,6 +102,7 @@ func (sp *JSONStreamReader) addTextChild(tok interface{}) {
 	}
 	child := CreateJSONNode(TextNode, data, jtype)
 	AddChild(sp.cur, child)
+	sp.limits.nodes++
 	// Since the child being added is a value node, there won't be anything else
 	// added below it, so no need to advance sp.cur to child.
 }
@@ -194,8 +201,20 @@ func (sp *JSONStreamReader) parse() (*Node, error) {
 			// including io.EOF
 			return nil, err
 		}
+		if err = sp.limits.check(sp.d.InputOffset()); err != nil {
+			return nil, err
+		}
+		// keeps the line tracking up with the decoder, even when no stream candidate is found for long.
+		sp.lines.lineAt(sp.d.InputOffset())
 		switch tok := tok.(type) {
 		case json.Delim:
+			if tok == '{' || tok == '[' {
+				if err = sp.limits.enter(); err != nil {
+					return nil, err
+				}
+			} else {
+				sp.limits.leave()
+			}
 			if ret := sp.parseDelim(tok); ret != nil {
 				return ret, nil
 			}
@@ -208,7 +227,10 @@ func (sp *JSONStreamReader) parse() (*Node, error) {
 }
 
 // Read returns a *Node that matches the xpath streaming criteria.
-func (sp *JSONStreamReader) Read() (*Node, error) {
+func (sp *JSONStreamReader) Read() (n *Node, err error) {
+	if sp.err != nil {
+		return nil, sp.err
+	}
 	// Because this is a streaming read, we need to release/remove last
 	// stream node from the node tree to free up memory. If Release() is
 	// called after Read() call, then sp.stream is already cleaned up;
@@ -217,7 +239,13 @@ func (sp *JSONStreamReader) Read() (*Node, error) {
 		RemoveAndReleaseTree(sp.stream)
 		sp.stream = nil
 	}
-	return sp.parse()
+	sp.limits.startRecord(sp.d.InputOffset())
+	n, err = sp.parse()
+	if IsErrLimitExceeded(err) {
+		// the reader is left in the middle of a record, so there is no going further.
+		sp.err = err
+	}
+	return n, err
 }
 
 // Release releases the *Node (and its subtree) that Read() has previously
@@ -230,11 +258,52 @@ func (sp *JSONStreamReader) Release(n *Node) {
 	RemoveAndReleaseTree(n)
 }
 
+// SetLimits sets the Limits the reader enforces on the input. It must be called before the first Read.
+func (sp *JSONStreamReader) SetLimits(limits Limits) {
+	sp.limits.Limits = limits
+}
+
 // AtLine returns the **rough** line number of the current JSON decoder.
 func (sp *JSONStreamReader) AtLine() int {
 	return sp.r.AtLine()
 }
 
+// RecordLineStart returns the line number where the node last returned by Read starts.
+func (sp *JSONStreamReader) RecordLineStart() int {
+	return sp.streamLine
+}
+
+// lineTracker tracks the line feeds of the input a decoder reads, so that the line of an input offset
+// the decoder has reached can be found, despite the decoder reading ahead.
+type lineTracker struct {
+	r    io.Reader
+	n    int64   // the number of bytes read so far.
+	lfs  []int64 // the offsets of the line feeds read, but not yet passed to lineAt.
+	line int     // the line of the last offset passed to lineAt.
+}
+
+func (lt *lineTracker) Read(p []byte) (int, error) {
+	n, err := lt.r.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] == '\n' {
+			lt.lfs = append(lt.lfs, lt.n+int64(i))
+		}
+	}
+	lt.n += int64(n)
+	return n, err
+}
+
+// lineAt returns the 1-based line number of the input offset, which must be no less than the offsets
+// passed in before.
+func (lt *lineTracker) lineAt(offset int64) int {
+	passed := 0
+	for ; passed < len(lt.lfs) && lt.lfs[passed] < offset; passed++ {
+	}
+	lt.line += passed
+	lt.lfs = lt.lfs[passed:]
+	return lt.line + 1
+}
+
 // NewJSONStreamReader creates a new instance of JSON streaming reader.
 func NewJSONStreamReader(r io.Reader, xpathStr string) (*JSONStreamReader, error) {
 	xpathStr = strings.TrimSpace(xpathStr)
@@ -244,9 +313,12 @@ func NewJSONStreamReader(r io.Reader, xpathStr string) (*JSONStreamReader, error
 		return nil, fmt.Errorf("invalid xpath '%s', err: %s", xpathStr, err.Error())
 	}
 	xpathNoFilterExpr, _ := caches.GetXPathExpr(xpathNoFilterStr)
-	lineCountingReader := ios.NewLineCountingReader(r)
+	input := &limitedInput{r: r}
+	lines := &lineTracker{r: input}
+	lineCountingReader := ios.NewLineCountingReader(lines)
 	reader := &JSONStreamReader{
 		r:         lineCountingReader,
+		lines:     lines,
 		d:         json.NewDecoder(lineCountingReader),
 		xpathExpr: xpathNoFilterExpr,
 		xpathFilterExpr: func() *xpath.Expr {
@@ -255,7 +327,8 @@ func NewJSONStreamReader(r io.Reader, xpathStr string) (*JSONStreamReader, error
 			}
 			return xpathExpr
 		}(),
-		root: CreateJSONNode(DocumentNode, "", JSONRoot),
+		root:   CreateJSONNode(DocumentNode, "", JSONRoot),
+		limits: streamLimits{input: input},
 	}
 	reader.cur = reader.root
 	return reader, nil
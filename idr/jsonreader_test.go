@@ -127,3 +127,30 @@ func TestJSONStreamReader(t *testing.T) {
 		})
 	}
 }
+
+func TestJSONStreamReader_RecordLineStart(t *testing.T) {
+	js := `{
+		"a": [
+			{ "id": 1 },
+			{
+				"id": 2
+			},
+
+			{ "id": 3 }
+		],
+		"b": [ { "id": 4 } ]
+	}`
+	sp, err := NewJSONStreamReader(strings.NewReader(js), "/*/*[id]")
+	assert.NoError(t, err)
+	var lines []int
+	for {
+		n, err := sp.Read()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		lines = append(lines, sp.RecordLineStart())
+		sp.Release(n)
+	}
+	assert.Equal(t, []int{3, 4, 8, 10}, lines)
+}
@@ -0,0 +1,128 @@
+package idr
+
+import (
+	"fmt"
+	"io"
+)
+
+// Limits guards readers against hostile inputs, whose records would otherwise be fully materialized
+// in memory, no matter how large they are. A zero limit means unlimited. Not all the limits apply to
+// all the readers: e.g. MaxAttributes only applies to XML, and MaxLineLength only to flat files, EDI
+// and JSON Lines.
+type Limits struct {
+	// MaxNodesPerRecord is the max number of nodes created while reading a record.
+	MaxNodesPerRecord int `json:"max_nodes_per_record,omitempty"`
+	// MaxRecordBytes is the max number of input bytes consumed while reading a record.
+	MaxRecordBytes int64 `json:"max_record_bytes,omitempty"`
+	// MaxDepth is the max nesting depth of XML elements or JSON/YAML objects/arrays.
+	MaxDepth int `json:"max_depth,omitempty"`
+	// MaxLineLength is the max length in bytes of a line of a flat file or JSON Lines input, or a segment of
+	// an EDI input.
+	MaxLineLength int `json:"max_line_length,omitempty"`
+	// MaxAttributes is the max number of attributes (including namespace declarations) of an XML element.
+	MaxAttributes int `json:"max_attributes,omitempty"`
+}
+
+// ErrLimitExceeded indicates the input exceeds one of the Limits. This is a fatal, non-continuable error.
+type ErrLimitExceeded string
+
+func (e ErrLimitExceeded) Error() string { return string(e) }
+
+// IsErrLimitExceeded checks if the `err` is of ErrLimitExceeded type.
+func IsErrLimitExceeded(err error) bool {
+	switch err.(type) {
+	case ErrLimitExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// LimitExceeded creates an ErrLimitExceeded with a formatted message.
+func LimitExceeded(format string, args ...interface{}) error {
+	return ErrLimitExceeded("limit exceeded: " + fmt.Sprintf(format, args...))
+}
+
+// CheckNodesPerRecord checks the number of nodes created for a record against the limits.
+func (l Limits) CheckNodesPerRecord(nodes int) error {
+	if l.MaxNodesPerRecord > 0 && nodes > l.MaxNodesPerRecord {
+		return LimitExceeded("record has more than %d nodes", l.MaxNodesPerRecord)
+	}
+	return nil
+}
+
+// CheckRecordBytes checks the number of input bytes consumed for a record against the limits.
+func (l Limits) CheckRecordBytes(bytes int64) error {
+	if l.MaxRecordBytes > 0 && bytes > l.MaxRecordBytes {
+		return LimitExceeded("record is larger than %d bytes", l.MaxRecordBytes)
+	}
+	return nil
+}
+
+// streamLimits enforces the Limits in XMLStreamReader and JSONStreamReader.
+type streamLimits struct {
+	Limits
+	input       *limitedInput
+	nodes       int   // the number of nodes created for the current record.
+	depth       int   // the current nesting depth.
+	recordStart int64 // the decoder's input offset where the current record starts.
+}
+
+// readAheadAllowance is how many more bytes than MaxRecordBytes limitedInput lets the decoders read for
+// a record, which is generous enough for their read-ahead buffering.
+func readAheadAllowance(maxRecordBytes int64) int64 {
+	return 2*maxRecordBytes + 64*1024
+}
+
+func (l *streamLimits) startRecord(offset int64) {
+	l.nodes = 0
+	l.recordStart = offset
+	if l.MaxRecordBytes > 0 {
+		l.input.limit = l.input.n + l.MaxRecordBytes + readAheadAllowance(l.MaxRecordBytes)
+		l.input.max = l.MaxRecordBytes
+	}
+}
+
+func (l *streamLimits) enter() error {
+	l.depth++
+	if l.MaxDepth > 0 && l.depth > l.MaxDepth {
+		return LimitExceeded("nesting is deeper than %d levels", l.MaxDepth)
+	}
+	return nil
+}
+
+func (l *streamLimits) leave() {
+	l.depth--
+}
+
+// check checks the current record against the limits, given the decoder's current input offset.
+func (l *streamLimits) check(offset int64) error {
+	if err := l.CheckNodesPerRecord(l.nodes); err != nil {
+		return err
+	}
+	return l.CheckRecordBytes(offset - l.recordStart)
+}
+
+// limitedInput is the input of a stream reader. While the decoder input offset based checks catch
+// records larger than MaxRecordBytes token by token, limitedInput stops a decoder from buffering up
+// an arbitrarily large single token in memory.
+type limitedInput struct {
+	r     io.Reader
+	n     int64 // the number of bytes read so far.
+	limit int64 // the max of n, or 0 if unlimited.
+	max   int64 // the MaxRecordBytes limit, for the error message.
+}
+
+func (in *limitedInput) Read(p []byte) (int, error) {
+	if in.limit > 0 {
+		if in.n >= in.limit {
+			return 0, LimitExceeded("record is larger than %d bytes", in.max)
+		}
+		if int64(len(p)) > in.limit-in.n {
+			p = p[:in.limit-in.n]
+		}
+	}
+	n, err := in.r.Read(p)
+	in.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,203 @@
+package idr
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsErrLimitExceeded(t *testing.T) {
+	assert.True(t, IsErrLimitExceeded(LimitExceeded("test %d", 1)))
+	assert.Equal(t, "limit exceeded: test 1", LimitExceeded("test %d", 1).Error())
+	assert.False(t, IsErrLimitExceeded(errors.New("test")))
+}
+
+func TestLimits_Check(t *testing.T) {
+	assert.NoError(t, Limits{}.CheckNodesPerRecord(100))
+	assert.NoError(t, Limits{MaxNodesPerRecord: 100}.CheckNodesPerRecord(100))
+	assert.Equal(t,
+		"limit exceeded: record has more than 100 nodes",
+		Limits{MaxNodesPerRecord: 100}.CheckNodesPerRecord(101).Error())
+	assert.NoError(t, Limits{}.CheckRecordBytes(100))
+	assert.NoError(t, Limits{MaxRecordBytes: 100}.CheckRecordBytes(100))
+	assert.Equal(t,
+		"limit exceeded: record is larger than 100 bytes",
+		Limits{MaxRecordBytes: 100}.CheckRecordBytes(101).Error())
+}
+
+type streamReader interface {
+	Read() (*Node, error)
+	Release(*Node)
+	SetLimits(Limits)
+}
+
+// readAllWithLimits reads all the records and returns their count, and the error that stops the reading,
+// unless it's io.EOF.
+func readAllWithLimits(t *testing.T, sp streamReader, limits Limits) (int, error) {
+	sp.SetLimits(limits)
+	count := 0
+	for {
+		n, err := sp.Read()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			// the error is sticky.
+			_, err2 := sp.Read()
+			assert.Equal(t, err, err2)
+			return count, err
+		}
+		count++
+		sp.Release(n)
+	}
+}
+
+func TestXMLStreamReader_Limits(t *testing.T) {
+	input := `
+		<ROOT xmlns:t="uri://test">
+			<Order id="1" status="open"><Item>A1</Item><Item>B2</Item></Order>
+			<Order id="2" status="closed"><Item>C3</Item><Item>C4</Item><Item>C5</Item><Item><Sub><Sub>D6</Sub></Sub></Item></Order>
+		</ROOT>`
+	for _, test := range []struct {
+		name   string
+		input  string
+		limits Limits
+		count  int
+		err    string
+	}{
+		{
+			name:   "no limits",
+			input:  input,
+			limits: Limits{},
+			count:  2,
+		},
+		{
+			name:   "within limits",
+			input:  input,
+			limits: Limits{MaxNodesPerRecord: 16, MaxRecordBytes: 124, MaxDepth: 5, MaxAttributes: 2},
+			count:  2,
+		},
+		{
+			name:   "too many nodes",
+			input:  input,
+			limits: Limits{MaxNodesPerRecord: 15},
+			count:  1,
+			err:    "limit exceeded: record has more than 15 nodes",
+		},
+		{
+			name:   "too many bytes",
+			input:  input,
+			limits: Limits{MaxRecordBytes: 110},
+			count:  1,
+			err:    "limit exceeded: record is larger than 110 bytes",
+		},
+		{
+			name:   "too deep",
+			input:  input,
+			limits: Limits{MaxDepth: 4},
+			count:  1,
+			err:    "limit exceeded: nesting is deeper than 4 levels",
+		},
+		{
+			name:   "too many attributes",
+			input:  input,
+			limits: Limits{MaxAttributes: 1},
+			count:  0,
+			err:    "limit exceeded: element 'Order' has more than 1 attributes",
+		},
+		{
+			name:   "single huge token",
+			input:  "<ROOT><Order>" + strings.Repeat("x", 1024*1024) + "</Order></ROOT>",
+			limits: Limits{MaxRecordBytes: 100},
+			count:  0,
+			err:    "limit exceeded: record is larger than 100 bytes",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sp, err := NewXMLStreamReader(strings.NewReader(test.input), "/ROOT/Order")
+			assert.NoError(t, err)
+			count, err := readAllWithLimits(t, sp, test.limits)
+			assert.Equal(t, test.count, count)
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.True(t, IsErrLimitExceeded(err))
+				assert.Equal(t, test.err, err.Error())
+			}
+		})
+	}
+}
+
+func TestJSONStreamReader_Limits(t *testing.T) {
+	input := `
+		[
+			{ "id": 1, "items": [ "A1", "B2" ] },
+			{ "id": 2, "items": [ "C3", "C4", "C5", [ [ "D6" ] ] ] }
+		]`
+	for _, test := range []struct {
+		name   string
+		input  string
+		limits Limits
+		count  int
+		err    string
+	}{
+		{
+			name:   "no limits",
+			input:  input,
+			limits: Limits{},
+			count:  2,
+		},
+		{
+			name:   "within limits",
+			input:  input,
+			limits: Limits{MaxNodesPerRecord: 14, MaxRecordBytes: 61, MaxDepth: 5},
+			count:  2,
+		},
+		{
+			name:   "too many nodes",
+			input:  input,
+			limits: Limits{MaxNodesPerRecord: 10},
+			count:  1,
+			err:    "limit exceeded: record has more than 10 nodes",
+		},
+		{
+			name:   "too many bytes",
+			input:  input,
+			limits: Limits{MaxRecordBytes: 50},
+			count:  1,
+			err:    "limit exceeded: record is larger than 50 bytes",
+		},
+		{
+			name:   "too deep",
+			input:  input,
+			limits: Limits{MaxDepth: 4},
+			count:  1,
+			err:    "limit exceeded: nesting is deeper than 4 levels",
+		},
+		{
+			name:   "single huge token",
+			input:  `[ { "id": "` + strings.Repeat("x", 1024*1024) + `" } ]`,
+			limits: Limits{MaxRecordBytes: 100},
+			count:  0,
+			err:    "limit exceeded: record is larger than 100 bytes",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sp, err := NewJSONStreamReader(strings.NewReader(test.input), "/*")
+			assert.NoError(t, err)
+			count, err := readAllWithLimits(t, sp, test.limits)
+			assert.Equal(t, test.count, count)
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.True(t, IsErrLimitExceeded(err))
+				assert.Equal(t, test.err, err.Error())
+			}
+		})
+	}
+}
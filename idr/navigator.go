@@ -6,6 +6,33 @@ import (
 
 type navigator struct {
 	root, cur *Node
+	// compiled and state are only set when the xpath expression being evaluated references custom
+	// functions and/or variables, which are exposed as virtual attributes. See xpathfuncs.go.
+	compiled *compiledXPath
+	state    *xpathQueryState
+	// vattrIdx is the index of the virtual attribute the navigator is currently on, or -1.
+	vattrIdx int
+	// vattrOwner is the node whose attributes the navigator is iterating through, and thus the node the
+	// virtual attributes are evaluated against: the element, or the attribute (e.g. in a predicate on an
+	// attribute step) MoveToNextAttribute is first called on. It's nil if not iterating.
+	vattrOwner *Node
+}
+
+func (nav *navigator) virtualAttrs() []*Node {
+	if nav.compiled == nil || len(nav.compiled.vattrs) == 0 || nav.vattrOwner == nil {
+		return nil
+	}
+	return nav.state.virtualAttrNodes(nav.compiled, nav.root, nav.vattrOwner)
+}
+
+func (nav *navigator) moveToVirtualAttr(idx int) bool {
+	vattrs := nav.virtualAttrs()
+	if idx >= len(vattrs) {
+		return false
+	}
+	nav.cur = vattrs[idx]
+	nav.vattrIdx = idx
+	return true
 }
 
 func (nav *navigator) Current() *Node {
@@ -55,6 +82,8 @@ func (nav *navigator) Copy() xpath.NodeNavigator {
 
 func (nav *navigator) MoveToRoot() {
 	nav.cur = nav.root
+	nav.vattrIdx = -1
+	nav.vattrOwner = nil
 }
 
 func (nav *navigator) MoveToParent() bool {
@@ -62,14 +91,23 @@ func (nav *navigator) MoveToParent() bool {
 		return false
 	}
 	nav.cur = nav.cur.Parent
+	nav.vattrIdx = -1
+	nav.vattrOwner = nil
 	return true
 }
 
 func (nav *navigator) MoveToNextAttribute() bool {
+	if nav.vattrOwner == nil {
+		nav.vattrOwner = nav.cur
+	}
+	if nav.vattrIdx >= 0 {
+		return nav.moveToVirtualAttr(nav.vattrIdx + 1)
+	}
 	if nav.cur.Type == AttributeNode {
 		// if we are currently on an AttributeNode, move to the next AttributeNode if there is one.
 		if nav.cur.NextSibling == nil || nav.cur.NextSibling.Type != AttributeNode {
-			return false
+			// real attributes exhausted, move onto the virtual ones, if any.
+			return nav.moveToVirtualAttr(0)
 		}
 		nav.cur = nav.cur.NextSibling
 		return true
@@ -77,7 +115,7 @@ func (nav *navigator) MoveToNextAttribute() bool {
 	// If we're not on an AttributeNode, then check its first child - because if there are
 	// AttributeNodes, they will always be packed first.
 	if nav.cur.FirstChild == nil || nav.cur.FirstChild.Type != AttributeNode {
-		return false
+		return nav.moveToVirtualAttr(0)
 	}
 	nav.cur = nav.cur.FirstChild
 	return true
@@ -147,6 +185,10 @@ func (nav *navigator) MoveTo(other xpath.NodeNavigator) bool {
 		return false
 	}
 	nav.cur = node.cur
+	// the node moved to becomes the context, of a predicate for example, rather than a position in an
+	// iteration through attributes.
+	nav.vattrIdx = -1
+	nav.vattrOwner = nil
 	return true
 }
 
@@ -154,7 +196,7 @@ func (nav *navigator) MoveTo(other xpath.NodeNavigator) bool {
 var _ xpath.NodeNavigator = &navigator{}
 
 func createNavigator(n *Node) *navigator {
-	return &navigator{root: n, cur: n}
+	return &navigator{root: n, cur: n, vattrIdx: -1}
 }
 
 func nodeFromIter(iter *xpath.NodeIterator) *Node {
@@ -58,18 +58,35 @@ func MatchAny(n *Node, expr *xpath.Expr) bool {
 
 // MatchAll returns all the matched nodes by an xpath query 'exprStr' against an IDR tree rooted at 'n'.
 func MatchAll(n *Node, exprStr string, flags ...uint) ([]*Node, error) {
+	return MatchAllWithVars(n, exprStr, nil, flags...)
+}
+
+// MatchAllWithVars is the same as MatchAll, except that 'exprStr' can reference variables (such
+// as `$name`) that are bound to the values in 'vars'.
+func MatchAllWithVars(n *Node, exprStr string, vars map[string]string, flags ...uint) ([]*Node, error) {
 	if exprStr == "." {
 		return []*Node{n}, nil
 	}
-	exp, err := loadXPathExpr(exprStr, flags)
+	c, err := loadCompiledXPath(exprStr, flags)
 	if err != nil {
 		return nil, err
 	}
-	iter := QueryIter(n, exp)
 	var ret []*Node
+	if canMatchSimpleXPath(n, c) {
+		matchSimpleXPath(n, exprStr, func(n *Node) bool {
+			ret = append(ret, n)
+			return true
+		})
+		return ret, nil
+	}
+	state := newXPathQueryState(vars)
+	iter := queryIter(n, c, state)
 	for iter.MoveNext() {
 		ret = append(ret, nodeFromIter(iter))
 	}
+	if state.err != nil {
+		return nil, state.err
+	}
 	return ret, nil
 }
 
@@ -77,20 +94,71 @@ func MatchAll(n *Node, exprStr string, flags ...uint) ([]*Node, error) {
 // at 'n'. If no matching node is found, ErrNoMatch is returned; if more than one matching nodes are found,
 // ErrMoreThanExpected is returned.
 func MatchSingle(n *Node, exprStr string, flags ...uint) (*Node, error) {
+	return MatchSingleWithVars(n, exprStr, nil, flags...)
+}
+
+// MatchSingleWithVars is the same as MatchSingle, except that 'exprStr' can reference variables (such
+// as `$name`) that are bound to the values in 'vars'.
+func MatchSingleWithVars(n *Node, exprStr string, vars map[string]string, flags ...uint) (*Node, error) {
 	if exprStr == "." {
 		return n, nil
 	}
-	expr, err := loadXPathExpr(exprStr, flags)
+	c, err := loadCompiledXPath(exprStr, flags)
 	if err != nil {
 		return nil, err
 	}
-	iter := QueryIter(n, expr)
-	if !iter.MoveNext() {
+	if canMatchSimpleXPath(n, c) {
+		return matchSingleSimpleXPath(n, exprStr)
+	}
+	state := newXPathQueryState(vars)
+	iter := queryIter(n, c, state)
+	found := iter.MoveNext()
+	if state.err != nil {
+		return nil, state.err
+	}
+	if !found {
 		return nil, ErrNoMatch
 	}
 	ret := nodeFromIter(iter)
-	if iter.MoveNext() {
+	more := iter.MoveNext()
+	if state.err != nil {
+		return nil, state.err
+	}
+	if more {
+		return nil, ErrMoreThanExpected
+	}
+	return ret, nil
+}
+
+// canMatchSimpleXPath returns true if a compiled xpath can be evaluated against 'n' by matchSimpleXPath.
+func canMatchSimpleXPath(n *Node, c *compiledXPath) bool {
+	return c.simple && (n.Type == DocumentNode || n.Type == ElementNode)
+}
+
+func matchSingleSimpleXPath(n *Node, exprStr string) (*Node, error) {
+	var ret *Node
+	more := false
+	matchSimpleXPath(n, exprStr, func(n *Node) bool {
+		if ret != nil {
+			more = true
+			return false
+		}
+		ret = n
+		return true
+	})
+	switch {
+	case ret == nil:
+		return nil, ErrNoMatch
+	case more:
 		return nil, ErrMoreThanExpected
 	}
 	return ret, nil
 }
+
+func queryIter(n *Node, c *compiledXPath, state *xpathQueryState) *xpath.NodeIterator {
+	nav := createNavigator(n)
+	if len(c.vattrs) > 0 {
+		nav.compiled, nav.state = c, state
+	}
+	return c.expr.Select(nav)
+}
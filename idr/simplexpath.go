@@ -0,0 +1,95 @@
+package idr
+
+import (
+	"strings"
+)
+
+// isSimpleXPath returns true if an xpath expression consists of only child element steps, each being
+// either a name without a namespace prefix or '*', optionally led by './' and followed by a final
+// attribute step ('@name' or '@*'), such as 'a/b/@c'. A simple xpath can be evaluated by walking the
+// IDR tree directly, instead of going through the full-blown xpath engine.
+func isSimpleXPath(exprStr string) bool {
+	exprStr = strings.TrimPrefix(exprStr, "./")
+	if exprStr == "" {
+		return false
+	}
+	for {
+		step := exprStr
+		slash := strings.IndexByte(exprStr, '/')
+		if slash >= 0 {
+			step = exprStr[:slash]
+		}
+		if strings.HasPrefix(step, "@") {
+			// an attribute step must be the last.
+			return slash < 0 && isSimpleXPathName(step[1:])
+		}
+		if !isSimpleXPathName(step) {
+			return false
+		}
+		if slash < 0 {
+			return true
+		}
+		exprStr = exprStr[slash+1:]
+	}
+}
+
+func isSimpleXPathName(name string) bool {
+	switch name {
+	case "*":
+		return true
+	case "", "and", "or", "div", "mod":
+		// the operator names might not be parsed as name tests by the xpath engine.
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case i > 0 && (c >= '0' && c <= '9' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// matchSimpleXPath evaluates a simple xpath (see isSimpleXPath) against 'n' and calls 'visit' with each of
+// the matched nodes in document order, until 'visit' returns false. It returns false if the evaluation is
+// stopped by 'visit'.
+func matchSimpleXPath(n *Node, exprStr string, visit func(*Node) bool) bool {
+	exprStr = strings.TrimPrefix(exprStr, "./")
+	step, rest := exprStr, ""
+	if slash := strings.IndexByte(exprStr, '/'); slash >= 0 {
+		step, rest = exprStr[:slash], exprStr[slash+1:]
+	}
+	if strings.HasPrefix(step, "@") {
+		// attribute nodes, if any, are always packed first among the children.
+		for c := n.FirstChild; c != nil && c.Type == AttributeNode; c = c.NextSibling {
+			if simpleXPathNameMatch(c, step[1:]) && !visit(c) {
+				return false
+			}
+		}
+		return true
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != ElementNode || !simpleXPathNameMatch(c, step) {
+			continue
+		}
+		if rest == "" {
+			if !visit(c) {
+				return false
+			}
+		} else if !matchSimpleXPath(c, rest, visit) {
+			return false
+		}
+	}
+	return true
+}
+
+func simpleXPathNameMatch(n *Node, name string) bool {
+	if name == "*" {
+		return true
+	}
+	// just like the xpath engine, a name without a namespace prefix matches only the nodes without one.
+	return n.Data == name && (!IsXML(n) || XMLSpecificOf(n).NamespacePrefix == "")
+}
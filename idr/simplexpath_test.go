@@ -0,0 +1,211 @@
+package idr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSimpleXPath(t *testing.T) {
+	for _, test := range []struct {
+		exprStr string
+		simple  bool
+	}{
+		{exprStr: "a", simple: true},
+		{exprStr: "a/b/c", simple: true},
+		{exprStr: "./a/b", simple: true},
+		{exprStr: "*", simple: true},
+		{exprStr: "a/*/c", simple: true},
+		{exprStr: "@a", simple: true},
+		{exprStr: "a/b/@c", simple: true},
+		{exprStr: "a/@*", simple: true},
+		{exprStr: "_a-b.c1", simple: true},
+		{exprStr: "", simple: false},
+		{exprStr: ".", simple: false},
+		{exprStr: "./", simple: false},
+		{exprStr: "..", simple: false},
+		{exprStr: "../a", simple: false},
+		{exprStr: "/a", simple: false},
+		{exprStr: "a/", simple: false},
+		{exprStr: "a//b", simple: false},
+		{exprStr: "a/./b", simple: false},
+		{exprStr: "@a/b", simple: false},
+		{exprStr: "@", simple: false},
+		{exprStr: "a[1]", simple: false},
+		{exprStr: "a[@b='c']", simple: false},
+		{exprStr: "t:a", simple: false},
+		{exprStr: "text()", simple: false},
+		{exprStr: "1a", simple: false},
+		{exprStr: "-a", simple: false},
+		{exprStr: " a", simple: false},
+		{exprStr: "a | b", simple: false},
+		{exprStr: "and", simple: false},
+		{exprStr: "a/div", simple: false},
+		{exprStr: "résumé", simple: false},
+	} {
+		t.Run(test.exprStr, func(t *testing.T) {
+			assert.Equal(t, test.simple, isSimpleXPath(test.exprStr))
+		})
+	}
+}
+
+func simpleXPathTestSetup(t testing.TB) (xmlNode, jsonNode *Node) {
+	xr, err := NewXMLStreamReader(strings.NewReader(`
+		<ROOT xmlns:t="uri://test">
+			<Order id="1" t:id="t1" status="open">
+				<Customer><Name>John</Name><Tier>gold</Tier></Customer>
+				<Item sku="A1"><Qty>2</Qty><t:Qty>20</t:Qty></Item>
+				<Item sku="B2"><Qty>1</Qty></Item>
+				<t:Item sku="C3"><Qty>5</Qty></t:Item>
+				<Note>fragile</Note>
+			</Order>
+		</ROOT>`), "/ROOT/Order")
+	assert.NoError(t, err)
+	xmlNode, err = xr.Read()
+	assert.NoError(t, err)
+	jr, err := NewJSONStreamReader(strings.NewReader(`
+		{
+			"id": 1,
+			"customer": { "name": "John", "tier": "gold" },
+			"items": [
+				{ "sku": "A1", "qty": 2 },
+				{ "sku": "B2", "qty": 1 }
+			],
+			"note": "fragile"
+		}`), "/")
+	assert.NoError(t, err)
+	jsonNode, err = jr.Read()
+	assert.NoError(t, err)
+	return xmlNode, jsonNode
+}
+
+func TestMatchSimpleXPath_SameAsXPath(t *testing.T) {
+	xmlNode, jsonNode := simpleXPathTestSetup(t)
+	for _, test := range []struct {
+		name    string
+		n       *Node
+		exprStr string
+	}{
+		{name: "xml: single element", n: xmlNode, exprStr: "Note"},
+		{name: "xml: nested element", n: xmlNode, exprStr: "Customer/Name"},
+		{name: "xml: leading dot", n: xmlNode, exprStr: "./Customer/Tier"},
+		{name: "xml: multiple elements", n: xmlNode, exprStr: "Item"},
+		{name: "xml: multiple nested elements", n: xmlNode, exprStr: "Item/Qty"},
+		{name: "xml: wildcard", n: xmlNode, exprStr: "*"},
+		{name: "xml: wildcard then element", n: xmlNode, exprStr: "*/Qty"},
+		{name: "xml: element then wildcard", n: xmlNode, exprStr: "Item/*"},
+		{name: "xml: attribute", n: xmlNode, exprStr: "@id"},
+		{name: "xml: nested attribute", n: xmlNode, exprStr: "Item/@sku"},
+		{name: "xml: wildcard attribute", n: xmlNode, exprStr: "@*"},
+		{name: "xml: no match", n: xmlNode, exprStr: "Customer/Address"},
+		{name: "xml: no attribute match", n: xmlNode, exprStr: "Customer/@id"},
+		{name: "xml: from document", n: xmlNode.Parent.Parent, exprStr: "ROOT/Order/Item/Qty"},
+		{name: "json: single element", n: jsonNode, exprStr: "note"},
+		{name: "json: nested element", n: jsonNode, exprStr: "customer/tier"},
+		{name: "json: array elements", n: jsonNode, exprStr: "items/*"},
+		{name: "json: nested array elements", n: jsonNode, exprStr: "items/*/sku"},
+		{name: "json: wildcard", n: jsonNode, exprStr: "*"},
+		{name: "json: no match", n: jsonNode, exprStr: "items/sku"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.True(t, isSimpleXPath(test.exprStr))
+			expr, err := loadXPathExpr(test.exprStr, []uint{DisableXPathCache})
+			assert.NoError(t, err)
+			var expected []*Node
+			for iter := QueryIter(test.n, expr); iter.MoveNext(); {
+				expected = append(expected, nodeFromIter(iter))
+			}
+			var actual []*Node
+			matchSimpleXPath(test.n, test.exprStr, func(n *Node) bool {
+				actual = append(actual, n)
+				return true
+			})
+			assert.Equal(t, expected, actual)
+			nodes, err := MatchAll(test.n, test.exprStr)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, nodes)
+			n, err := MatchSingle(test.n, test.exprStr)
+			switch len(expected) {
+			case 0:
+				assert.Equal(t, ErrNoMatch, err)
+				assert.Nil(t, n)
+			case 1:
+				assert.NoError(t, err)
+				assert.True(t, expected[0] == n)
+			default:
+				assert.Equal(t, ErrMoreThanExpected, err)
+				assert.Nil(t, n)
+			}
+		})
+	}
+}
+
+func TestMatchSimpleXPath_Stop(t *testing.T) {
+	xmlNode, _ := simpleXPathTestSetup(t)
+	var visited []string
+	assert.False(t, matchSimpleXPath(xmlNode, "Item/@sku", func(n *Node) bool {
+		visited = append(visited, n.InnerText())
+		return len(visited) < 2
+	}))
+	assert.Equal(t, []string{"A1", "B2"}, visited)
+}
+
+func TestMatchAll_SimpleXPathOnNonElement(t *testing.T) {
+	xmlNode, _ := simpleXPathTestSetup(t)
+	attr, err := MatchSingle(xmlNode, "@status")
+	assert.NoError(t, err)
+	// not evaluated by matchSimpleXPath, since the context node is an attribute.
+	nodes, err := MatchAll(attr, "@id")
+	assert.NoError(t, err)
+	expr, err := loadXPathExpr("@id", nil)
+	assert.NoError(t, err)
+	var expected []*Node
+	for iter := QueryIter(attr, expr); iter.MoveNext(); {
+		expected = append(expected, nodeFromIter(iter))
+	}
+	assert.Equal(t, expected, nodes)
+}
+
+// go test -bench=Match -benchmem
+// BenchmarkMatchSingle_SimpleXPath 	 3301284	       367.6 ns/op	      64 B/op	       2 allocs/op
+// BenchmarkMatchSingle_FullXPath   	  446186	      2304 ns/op	     954 B/op	      23 allocs/op
+// BenchmarkMatchAll_SimpleXPath    	 1966603	       609.6 ns/op	      88 B/op	       4 allocs/op
+// BenchmarkMatchAll_FullXPath      	  430341	      2787 ns/op	    1123 B/op	      29 allocs/op
+
+func benchmarkMatchSingle(b *testing.B, exprStr string) {
+	xmlNode, _ := simpleXPathTestSetup(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := MatchSingle(xmlNode, exprStr); err != nil {
+			b.FailNow()
+		}
+	}
+}
+
+func benchmarkMatchAll(b *testing.B, exprStr string) {
+	xmlNode, _ := simpleXPathTestSetup(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if nodes, err := MatchAll(xmlNode, exprStr); err != nil || len(nodes) != 2 {
+			b.FailNow()
+		}
+	}
+}
+
+func BenchmarkMatchSingle_SimpleXPath(b *testing.B) {
+	benchmarkMatchSingle(b, "Customer/Name")
+}
+
+func BenchmarkMatchSingle_FullXPath(b *testing.B) {
+	// the trailing predicate makes the otherwise equivalent xpath not simple.
+	benchmarkMatchSingle(b, "Customer/Name[true()]")
+}
+
+func BenchmarkMatchAll_SimpleXPath(b *testing.B) {
+	benchmarkMatchAll(b, "Item/Qty")
+}
+
+func BenchmarkMatchAll_FullXPath(b *testing.B) {
+	benchmarkMatchAll(b, "Item/Qty[true()]")
+}
@@ -18,6 +18,8 @@ type XMLStreamReader struct {
 	space2prefix               map[string]string
 	xpathExpr, xpathFilterExpr *xpath.Expr
 	root, cur, stream          *Node
+	streamLine                 int // the line where the current stream candidate starts.
+	limits                     streamLimits
 	err                        error
 }
 
@@ -26,6 +28,8 @@ type XMLStreamReader struct {
 func (sp *XMLStreamReader) streamCandidateCheck() {
 	if sp.xpathExpr != nil && sp.stream == nil && MatchAny(sp.root, sp.xpathExpr) {
 		sp.stream = sp.cur
+		// the decoder is right past the start tag, which is good enough for a start tag spanning lines.
+		sp.streamLine = sp.AtLine()
 	}
 }
 
@@ -114,6 +118,7 @@ func (sp *XMLStreamReader) addNonTextChild(ntype NodeType, tok interface{}) erro
 	child := CreateXMLNode(ntype, data, xmlSpecific)
 	AddChild(sp.cur, child)
 	sp.cur = child
+	sp.limits.nodes++
 	return nil
 }
 
@@ -123,6 +128,7 @@ func (sp *XMLStreamReader) addNonTextChild(ntype NodeType, tok interface{}) erro
 func (sp *XMLStreamReader) addTextChild(text string) {
 	child := CreateXMLNode(TextNode, text, XMLSpecific{})
 	AddChild(sp.cur, child)
+	sp.limits.nodes++
 }
 
 func (sp *XMLStreamReader) parse() (*Node, error) {
@@ -132,8 +138,18 @@ func (sp *XMLStreamReader) parse() (*Node, error) {
 			// including io.EOF
 			return nil, err
 		}
+		if err = sp.limits.check(sp.d.InputOffset()); err != nil {
+			return nil, err
+		}
 		switch tok := tok.(type) {
 		case xml.StartElement:
+			if err = sp.limits.enter(); err != nil {
+				return nil, err
+			}
+			if sp.limits.MaxAttributes > 0 && len(tok.Attr) > sp.limits.MaxAttributes {
+				return nil, LimitExceeded(
+					"element '%s' has more than %d attributes", tok.Name.Local, sp.limits.MaxAttributes)
+			}
 			sp.updateNamespaces(tok.Attr)
 			err = sp.addNonTextChild(ElementNode, tok.Name)
 			if err != nil {
@@ -152,6 +168,7 @@ func (sp *XMLStreamReader) parse() (*Node, error) {
 			}
 			sp.streamCandidateCheck()
 		case xml.EndElement:
+			sp.limits.leave()
 			ret := sp.wrapUpCurAndTargetCheck()
 			if ret != nil {
 				return ret, nil
@@ -175,6 +192,7 @@ func (sp *XMLStreamReader) Read() (n *Node, err error) {
 		RemoveAndReleaseTree(sp.stream)
 		sp.stream = nil
 	}
+	sp.limits.startRecord(sp.d.InputOffset())
 	n, sp.err = sp.parse()
 	return n, sp.err
 }
@@ -189,6 +207,11 @@ func (sp *XMLStreamReader) Release(n *Node) {
 	RemoveAndReleaseTree(n)
 }
 
+// SetLimits sets the Limits the reader enforces on the input. It must be called before the first Read.
+func (sp *XMLStreamReader) SetLimits(limits Limits) {
+	sp.limits.Limits = limits
+}
+
 // AtLine returns the **rough** line number of the current XML decoder.
 func (sp *XMLStreamReader) AtLine() int {
 	// Given all the libraries are of fixed versions in go modules, we're fine.
@@ -197,6 +220,11 @@ func (sp *XMLStreamReader) AtLine() int {
 	return int(reflect.ValueOf(sp.d).Elem().FieldByName("line").Int())
 }
 
+// RecordLineStart returns the **rough** line number where the node last returned by Read starts.
+func (sp *XMLStreamReader) RecordLineStart() int {
+	return sp.streamLine
+}
+
 // NewXMLStreamReader creates a new instance of XML streaming reader.
 func NewXMLStreamReader(r io.Reader, xpathStr string) (*XMLStreamReader, error) {
 	xpathStr = strings.TrimSpace(xpathStr)
@@ -208,8 +236,9 @@ func NewXMLStreamReader(r io.Reader, xpathStr string) (*XMLStreamReader, error)
 	// If the original xpath is valid, then this xpath with last filter removed gotta
 	// be valid as well. So no error checking.
 	xpathNoFilterExpr, _ := caches.GetXPathExpr(xpathNoFilterStr)
+	input := &limitedInput{r: r}
 	reader := &XMLStreamReader{
-		d: xml.NewDecoder(r),
+		d: xml.NewDecoder(input),
 		// http://www.w3.org/XML/1998/namespace is bound by definition to the prefix xml.
 		space2prefix: map[string]string{
 			"http://www.w3.org/XML/1998/namespace": "xml",
@@ -221,7 +250,8 @@ func NewXMLStreamReader(r io.Reader, xpathStr string) (*XMLStreamReader, error)
 			}
 			return xpathExpr
 		}(),
-		root: CreateXMLNode(DocumentNode, "", XMLSpecific{}),
+		root:   CreateXMLNode(DocumentNode, "", XMLSpecific{}),
+		limits: streamLimits{input: input},
 	}
 	reader.d.CharsetReader = charset.NewReaderLabel
 	reader.cur = reader.root
@@ -46,6 +46,7 @@ func TestXMLStreamReader_SuccessWithXPathWithFilter(t *testing.T) {
 	// intentionally not calling sp.Release() to verify subsequent sp.Read()
 	// call does the right thing and frees the stream node.
 	assert.Equal(t, 5, sp.AtLine())
+	assert.Equal(t, 5, sp.RecordLineStart())
 
 	// Second `<t:BBB>` read
 	n, err = sp.Read()
@@ -56,6 +57,7 @@ func TestXMLStreamReader_SuccessWithXPathWithFilter(t *testing.T) {
 	})
 	sp.Release(n)
 	assert.Equal(t, 7, sp.AtLine())
+	assert.Equal(t, 7, sp.RecordLineStart())
 
 	// Third `<t:BBB>` read (Note we will skip 'b3' since the streamElementFilter excludes it)
 	n, err = sp.Read()
@@ -66,6 +68,7 @@ func TestXMLStreamReader_SuccessWithXPathWithFilter(t *testing.T) {
 	})
 	sp.Release(n)
 	assert.Equal(t, 11, sp.AtLine())
+	assert.Equal(t, 11, sp.RecordLineStart())
 
 	n, err = sp.Read()
 	assert.Equal(t, io.EOF, err)
@@ -100,6 +103,7 @@ func TestXMLStreamReader_SuccessWithXPathWithoutFilter(t *testing.T) {
 		cupaloy.SnapshotT(t, JSONify1(rootOf(n)))
 	})
 	assert.Equal(t, 5, sp.AtLine())
+	assert.Equal(t, 5, sp.RecordLineStart())
 
 	// Second `<t:BBB>` read
 	n, err = sp.Read()
@@ -110,6 +114,7 @@ func TestXMLStreamReader_SuccessWithXPathWithoutFilter(t *testing.T) {
 	})
 	sp.Release(n)
 	assert.Equal(t, 7, sp.AtLine())
+	assert.Equal(t, 7, sp.RecordLineStart())
 
 	// Third `<t:BBB>` read
 	n, err = sp.Read()
@@ -120,6 +125,7 @@ func TestXMLStreamReader_SuccessWithXPathWithoutFilter(t *testing.T) {
 	})
 	sp.Release(n)
 	assert.Equal(t, 8, sp.AtLine())
+	assert.Equal(t, 8, sp.RecordLineStart())
 
 	// Fourth `<t:BBB>` read
 	n, err = sp.Read()
@@ -130,6 +136,7 @@ func TestXMLStreamReader_SuccessWithXPathWithoutFilter(t *testing.T) {
 	})
 	sp.Release(n)
 	assert.Equal(t, 11, sp.AtLine())
+	assert.Equal(t, 11, sp.RecordLineStart())
 
 	n, err = sp.Read()
 	assert.Equal(t, io.EOF, err)
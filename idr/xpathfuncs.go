@@ -0,0 +1,560 @@
+package idr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xpath"
+	"github.com/jf-tech/go-corelib/caches"
+)
+
+// XPathFuncCtx is the context object passed to an XPathFunc.
+type XPathFuncCtx struct {
+	// Node is the context node the custom xpath function call is evaluated against.
+	Node *Node
+	// Vars contains the variable bindings of the current xpath query.
+	Vars map[string]string
+}
+
+// XPathFunc is a custom function callable inside an xpath expression as 'namespace:name(args...)'.
+// Each arg is itself an xpath expression evaluated against the context node and passed in as a
+// string, float64 or bool; a node-set arg is passed in as the string value of its first node, or
+// "" if empty. The return value must be a string, a number, a bool or nil. A false or nil return
+// value is treated as if the call yielded nothing. A call used directly as a predicate or as an operand
+// of 'and', 'or' or 'not()', e.g. `Items/Item[omni:regex-match(@code, '^A')]`, is converted to a bool
+// the XPath way: nil, false, "", 0 and NaN are false.
+type XPathFunc func(ctx *XPathFuncCtx, args ...interface{}) (interface{}, error)
+
+const (
+	// XPathFuncNamespaceOmni is the namespace of all the builtin custom xpath functions.
+	XPathFuncNamespaceOmni = "omni"
+)
+
+// xpathFuncs is the custom xpath function registry: namespace -> function name -> function.
+var xpathFuncs = map[string]map[string]XPathFunc{
+	XPathFuncNamespaceOmni: {
+		// keep these custom xpath funcs lexically sorted
+		"external":    xpathFuncExternal,
+		"lower":       xpathFuncLower,
+		"regex-match": xpathFuncRegexMatch,
+		"trim":        xpathFuncTrim,
+		"upper":       xpathFuncUpper,
+	},
+}
+
+// RegisterXPathFunc registers a custom xpath function so it can be called inside xpath expressions
+// as 'namespace:name(args...)'. Registration isn't thread-safe and must be done at package init time,
+// before any xpath query is made.
+func RegisterXPathFunc(namespace, name string, fn XPathFunc) {
+	if _, found := xpathFuncs[namespace]; !found {
+		xpathFuncs[namespace] = map[string]XPathFunc{}
+	}
+	xpathFuncs[namespace][name] = fn
+}
+
+func lookupXPathFunc(namespace, name string) (fn XPathFunc, namespaceFound bool) {
+	fns, found := xpathFuncs[namespace]
+	if !found {
+		return nil, false
+	}
+	return fns[name], true
+}
+
+func xpathFuncArgStrs(name string, args []interface{}, count int) ([]string, error) {
+	if len(args) != count {
+		return nil, fmt.Errorf("%s() expects %d argument(s), instead got %d", name, count, len(args))
+	}
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = xpathValueToStr(arg)
+	}
+	return strs, nil
+}
+
+func xpathFuncExternal(ctx *XPathFuncCtx, args ...interface{}) (interface{}, error) {
+	strs, err := xpathFuncArgStrs("external", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	v, found := ctx.Vars[strs[0]]
+	if !found {
+		return nil, fmt.Errorf("cannot find external property '%s'", strs[0])
+	}
+	return v, nil
+}
+
+func xpathFuncLower(_ *XPathFuncCtx, args ...interface{}) (interface{}, error) {
+	strs, err := xpathFuncArgStrs("lower", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToLower(strs[0]), nil
+}
+
+func xpathFuncRegexMatch(_ *XPathFuncCtx, args ...interface{}) (interface{}, error) {
+	strs, err := xpathFuncArgStrs("regex-match", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	r, err := caches.GetRegex(strs[1])
+	if err != nil {
+		return nil, err
+	}
+	return r.MatchString(strs[0]), nil
+}
+
+func xpathFuncTrim(_ *XPathFuncCtx, args ...interface{}) (interface{}, error) {
+	strs, err := xpathFuncArgStrs("trim", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return strings.TrimSpace(strs[0]), nil
+}
+
+func xpathFuncUpper(_ *XPathFuncCtx, args ...interface{}) (interface{}, error) {
+	strs, err := xpathFuncArgStrs("upper", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToUpper(strs[0]), nil
+}
+
+func xpathValueToStr(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// xpathValueToBool converts a custom function return value or a variable value to a bool the XPath
+// way, as boolean() does.
+func xpathValueToBool(v interface{}) bool {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Invalid:
+		return false
+	case reflect.Bool:
+		return value.Bool()
+	case reflect.String:
+		return value.String() != ""
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() != 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint() != 0
+	case reflect.Float32, reflect.Float64:
+		f := value.Float()
+		return f != 0 && !math.IsNaN(f)
+	default:
+		return true
+	}
+}
+
+// antchfx/xpath doesn't support custom functions nor variables. So before compiling an xpath
+// expression, we rewrite each custom function call (e.g. `omni:lower(@type)`) and each variable
+// reference (e.g. `$name`) into a reference to a virtual attribute (e.g. `@__omni_fn_0`). During
+// the query, the navigator exposes these virtual attributes on every context node (an element, the
+// document, or an attribute in a predicate on an attribute step), with their values computed by
+// calling the custom functions (or looking up the variables) against the node they're attached to.
+// This gives us the exact per context node evaluation semantics needed both at the top level and
+// inside predicates.
+const (
+	virtualAttrPrefix        = "__omni_"
+	virtualAttrFuncPrefix    = virtualAttrPrefix + "fn_"
+	virtualAttrVarPrefix     = virtualAttrPrefix + "var_"
+	virtualAttrBoolVarPrefix = virtualAttrPrefix + "bvar_"
+)
+
+// virtualAttrFilter is appended to each attribute wildcard step (e.g. `@*`) of a rewritten xpath
+// expression so that the virtual attributes are only ever visible to the name tests referencing them.
+// antchfx/xpath evaluates a predicate by moving the context navigator onto each node filtered, without
+// moving it back afterwards, which would leave, say, `omni:lower(@code)` in `Item[count(@*) = 2 and
+// omni:lower(@code) = 'a1']` evaluated against the last attribute of Item. So once done with the name
+// of the attribute, the filter moves the context back onto the attribute's owner, by an (always empty)
+// predicate of its own on '..'.
+const virtualAttrFilter = "[not(starts-with(concat(name(), ..[false()]), '" + virtualAttrPrefix + "'))]"
+
+type xpathFuncCall struct {
+	qname string
+	fn    XPathFunc
+	args  []*compiledXPath
+}
+
+type virtualAttr struct {
+	name    string
+	varName string         // set if the virtual attr is for a variable reference.
+	call    *xpathFuncCall // set if the virtual attr is for a custom function call.
+	// boolean is set if the function call or the variable reference is in a boolean context, such as
+	// a predicate, where the virtual attr only exists if the value is true, converted the XPath way.
+	boolean bool
+}
+
+// compiledXPath is a compiled xpath expression along with the virtual attributes it references.
+type compiledXPath struct {
+	expr   *xpath.Expr
+	vattrs []*virtualAttr
+	// simple is true if the xpath expression is simple enough to be evaluated by matchSimpleXPath.
+	simple bool
+}
+
+// xpathQueryState is shared by all the navigators involved in a single xpath query, including the
+// ones used for evaluating custom function args.
+type xpathQueryState struct {
+	vars   map[string]string
+	err    error
+	vnodes map[vnodesKey][]*Node
+}
+
+type vnodesKey struct {
+	c  *compiledXPath
+	id int64
+}
+
+func newXPathQueryState(vars map[string]string) *xpathQueryState {
+	return &xpathQueryState{vars: vars}
+}
+
+func (s *xpathQueryState) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// virtualAttrNodes returns the virtual attribute nodes for context node 'n'. Note a virtual
+// attribute is omitted if its value is nil or false (or, in a boolean context, converts to false),
+// and virtual attribute nodes are never linked into the IDR tree (only their Parent is set) nor
+// allocated from the node pool.
+func (s *xpathQueryState) virtualAttrNodes(c *compiledXPath, root, n *Node) []*Node {
+	key := vnodesKey{c: c, id: n.ID}
+	if nodes, found := s.vnodes[key]; found {
+		return nodes
+	}
+	var nodes []*Node
+	for _, vattr := range c.vattrs {
+		v, err := vattr.eval(s, root, n)
+		if err != nil {
+			s.fail(err)
+			continue
+		}
+		if v == nil || v == false || vattr.boolean && !xpathValueToBool(v) {
+			continue
+		}
+		attrNode := allocNode()
+		attrNode.Type = AttributeNode
+		attrNode.Data = vattr.name
+		attrNode.Parent = n
+		textNode := allocNode()
+		textNode.Type = TextNode
+		textNode.Data = xpathValueToStr(v)
+		textNode.Parent = attrNode
+		attrNode.FirstChild, attrNode.LastChild = textNode, textNode
+		nodes = append(nodes, attrNode)
+	}
+	if s.vnodes == nil {
+		s.vnodes = map[vnodesKey][]*Node{}
+	}
+	s.vnodes[key] = nodes
+	return nodes
+}
+
+func (vattr *virtualAttr) eval(s *xpathQueryState, root, n *Node) (interface{}, error) {
+	if vattr.call == nil {
+		v, found := s.vars[vattr.varName]
+		if !found {
+			return nil, fmt.Errorf("xpath variable '$%s' is not bound", vattr.varName)
+		}
+		return v, nil
+	}
+	args := make([]interface{}, len(vattr.call.args))
+	for i, arg := range vattr.call.args {
+		nav := &navigator{root: root, cur: n, compiled: arg, state: s, vattrIdx: -1}
+		switch v := arg.expr.Evaluate(nav).(type) {
+		case *xpath.NodeIterator:
+			args[i] = ""
+			if v.MoveNext() {
+				args[i] = v.Current().Value()
+			}
+		default:
+			args[i] = v
+		}
+	}
+	v, err := vattr.call.fn(&XPathFuncCtx{Node: n, Vars: s.vars}, args...)
+	if err != nil {
+		return nil, fmt.Errorf("xpath function '%s' failed: %s", vattr.call.qname, err.Error())
+	}
+	return v, nil
+}
+
+// compiledXPathCache caches compiledXPath's of the xpath expressions that reference custom
+// functions or variables. Those that don't are cached in caches.XPathExprCache as before.
+var compiledXPathCache = caches.NewLoadingCache()
+
+func loadCompiledXPath(exprStr string, flags []uint) (*compiledXPath, error) {
+	var flagsActual uint
+	if len(flags) == 1 {
+		flagsActual = flags[0]
+	}
+	if !mayReferenceXPathFuncsOrVars(exprStr) {
+		expr, err := loadXPathExpr(exprStr, flags)
+		if err != nil {
+			return nil, err
+		}
+		return &compiledXPath{expr: expr, simple: isSimpleXPath(exprStr)}, nil
+	}
+	if len(flags) > 1 {
+		return nil, fmt.Errorf("only one flag is allowed, instead got: %d", len(flags))
+	}
+	var c interface{}
+	var err error
+	if flagsActual&DisableXPathCache != 0 {
+		c, err = compileXPath(exprStr)
+	} else {
+		c, err = compiledXPathCache.Get(exprStr, func(interface{}) (interface{}, error) {
+			return compileXPath(exprStr)
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("xpath '%s' compilation failed: %s", exprStr, err.Error())
+	}
+	return c.(*compiledXPath), nil
+}
+
+// mayReferenceXPathFuncsOrVars is a quick check to skip the rewriting for the vast majority of
+// xpath expressions that don't use any custom functions or variables.
+func mayReferenceXPathFuncsOrVars(exprStr string) bool {
+	return strings.ContainsAny(exprStr, "$:")
+}
+
+func compileXPath(exprStr string) (*compiledXPath, error) {
+	c := &compiledXPath{}
+	rewritten, err := c.rewrite(exprStr)
+	if err != nil {
+		return nil, err
+	}
+	c.expr, err = xpath.Compile(rewritten)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func isNCNameStartChar(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || b >= 0x80
+}
+
+func isNCNameChar(b byte) bool {
+	return isNCNameStartChar(b) || b == '-' || b == '.' || (b >= '0' && b <= '9')
+}
+
+func scanNCName(s string, i int) int {
+	if i >= len(s) || !isNCNameStartChar(s[i]) {
+		return i
+	}
+	for i++; i < len(s) && isNCNameChar(s[i]); i++ {
+	}
+	return i
+}
+
+func skipSpaces(s string, i int) int {
+	for ; i < len(s) && strings.IndexByte(" \t\r\n", s[i]) >= 0; i++ {
+	}
+	return i
+}
+
+// scanAttrWildcard scans, starting right after an attribute axis ('@' or 'attribute::'), a node test
+// that matches any attribute, i.e. '*' or 'node()', and returns the index right after it, or i if the
+// node test is anything else.
+func scanAttrWildcard(s string, i int) int {
+	j := skipSpaces(s, i)
+	if j < len(s) && s[j] == '*' {
+		return j + 1
+	}
+	if end := scanNCName(s, j); s[j:end] == "node" {
+		if k := skipSpaces(s, end); k < len(s) && s[k] == '(' {
+			if k = skipSpaces(s, k+1); k < len(s) && s[k] == ')' {
+				return k + 1
+			}
+		}
+	}
+	return i
+}
+
+// scanArgs scans the args of a function call, starting right after the '(', and returns the
+// raw arg strings as well as the index right after the matching ')'.
+func scanArgs(s string, i int) ([]string, int, error) {
+	var args []string
+	depth, start := 0, i
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"':
+			end := strings.IndexByte(s[i+1:], s[i])
+			if end < 0 {
+				return nil, 0, fmt.Errorf("unclosed string literal at position %d", i)
+			}
+			i += end + 1
+		case '(', '[':
+			depth++
+		case ']':
+			depth--
+		case ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if arg := strings.TrimSpace(s[start:i]); arg != "" || len(args) > 0 {
+				args = append(args, arg)
+			}
+			return args, i + 1, nil
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return nil, 0, fmt.Errorf("missing ')' at the end")
+}
+
+// inBooleanContext checks if a function call or a variable reference, between the rewritten xpath
+// expression 'before' and the rest of the original expression 'after', is used directly as a predicate,
+// or as an operand of 'and', 'or', 'not()' or 'boolean()'.
+func inBooleanContext(before, after string) bool {
+	before = strings.TrimRight(before, " \t\r\n")
+	after = strings.TrimLeft(after, " \t\r\n")
+	opensBoolean := strings.HasSuffix(before, "[") || endsWithWord(before, "and") || endsWithWord(before, "or")
+	if !opensBoolean && strings.HasSuffix(before, "(") {
+		before = strings.TrimRight(before[:len(before)-1], " \t\r\n")
+		opensBoolean = endsWithWord(before, "not") || endsWithWord(before, "boolean")
+	}
+	return opensBoolean && (strings.HasPrefix(after, "]") || strings.HasPrefix(after, ")") ||
+		startsWithWord(after, "and") || startsWithWord(after, "or"))
+}
+
+func endsWithWord(s, word string) bool {
+	return strings.HasSuffix(s, word) && (len(s) == len(word) || !isNCNameChar(s[len(s)-len(word)-1]))
+}
+
+func startsWithWord(s, word string) bool {
+	return strings.HasPrefix(s, word) && (len(s) == len(word) || !isNCNameChar(s[len(word)]))
+}
+
+func (c *compiledXPath) addVirtualAttr(vattr *virtualAttr) string {
+	for _, existing := range c.vattrs {
+		if existing.varName != "" && existing.varName == vattr.varName && existing.boolean == vattr.boolean {
+			return "@" + existing.name
+		}
+	}
+	c.vattrs = append(c.vattrs, vattr)
+	return "@" + vattr.name
+}
+
+func (c *compiledXPath) rewrite(s string) (string, error) {
+	var w strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '\'' || s[i] == '"':
+			end := strings.IndexByte(s[i+1:], s[i])
+			if end < 0 {
+				// let xpath compilation report the error.
+				w.WriteString(s[i:])
+				return w.String(), nil
+			}
+			w.WriteString(s[i : i+end+2])
+			i += end + 2
+		case s[i] == '$':
+			end := scanNCName(s, i+1)
+			if end == i+1 {
+				return "", fmt.Errorf("invalid variable reference at position %d", i)
+			}
+			varName := s[i+1 : end]
+			vattr := &virtualAttr{name: virtualAttrVarPrefix + varName, varName: varName}
+			if vattr.boolean = inBooleanContext(w.String(), s[end:]); vattr.boolean {
+				vattr.name = virtualAttrBoolVarPrefix + varName
+			}
+			w.WriteString(c.addVirtualAttr(vattr))
+			i = end
+		case s[i] == '@':
+			end := scanAttrWildcard(s, i+1)
+			w.WriteString(s[i:end])
+			if end > i+1 {
+				w.WriteString(virtualAttrFilter)
+			}
+			i = end
+		case isNCNameStartChar(s[i]) && (i == 0 || !isNCNameChar(s[i-1])):
+			prefixEnd := scanNCName(s, i)
+			if axisEnd := skipSpaces(s, prefixEnd) + 2; s[i:prefixEnd] == "attribute" &&
+				strings.HasPrefix(s[axisEnd-2:], "::") {
+				end := scanAttrWildcard(s, axisEnd)
+				w.WriteString(s[i:end])
+				if end > axisEnd {
+					w.WriteString(virtualAttrFilter)
+				}
+				i = end
+				continue
+			}
+			if prefixEnd+1 >= len(s) || s[prefixEnd] != ':' || s[prefixEnd+1] == ':' {
+				w.WriteString(s[i:prefixEnd])
+				i = prefixEnd
+				continue
+			}
+			nameEnd := scanNCName(s, prefixEnd+1)
+			parenPos := skipSpaces(s, nameEnd)
+			if nameEnd == prefixEnd+1 || parenPos >= len(s) || s[parenPos] != '(' {
+				w.WriteString(s[i:nameEnd])
+				i = nameEnd
+				continue
+			}
+			namespace, name := s[i:prefixEnd], s[prefixEnd+1:nameEnd]
+			fn, _ := lookupXPathFunc(namespace, name)
+			if fn == nil {
+				// antchfx/xpath has no namespaced functions of its own.
+				return "", fmt.Errorf("unknown xpath function '%s:%s'", namespace, name)
+			}
+			argStrs, end, err := scanArgs(s, parenPos+1)
+			if err != nil {
+				return "", fmt.Errorf("xpath function '%s:%s' call: %s", namespace, name, err.Error())
+			}
+			call := &xpathFuncCall{qname: namespace + ":" + name, fn: fn}
+			for _, argStr := range argStrs {
+				arg, err := compileXPath(argStr)
+				if err != nil {
+					return "", fmt.Errorf("xpath function '%s' arg '%s' invalid: %s", call.qname, argStr, err.Error())
+				}
+				call.args = append(call.args, arg)
+			}
+			w.WriteString(c.addVirtualAttr(&virtualAttr{
+				name:    virtualAttrFuncPrefix + strconv.Itoa(len(c.vattrs)),
+				call:    call,
+				boolean: inBooleanContext(w.String(), s[end:]),
+			}))
+			i = end
+		default:
+			w.WriteByte(s[i])
+			i++
+		}
+	}
+	return w.String(), nil
+}
+
+// ValidateXPathFuncs checks that an xpath expression compiles, which includes all the custom xpath
+// functions called in it being registered and invoked with valid arg expressions.
+func ValidateXPathFuncs(exprStr string) error {
+	var err error
+	if mayReferenceXPathFuncsOrVars(exprStr) {
+		_, err = compileXPath(exprStr)
+	} else {
+		_, err = xpath.Compile(exprStr)
+	}
+	if err != nil {
+		return fmt.Errorf("xpath '%s' is invalid: %s", exprStr, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package idr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func xpathFuncsTestSetup(t *testing.T) *Node {
+	r, err := NewXMLStreamReader(strings.NewReader(`
+		<Items>
+			<Item code="A1" type="Book">The Go Programming Language</Item>
+			<Item code="B2" type="book">Clean Code</Item>
+			<Item code="A3" type="Music"> Abbey Road </Item>
+		</Items>`), "/Items")
+	assert.NoError(t, err)
+	n, err := r.Read()
+	assert.NoError(t, err)
+	return n
+}
+
+func TestRegisterXPathFunc(t *testing.T) {
+	RegisterXPathFunc("test", "len", func(_ *XPathFuncCtx, args ...interface{}) (interface{}, error) {
+		return float64(len(xpathValueToStr(args[0]))), nil
+	})
+	defer delete(xpathFuncs, "test")
+	n := xpathFuncsTestSetup(t)
+	nodes, err := MatchAll(n, "Item[test:len(.) > 12]", DisableXPathCache)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(nodes))
+	assert.Equal(t, "The Go Programming Language", nodes[0].InnerText())
+}
+
+func TestMatchAllWithVars_XPathFuncsTruthiness(t *testing.T) {
+	RegisterXPathFunc("test", "id", func(_ *XPathFuncCtx, args ...interface{}) (interface{}, error) {
+		return args[0], nil
+	})
+	defer delete(xpathFuncs, "test")
+	all := []string{"The Go Programming Language", "Clean Code", " Abbey Road "}
+	for _, test := range []struct {
+		exprStr  string
+		expected []string
+	}{
+		{exprStr: "Item[omni:trim(@missing)]", expected: nil},
+		{exprStr: "Item[omni:trim(@code)]", expected: all},
+		{exprStr: "Item[not(omni:trim(@missing))]", expected: all},
+		{exprStr: "Item[@code = 'B2' or omni:trim(@missing)]", expected: []string{"Clean Code"}},
+		{exprStr: "Item[omni:trim(@missing) and @code = 'B2']", expected: nil},
+		{exprStr: "Item[boolean(omni:trim(@missing))]", expected: nil},
+		{exprStr: "Item[omni:trim(@missing) = '']", expected: all},
+		{exprStr: "Item[omni:lower(@missing) != 'a']", expected: all},
+		{exprStr: "Item[$empty]", expected: nil},
+		{exprStr: "Item[$empty or $nonempty]", expected: all},
+		{exprStr: "Item[@code != $empty]", expected: all},
+		{exprStr: "Item[test:id(0)]", expected: nil},
+		{exprStr: "Item[test:id(0 div 0)]", expected: nil},
+		{exprStr: "Item[test:id(0.5)]", expected: all},
+		{exprStr: "Item[test:id(0) = 0]", expected: all},
+		{exprStr: "Item[test:id(true())]", expected: all},
+		{exprStr: "Item[test:id(false())]", expected: nil},
+	} {
+		t.Run(test.exprStr, func(t *testing.T) {
+			n := xpathFuncsTestSetup(t)
+			nodes, err := MatchAllWithVars(
+				n, test.exprStr, map[string]string{"empty": "", "nonempty": "x"}, DisableXPathCache)
+			assert.NoError(t, err)
+			var actual []string
+			for _, node := range nodes {
+				actual = append(actual, node.InnerText())
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestMatchAllWithVars_XPathFuncs(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		exprStr  string
+		vars     map[string]string
+		err      string
+		expected []string
+	}{
+		{
+			name:     "func in predicate",
+			exprStr:  "Item[omni:lower(@type) = 'book']",
+			expected: []string{"The Go Programming Language", "Clean Code"},
+		},
+		{
+			name:     "bool func as predicate",
+			exprStr:  "Item[omni:regex-match(@code, '^A')]",
+			expected: []string{"The Go Programming Language", " Abbey Road "},
+		},
+		{
+			name:     "not() of bool func",
+			exprStr:  "Item[not(omni:regex-match(@code, '^A'))]",
+			expected: []string{"Clean Code"},
+		},
+		{
+			name:     "nested funcs",
+			exprStr:  "Item[omni:upper(omni:trim(.)) = 'ABBEY ROAD']/@code",
+			expected: []string{"A3"},
+		},
+		{
+			name:     "func result as the query result",
+			exprStr:  "omni:upper(Item[2]/@type)",
+			expected: []string{"BOOK"},
+		},
+		{
+			name:     "variable",
+			exprStr:  "Item[@code = $code]",
+			vars:     map[string]string{"code": "B2"},
+			expected: []string{"Clean Code"},
+		},
+		{
+			name:     "same variable referenced twice",
+			exprStr:  "Item[@code = $code or @type = $code]",
+			vars:     map[string]string{"code": "Music"},
+			expected: []string{" Abbey Road "},
+		},
+		{
+			name:     "omni:external",
+			exprStr:  "Item[omni:lower(@type) = omni:external('type')]",
+			vars:     map[string]string{"type": "music"},
+			expected: []string{" Abbey Road "},
+		},
+		{
+			name:     "string literals and axes left intact",
+			exprStr:  "child::Item[@code = 'omni:lower($x)']",
+			expected: nil,
+		},
+		{
+			name:     "attribute wildcard doesn't see virtual attributes",
+			exprStr:  "Item[omni:lower(@type) = 'book']/@*",
+			expected: []string{"A1", "Book", "B2", "book"},
+		},
+		{
+			name:     "attribute axis wildcards don't see virtual attributes",
+			exprStr:  "Item[omni:lower(@type) = 'music']/attribute::*|Item[omni:upper(@type) = 'MUSIC']/attribute::node()",
+			expected: []string{"A3", "Music"},
+		},
+		{
+			name:     "count of attribute wildcard",
+			exprStr:  "Item[count(@*) = 2 and omni:regex-match(@code, '^A')]",
+			expected: []string{"The Go Programming Language", " Abbey Road "},
+		},
+		{
+			name:     "attribute referenced after attribute wildcard",
+			exprStr:  "Item[omni:lower(@type) = 'book' and count(@*) = 2 and @code = 'B2']",
+			expected: []string{"Clean Code"},
+		},
+		{
+			name:     "func in predicate on attribute step",
+			exprStr:  "Item/@code[omni:lower(.) = 'a1']",
+			expected: []string{"A1"},
+		},
+		{
+			name:     "func in predicate on attribute wildcard step",
+			exprStr:  "Item/@*[omni:lower(.) = 'book']",
+			expected: []string{"Book", "book"},
+		},
+		{
+			name:     "variable in predicate on attribute step",
+			exprStr:  "Item/@type[. = $type]",
+			vars:     map[string]string{"type": "Music"},
+			expected: []string{"Music"},
+		},
+		{
+			name:    "unknown func",
+			exprStr: "Item[omni:unknown(.)]",
+			err:     "xpath 'Item[omni:unknown(.)]' compilation failed: unknown xpath function 'omni:unknown'",
+		},
+		{
+			name:    "func missing ')'",
+			exprStr: "Item[omni:lower(.]",
+			err:     "xpath 'Item[omni:lower(.]' compilation failed: xpath function 'omni:lower' call: missing ')' at the end",
+		},
+		{
+			name:    "invalid func arg",
+			exprStr: "Item[omni:lower(]) = 'a']",
+			err:     "xpath 'Item[omni:lower(]) = 'a']' compilation failed: xpath function 'omni:lower' arg ']' invalid: expression must evaluate to a node-set",
+		},
+		{
+			name:    "unbound variable",
+			exprStr: "Item[@code = $code]",
+			err:     "xpath variable '$code' is not bound",
+		},
+		{
+			name:    "func failed",
+			exprStr: "Item[omni:lower(., .)]",
+			err:     "xpath function 'omni:lower' failed: lower() expects 1 argument(s), instead got 2",
+		},
+		{
+			name:    "external not found",
+			exprStr: "Item[omni:external('type')]",
+			vars:    map[string]string{},
+			err:     "xpath function 'omni:external' failed: cannot find external property 'type'",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			n := xpathFuncsTestSetup(t)
+			nodes, err := MatchAllWithVars(n, test.exprStr, test.vars)
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				assert.Nil(t, nodes)
+				return
+			}
+			assert.NoError(t, err)
+			var actual []string
+			for _, node := range nodes {
+				actual = append(actual, node.InnerText())
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestMatchSingleWithVars_XPathFuncs(t *testing.T) {
+	n := xpathFuncsTestSetup(t)
+	found, err := MatchSingleWithVars(n, "Item[omni:lower(@type) = $t]", map[string]string{"t": "music"})
+	assert.NoError(t, err)
+	assert.Equal(t, " Abbey Road ", found.InnerText())
+
+	found, err = MatchSingleWithVars(n, "Item[omni:lower(@type) = $t]", map[string]string{"t": "book"})
+	assert.Equal(t, ErrMoreThanExpected, err)
+	assert.Nil(t, found)
+
+	found, err = MatchSingleWithVars(n, "Item[omni:lower(@type) = $t]", map[string]string{"t": "movie"})
+	assert.Equal(t, ErrNoMatch, err)
+	assert.Nil(t, found)
+
+	found, err = MatchSingleWithVars(n, "Item[omni:lower(@type) = $t]", nil)
+	assert.Equal(t, errors.New("xpath variable '$t' is not bound"), err)
+	assert.Nil(t, found)
+}
+
+func TestValidateXPathFuncs(t *testing.T) {
+	assert.NoError(t, ValidateXPathFuncs("Item[omni:lower(@type) = $t]"))
+	assert.NoError(t, ValidateXPathFuncs("soap:Envelope/soap:Body"))
+	assert.NoError(t, ValidateXPathFuncs("Item[string-length(@type) > 2]"))
+	for exprStr, expectedErr := range map[string]string{
+		"Item[omni:lowercase(@type)]": "unknown xpath function 'omni:lowercase'",
+		"Item[omnii:lower(@type)]":    "unknown xpath function 'omnii:lower'",
+		"Item[foo:bar(.) = $t]":       "unknown xpath function 'foo:bar'",
+		"Item[lowercase(@type)]":      "not yet support this function lowercase()",
+		"1/2/3":                       "expression must evaluate to a node-set",
+	} {
+		err := ValidateXPathFuncs(exprStr)
+		assert.Error(t, err)
+		assert.Equal(t, "xpath '"+exprStr+"' is invalid: "+expectedErr, err.Error())
+	}
+}
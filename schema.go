@@ -129,7 +129,13 @@ func (s *schema) NewTransform(name string, input io.Reader, ctx *transformctx.Ct
 	if ctx.CtxAwareErr == nil {
 		ctx.CtxAwareErr = ingester
 	}
-	return &transform{ingester: ingester}, nil
+	t := &transform{ingester: ingester}
+	if ctx.Dedup != nil {
+		if t.dedup, err = newDeduper(ctx.Dedup, ingester); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
 }
 
 // Header returns the schema header.
@@ -45,6 +45,13 @@ type RawRecord interface {
 	Checksum() string
 }
 
+// RedactedRawRecord is an optional interface a RawRecord can implement to offer a dump of itself with
+// the sensitive values redacted, which is safe to be logged or saved (e.g. into a dead-letter queue).
+type RedactedRawRecord interface {
+	// Redacted returns a dump of the raw record with the sensitive values redacted.
+	Redacted() string
+}
+
 // Ingester is an interface of ingestion and transformation for a given input stream.
 type Ingester interface {
 	// Read is called repeatedly during the processing of an input stream. Each call it should return
@@ -67,3 +74,19 @@ type Ingester interface {
 	// context aware (such as input file name + line number) error formatting.
 	errs.CtxAwareErr
 }
+
+// RecordWriter is an optional interface an Ingester can implement to write each transformed record
+// directly into an io.Writer, instead of returning it as a []byte, saving the intermediate allocations.
+type RecordWriter interface {
+	// WriteRecord is like Read, except it writes the transformed record into w, and returns the number
+	// of bytes written. An error from w is returned as is, and is not expected to be continuable.
+	WriteRecord(w io.Writer) (RawRecord, int64, error)
+}
+
+// RecordKeyer is an optional interface an Ingester can implement to support deduping records by the keys
+// computed by the schema, i.e. transformctx.DedupBySchemaKey.
+type RecordKeyer interface {
+	// RecordKey returns the key of the record last returned by Read or WriteRecord. Records with the
+	// same non-empty key are duplicates.
+	RecordKey() (string, error)
+}
@@ -2,6 +2,7 @@ package omniparser
 
 import (
 	"errors"
+	"io"
 
 	"github.com/jf-tech/omniparser/errs"
 	"github.com/jf-tech/omniparser/schemahandler"
@@ -10,6 +11,8 @@ import (
 // Transform is an interface that represents one input stream ingestion and transform
 // operation. An instance of a Transform must not be shared and reused among different
 // input streams. An instance of a Transform must not be used across multiple goroutines.
+// The Transform returned by Schema.NewTransform also implements io.Closer, to release its resources
+// early if it's not read through to the end of the input stream.
 type Transform interface {
 	// Read returns a JSON byte slice representing one ingested and transformed record.
 	// io.EOF should be returned when input stream is completely consumed and future calls
@@ -26,8 +29,26 @@ type Transform interface {
 	RawRecord() (schemahandler.RawRecord, error)
 }
 
+// RecordWriter is an optional interface a Transform implements to write each record directly into an
+// io.Writer. The Transform returned by Schema.NewTransform implements it.
+type RecordWriter interface {
+	// WriteRecordTo is like Read, except it writes the JSON of the ingested and transformed record into
+	// w, and returns the number of bytes written, instead of returning the record. Read and WriteRecordTo
+	// can be mixed. Each call writes exactly one record; any error from w is considered fatal. Note it's
+	// not io.WriterTo: it returns io.EOF once the input stream is exhausted.
+	WriteRecordTo(w io.Writer) (int64, error)
+}
+
+// DedupReporter is an optional interface a Transform implements to report on its dedup stage, which is
+// enabled by transformctx.Ctx.Dedup. The Transform returned by Schema.NewTransform implements it.
+type DedupReporter interface {
+	// Duplicates returns the number of duplicate records dropped (or reported) so far by the dedup stage.
+	Duplicates() int
+}
+
 type transform struct {
 	ingester      schemahandler.Ingester
+	dedup         *deduper
 	lastRawRecord schemahandler.RawRecord
 	lastErr       error
 }
@@ -49,23 +70,82 @@ func (o *transform) Read() ([]byte, error) {
 	if o.lastErr != nil && !errs.IsErrTransformFailed(o.lastErr) {
 		return nil, o.lastErr
 	}
-	rawRecord, transformed, err := o.ingester.Read()
-	if err != nil {
-		if o.ingester.IsContinuableError(err) {
-			// If ingester error is continuable, wrap it into a standard generic ErrTransformFailed
-			// so caller has an easier time to deal with it. If fatal error, then leave it raw to the
-			// caller, so they can decide what it is and how to proceed.
-			err = errs.ErrTransformFailed(err.Error())
+	for {
+		rawRecord, transformed, err := o.ingester.Read()
+		if err == nil && o.dedup != nil {
+			var duplicate bool
+			duplicate, err = o.dedupRecord(rawRecord)
+			if duplicate && err == nil {
+				// silently dropped, so move on to the next record.
+				continue
+			}
 		}
-		transformed = nil
+		if err != nil {
+			if o.ingester.IsContinuableError(err) {
+				// If ingester error is continuable, wrap it into a standard generic ErrTransformFailed
+				// so caller has an easier time to deal with it. If fatal error, then leave it raw to the
+				// caller, so they can decide what it is and how to proceed.
+				err = errs.ErrTransformFailed(err.Error())
+			}
+			transformed = nil
+		}
+		o.setLast(rawRecord, err)
+		return transformed, err
+	}
+}
+
+// dedupRecord checks if a record just read is a duplicate. A duplicate is either to be dropped silently
+// (returning true and no error) or reported (returning an errs.ErrTransformFailed).
+func (o *transform) dedupRecord(rawRecord schemahandler.RawRecord) (bool, error) {
+	duplicate, err := o.dedup.isDuplicate(o.ingester, rawRecord)
+	if err != nil || !duplicate {
+		return false, err
+	}
+	if o.dedup.report {
+		return true, errs.ErrTransformFailed(o.ingester.FmtErr("duplicate record").Error())
 	}
+	return true, nil
+}
+
+func (o *transform) setLast(rawRecord schemahandler.RawRecord, err error) {
 	if err == nil {
 		o.lastRawRecord = rawRecord
 	} else {
 		o.lastRawRecord = nil
 	}
 	o.lastErr = err
-	return transformed, err
+	// the dedup stage is done once the transform is, either at the end of the input or a fatal error.
+	if o.dedup != nil && err != nil && !errs.IsErrTransformFailed(err) {
+		o.dedup.close()
+	}
+}
+
+// WriteRecordTo is like Read, except it writes the JSON of the ingested and transformed record into
+// w, and returns the number of bytes written, instead of returning the record. If the ingester of the
+// schema handler implements schemahandler.RecordWriter, the record is written without being built up
+// as a []byte first, unless the dedup stage is enabled, which has to see a record before it's written.
+func (o *transform) WriteRecordTo(w io.Writer) (int64, error) {
+	recordWriter, ok := o.ingester.(schemahandler.RecordWriter)
+	if !ok || o.dedup != nil {
+		transformed, err := o.Read()
+		if err != nil {
+			return 0, err
+		}
+		written, err := w.Write(transformed)
+		if err != nil {
+			o.setLast(nil, err)
+		}
+		return int64(written), err
+	}
+	if o.lastErr != nil && !errs.IsErrTransformFailed(o.lastErr) {
+		return 0, o.lastErr
+	}
+	rawRecord, written, err := recordWriter.WriteRecord(w)
+	if err != nil && o.ingester.IsContinuableError(err) {
+		err = errs.ErrTransformFailed(err.Error())
+	}
+	o.setLast(rawRecord, err)
+	return written, err
 }
 
 // RawRecord returns the current raw record ingested from the input stream. If the last
@@ -79,3 +159,30 @@ func (o *transform) RawRecord() (schemahandler.RawRecord, error) {
 	}
 	return o.lastRawRecord, nil
 }
+
+var errTransformClosed = errors.New("transform is closed")
+
+// Close releases the resources the transform holds, i.e. the temp files of the dedup stage, which are
+// otherwise released only once the end of the input stream or a fatal error is reached. Future calls to
+// Read return an error.
+func (o *transform) Close() error {
+	if o.lastErr == nil || errs.IsErrTransformFailed(o.lastErr) {
+		o.setLast(nil, errTransformClosed)
+	}
+	return nil
+}
+
+// Duplicates returns the number of duplicate records dropped (or reported) so far by the dedup stage,
+// which is enabled by transformctx.Ctx.Dedup.
+func (o *transform) Duplicates() int {
+	if o.dedup == nil {
+		return 0
+	}
+	return o.dedup.duplicate
+}
+
+// Ensure *transform implements Transform and its optional interfaces.
+var _ Transform = &transform{}
+var _ RecordWriter = &transform{}
+var _ DedupReporter = &transform{}
+var _ io.Closer = &transform{}
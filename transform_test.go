@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -160,3 +161,83 @@ func TestTransform_RawRecord_CalledBeforeRead(t *testing.T) {
 	assert.Equal(t, "must call Read first", err.Error())
 	assert.Nil(t, raw)
 }
+
+type testRecordWriterIngester struct {
+	testIngester
+}
+
+func (g *testRecordWriterIngester) WriteRecord(w io.Writer) (schemahandler.RawRecord, int64, error) {
+	raw, b, err := g.Read()
+	if err != nil {
+		return nil, 0, err
+	}
+	written, err := w.Write(append([]byte("written: "), b...))
+	return raw, int64(written), err
+}
+
+type testFailingWriter struct{}
+
+func (testFailingWriter) Write([]byte) (int, error) { return 0, errors.New("write failure") }
+
+func TestTransform_WriteRecordTo(t *testing.T) {
+	continuableErr := errors.New("continuable error")
+	for _, test := range []struct {
+		name     string
+		ingester func(calls []testReadCall) schemahandler.Ingester
+		prefix   string
+	}{
+		{
+			name: "ingester without RecordWriter",
+			ingester: func(calls []testReadCall) schemahandler.Ingester {
+				return &testIngester{readCalls: calls, continuableErrs: map[error]bool{continuableErr: true}}
+			},
+		},
+		{
+			name: "ingester with RecordWriter",
+			ingester: func(calls []testReadCall) schemahandler.Ingester {
+				return &testRecordWriterIngester{testIngester{
+					readCalls: calls, continuableErrs: map[error]bool{continuableErr: true}}}
+			},
+			prefix: "written: ",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tfm := &transform{ingester: test.ingester([]testReadCall{
+				{result: []byte("1st good read")},
+				{err: continuableErr},
+				{result: []byte("2nd good read")},
+				{err: io.EOF},
+			})}
+			var w strings.Builder
+			n, err := tfm.WriteRecordTo(&w)
+			assert.NoError(t, err)
+			assert.Equal(t, test.prefix+"1st good read", w.String())
+			assert.Equal(t, int64(w.Len()), n)
+			raw, err := tfm.RawRecord()
+			assert.NoError(t, err)
+			assert.Equal(t, "raw record of '1st good read'", raw.Raw())
+
+			w.Reset()
+			n, err = tfm.WriteRecordTo(&w)
+			assert.Error(t, err)
+			assert.True(t, errs.IsErrTransformFailed(err))
+			assert.Equal(t, int64(0), n)
+			assert.Equal(t, "", w.String())
+			_, err = tfm.RawRecord()
+			assert.True(t, errs.IsErrTransformFailed(err))
+
+			n, err = tfm.WriteRecordTo(testFailingWriter{})
+			assert.Error(t, err)
+			assert.Equal(t, "write failure", err.Error())
+			assert.Equal(t, int64(0), n)
+			_, err = tfm.RawRecord()
+			assert.Equal(t, "write failure", err.Error())
+
+			// a write failure is fatal.
+			n, err = tfm.WriteRecordTo(&w)
+			assert.Error(t, err)
+			assert.Equal(t, "write failure", err.Error())
+			assert.Equal(t, int64(0), n)
+		})
+	}
+}
@@ -13,6 +13,11 @@ type Ctx struct {
 	// ExternalProperties contains externally set string properties used by `external` transform
 	// in a schema.
 	ExternalProperties map[string]string
+	// ExternalValues contains externally set properties of arbitrary JSON types, such as numbers, booleans,
+	// arrays (`[]interface{}`) and objects (`map[string]interface{}`), used by `external` transform in a
+	// schema. If a property name exists in both ExternalProperties and ExternalValues, the one in
+	// ExternalValues takes precedence.
+	ExternalValues map[string]interface{}
 	// CtxAwareErr allows context aware error formatting such as adding input (file) name
 	// and line number as a prefix to the error string. Most of the time there is no need for caller
 	// of NewTransform to set it, it will be auto-set by omniparser.
@@ -21,10 +26,61 @@ type Ctx struct {
 	// param will be passed along with the Ctx object throughout all the stages and operations of
 	// a transform, including passing to all the `custom_func` and `custom_parse`.
 	CustomParam interface{}
+	// Dedup, if not nil, enables the dedup stage of the Transform, which drops the records that are
+	// duplicates of the ones read earlier from the same input stream.
+	Dedup *DedupSettings
 }
 
-// External looks up, and returns an external property value, if exists.
+// DedupKey tells what identifies duplicate records.
+type DedupKey string
+
+const (
+	// DedupByChecksum identifies duplicate records by the checksum of their raw records, i.e. records are
+	// duplicates if they're identical in the input.
+	DedupByChecksum DedupKey = "checksum"
+	// DedupBySchemaKey identifies duplicate records by the key computed by the schema, e.g. the
+	// `DEDUP_KEY` decl of an 'omni.2.1' schema. Records with an empty key are never considered duplicates.
+	DedupBySchemaKey DedupKey = "schema_key"
+)
+
+// DefaultDedupCapacity is the number of record keys the dedup stage keeps in memory by default.
+const DefaultDedupCapacity = 100000
+
+// DedupSettings configures the dedup stage of a Transform.
+type DedupSettings struct {
+	// Key tells what identifies duplicate records, DedupByChecksum if empty.
+	Key DedupKey
+	// Capacity is the max number of record keys kept in memory, DefaultDedupCapacity if 0. Once
+	// reached, the least recently seen keys are forgotten, and the duplicates of their records are no
+	// longer detected, unless SpillDir is set.
+	Capacity int
+	// SpillDir, if not empty, is the directory where the record keys are spilled into temp files when
+	// Capacity is reached, so that all the duplicates are detected, at the cost of disk I/O. The temp
+	// files are removed once the Transform reaches the end of the input stream or a fatal error, or is
+	// closed.
+	SpillDir string
+	// ReportDuplicates makes the Transform return an errs.ErrTransformFailed for each duplicate record,
+	// instead of silently dropping it.
+	ReportDuplicates bool
+}
+
+// External looks up, and returns an external property value, if exists and is a string.
 func (ctx *Ctx) External(name string) (string, bool) {
-	v, found := ctx.ExternalProperties[name]
-	return v, found
+	v, found := ctx.ExternalValue(name)
+	if !found {
+		return "", false
+	}
+	s, ok := v.(string)
+	return s, ok
+}
+
+// ExternalValue looks up, and returns an external property value of any type, if exists.
+func (ctx *Ctx) ExternalValue(name string) (interface{}, bool) {
+	if v, found := ctx.ExternalValues[name]; found {
+		return v, true
+	}
+	if v, found := ctx.ExternalProperties[name]; found {
+		return v, true
+	}
+	return nil, false
 }
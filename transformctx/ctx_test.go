@@ -50,3 +50,68 @@ func TestCtx_External(t *testing.T) {
 		})
 	}
 }
+
+func TestCtx_ExternalValue(t *testing.T) {
+	ctx := &Ctx{
+		ExternalProperties: map[string]string{"a": "str_a", "b": "str_b"},
+		ExternalValues:     map[string]interface{}{"b": 2.5, "c": "str_c", "d": []interface{}{"x"}},
+	}
+	for _, test := range []struct {
+		name                string
+		lookup              string
+		expectedValue       interface{}
+		expectedFound       bool
+		expectedString      string
+		expectedStringFound bool
+	}{
+		{
+			name:                "only in properties",
+			lookup:              "a",
+			expectedValue:       "str_a",
+			expectedFound:       true,
+			expectedString:      "str_a",
+			expectedStringFound: true,
+		},
+		{
+			name:                "values take precedence",
+			lookup:              "b",
+			expectedValue:       2.5,
+			expectedFound:       true,
+			expectedString:      "",
+			expectedStringFound: false,
+		},
+		{
+			name:                "string in values",
+			lookup:              "c",
+			expectedValue:       "str_c",
+			expectedFound:       true,
+			expectedString:      "str_c",
+			expectedStringFound: true,
+		},
+		{
+			name:                "array in values",
+			lookup:              "d",
+			expectedValue:       []interface{}{"x"},
+			expectedFound:       true,
+			expectedString:      "",
+			expectedStringFound: false,
+		},
+		{
+			name:                "not found",
+			lookup:              "e",
+			expectedValue:       nil,
+			expectedFound:       false,
+			expectedString:      "",
+			expectedStringFound: false,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			v, found := ctx.ExternalValue(test.lookup)
+			assert.Equal(t, test.expectedValue, v)
+			assert.Equal(t, test.expectedFound, found)
+			s, found := ctx.External(test.lookup)
+			assert.Equal(t, test.expectedString, s)
+			assert.Equal(t, test.expectedStringFound, found)
+		})
+	}
+}